@@ -1,6 +1,20 @@
 # Directories.
 SCRIPTS_DIR := hack
 
+CONTROLLER_GEN_VERSION := v0.18.0
+CONTROLLER_GEN := go run sigs.k8s.io/controller-tools/cmd/controller-gen@$(CONTROLLER_GEN_VERSION)
+
 sync-chart-crd:
 	@echo "$(GEN_COLOR)Sync Chart CRD with apiextensions-application$(NO_COLOR)"
 	cd $(SCRIPTS_DIR); ./sync-chart-crd.sh
+
+##@ Generate
+
+.PHONY: generate
+generate: ## Generate the deepcopy functions and the CRD of the AppRollout API.
+	@echo "$(GEN_COLOR)Generating deepcopy functions and CRDs$(NO_COLOR)"
+	$(CONTROLLER_GEN) object paths=./pkg/apis/... crd:crdVersions=v1 output:crd:artifacts:config=helm/app-operator/files
+
+.PHONY: verify-generate
+verify-generate: generate ## Fail when the generated files are out of date.
+	@test -z "$$(git status --porcelain -- pkg/apis helm/app-operator/files)" || (git status --porcelain -- pkg/apis helm/app-operator/files && echo "generated files are out of date, run make generate" && exit 1)
@@ -5,4 +5,17 @@ type App struct {
 	WatchNamespace               string
 	WorkloadClusterID            string
 	DependencyWaitTimeoutMinutes string
+	ValuesSchemaValidation       string
+	ImmutableValues              string
+	ValuesRetention              string
+	ValuesCompression            string
+	MaintenanceWindowSchedule    string
+	MaintenanceWindowDuration    string
+	MaintenanceWindowTimezone    string
+	DriftReconcile               string
+	ValuesWatchMode              string
+	ValuesTriggerDebounce        string
+	ValuesTriggerRateLimit       string
+	ValuesTriggerBurst           string
+	MultiClusterChartStatus      string
 }
@@ -0,0 +1,7 @@
+package debug
+
+// Debug is a data structure to hold debugging specific configuration.
+type Debug struct {
+	// Values enables the endpoint rendering the merged values of app CRs.
+	Values string
+}
@@ -0,0 +1,8 @@
+package clientcache
+
+// ClientCache configures the cache of workload cluster clients.
+type ClientCache struct {
+	CircuitBreakerCooldown string
+	FailureThreshold       string
+	MaxSize                string
+}
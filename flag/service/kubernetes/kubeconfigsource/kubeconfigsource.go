@@ -0,0 +1,11 @@
+package kubeconfigsource
+
+// KubeConfigSource configures how workload clusters are reached.
+type KubeConfigSource struct {
+	Default           string
+	ExecArgs          string
+	ExecCommand       string
+	File              string
+	TokenFile         string
+	TrustedNamespaces string
+}
@@ -3,16 +3,21 @@ package kubernetes
 import (
 	"github.com/giantswarm/operatorkit/v7/pkg/flag/service/kubernetes/tls"
 	"github.com/giantswarm/operatorkit/v7/pkg/flag/service/kubernetes/watch"
+
+	"github.com/giantswarm/app-operator/v7/flag/service/kubernetes/clientcache"
+	"github.com/giantswarm/app-operator/v7/flag/service/kubernetes/kubeconfigsource"
 )
 
 // Kubernetes is a data structure to hold Kubernetes specific command line
 // configuration flags.
 type Kubernetes struct {
 	Address            string
+	ClientCache        clientcache.ClientCache
 	DisableClientCache string
 	InCluster          string
 	KubeConfig         string
 	KubeConfigPath     string
+	KubeConfigSource   kubeconfigsource.KubeConfigSource
 	TLS                tls.TLS
 	Watch              watch.Watch
 }
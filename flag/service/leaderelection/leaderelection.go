@@ -0,0 +1,10 @@
+package leaderelection
+
+// LeaderElection configures the election of the replica of an app-operator
+// instance running its controllers and watchers.
+type LeaderElection struct {
+	Enabled       string
+	LeaseDuration string
+	RenewDeadline string
+	RetryPeriod   string
+}
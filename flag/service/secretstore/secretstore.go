@@ -0,0 +1,22 @@
+package secretstore
+
+// SecretStore holds the configuration of the external secret stores values
+// of app CRs can be resolved from.
+type SecretStore struct {
+	File            File
+	RefreshInterval string
+	TTL             string
+	Vault           Vault
+}
+
+// File configures the provider reading YAML files, e.g. for local testing.
+type File struct {
+	Root string
+}
+
+// Vault configures the provider reading secrets from Vault.
+type Vault struct {
+	Address    string
+	PathPrefix string
+	TokenFile  string
+}
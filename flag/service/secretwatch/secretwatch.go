@@ -0,0 +1,8 @@
+package secretwatch
+
+// SecretWatch holds the allow-list of namespaces secrets app CRs source
+// values from may be watched in besides the namespace of app-operator.
+type SecretWatch struct {
+	Namespaces        string
+	NamespaceSelector string
+}
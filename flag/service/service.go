@@ -4,21 +4,36 @@ import (
 	"github.com/giantswarm/app-operator/v7/flag/service/app"
 	"github.com/giantswarm/app-operator/v7/flag/service/appcatalog"
 	"github.com/giantswarm/app-operator/v7/flag/service/chart"
+	"github.com/giantswarm/app-operator/v7/flag/service/debug"
 	"github.com/giantswarm/app-operator/v7/flag/service/helm"
 	"github.com/giantswarm/app-operator/v7/flag/service/image"
 	"github.com/giantswarm/app-operator/v7/flag/service/kubernetes"
+	"github.com/giantswarm/app-operator/v7/flag/service/leaderelection"
 	"github.com/giantswarm/app-operator/v7/flag/service/operatorkit"
 	"github.com/giantswarm/app-operator/v7/flag/service/provider"
+	"github.com/giantswarm/app-operator/v7/flag/service/secretstore"
+	"github.com/giantswarm/app-operator/v7/flag/service/secretwatch"
+	"github.com/giantswarm/app-operator/v7/flag/service/shard"
+	"github.com/giantswarm/app-operator/v7/flag/service/valueref"
+	"github.com/giantswarm/app-operator/v7/flag/service/webhook"
 )
 
 // Service is an intermediate data structure for command line configuration flags.
 type Service struct {
-	App         app.App
-	AppCatalog  appcatalog.AppCatalog
-	Chart       chart.Chart
-	Helm        helm.Helm
-	Image       image.Image
-	Kubernetes  kubernetes.Kubernetes
-	Operatorkit operatorkit.Operatorkit
-	Provider    provider.Provider
+	App             app.App
+	AppCatalog      appcatalog.AppCatalog
+	Chart           chart.Chart
+	Debug           debug.Debug
+	Helm            helm.Helm
+	Image           image.Image
+	Kubernetes      kubernetes.Kubernetes
+	LeaderElection  leaderelection.LeaderElection
+	Operatorkit     operatorkit.Operatorkit
+	Provider        provider.Provider
+	SecretStore     secretstore.SecretStore
+	SecretWatch     secretwatch.SecretWatch
+	Shard           shard.Shard
+	ShutdownTimeout string
+	ValueRef        valueref.ValueRef
+	Webhook         webhook.Webhook
 }
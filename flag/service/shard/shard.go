@@ -0,0 +1,8 @@
+package shard
+
+// Shard configures sharding app CRs across the replicas of an app-operator
+// instance.
+type Shard struct {
+	Enabled       string
+	LeaseDuration string
+}
@@ -0,0 +1,8 @@
+package valueref
+
+// ValueRef holds the allow-list of namespaces objects and app CRs
+// referenced in the values-refs annotation may be read from besides the
+// namespace of the app CR.
+type ValueRef struct {
+	Namespaces string
+}
@@ -0,0 +1,16 @@
+package webhook
+
+// Webhook is a data structure to hold admission webhook specific
+// configuration.
+type Webhook struct {
+	Enabled       string
+	ListenAddress string
+	TLS           TLS
+}
+
+// TLS holds the paths of the certificate and key mounted from the webhook
+// TLS secret.
+type TLS struct {
+	CrtFile string
+	KeyFile string
+}
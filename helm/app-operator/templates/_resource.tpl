@@ -63,3 +63,33 @@ limits:
 {{- end -}}
 {{- end -}}
 {{- end -}}
+
+{{- define "resource.webhook.name" -}}
+{{- include "resource.default.name" . -}}-webhook
+{{- end -}}
+
+{{/*
+The webhook certificate is read from the given secret or from the secret
+created by cert-manager.
+*/}}
+{{- define "resource.webhook.certSecretName" -}}
+{{- if .Values.webhook.certSecretName }}{{ .Values.webhook.certSecretName }}{{ else }}{{ include "resource.webhook.name" . }}-certs{{ end }}
+{{- end -}}
+
+{{/*
+The app webhooks only admit the app CRs reconciled by this instance. This
+matches the label selectors of the app controller.
+*/}}
+{{- define "resource.webhook.appObjectSelector" -}}
+{{- if .Values.app.workloadClusterID -}}
+matchLabels:
+  giantswarm.io/cluster: {{ .Values.app.workloadClusterID | quote }}
+matchExpressions:
+- key: app-operator.giantswarm.io/version
+  operator: NotIn
+  values: ["0.0.0"]
+{{- else -}}
+matchLabels:
+  app-operator.giantswarm.io/version: {{ include "resource.app.version" . | quote }}
+{{- end -}}
+{{- end -}}
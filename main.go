@@ -3,27 +3,37 @@ package main
 import (
 	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 
 	applicationv1alpha1 "github.com/giantswarm/apiextensions-application/api/v1alpha1"
 	"github.com/giantswarm/k8sclient/v7/pkg/k8sclient"
 	"github.com/giantswarm/k8sclient/v7/pkg/k8srestconfig"
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/microkit/command"
+	daemonflag "github.com/giantswarm/microkit/command/daemon/flag"
+	microflag "github.com/giantswarm/microkit/flag"
 	microserver "github.com/giantswarm/microkit/server"
 	"github.com/giantswarm/micrologger"
 	prometheusMonitoringV1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"k8s.io/client-go/rest"
 	capiv1beta1 "sigs.k8s.io/cluster-api/api/v1beta1"
 
 	"github.com/giantswarm/app-operator/v7/flag"
+	rolloutv1alpha1 "github.com/giantswarm/app-operator/v7/pkg/apis/rollout/v1alpha1"
 	"github.com/giantswarm/app-operator/v7/pkg/project"
 	"github.com/giantswarm/app-operator/v7/server"
+	"github.com/giantswarm/app-operator/v7/server/webhook"
 	"github.com/giantswarm/app-operator/v7/service"
 )
 
 var (
-	f = flag.New()
+	daemonFlag = daemonflag.New()
+	f          = flag.New()
 )
 
 func main() {
@@ -79,6 +89,7 @@ func mainWithError() (err error) {
 					prometheusMonitoringV1.AddToScheme,
 					applicationv1alpha1.AddToScheme,
 					capiv1beta1.AddToScheme,
+					rolloutv1alpha1.AddToScheme,
 				},
 
 				RestConfig: restConfig,
@@ -107,6 +118,26 @@ func mainWithError() (err error) {
 			go newService.Boot(ctx)
 		}
 
+		// The admission webhook is served on its own TLS listener since the
+		// microkit server only serves plain HTTP.
+		if v.GetBool(f.Service.Webhook.Enabled) {
+			c := webhook.Config{
+				K8sClient: k8sClient,
+				Logger:    newLogger,
+
+				CrtFile:       v.GetString(f.Service.Webhook.TLS.CrtFile),
+				KeyFile:       v.GetString(f.Service.Webhook.TLS.KeyFile),
+				ListenAddress: v.GetString(f.Service.Webhook.ListenAddress),
+				Provider:      v.GetString(f.Service.Provider.Kind),
+			}
+			newWebhook, err := webhook.New(c)
+			if err != nil {
+				panic(fmt.Sprintf("%#v\n", microerror.Mask(err)))
+			}
+
+			go newWebhook.Boot(ctx)
+		}
+
 		// New custom server that bundles microkit endpoints.
 		var newServer microserver.Server
 		{
@@ -114,7 +145,8 @@ func mainWithError() (err error) {
 				Logger:  newLogger,
 				Service: newService,
 
-				Viper: v,
+				ShutdownTimeout: v.GetDuration(f.Service.ShutdownTimeout),
+				Viper:           v,
 			}
 
 			newServer, err = server.New(c)
@@ -127,6 +159,8 @@ func mainWithError() (err error) {
 	}
 
 	// Create a new microkit command that manages operator daemon.
+	v := viper.New()
+
 	var newCommand command.Command
 	{
 		c := command.Config{
@@ -138,6 +172,7 @@ func mainWithError() (err error) {
 			Name:        project.Name(),
 			Source:      project.Source(),
 			Version:     project.Version(),
+			Viper:       v,
 		}
 
 		newCommand, err = command.New(c)
@@ -148,24 +183,74 @@ func mainWithError() (err error) {
 
 	daemonCommand := newCommand.DaemonCommand().CobraCommand()
 
+	// The microkit daemon exits 3 seconds after SIGINT and SIGTERM without
+	// shutting down the custom server, so the daemon is run here to exit
+	// only once the controllers and watchers were drained.
+	daemonCommand.Run = func(cmd *cobra.Command, args []string) {
+		runDaemon(cmd, v, newServerFactory)
+	}
+
 	daemonCommand.PersistentFlags().Bool(f.Service.App.Unique, false, "Whether the operator is deployed as a unique app.")
 	daemonCommand.PersistentFlags().String(f.Service.App.WatchNamespace, "", "Namespace to watch for app CRs.")
 	daemonCommand.PersistentFlags().String(f.Service.App.WorkloadClusterID, "", "Workload cluster ID for app CR label selector.")
 	daemonCommand.PersistentFlags().Int(f.Service.App.DependencyWaitTimeoutMinutes, 30, "Timeout in seconds after which to ignore dependencies and make app installation to move on.")
+	daemonCommand.PersistentFlags().Bool(f.Service.App.ValuesSchemaValidation, true, "Whether to validate merged values against the values schema of the chart.")
+	daemonCommand.PersistentFlags().Bool(f.Service.App.ImmutableValues, false, "Whether to generate immutable configmaps and secrets named after the hash of the values instead of updating them in place.")
+	daemonCommand.PersistentFlags().Int(f.Service.App.ValuesRetention, 3, "The number of immutable values generations kept per app for rollbacks.")
+	daemonCommand.PersistentFlags().Bool(f.Service.App.ValuesCompression, false, "Whether to gzip and base64 encode merged values approaching the size limit of configmaps and secrets.")
+	daemonCommand.PersistentFlags().String(f.Service.App.MaintenanceWindowSchedule, "", "Cron schedule of the default maintenance window updates of app CRs are deferred to. When empty updates are applied immediately.")
+	daemonCommand.PersistentFlags().String(f.Service.App.MaintenanceWindowDuration, "", "Duration of the default maintenance window, e.g. 2h.")
+	daemonCommand.PersistentFlags().String(f.Service.App.MaintenanceWindowTimezone, "UTC", "IANA timezone of the default maintenance window schedule.")
+	daemonCommand.PersistentFlags().Bool(f.Service.App.DriftReconcile, false, "Whether to reconcile app CRs immediately when their chart CR was changed in the workload cluster.")
+	daemonCommand.PersistentFlags().String(f.Service.App.ValuesWatchMode, "label", "How configmaps and secrets app CRs source values from are watched. Either label to label them or index to watch their namespaces without modifying them.")
+	daemonCommand.PersistentFlags().String(f.Service.App.ValuesTriggerDebounce, "5s", "Window in which changes of resources app CRs source values from are coalesced into one update of the app CR.")
+	daemonCommand.PersistentFlags().Float64(f.Service.App.ValuesTriggerRateLimit, 10, "Maximum number of app CR updates per second caused by changes of resources their values are sourced from. Zero disables the limit.")
+	daemonCommand.PersistentFlags().Int(f.Service.App.ValuesTriggerBurst, 20, "Burst of app CR updates caused by changes of resources their values are sourced from.")
+	daemonCommand.PersistentFlags().Bool(f.Service.App.MultiClusterChartStatus, false, "Whether to watch the chart CRs of all clusters app CRs are installed in.")
 	daemonCommand.PersistentFlags().Int(f.Service.AppCatalog.MaxEntriesPerApp, 5, "The maximum number of appCatalogEntries per app.")
 	daemonCommand.PersistentFlags().String(f.Service.Chart.Namespace, "giantswarm", "The namespace where chart CRs are located.")
+	daemonCommand.PersistentFlags().Bool(f.Service.Debug.Values, false, "Whether to serve the endpoint rendering the merged values of app CRs.")
 	daemonCommand.PersistentFlags().String(f.Service.Helm.HTTP.ClientTimeout, "5s", "HTTP timeout for pulling chart tarballs.")
 	daemonCommand.PersistentFlags().String(f.Service.Image.Registry, "gsoci.azurecr.io", "The container registry for pulling Tiller images.")
 	daemonCommand.PersistentFlags().String(f.Service.Kubernetes.Address, "", "Address used to connect to Kubernetes. When empty in-cluster config is created.")
+	daemonCommand.PersistentFlags().String(f.Service.Kubernetes.ClientCache.CircuitBreakerCooldown, "1m", "Time no clients are created for a workload cluster after its API was not available for the failure threshold.")
+	daemonCommand.PersistentFlags().Int(f.Service.Kubernetes.ClientCache.FailureThreshold, 5, "Number of consecutive requests failing because the workload cluster API is not available after which its clients are evicted.")
+	daemonCommand.PersistentFlags().Int(f.Service.Kubernetes.ClientCache.MaxSize, 100, "Maximum number of workload clusters clients are cached for.")
 	daemonCommand.PersistentFlags().Bool(f.Service.Kubernetes.DisableClientCache, false, "Disable Kubernetes client cache.")
 	daemonCommand.PersistentFlags().Bool(f.Service.Kubernetes.InCluster, true, "Whether to use the in-cluster config to authenticate with Kubernetes.")
 	daemonCommand.PersistentFlags().String(f.Service.Kubernetes.KubeConfig, "", "KubeConfig used to connect to Kubernetes. When empty other settings are used.")
+	daemonCommand.PersistentFlags().String(f.Service.Kubernetes.KubeConfigSource.Default, "secret", "Kubeconfig source of app CRs without the kubeconfig source annotation. One of secret, capi, exec or file.")
+	daemonCommand.PersistentFlags().StringSlice(f.Service.Kubernetes.KubeConfigSource.ExecArgs, []string{}, "Arguments of the exec credential plugin of the exec kubeconfig source.")
+	daemonCommand.PersistentFlags().String(f.Service.Kubernetes.KubeConfigSource.ExecCommand, "", "Exec credential plugin exchanging the projected service account token for the exec kubeconfig source. When empty the token is used as it is.")
+	daemonCommand.PersistentFlags().String(f.Service.Kubernetes.KubeConfigSource.File, "", "Kubeconfig file of the file kubeconfig source. Its contexts are named after the clusters.")
+	daemonCommand.PersistentFlags().String(f.Service.Kubernetes.KubeConfigSource.TokenFile, "", "Projected service account token file of the exec kubeconfig source.")
+	daemonCommand.PersistentFlags().StringSlice(f.Service.Kubernetes.KubeConfigSource.TrustedNamespaces, []string{}, "Namespaces whose app CRs may use the exec and file kubeconfig sources, selected with the kubeconfig source annotation or as the default kubeconfig source.")
 	daemonCommand.PersistentFlags().String(f.Service.Kubernetes.TLS.CAFile, "", "Certificate authority file path to use to authenticate with Kubernetes.")
 	daemonCommand.PersistentFlags().String(f.Service.Kubernetes.TLS.CrtFile, "", "Certificate file path to use to authenticate with Kubernetes.")
 	daemonCommand.PersistentFlags().String(f.Service.Kubernetes.TLS.KeyFile, "", "Key file path to use to authenticate with Kubernetes.")
 	daemonCommand.PersistentFlags().String(f.Service.Kubernetes.Watch.Namespace, "default", "The namespace where appcatalog and app CRs are located.")
+	daemonCommand.PersistentFlags().Bool(f.Service.LeaderElection.Enabled, false, "Whether to run the controllers and watchers only in the replica elected as leader.")
+	daemonCommand.PersistentFlags().String(f.Service.LeaderElection.LeaseDuration, "15s", "Duration followers wait before taking over a leader lease which was not renewed.")
+	daemonCommand.PersistentFlags().String(f.Service.LeaderElection.RenewDeadline, "10s", "Duration after which the leader gives up leadership when it could not renew its lease.")
+	daemonCommand.PersistentFlags().String(f.Service.LeaderElection.RetryPeriod, "2s", "Interval in which the leader lease is renewed or acquired.")
 	daemonCommand.PersistentFlags().String(f.Service.Operatorkit.ResyncPeriod, "5m", "Resync period after which a complete resync of all runtime objects is performed.")
 	daemonCommand.PersistentFlags().String(f.Service.Provider.Kind, "", "Provider of the management cluster. One of aws, azure, kvm.")
+	daemonCommand.PersistentFlags().String(f.Service.SecretStore.File.Root, "", "Directory of the file secret store provider. When empty the provider is disabled.")
+	daemonCommand.PersistentFlags().String(f.Service.SecretStore.RefreshInterval, "1m", "Interval after which values of secret store references are resolved again to update apps.")
+	daemonCommand.PersistentFlags().String(f.Service.SecretStore.TTL, "5m", "Duration values resolved from secret stores are cached.")
+	daemonCommand.PersistentFlags().String(f.Service.SecretStore.Vault.Address, "", "Address of the Vault secret store provider. When empty the provider is disabled.")
+	daemonCommand.PersistentFlags().String(f.Service.SecretStore.Vault.PathPrefix, "secret/data", "Prefix of the Vault paths secret store references may read. Paths must be below the prefix followed by the namespace of the app CR.")
+	daemonCommand.PersistentFlags().String(f.Service.SecretStore.Vault.TokenFile, "/var/run/secrets/vault/token", "Token file path used to authenticate with Vault.")
+	daemonCommand.PersistentFlags().StringSlice(f.Service.SecretWatch.Namespaces, []string{}, "Namespaces besides the namespace of app-operator secrets app CRs source values from are watched in.")
+	daemonCommand.PersistentFlags().String(f.Service.SecretWatch.NamespaceSelector, "", "Label selector of namespaces secrets app CRs source values from are watched in.")
+	daemonCommand.PersistentFlags().Bool(f.Service.Shard.Enabled, false, "Whether to shard app CRs across the replicas of app-operator.")
+	daemonCommand.PersistentFlags().String(f.Service.Shard.LeaseDuration, "15s", "Duration after which a replica which did not renew its lease leaves the shard group.")
+	daemonCommand.PersistentFlags().String(f.Service.ShutdownTimeout, "20s", "Time in-flight reconciliations are drained for on SIGINT and SIGTERM before the daemon exits.")
+	daemonCommand.PersistentFlags().StringSlice(f.Service.ValueRef.Namespaces, []string{}, "Namespaces besides the namespace of the app CR objects and app CRs referenced in the values-refs annotation may be read from.")
+	daemonCommand.PersistentFlags().Bool(f.Service.Webhook.Enabled, false, "Whether to serve the admission webhook for app and catalog CRs.")
+	daemonCommand.PersistentFlags().String(f.Service.Webhook.ListenAddress, ":8443", "Address the admission webhook listens on.")
+	daemonCommand.PersistentFlags().String(f.Service.Webhook.TLS.CrtFile, "/etc/webhook/certs/tls.crt", "Certificate file path of the admission webhook.")
+	daemonCommand.PersistentFlags().String(f.Service.Webhook.TLS.KeyFile, "/etc/webhook/certs/tls.key", "Key file path of the admission webhook.")
 
 	err = newCommand.CobraCommand().Execute()
 	if err != nil {
@@ -174,3 +259,68 @@ func mainWithError() (err error) {
 
 	return nil
 }
+
+// runDaemon runs the daemon like the microkit daemon command. On SIGINT and
+// SIGTERM the microkit server and the custom server are shut down
+// concurrently and the process exits once both returned. A second signal
+// exits immediately.
+func runDaemon(cmd *cobra.Command, v *viper.Viper, newServerFactory func(v *viper.Viper) microserver.Server) {
+	// The flags given via command line are parsed first so the locations of
+	// configuration directories and files are known before they are merged.
+	microflag.Parse(v, cmd.Flags())
+
+	err := microflag.Merge(v, cmd.Flags(), v.GetStringSlice(daemonFlag.Config.Dirs), v.GetStringSlice(daemonFlag.Config.Files))
+	if err != nil {
+		panic(err)
+	}
+
+	customServer := newServerFactory(v)
+
+	var newServer microserver.Server
+	{
+		c := customServer.Config()
+
+		c.EnableDebugServer = v.GetBool(daemonFlag.Server.Enable.Debug.Server)
+		c.LogAccess = v.GetBool(daemonFlag.Server.Log.Access)
+		if c.ListenAddress == "" {
+			c.ListenAddress = v.GetString(daemonFlag.Server.Listen.Address)
+		}
+		if c.ListenMetricsAddress == "" {
+			c.ListenMetricsAddress = v.GetString(daemonFlag.Server.Listen.MetricsAddress)
+		}
+		if c.TLSCAFile == "" {
+			c.TLSCAFile = v.GetString(daemonFlag.Server.TLS.CaFile)
+		}
+		if c.TLSCrtFile == "" {
+			c.TLSCrtFile = v.GetString(daemonFlag.Server.TLS.CrtFile)
+		}
+		if c.TLSKeyFile == "" {
+			c.TLSKeyFile = v.GetString(daemonFlag.Server.TLS.KeyFile)
+		}
+
+		newServer, err = microserver.New(c)
+		if err != nil {
+			panic(err)
+		}
+
+		go newServer.Boot()
+	}
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	<-signals
+
+	go func() {
+		var wg sync.WaitGroup
+		wg.Go(newServer.Shutdown)
+		wg.Go(customServer.Shutdown)
+		wg.Wait()
+
+		os.Exit(0)
+	}()
+
+	<-signals
+
+	os.Exit(0)
+}
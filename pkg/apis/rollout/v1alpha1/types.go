@@ -0,0 +1,102 @@
+// Package v1alpha1 contains the AppRollout API. An AppRollout rolls a new
+// version out to the app CRs selected by its label selector in waves. It is
+// reconciled by the unique app-operator instance in the management cluster.
+//
+// The deepcopy functions and the CRD are generated with make generate.
+//
+// +kubebuilder:object:generate=true
+// +groupName=application.giantswarm.io
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// PhaseProgressing is set while waves are rolled out.
+	PhaseProgressing = "Progressing"
+	// PhaseCompleted is set when all selected app CRs are deployed with the
+	// version of the rollout.
+	PhaseCompleted = "Completed"
+	// PhaseHalted is set when more app CRs failed than tolerated. Halted
+	// rollouts are resumed by changing their spec.
+	PhaseHalted = "Halted"
+)
+
+// AppRollout rolls a version out to app CRs selected by labels in waves.
+//
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster,categories=common;giantswarm
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.spec.version`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Wave",type=integer,JSONPath=`.status.wave`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type AppRollout struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AppRolloutSpec   `json:"spec"`
+	Status AppRolloutStatus `json:"status,omitempty"`
+}
+
+type AppRolloutSpec struct {
+	// Selector selects the app CRs in all namespaces of the management
+	// cluster the version is rolled out to.
+	Selector metav1.LabelSelector `json:"selector"`
+	// Version is set in spec.version of the selected app CRs.
+	// +kubebuilder:validation:MinLength=1
+	Version string `json:"version"`
+	// Waves are rolled out in order. Each wave updates the number of app
+	// CRs given by its count or percentage. App CRs not covered by the waves
+	// are updated in a final wave.
+	Waves []AppRolloutWave `json:"waves,omitempty"`
+	// MaxFailures is the number of app CRs which may fail before the
+	// rollout is halted.
+	// +kubebuilder:validation:Minimum=0
+	MaxFailures int `json:"maxFailures,omitempty"`
+	// Timeout is the time an app CR has to be deployed after its version was
+	// updated. App CRs exceeding it count as failed. Defaults to 30m.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+type AppRolloutWave struct {
+	// Count is the number of app CRs updated in the wave.
+	// +kubebuilder:validation:Minimum=0
+	Count int `json:"count,omitempty"`
+	// Percentage is the percentage of selected app CRs updated in the wave.
+	// It is rounded up. Count takes precedence when both are set.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	Percentage int `json:"percentage,omitempty"`
+}
+
+type AppRolloutStatus struct {
+	// ObservedGeneration is the generation of the spec the status refers
+	// to. The rollout is restarted when the spec changes.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Phase is one of Progressing, Completed or Halted.
+	Phase string `json:"phase,omitempty"`
+	// Reason describes the phase.
+	Reason string `json:"reason,omitempty"`
+	// Wave is the number of waves started so far.
+	Wave int `json:"wave,omitempty"`
+	// WaveStarted is the time the current wave was started.
+	WaveStarted *metav1.Time `json:"waveStarted,omitempty"`
+	// Updated lists the app CRs updated by the rollout as namespace/name.
+	Updated []string `json:"updated,omitempty"`
+	// Deployed lists the updated app CRs deployed with the version.
+	Deployed []string `json:"deployed,omitempty"`
+	// Failed lists the updated app CRs which failed.
+	Failed []string `json:"failed,omitempty"`
+}
+
+// AppRolloutList is a list of AppRollouts.
+//
+// +kubebuilder:object:root=true
+type AppRolloutList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []AppRollout `json:"items"`
+}
@@ -0,0 +1,55 @@
+// Package chartdrift detects chart CRs whose spec was changed in the
+// workload cluster by something other than app-operator, e.g. a manual edit.
+//
+// app-operator records the hash of the spec it applies in SpecHashAnnotation
+// of the chart CR. A chart CR has drifted when the hash of its spec no longer
+// matches the recorded one.
+package chartdrift
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+)
+
+const (
+	// SpecHashAnnotation is the hash of the chart CR spec last applied by
+	// app-operator.
+	SpecHashAnnotation = "app-operator.giantswarm.io/spec-hash"
+	// LatestDriftAnnotation is set in the app CR to the resource version of
+	// the drifted chart CR to trigger a reconciliation reverting the drift.
+	LatestDriftAnnotation = "app-operator.giantswarm.io/latest-chart-drift"
+)
+
+// Hash returns the hash of the chart CR spec.
+func Hash(spec v1alpha1.ChartSpec) string {
+	// The spec only contains types which can be marshalled so the error
+	// is always nil.
+	b, _ := json.Marshal(spec)
+	sum := sha256.Sum256(b)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// SetHash records the hash of the spec in the annotations of the chart CR.
+func SetHash(chart *v1alpha1.Chart) {
+	if chart.Annotations == nil {
+		chart.Annotations = map[string]string{}
+	}
+
+	chart.Annotations[SpecHashAnnotation] = Hash(chart.Spec)
+}
+
+// IsDrifted returns whether the spec of the chart CR differs from the spec
+// last applied by app-operator. Chart CRs without a recorded hash have not
+// been updated since drift detection was added and are not drifted.
+func IsDrifted(chart v1alpha1.Chart) bool {
+	hash, ok := chart.Annotations[SpecHashAnnotation]
+	if !ok {
+		return false
+	}
+
+	return hash != Hash(chart.Spec)
+}
@@ -0,0 +1,88 @@
+package chartdrift
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_IsDrifted(t *testing.T) {
+	applied := v1alpha1.Chart{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-cool-prometheus",
+			Namespace: "giantswarm",
+		},
+		Spec: v1alpha1.ChartSpec{
+			Name:       "my-cool-prometheus",
+			Namespace:  "monitoring",
+			TarballURL: "https://giantswarm.github.io/app-catalog/prometheus-1.0.0.tgz",
+			Version:    "1.0.0",
+		},
+	}
+	SetHash(&applied)
+
+	tests := []struct {
+		name     string
+		chart    func() v1alpha1.Chart
+		expected bool
+	}{
+		{
+			name: "case 0: chart as applied",
+			chart: func() v1alpha1.Chart {
+				return *applied.DeepCopy()
+			},
+			expected: false,
+		},
+		{
+			name: "case 1: version edited",
+			chart: func() v1alpha1.Chart {
+				chart := applied.DeepCopy()
+				chart.Spec.Version = "1.0.1"
+				return *chart
+			},
+			expected: true,
+		},
+		{
+			name: "case 2: values configmap edited",
+			chart: func() v1alpha1.Chart {
+				chart := applied.DeepCopy()
+				chart.Spec.Config.ConfigMap.Name = "other-values"
+				return *chart
+			},
+			expected: true,
+		},
+		{
+			name: "case 3: status changes are not drift",
+			chart: func() v1alpha1.Chart {
+				chart := applied.DeepCopy()
+				chart.Status.Release.Status = "deployed"
+				chart.Annotations["chart-operator.giantswarm.io/values-md5-checksum"] = "1678b4446ba0392da6681840add3d06a"
+				return *chart
+			},
+			expected: false,
+		},
+		{
+			name: "case 4: chart without hash",
+			chart: func() v1alpha1.Chart {
+				chart := applied.DeepCopy()
+				delete(chart.Annotations, SpecHashAnnotation)
+				chart.Spec.Version = "1.0.1"
+				return *chart
+			},
+			expected: false,
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			drifted := IsDrifted(tc.chart())
+			if drifted != tc.expected {
+				t.Fatalf("drifted == %t, want %t", drifted, tc.expected)
+			}
+		})
+	}
+}
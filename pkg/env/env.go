@@ -11,17 +11,13 @@ const (
 	EnvVarPodNamespace = "POD_NAMESPACE"
 )
 
-var (
-	podNamespace string
-)
-
-func init() {
-	podNamespace = os.Getenv(EnvVarPodNamespace)
+// PodNamespace returns the namespace of the pod. It is read when it is
+// first needed so packages importing env can be tested without the env var.
+func PodNamespace() string {
+	podNamespace := os.Getenv(EnvVarPodNamespace)
 	if podNamespace == "" {
 		panic(fmt.Sprintf("env var '%s' must not be empty", EnvVarPodNamespace))
 	}
-}
 
-func PodNamespace() string {
 	return podNamespace
 }
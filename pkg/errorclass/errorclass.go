@@ -0,0 +1,97 @@
+// Package errorclass classifies errors returned while reconciling app CRs
+// into a stable status code, a user facing reason, a retryable flag and a
+// suggested remediation. Resources define their own rules for the errors
+// they own and fall back to the common rules defined in this package.
+package errorclass
+
+import (
+	"fmt"
+
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/app-operator/v7/pkg/status"
+)
+
+// Class is the result of classifying an error.
+type Class struct {
+	// Status is the stable status code set in the app CR status.
+	Status string
+	// Reason is the user facing error message.
+	Reason string
+	// Retryable is true when the error is transient and the reconciliation
+	// should be retried. Non retryable errors are reported in the app CR
+	// status and the resource is canceled.
+	Retryable bool
+	// Remediation is a suggestion on how to fix the error. It may be empty.
+	Remediation string
+}
+
+// Message returns the reason with the remediation appended so both can be
+// shown in the app CR status.
+func (c Class) Message() string {
+	if c.Remediation == "" {
+		return c.Reason
+	}
+
+	return fmt.Sprintf("%s; remediation: %s", c.Reason, c.Remediation)
+}
+
+// Rule maps errors matched by Match to a status, retryable flag and
+// remediation.
+type Rule struct {
+	Match       func(error) bool
+	Status      string
+	Retryable   bool
+	Remediation string
+}
+
+// Classifier classifies errors using an ordered list of rules. The first
+// matching rule wins.
+type Classifier struct {
+	rules []Rule
+}
+
+// New creates a classifier that checks the given rules before the common
+// rules shared by all resources.
+func New(rules ...Rule) *Classifier {
+	c := &Classifier{}
+
+	c.rules = append(c.rules, rules...)
+	c.rules = append(c.rules, commonRules...)
+
+	return c
+}
+
+// Classify returns the class of the given error. Errors not matching any rule
+// are classified as retryable unknown errors. A nil error returns an empty
+// class.
+func (c *Classifier) Classify(err error) Class {
+	if err == nil {
+		return Class{}
+	}
+
+	for _, r := range c.rules {
+		if r.Match(err) {
+			return Class{
+				Status:      r.Status,
+				Reason:      err.Error(),
+				Retryable:   r.Retryable,
+				Remediation: r.Remediation,
+			}
+		}
+	}
+
+	return Class{
+		Status:    status.UnknownError,
+		Reason:    err.Error(),
+		Retryable: true,
+	}
+}
+
+// CauseIs returns a matcher for errors caused by the given microerror. It is
+// used by resources to build rules for their own unexported errors.
+func CauseIs(target *microerror.Error) func(error) bool {
+	return func(err error) bool {
+		return microerror.Cause(err) == target
+	}
+}
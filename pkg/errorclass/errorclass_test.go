@@ -0,0 +1,124 @@
+package errorclass
+
+import (
+	"crypto/x509"
+	"fmt"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/giantswarm/app-operator/v7/pkg/status"
+)
+
+var testError = &microerror.Error{
+	Kind: "testError",
+}
+
+func Test_Classifier_Classify(t *testing.T) {
+	tests := []struct {
+		name          string
+		rules         []Rule
+		err           error
+		expectedClass Class
+	}{
+		{
+			name:          "case 0: nil error",
+			err:           nil,
+			expectedClass: Class{},
+		},
+		{
+			name: "case 1: unknown error is retryable",
+			err:  fmt.Errorf("something went wrong"),
+			expectedClass: Class{
+				Status:    status.UnknownError,
+				Reason:    "something went wrong",
+				Retryable: true,
+			},
+		},
+		{
+			name: "case 2: resource rule matches masked error",
+			rules: []Rule{
+				{
+					Match:       CauseIs(testError),
+					Status:      status.AppNotFoundStatus,
+					Remediation: "fix it",
+				},
+			},
+			err: microerror.Maskf(testError, "app %#q", "test"),
+			expectedClass: Class{
+				Status:      status.AppNotFoundStatus,
+				Reason:      "test error: app `test`",
+				Remediation: "fix it",
+			},
+		},
+		{
+			name: "case 3: resource rules are checked before common rules",
+			rules: []Rule{
+				{
+					Match:  apierrors.IsNotFound,
+					Status: status.ConfigmapMergeFailedStatus,
+				},
+			},
+			err: apierrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, "test"),
+			expectedClass: Class{
+				Status: status.ConfigmapMergeFailedStatus,
+				Reason: `configmaps "test" not found`,
+			},
+		},
+		{
+			name: "case 4: kubernetes not found error",
+			err:  microerror.Mask(apierrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, "test")),
+			expectedClass: Class{
+				Status:      status.ResourceNotFoundStatus,
+				Reason:      `secrets "test" not found`,
+				Remediation: "check the resources referenced by the app CR exist",
+			},
+		},
+		{
+			name: "case 5: kubernetes forbidden error",
+			err:  apierrors.NewForbidden(schema.GroupResource{Resource: "secrets"}, "test", fmt.Errorf("denied")),
+			expectedClass: Class{
+				Status:      status.ForbiddenStatus,
+				Reason:      `secrets "test" is forbidden: denied`,
+				Remediation: "check app-operator has RBAC permissions for the resources referenced by the app CR",
+			},
+		},
+		{
+			name: "case 6: TLS error",
+			err:  microerror.Mask(fmt.Errorf("get index: %w", x509.UnknownAuthorityError{})),
+			expectedClass: Class{
+				Status:      status.TLSErrorStatus,
+				Reason:      "get index: x509: certificate signed by unknown authority",
+				Remediation: "check the certificate of the server is valid and signed by a trusted certificate authority",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := New(tc.rules...)
+
+			class := c.Classify(tc.err)
+			if class != tc.expectedClass {
+				t.Fatalf("expected %#v got %#v", tc.expectedClass, class)
+			}
+		})
+	}
+}
+
+func Test_Class_Message(t *testing.T) {
+	c := Class{
+		Reason:      "app not found",
+		Remediation: "check .spec.name",
+	}
+	if c.Message() != "app not found; remediation: check .spec.name" {
+		t.Fatalf("unexpected message %#q", c.Message())
+	}
+
+	c.Remediation = ""
+	if c.Message() != "app not found" {
+		t.Fatalf("unexpected message %#q", c.Message())
+	}
+}
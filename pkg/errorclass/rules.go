@@ -0,0 +1,102 @@
+package errorclass
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+
+	"github.com/giantswarm/errors/tenant"
+	"github.com/giantswarm/kubeconfig/v4"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/giantswarm/app-operator/v7/pkg/status"
+)
+
+// commonRules are checked after the rules of a resource. The API not
+// available rule must come before the TLS rule since transient certificate
+// errors are returned while a workload cluster API is coming up.
+var commonRules = []Rule{
+	{
+		Match:     tenant.IsAPINotAvailable,
+		Status:    status.ClusterUnavailableStatus,
+		Retryable: true,
+	},
+	{
+		Match:       IsTLSError,
+		Status:      status.TLSErrorStatus,
+		Remediation: "check the certificate of the server is valid and signed by a trusted certificate authority",
+	},
+	{
+		Match:       kubeconfig.IsNotFoundError,
+		Status:      status.ResourceNotFoundStatus,
+		Remediation: "check the kubeconfig secret referenced in .spec.kubeConfig exists",
+	},
+	{
+		Match:       apierrors.IsNotFound,
+		Status:      status.ResourceNotFoundStatus,
+		Remediation: "check the resources referenced by the app CR exist",
+	},
+	{
+		Match: func(err error) bool {
+			return apierrors.IsForbidden(err) || apierrors.IsUnauthorized(err)
+		},
+		Status:      status.ForbiddenStatus,
+		Remediation: "check app-operator has RBAC permissions for the resources referenced by the app CR",
+	},
+}
+
+// IsTLSError asserts errors caused by failed TLS handshakes or certificate
+// verification.
+func IsTLSError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	if errors.As(err, &unknownAuthorityErr) {
+		return true
+	}
+	var certificateInvalidErr x509.CertificateInvalidError
+	if errors.As(err, &certificateInvalidErr) {
+		return true
+	}
+	var hostnameErr x509.HostnameError
+	if errors.As(err, &hostnameErr) {
+		return true
+	}
+	var verificationErr *tls.CertificateVerificationError
+	if errors.As(err, &verificationErr) {
+		return true
+	}
+
+	// Errors returned by HTTP clients are often only available as strings
+	// once they have been masked.
+	return strings.Contains(err.Error(), "x509: ") || strings.Contains(err.Error(), "tls: ")
+}
+
+// IsNetworkError asserts transient network errors like timeouts, refused or
+// reset connections and connections closed before a response was read. TLS
+// errors are not network errors.
+func IsNetworkError(err error) bool {
+	if err == nil || IsTLSError(err) {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+
+	return errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF)
+}
@@ -0,0 +1,30 @@
+package kubeconfigsource
+
+import "github.com/giantswarm/microerror"
+
+var missingClusterError = &microerror.Error{
+	Kind: "missingClusterError",
+}
+
+// IsMissingCluster asserts missingClusterError.
+func IsMissingCluster(err error) bool {
+	return microerror.Cause(err) == missingClusterError
+}
+
+var unknownSourceError = &microerror.Error{
+	Kind: "unknownSourceError",
+}
+
+// IsUnknownSource asserts unknownSourceError.
+func IsUnknownSource(err error) bool {
+	return microerror.Cause(err) == unknownSourceError
+}
+
+var untrustedSourceError = &microerror.Error{
+	Kind: "untrustedSourceError",
+}
+
+// IsUntrustedSource asserts untrustedSourceError.
+func IsUntrustedSource(err error) bool {
+	return microerror.Cause(err) == untrustedSourceError
+}
@@ -0,0 +1,107 @@
+// Package kubeconfigsource selects how app-operator reaches the workload
+// cluster of an app CR. By default the kubeconfig secret of the app CR is
+// used. Other sources are selected per app CR with Annotation or for all
+// app CRs by flag.
+//
+// The Exec and File sources use credentials of app-operator instead of
+// credentials provided by the owner of the app CR. With Exec app-operator
+// sends its projected service account token to the control plane endpoint
+// of the Cluster API cluster in the namespace of the app CR, so whoever can
+// write that cluster receives the token. The token is issued for a
+// dedicated audience which only the workload cluster API servers accept so
+// it cannot be replayed against the management cluster. It is still valid
+// for every workload cluster trusting the audience. These sources are
+// therefore only used for app CRs in trusted namespaces, whether they are
+// selected with Annotation or are the default source set by the operator.
+package kubeconfigsource
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/k8smetadata/pkg/label"
+	"github.com/giantswarm/microerror"
+)
+
+const (
+	// Annotation selects the kubeconfig source of an app CR. Exec and File
+	// are only used by app CRs in trusted namespaces.
+	Annotation = "application.giantswarm.io/kubeconfig-source"
+
+	// Secret is the kubeconfig secret set in the app CR spec.
+	Secret = "secret"
+	// CAPI is the kubeconfig secret Cluster API creates for the cluster of
+	// the app CR. It is named after the cluster and is in the namespace of
+	// the app CR.
+	CAPI = "capi"
+	// Exec connects to the control plane endpoint of the Cluster API
+	// cluster of the app CR with the projected service account token of
+	// app-operator, optionally exchanged by an exec credential plugin.
+	Exec = "exec"
+	// File uses the context named after the cluster of the app CR in a
+	// local kubeconfig file. It is meant for development.
+	File = "file"
+)
+
+// Sources are the supported kubeconfig sources.
+var Sources = []string{Secret, CAPI, Exec, File}
+
+// Source returns the kubeconfig source of the app CR. It is defaultSource
+// unless the app CR is annotated with another one. The Exec and File
+// sources are only used by app CRs in trustedNamespaces, also when they
+// are the defaultSource.
+func Source(app v1alpha1.App, defaultSource string, trustedNamespaces []string) (string, error) {
+	source := app.Annotations[Annotation]
+	if source == "" {
+		source = defaultSource
+	}
+	if source == "" {
+		source = Secret
+	}
+
+	err := Validate(source)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	if (source == Exec || source == File) && !slices.Contains(trustedNamespaces, app.Namespace) {
+		return "", microerror.Maskf(untrustedSourceError, "kubeconfig source %#q may only be used by app CRs in namespaces %v", source, trustedNamespaces)
+	}
+
+	return source, nil
+}
+
+// Validate returns an unknownSourceError unless the source is supported.
+func Validate(source string) error {
+	for _, s := range Sources {
+		if s == source {
+			return nil
+		}
+	}
+
+	return microerror.Maskf(unknownSourceError, "kubeconfig source %#q must be one of %v", source, Sources)
+}
+
+// ClusterName returns the name of the Cluster API cluster of the app CR from
+// its cluster label.
+func ClusterName(app v1alpha1.App) (string, error) {
+	name := app.Labels[label.Cluster]
+	if name == "" {
+		return "", microerror.Maskf(missingClusterError, "app CR must have label %#q", label.Cluster)
+	}
+
+	return name, nil
+}
+
+// CAPISecretName returns the name of the kubeconfig secret Cluster API
+// creates for the cluster.
+func CAPISecretName(clusterName string) string {
+	return fmt.Sprintf("%s-kubeconfig", clusterName)
+}
+
+// CAPICASecretName returns the name of the secret holding the certificate
+// authority of the cluster created by Cluster API.
+func CAPICASecretName(clusterName string) string {
+	return fmt.Sprintf("%s-ca", clusterName)
+}
@@ -0,0 +1,21 @@
+package maintenancewindow
+
+import "github.com/giantswarm/microerror"
+
+var invalidConfigError = &microerror.Error{
+	Kind: "invalidConfigError",
+}
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+var invalidWindowError = &microerror.Error{
+	Kind: "invalidWindowError",
+}
+
+// IsInvalidWindow asserts invalidWindowError.
+func IsInvalidWindow(err error) bool {
+	return microerror.Cause(err) == invalidWindowError
+}
@@ -0,0 +1,187 @@
+// Package maintenancewindow defers changes of chart CRs and their values to
+// maintenance windows. A window opens whenever its cron schedule fires and
+// stays open for its duration, e.g. the schedule "0 2 * * 1-5" with the
+// duration "2h" opens a window from 2am to 4am on weekdays in its timezone.
+//
+// Windows are set per app CR via annotations or for all app CRs of an
+// app-operator instance, i.e. per workload cluster, via its configuration.
+// Changes are never deferred for app CRs with OverrideAnnotation set to
+// "true" which allows to roll out emergency fixes.
+package maintenancewindow
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/microerror"
+)
+
+const (
+	// ScheduleAnnotation is the cron schedule of the maintenance window of
+	// an app CR.
+	ScheduleAnnotation = "application.giantswarm.io/maintenance-window-schedule"
+	// DurationAnnotation is the duration of the maintenance window, e.g. 2h.
+	DurationAnnotation = "application.giantswarm.io/maintenance-window-duration"
+	// TimezoneAnnotation is the IANA timezone of the schedule. It defaults
+	// to UTC.
+	TimezoneAnnotation = "application.giantswarm.io/maintenance-window-timezone"
+	// OverrideAnnotation applies changes outside of the maintenance window
+	// when set to "true".
+	OverrideAnnotation = "application.giantswarm.io/maintenance-window-override"
+
+	// maxDuration bounds the duration so checking a window stays cheap.
+	maxDuration = 7 * 24 * time.Hour
+	// maxLookahead bounds the search for the next window.
+	maxLookahead = 366 * 24 * time.Hour
+)
+
+// Window is a maintenance window.
+type Window struct {
+	Schedule *Schedule
+	Duration time.Duration
+	Location *time.Location
+}
+
+// Parse parses a maintenance window. The timezone defaults to UTC.
+func Parse(schedule, duration, timezone string) (*Window, error) {
+	s, err := ParseSchedule(schedule)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	d, err := time.ParseDuration(duration)
+	if err != nil {
+		return nil, microerror.Maskf(invalidWindowError, "invalid duration %#q", duration)
+	}
+	if d < time.Minute || d > maxDuration {
+		return nil, microerror.Maskf(invalidWindowError, "duration %#q must be between 1m and %s", duration, maxDuration)
+	}
+
+	location := time.UTC
+	if timezone != "" {
+		location, err = time.LoadLocation(timezone)
+		if err != nil {
+			return nil, microerror.Maskf(invalidWindowError, "invalid timezone %#q", timezone)
+		}
+	}
+
+	w := &Window{
+		Schedule: s,
+		Duration: d,
+		Location: location,
+	}
+
+	return w, nil
+}
+
+// FromApp returns the maintenance window set in the annotations of the app
+// CR. It returns nil when the app CR has none.
+func FromApp(app v1alpha1.App) (*Window, error) {
+	annotations := app.GetAnnotations()
+
+	schedule := annotations[ScheduleAnnotation]
+	if schedule == "" {
+		return nil, nil
+	}
+
+	w, err := Parse(schedule, annotations[DurationAnnotation], annotations[TimezoneAnnotation])
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return w, nil
+}
+
+// Contains returns whether the window is open at t.
+func (w *Window) Contains(t time.Time) bool {
+	start := t.In(w.Location).Truncate(time.Minute)
+
+	for offset := time.Duration(0); offset < w.Duration; offset += time.Minute {
+		if w.Schedule.Matches(start.Add(-offset)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Next returns the start of the next window after t. It returns false when
+// the schedule does not fire within a year.
+func (w *Window) Next(t time.Time) (time.Time, bool) {
+	start := t.In(w.Location).Truncate(time.Minute).Add(time.Minute)
+	end := start.Add(maxLookahead)
+
+	for next := start; next.Before(end); {
+		switch {
+		case !w.Schedule.matchesDay(next):
+			y, m, d := next.Date()
+			next = time.Date(y, m, d+1, 0, 0, 0, 0, w.Location)
+		case !has(w.Schedule.hour, next.Hour()):
+			y, m, d := next.Date()
+			next = time.Date(y, m, d, next.Hour()+1, 0, 0, 0, w.Location)
+		case !has(w.Schedule.minute, next.Minute()):
+			next = next.Add(time.Minute)
+		default:
+			return next, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+type Config struct {
+	// Schedule, Duration and Timezone define the default maintenance window
+	// of app CRs without their own. No window is set when Schedule is
+	// empty.
+	Schedule string
+	Duration string
+	Timezone string
+}
+
+// Policy decides whether changes of app CRs are deferred.
+type Policy struct {
+	window *Window
+}
+
+func New(config Config) (*Policy, error) {
+	p := &Policy{}
+
+	if config.Schedule != "" {
+		w, err := Parse(config.Schedule, config.Duration, config.Timezone)
+		if err != nil {
+			return nil, microerror.Maskf(invalidConfigError, "%T.Schedule, %T.Duration and %T.Timezone must define a valid window: %s", config, config, config, err)
+		}
+
+		p.window = w
+	}
+
+	return p, nil
+}
+
+// Deferred returns whether changes of the app CR are deferred at now and
+// the reason shown in the app CR status.
+func (p *Policy) Deferred(app v1alpha1.App, now time.Time) (bool, string, error) {
+	if app.GetAnnotations()[OverrideAnnotation] == "true" {
+		return false, "", nil
+	}
+
+	w, err := FromApp(app)
+	if err != nil {
+		return false, "", microerror.Mask(err)
+	}
+	if w == nil {
+		w = p.window
+	}
+	if w == nil || w.Contains(now) {
+		return false, "", nil
+	}
+
+	reason := "Changes are deferred until the next maintenance window"
+	if next, ok := w.Next(now); ok {
+		reason = fmt.Sprintf("Changes are deferred until the next maintenance window starting at %s", next.Format(time.RFC3339))
+	}
+	reason = fmt.Sprintf("%s. Set the %s annotation to \"true\" to apply them now.", reason, OverrideAnnotation)
+
+	return true, reason, nil
+}
@@ -0,0 +1,151 @@
+package maintenancewindow
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_Policy_Deferred(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	// Monday 19th of October 2026.
+	monday := func(hour, minute int) time.Time {
+		return time.Date(2026, 10, 19, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name             string
+		config           Config
+		annotations      map[string]string
+		now              time.Time
+		expectedDeferred bool
+		expectedNext     time.Time
+		errorMatcher     func(error) bool
+	}{
+		{
+			name: "case 0: changes are applied without window",
+			now:  monday(12, 0),
+		},
+		{
+			name: "case 1: changes are applied inside the default window",
+			config: Config{
+				Schedule: "0 2 * * 1-5",
+				Duration: "2h",
+			},
+			now: monday(3, 59),
+		},
+		{
+			name: "case 2: changes are deferred outside the default window",
+			config: Config{
+				Schedule: "0 2 * * 1-5",
+				Duration: "2h",
+			},
+			now:              monday(4, 0),
+			expectedDeferred: true,
+			expectedNext:     time.Date(2026, 10, 20, 2, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "case 3: app CR window takes precedence and uses its timezone",
+			config: Config{
+				Schedule: "0 2 * * 1-5",
+				Duration: "2h",
+			},
+			annotations: map[string]string{
+				ScheduleAnnotation: "30 22 * * 6,0",
+				DurationAnnotation: "1h",
+				TimezoneAnnotation: "Europe/Berlin",
+			},
+			now:              monday(3, 0),
+			expectedDeferred: true,
+			expectedNext:     time.Date(2026, 10, 24, 22, 30, 0, 0, berlin),
+		},
+		{
+			name: "case 4: window is open across midnight",
+			annotations: map[string]string{
+				ScheduleAnnotation: "0 23 * * *",
+				DurationAnnotation: "3h",
+			},
+			now: monday(1, 30),
+		},
+		{
+			name: "case 5: override applies changes outside the window",
+			config: Config{
+				Schedule: "0 2 * * 1-5",
+				Duration: "2h",
+			},
+			annotations: map[string]string{
+				OverrideAnnotation: "true",
+			},
+			now: monday(12, 0),
+		},
+		{
+			name: "case 6: invalid schedule is rejected",
+			annotations: map[string]string{
+				ScheduleAnnotation: "0 25 * * *",
+				DurationAnnotation: "1h",
+			},
+			now:          monday(12, 0),
+			errorMatcher: IsInvalidWindow,
+		},
+		{
+			name: "case 7: missing duration is rejected",
+			annotations: map[string]string{
+				ScheduleAnnotation: "0 2 * * *",
+			},
+			now:          monday(12, 0),
+			errorMatcher: IsInvalidWindow,
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			p, err := New(tc.config)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			app := v1alpha1.App{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: tc.annotations,
+				},
+			}
+
+			deferred, reason, err := p.Deferred(app, tc.now)
+			switch {
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case err != nil && !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if deferred != tc.expectedDeferred {
+				t.Fatalf("deferred == %t, want %t", deferred, tc.expectedDeferred)
+			}
+			if !tc.expectedNext.IsZero() {
+				w, err := FromApp(app)
+				if err != nil {
+					t.Fatalf("error == %#v, want nil", err)
+				}
+				if w == nil {
+					w = p.window
+				}
+
+				next, ok := w.Next(tc.now)
+				if !ok || !next.Equal(tc.expectedNext) {
+					t.Fatalf("next == %s, want %s (reason %q)", next, tc.expectedNext, reason)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package maintenancewindow
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/giantswarm/microerror"
+)
+
+// Schedule is a parsed cron schedule with the standard five fields minute,
+// hour, day of month, month and day of week. Fields support *, values,
+// ranges, lists and steps, e.g. "0 2 * * 1-5" or "*/30 22-23 * * 6,0".
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+
+	// domAny and dowAny are set when the field is *. As in cron a time
+	// matches when either the day of month or the day of week matches if
+	// both are restricted.
+	domAny, dowAny bool
+}
+
+type field struct {
+	name     string
+	min, max int
+}
+
+var fields = []field{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12},
+	{name: "day of week", min: 0, max: 7},
+}
+
+// ParseSchedule parses a cron schedule.
+func ParseSchedule(spec string) (*Schedule, error) {
+	parts := strings.Fields(spec)
+	if len(parts) != len(fields) {
+		return nil, microerror.Maskf(invalidWindowError, "schedule %#q must have %d fields", spec, len(fields))
+	}
+
+	var bits [5]uint64
+	for i, part := range parts {
+		b, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		bits[i] = b
+	}
+
+	// Sunday is 0 or 7.
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+
+	s := &Schedule{
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    bits[4],
+
+		domAny: parts[2] == "*",
+		dowAny: parts[4] == "*",
+	}
+
+	return s, nil
+}
+
+// Matches returns whether the schedule fires in the minute of t.
+func (s *Schedule) Matches(t time.Time) bool {
+	return has(s.minute, t.Minute()) && has(s.hour, t.Hour()) && s.matchesDay(t)
+}
+
+func (s *Schedule) matchesDay(t time.Time) bool {
+	if !has(s.month, int(t.Month())) {
+		return false
+	}
+
+	dom := has(s.dom, t.Day())
+	dow := has(s.dow, int(t.Weekday()))
+
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+func parseField(spec string, f field) (uint64, error) {
+	var bits uint64
+
+	for _, item := range strings.Split(spec, ",") {
+		rangeSpec, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			var err error
+			rangeSpec = item[:i]
+			step, err = strconv.Atoi(item[i+1:])
+			if err != nil || step <= 0 {
+				return 0, microerror.Maskf(invalidWindowError, "invalid step in %s field %#q", f.name, spec)
+			}
+		}
+
+		start, end := f.min, f.max
+		if rangeSpec != "*" {
+			bounds := strings.SplitN(rangeSpec, "-", 2)
+
+			var err error
+			start, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return 0, microerror.Maskf(invalidWindowError, "invalid value in %s field %#q", f.name, spec)
+			}
+			end = start
+			if len(bounds) == 2 {
+				end, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return 0, microerror.Maskf(invalidWindowError, "invalid value in %s field %#q", f.name, spec)
+				}
+			} else if step > 1 {
+				// A single value with a step runs to the maximum, e.g. 5/15.
+				end = f.max
+			}
+		}
+
+		if start < f.min || end > f.max || start > end {
+			return 0, microerror.Maskf(invalidWindowError, "%s field %#q must be within %d-%d", f.name, spec, f.min, f.max)
+		}
+
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+func has(bits uint64, v int) bool {
+	return bits&(1<<uint(v)) != 0
+}
@@ -0,0 +1,231 @@
+// Package rollback implements the opt-in auto-rollback policy of app CRs.
+// When Annotation of an app CR is set to Enabled and chart-operator reports
+// a failed release of a new version or new values, the chart CR is reverted
+// to the last release app-operator saw deployed successfully.
+//
+// The policy keeps its State in StateAnnotation of the chart CR. A release
+// applied to the chart CR is pending until chart-operator reports it as
+// deployed. It then becomes the last good release. A failed pending release
+// is rolled back and held back until the app CR is changed again, e.g. by
+// fixing the version or values.
+//
+// Values are rolled back by pointing the chart CR at the configmap and
+// secret resource versions of the last good release. Only immutable values
+// generations keep the previous values, so values are only restored when
+// immutable values are enabled.
+package rollback
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/microerror"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/giantswarm/app-operator/v7/pkg/status"
+)
+
+const (
+	// Annotation enables the auto-rollback policy of an app CR when set to
+	// Enabled.
+	Annotation = "application.giantswarm.io/auto-rollback"
+	// Enabled is the value of Annotation enabling the policy.
+	Enabled = "enabled"
+
+	// StateAnnotation holds the JSON encoded State of the chart CR.
+	StateAnnotation = "app-operator.giantswarm.io/rollback-state"
+
+	deployedStatus = "deployed"
+	failedStatus   = "failed"
+)
+
+// Release is the part of the chart CR spec a rollback reverts.
+type Release struct {
+	Config     v1alpha1.ChartSpecConfig `json:"config"`
+	TarballURL string                   `json:"tarballURL"`
+	Version    string                   `json:"version"`
+}
+
+// State is the rollback state of a chart CR.
+type State struct {
+	// LastGood is the last release reported as deployed.
+	LastGood *Release `json:"lastGood,omitempty"`
+	// Pending is the release applied to the chart CR which is not yet
+	// reported as deployed.
+	Pending *Release `json:"pending,omitempty"`
+	// PendingSince is the time Pending was applied.
+	PendingSince *metav1.Time `json:"pendingSince,omitempty"`
+	// RolledBackFrom is the failed release which was rolled back. It is
+	// held back as long as the app CR still resolves to it.
+	RolledBackFrom *Release `json:"rolledBackFrom,omitempty"`
+	// Reason is the reason chart-operator reported for the failed release.
+	Reason string `json:"reason,omitempty"`
+}
+
+// IsEnabled returns whether the auto-rollback policy is enabled for the app
+// CR.
+func IsEnabled(app v1alpha1.App) bool {
+	return app.GetAnnotations()[Annotation] == Enabled
+}
+
+// ReleaseOf returns the release the chart CR is pointed at.
+func ReleaseOf(chart v1alpha1.Chart) Release {
+	return Release{
+		Config:     chart.Spec.Config,
+		TarballURL: chart.Spec.TarballURL,
+		Version:    chart.Spec.Version,
+	}
+}
+
+// FromChart returns the rollback state stored in the chart CR. The state is
+// empty when the chart CR has none.
+func FromChart(chart v1alpha1.Chart) (State, error) {
+	var state State
+
+	value := chart.GetAnnotations()[StateAnnotation]
+	if value == "" {
+		return state, nil
+	}
+
+	err := json.Unmarshal([]byte(value), &state)
+	if err != nil {
+		return State{}, microerror.Maskf(invalidStateError, "failed to parse annotation %#q: %s", StateAnnotation, err)
+	}
+
+	return state, nil
+}
+
+// ToAnnotation returns the value of StateAnnotation for the state.
+func ToAnnotation(state State) (string, error) {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	return string(b), nil
+}
+
+// Next returns the state and release of the desired chart CR given the
+// current chart CR, which may be nil when it does not exist yet, and the
+// release resolved from the app CR. rolledBack is true when the current
+// release failed and is rolled back by the returned release.
+func Next(state State, current *v1alpha1.Chart, desired Release, now time.Time) (next State, release Release, rolledBack bool) {
+	next = state
+	release = desired
+
+	if current != nil {
+		currentRelease := ReleaseOf(*current)
+		releaseStatus := strings.ToLower(current.Status.Release.Status)
+
+		isPending := next.Pending != nil && equal(*next.Pending, currentRelease)
+
+		switch {
+		case releaseStatus == deployedStatus && isPending && deployedSince(*current, next.PendingSince):
+			next.LastGood = &currentRelease
+			next.Pending = nil
+			next.PendingSince = nil
+		case releaseStatus == deployedStatus && next.Pending == nil && next.LastGood == nil:
+			// Charts created before the policy was enabled have no state.
+			// Their deployed release is the last good one.
+			next.LastGood = &currentRelease
+		case releaseStatus == failedStatus && isPending && !deployedBefore(*current, next.PendingSince) && next.LastGood != nil && !equal(*next.LastGood, currentRelease):
+			next.RolledBackFrom = &currentRelease
+			next.Reason = current.Status.Reason
+			next.Pending = nil
+			next.PendingSince = nil
+			rolledBack = true
+		}
+	}
+
+	if next.RolledBackFrom != nil {
+		if equal(*next.RolledBackFrom, desired) && next.LastGood != nil {
+			// The app CR still resolves to the failed release.
+			release = *next.LastGood
+		} else {
+			next.RolledBackFrom = nil
+			next.Reason = ""
+		}
+	}
+
+	if current == nil || !equal(ReleaseOf(*current), release) {
+		next.Pending = &release
+		next.PendingSince = &metav1.Time{Time: now.UTC().Truncate(time.Second)}
+	}
+
+	return next, release, rolledBack
+}
+
+// Apply points the chart CR at the release.
+func Apply(chart *v1alpha1.Chart, release Release) {
+	chart.Spec.Config = release.Config
+	chart.Spec.TarballURL = release.TarballURL
+	chart.Spec.Version = release.Version
+}
+
+// AppStatus returns the app CR status for the chart CR status. When the
+// chart CR was rolled back and the rollback is deployed the release status
+// is status.RolledBackStatus and the reason names the failed version.
+func AppStatus(chart v1alpha1.Chart, appStatus v1alpha1.AppStatus) v1alpha1.AppStatus {
+	if strings.ToLower(chart.Status.Release.Status) != deployedStatus {
+		return appStatus
+	}
+
+	state, err := FromChart(chart)
+	if err != nil || state.RolledBackFrom == nil || state.LastGood == nil {
+		return appStatus
+	}
+
+	appStatus.Release.Status = status.RolledBackStatus
+	appStatus.Release.Reason = Message(state)
+
+	return appStatus
+}
+
+// Message describes the rollback recorded in the state.
+func Message(state State) string {
+	if state.RolledBackFrom == nil || state.LastGood == nil {
+		return ""
+	}
+
+	message := fmt.Sprintf("Rolled back from version %#q to %#q after the release failed", state.RolledBackFrom.Version, state.LastGood.Version)
+	if state.RolledBackFrom.Version == state.LastGood.Version {
+		message = fmt.Sprintf("Rolled back the values of version %#q after the release failed", state.LastGood.Version)
+	}
+	if state.Reason != "" {
+		message = fmt.Sprintf("%s: %s", message, state.Reason)
+	}
+
+	return message + ". Change the version or values of the app CR to retry."
+}
+
+// deployedSince returns whether the chart CR was deployed after the pending
+// release was applied.
+func deployedSince(chart v1alpha1.Chart, since *metav1.Time) bool {
+	lastDeployed := chart.Status.Release.LastDeployed
+	if lastDeployed == nil || since == nil {
+		return false
+	}
+
+	return !lastDeployed.Before(since)
+}
+
+// deployedBefore returns whether the release of the chart CR was deployed
+// before the pending release was applied. A failure reported for an earlier
+// release must not roll back the pending one. The release time is unknown
+// when chart-operator does not set it.
+func deployedBefore(chart v1alpha1.Chart, since *metav1.Time) bool {
+	lastDeployed := chart.Status.Release.LastDeployed
+	if lastDeployed == nil || since == nil {
+		return false
+	}
+
+	return lastDeployed.Before(since)
+}
+
+func equal(a, b Release) bool {
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,270 @@
+package rollback
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/giantswarm/app-operator/v7/pkg/status"
+)
+
+func Test_Next(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	earlier := metav1.NewTime(now.Add(-10 * time.Minute))
+	later := metav1.NewTime(now.Add(-5 * time.Minute))
+	nowTime := metav1.NewTime(now)
+
+	good := Release{
+		Config: v1alpha1.ChartSpecConfig{
+			ConfigMap: v1alpha1.ChartSpecConfigConfigMap{
+				Name:            "prometheus-chart-values",
+				Namespace:       "giantswarm",
+				ResourceVersion: "100",
+			},
+		},
+		TarballURL: "https://giantswarm.github.io/app-catalog/prometheus-1.0.0.tgz",
+		Version:    "1.0.0",
+	}
+	bad := Release{
+		Config:     good.Config,
+		TarballURL: "https://giantswarm.github.io/app-catalog/prometheus-1.1.0.tgz",
+		Version:    "1.1.0",
+	}
+	fixed := Release{
+		Config:     good.Config,
+		TarballURL: "https://giantswarm.github.io/app-catalog/prometheus-1.1.1.tgz",
+		Version:    "1.1.1",
+	}
+
+	chart := func(release Release, releaseStatus string, lastDeployed *metav1.Time) *v1alpha1.Chart {
+		c := &v1alpha1.Chart{
+			Status: v1alpha1.ChartStatus{
+				Reason: "timed out waiting for the condition",
+				Release: v1alpha1.ChartStatusRelease{
+					LastDeployed: lastDeployed,
+					Status:       releaseStatus,
+				},
+			},
+		}
+		Apply(c, release)
+
+		return c
+	}
+
+	tests := []struct {
+		name               string
+		state              State
+		current            *v1alpha1.Chart
+		desired            Release
+		expectedState      State
+		expectedRelease    Release
+		expectedRolledBack bool
+	}{
+		{
+			name:    "case 0: release of a new chart is pending",
+			desired: good,
+			expectedState: State{
+				Pending:      &good,
+				PendingSince: &nowTime,
+			},
+			expectedRelease: good,
+		},
+		{
+			name: "case 1: deployed pending release becomes the last good release",
+			state: State{
+				Pending:      &good,
+				PendingSince: &earlier,
+			},
+			current: chart(good, "deployed", &later),
+			desired: good,
+			expectedState: State{
+				LastGood: &good,
+			},
+			expectedRelease: good,
+		},
+		{
+			name:    "case 2: deployed release of a chart without state becomes the last good release",
+			current: chart(good, "deployed", &later),
+			desired: good,
+			expectedState: State{
+				LastGood: &good,
+			},
+			expectedRelease: good,
+		},
+		{
+			name: "case 3: new release is pending",
+			state: State{
+				LastGood: &good,
+			},
+			current: chart(good, "deployed", &later),
+			desired: bad,
+			expectedState: State{
+				LastGood:     &good,
+				Pending:      &bad,
+				PendingSince: &nowTime,
+			},
+			expectedRelease: bad,
+		},
+		{
+			name: "case 4: failed pending release is rolled back",
+			state: State{
+				LastGood:     &good,
+				Pending:      &bad,
+				PendingSince: &earlier,
+			},
+			current: chart(bad, "failed", &later),
+			desired: bad,
+			expectedState: State{
+				LastGood:       &good,
+				Pending:        &good,
+				PendingSince:   &nowTime,
+				RolledBackFrom: &bad,
+				Reason:         "timed out waiting for the condition",
+			},
+			expectedRelease:    good,
+			expectedRolledBack: true,
+		},
+		{
+			name: "case 5: failed release is held back",
+			state: State{
+				LastGood:       &good,
+				RolledBackFrom: &bad,
+				Reason:         "timed out waiting for the condition",
+			},
+			current: chart(good, "deployed", &later),
+			desired: bad,
+			expectedState: State{
+				LastGood:       &good,
+				RolledBackFrom: &bad,
+				Reason:         "timed out waiting for the condition",
+			},
+			expectedRelease: good,
+		},
+		{
+			name: "case 6: changed release is applied after a rollback",
+			state: State{
+				LastGood:       &good,
+				RolledBackFrom: &bad,
+				Reason:         "timed out waiting for the condition",
+			},
+			current: chart(good, "deployed", &later),
+			desired: fixed,
+			expectedState: State{
+				LastGood:     &good,
+				Pending:      &fixed,
+				PendingSince: &nowTime,
+			},
+			expectedRelease: fixed,
+		},
+		{
+			name: "case 7: failed release without last good release is not rolled back",
+			state: State{
+				Pending:      &bad,
+				PendingSince: &earlier,
+			},
+			current: chart(bad, "failed", &later),
+			desired: bad,
+			expectedState: State{
+				Pending:      &bad,
+				PendingSince: &earlier,
+			},
+			expectedRelease: bad,
+		},
+		{
+			name: "case 8: failure reported before the pending release was applied is ignored",
+			state: State{
+				LastGood:     &good,
+				Pending:      &bad,
+				PendingSince: &later,
+			},
+			current: chart(bad, "failed", &earlier),
+			desired: bad,
+			expectedState: State{
+				LastGood:     &good,
+				Pending:      &bad,
+				PendingSince: &later,
+			},
+			expectedRelease: bad,
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			state, release, rolledBack := Next(tc.state, tc.current, tc.desired, now)
+
+			if diff := cmp.Diff(tc.expectedState, state); diff != "" {
+				t.Fatalf("want matching state \n %s", diff)
+			}
+			if diff := cmp.Diff(tc.expectedRelease, release); diff != "" {
+				t.Fatalf("want matching release \n %s", diff)
+			}
+			if rolledBack != tc.expectedRolledBack {
+				t.Fatalf("rolledBack == %t, want %t", rolledBack, tc.expectedRolledBack)
+			}
+		})
+	}
+}
+
+func Test_AppStatus(t *testing.T) {
+	state := State{
+		LastGood: &Release{
+			Version: "1.0.0",
+		},
+		RolledBackFrom: &Release{
+			Version: "1.1.0",
+		},
+		Reason: "timed out waiting for the condition",
+	}
+	value, err := ToAnnotation(state)
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	tests := []struct {
+		name           string
+		releaseStatus  string
+		expectedStatus string
+	}{
+		{
+			name:           "case 0: deployed rollback is reported",
+			releaseStatus:  "deployed",
+			expectedStatus: status.RolledBackStatus,
+		},
+		{
+			name:           "case 1: pending rollback is not reported",
+			releaseStatus:  "pending-rollback",
+			expectedStatus: "pending-rollback",
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			chart := v1alpha1.Chart{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						StateAnnotation: value,
+					},
+				},
+			}
+			appStatus := v1alpha1.AppStatus{
+				Release: v1alpha1.AppStatusRelease{
+					Status: tc.releaseStatus,
+				},
+			}
+			chart.Status.Release.Status = tc.releaseStatus
+
+			result := AppStatus(chart, appStatus)
+			if result.Release.Status != tc.expectedStatus {
+				t.Fatalf("status == %#q, want %#q", result.Release.Status, tc.expectedStatus)
+			}
+		})
+	}
+}
@@ -13,6 +13,16 @@ const (
 	// has no entries or is nil.
 	CatalogEmptyStatus = "catalog-empty"
 
+	// CatalogUnreachableStatus is set in the CR status when the catalog's
+	// index.yaml cannot be fetched due to a network error.
+	CatalogUnreachableStatus = "catalog-unreachable"
+
+	// ClusterUnavailableStatus is used for errors caused by the workload
+	// cluster API not being available yet. These errors are retried. It is
+	// set in the CR status while the circuit breaker of the workload cluster
+	// is open.
+	ClusterUnavailableStatus = "cluster-unavailable"
+
 	// ConfigmapMergeFailedStatus is set in the CR status when there is an failure during
 	// merge configmaps.
 	ConfigmapMergeFailedStatus = "configmap-merge-failed"
@@ -24,26 +34,69 @@ const (
 	// CordonStatus is set in the CR status when an app has been successfully cordoned.
 	CordonStatus = "cordoned"
 
+	// ForbiddenStatus is set in the CR status when app-operator is not
+	// allowed to access a resource referenced by the app CR.
+	ForbiddenStatus = "forbidden"
+
 	// IndexNotFoundStatus is set in the CR status when the catalog's index.yaml
 	// has no entries or is nil.
 	IndexNotFoundStatus = "index-not-found"
 
+	// KubeConfigSourceInvalidStatus is set in the CR status when the
+	// kubeconfig source of the app CR is unknown, misses the cluster label
+	// or may not be selected in the namespace of the app CR.
+	KubeConfigSourceInvalidStatus = "kubeconfig-source-invalid"
+
+	// PendingMaintenanceWindowStatus is set in the CR status when changes of
+	// the app CR are deferred until its next maintenance window.
+	PendingMaintenanceWindowStatus = "pending-maintenance-window"
+
 	// ResourceNotFoundStatus is set in the CR status when there is an failure during
 	// finding dependents kubernete resources.
 	ResourceNotFoundStatus = "resource-not-found"
 
+	// RolledBackStatus is set in the CR status when a failed release was
+	// rolled back to the last good release by the auto-rollback policy.
+	RolledBackStatus = "rolled-back"
+
 	// SecretMergeFailedStatus is set in the CR status when there is an failure during
 	// merge secrets.
 	SecretMergeFailedStatus = "secret-merge-failed"
 
+	// TLSErrorStatus is set in the CR status when a TLS handshake or
+	// certificate verification fails, e.g. when pulling the catalog index.
+	TLSErrorStatus = "tls-error"
+
+	// ValuesSchemaInvalidStatus is set in the CR status when the merged values
+	// do not match the values.schema.json of the chart. The reason lists the
+	// JSON pointer of each violation.
+	ValuesSchemaInvalidStatus = "values-schema-invalid"
+
+	// ValuesTooLargeStatus is set in the CR status when the merged values
+	// exceed the size limit of configmaps and secrets, also after they were
+	// compressed.
+	ValuesTooLargeStatus = "values-too-large"
+
+	// ValidationFailedStatus is set in the CR status when the app CR spec
+	// is invalid.
+	ValidationFailedStatus = "validation-failed"
+
 	// UnknownError is set in the CR status when there is an failure during
 	// merge secrets.
 	UnknownError = "unknown-error"
 )
 
 var (
+	// FailedStatus holds the statuses set by resources running before the
+	// chart resource. When one of them is set the chart CR is not reconciled.
 	FailedStatus = map[string]bool{
-		ConfigmapMergeFailedStatus: true,
-		SecretMergeFailedStatus:    true,
+		AppVersionNotFoundStatus:      true,
+		ConfigmapMergeFailedStatus:    true,
+		ForbiddenStatus:               true,
+		KubeConfigSourceInvalidStatus: true,
+		ResourceNotFoundStatus:        true,
+		SecretMergeFailedStatus:       true,
+		TLSErrorStatus:                true,
+		ValuesTooLargeStatus:          true,
 	}
 )
@@ -0,0 +1,30 @@
+package valueref
+
+import "github.com/giantswarm/microerror"
+
+var invalidConfigError = &microerror.Error{
+	Kind: "invalidConfigError",
+}
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+var invalidRefError = &microerror.Error{
+	Kind: "invalidRefError",
+}
+
+// IsInvalidRef asserts invalidRefError.
+func IsInvalidRef(err error) bool {
+	return microerror.Cause(err) == invalidRefError
+}
+
+var notFoundError = &microerror.Error{
+	Kind: "notFoundError",
+}
+
+// IsNotFound asserts notFoundError.
+func IsNotFound(err error) bool {
+	return microerror.Cause(err) == notFoundError
+}
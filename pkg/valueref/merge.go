@@ -0,0 +1,107 @@
+package valueref
+
+import (
+	"github.com/giantswarm/microerror"
+	"github.com/imdario/mergo"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/giantswarm/app-operator/v7/pkg/valuesource"
+)
+
+// Merge merges the values of the reference layers into the values merged
+// from the source layers of the app CR. A reference is ordered like an extra
+// config with the same priority. Since the sources are already merged, every
+// source that would be merged after a reference is merged again on top of it
+// so it keeps precedence.
+func Merge(mergedData map[string]interface{}, refLayers []Layer, sourceLayers []valuesource.Layer) (map[string]interface{}, error) {
+	if len(refLayers) == 0 {
+		return mergedData, nil
+	}
+
+	if mergedData == nil {
+		mergedData = map[string]interface{}{}
+	}
+
+	for _, ref := range refLayers {
+		err := mergo.Merge(&mergedData, ref.Values, mergo.WithOverride)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		for _, source := range sourceLayers {
+			if !mergedAfter(source.Source, ref.Ref.Priority) {
+				continue
+			}
+
+			// Merging may reuse nested maps of the source so it is copied
+			// before it is merged again for the next reference.
+			err = mergo.Merge(&mergedData, runtime.DeepCopyJSON(source.Values), mergo.WithOverride)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+		}
+	}
+
+	return mergedData, nil
+}
+
+// mergedAfter returns true when the source is merged after a reference with
+// the given priority. Extra configs with the same priority as the catalog,
+// app or user values are merged before them, and so are references.
+func mergedAfter(source valuesource.Source, priority int) bool {
+	if source.Priority > priority {
+		return true
+	}
+
+	return source.Priority == priority && source.Kind != valuesource.KindExtraConfig
+}
+
+// Source returns the value source of the reference so it is listed with the
+// configmaps and secrets values are merged from.
+func (l Layer) Source() valuesource.Source {
+	s := valuesource.Source{
+		Type:            l.Ref.Type(),
+		Name:            l.Ref.Name,
+		Namespace:       l.Ref.Namespace,
+		ResourceVersion: l.ResourceVersion,
+		Priority:        l.Ref.Priority,
+	}
+
+	switch l.Ref.Kind {
+	case KindApp:
+		s.Kind = valuesource.KindAppRef
+	case KindSecretStore:
+		s.Kind = valuesource.KindSecretStore
+		s.Name = l.Ref.Path
+		s.Namespace = ""
+		s.Provider = l.Ref.Provider
+	default:
+		s.Kind = valuesource.KindObjectRef
+		s.APIVersion = l.Ref.APIVersion
+		s.ObjectKind = l.Ref.ObjectKind
+	}
+
+	return s
+}
+
+// Layers returns the source layers and the reference layers in the order
+// they take effect. A reference is placed before the first source merged
+// after it.
+func Layers(refLayers []Layer, sourceLayers []valuesource.Layer) []valuesource.Layer {
+	var layers []valuesource.Layer
+
+	i := 0
+	for _, ref := range refLayers {
+		for i < len(sourceLayers) && !mergedAfter(sourceLayers[i].Source, ref.Ref.Priority) {
+			layers = append(layers, sourceLayers[i])
+			i++
+		}
+
+		layers = append(layers, valuesource.Layer{
+			Source: ref.Source(),
+			Values: ref.Values,
+		})
+	}
+
+	return append(layers, sourceLayers[i:]...)
+}
@@ -0,0 +1,546 @@
+// Package valueref resolves values sourced from references other than
+// configmaps and secrets. The kind of extra configs is limited to configMap
+// and secret by the app CRD so these references are declared in the
+// Annotation of the app CR instead, e.g.
+//
+//	application.giantswarm.io/values-refs: |
+//	  - kind: object
+//	    apiVersion: cluster.x-k8s.io/v1beta1
+//	    objectKind: Cluster
+//	    name: demo
+//	    namespace: org-acme
+//	    jsonPath: "{.spec.clusterNetwork.pods.cidrBlocks[0]}"
+//	    target: cluster.podCIDR
+//	  - kind: app
+//	    name: demo-database
+//	    namespace: org-acme
+//	    priority: 60
+//	  - kind: secretStore
+//	    provider: vault
+//	    path: secret/data/org-acme/demo
+//	    target: database
+//
+// Values of object references are read from a field of any object in the
+// management cluster except core secrets. Object and app references may only
+// point to the namespace of the app CR unless the operator allows further
+// namespaces. Values of app references are the values generated for
+// another app CR, read from its chart configmap. Both are merged into the
+// configmap values. Values of secret store references are read from an
+// external secret store and merged into the secret values. Their path must
+// be below the namespace of the app CR, e.g. org-acme/demo, optionally
+// prefixed per provider, e.g. secret/data/org-acme/demo for vault.
+package valueref
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/app/v8/pkg/key"
+	"github.com/giantswarm/k8sclient/v7/pkg/k8sclient"
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/jsonpath"
+	"sigs.k8s.io/yaml"
+
+	"github.com/giantswarm/app-operator/v7/pkg/valuesencoding"
+	"github.com/giantswarm/app-operator/v7/pkg/valuesgeneration"
+)
+
+const (
+	// Annotation is set on app CRs to declare their value references.
+	Annotation = "application.giantswarm.io/values-refs"
+	// LatestVersionAnnotation is set on app CRs by the appvalue watcher when
+	// one of their references changed to trigger a reconciliation.
+	LatestVersionAnnotation = "app-operator.giantswarm.io/latest-values-ref-version"
+
+	// KindObject references a field of an object selected with JSONPath.
+	KindObject = "object"
+	// KindApp references the values generated for another app CR.
+	KindApp = "app"
+	// KindSecretStore references values in an external secret store.
+	KindSecretStore = "secretStore"
+
+	// TypeConfigMap and TypeSecret are the types of values references are
+	// merged into. They match the types of the valuesource package.
+	TypeConfigMap = "configMap"
+	TypeSecret    = "secret"
+)
+
+// SecretStore resolves secret store references.
+type SecretStore interface {
+	// Resolve returns the values stored at the path of the given provider
+	// and their version.
+	Resolve(ctx context.Context, provider, path string) (map[string]interface{}, string, error)
+}
+
+// Ref is a single value reference. Name and Namespace identify the object or
+// app CR that is referenced.
+type Ref struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	// Priority is used to order references with the sources of the app CR
+	// the same way as for extra configs. It defaults to 25.
+	Priority int `json:"priority,omitempty"`
+
+	// APIVersion and ObjectKind select the type of the referenced object.
+	// They are only used by object references.
+	APIVersion string `json:"apiVersion,omitempty"`
+	ObjectKind string `json:"objectKind,omitempty"`
+	// JSONPath selects the field of the referenced object, e.g.
+	// {.spec.clusterNetwork.pods.cidrBlocks[0]}.
+	JSONPath string `json:"jsonPath,omitempty"`
+	// Target is the dot separated path the value is set at, e.g.
+	// cluster.podCIDR. It may only be empty when the field is an object.
+	Target string `json:"target,omitempty"`
+
+	// Provider and Path select the values of secret store references.
+	Provider string `json:"provider,omitempty"`
+	Path     string `json:"path,omitempty"`
+}
+
+// Type returns the type of values the reference is merged into.
+func (r Ref) Type() string {
+	if r.Kind == KindSecretStore {
+		return TypeSecret
+	}
+
+	return TypeConfigMap
+}
+
+// Layer is a reference with the values it resolved to.
+type Layer struct {
+	Ref             Ref
+	ResourceVersion string
+	Values          map[string]interface{}
+}
+
+// Config represents the configuration used to create a new resolver.
+type Config struct {
+	// K8sClient is the management cluster client.
+	K8sClient k8sclient.Interface
+	// SecretStore is optional. Secret store references fail to resolve when
+	// it is nil.
+	SecretStore SecretStore
+
+	ChartNamespace string
+	// Namespaces are the namespaces object and app references may point to
+	// besides the namespace of the app CR.
+	Namespaces []string
+	// SecretStorePathPrefixes are the prefixes of the paths of secret store
+	// references by provider. Paths must be below the prefix followed by the
+	// namespace of the app CR. Providers without a prefix only allow paths
+	// below the namespace.
+	SecretStorePathPrefixes map[string]string
+}
+
+// Resolver resolves the value references of app CRs.
+type Resolver struct {
+	k8sClient   k8sclient.Interface
+	secretStore SecretStore
+
+	chartNamespace          string
+	namespaces              map[string]bool
+	secretStorePathPrefixes map[string]string
+}
+
+// New creates a new configured resolver.
+func New(config Config) (*Resolver, error) {
+	if config.K8sClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.K8sClient must not be empty", config)
+	}
+
+	if config.ChartNamespace == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.ChartNamespace must not be empty", config)
+	}
+
+	namespaces := map[string]bool{}
+	for _, n := range config.Namespaces {
+		namespaces[n] = true
+	}
+
+	r := &Resolver{
+		k8sClient:   config.K8sClient,
+		secretStore: config.SecretStore,
+
+		chartNamespace:          config.ChartNamespace,
+		namespaces:              namespaces,
+		secretStorePathPrefixes: config.SecretStorePathPrefixes,
+	}
+
+	return r, nil
+}
+
+// FromApp returns the references declared in the Annotation of the app CR
+// ordered by priority.
+func FromApp(app v1alpha1.App) ([]Ref, error) {
+	value, ok := app.GetAnnotations()[Annotation]
+	if !ok || strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	var refs []Ref
+	err := yaml.Unmarshal([]byte(value), &refs)
+	if err != nil {
+		return nil, microerror.Maskf(invalidRefError, "failed to parse annotation %#q: %s", Annotation, err)
+	}
+
+	for i, ref := range refs {
+		if ref.Namespace == "" {
+			refs[i].Namespace = app.Namespace
+		}
+		if ref.Priority == 0 {
+			refs[i].Priority = v1alpha1.ConfigPriorityDefault
+		}
+
+		err = validate(refs[i])
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	sort.SliceStable(refs, func(i, j int) bool {
+		return refs[i].Priority < refs[j].Priority
+	})
+
+	return refs, nil
+}
+
+// HasRefs returns true when the app CR declares value references merged
+// into values of the given type. Invalid references are reported as present
+// so they fail when they are resolved.
+func HasRefs(app v1alpha1.App, refType string) bool {
+	refs, err := FromApp(app)
+	if err != nil {
+		return true
+	}
+
+	for _, ref := range refs {
+		if ref.Type() == refType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Layers resolves the references of the app CR merged into values of the
+// given type in priority order. The clusterClient is the client of the
+// cluster the app is deployed to where the chart configmaps of referenced
+// apps are located.
+func (r *Resolver) Layers(ctx context.Context, app v1alpha1.App, clusterClient kubernetes.Interface, refType string) ([]Layer, error) {
+	refs, err := FromApp(app)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var layers []Layer
+	for _, ref := range refs {
+		if ref.Type() != refType {
+			continue
+		}
+
+		err = r.checkNamespace(app, ref)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		var layer Layer
+		switch ref.Kind {
+		case KindApp:
+			layer, err = r.appLayer(ctx, app, ref, clusterClient)
+		case KindSecretStore:
+			layer, err = r.secretStoreLayer(ctx, app, ref)
+		default:
+			layer, err = r.objectLayer(ctx, ref)
+		}
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		layers = append(layers, layer)
+	}
+
+	return layers, nil
+}
+
+// checkNamespace rejects object and app references pointing to namespaces
+// other than the namespace of the app CR which are not allowed by the
+// operator. Otherwise tenants could read any object app-operator has access
+// to.
+func (r *Resolver) checkNamespace(app v1alpha1.App, ref Ref) error {
+	if ref.Kind == KindSecretStore || ref.Namespace == app.Namespace || r.namespaces[ref.Namespace] {
+		return nil
+	}
+
+	return microerror.Maskf(invalidRefError, "%s reference %#q must be in namespace %#q of the app CR but is in namespace %#q", ref.Kind, ref.Name, app.Namespace, ref.Namespace)
+}
+
+func (r *Resolver) appLayer(ctx context.Context, app v1alpha1.App, ref Ref, clusterClient kubernetes.Interface) (Layer, error) {
+	var referenced v1alpha1.App
+	err := r.k8sClient.CtrlClient().Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: ref.Namespace}, &referenced)
+	if apierrors.IsNotFound(err) {
+		return Layer{}, microerror.Maskf(notFoundError, "app %#q in namespace %#q not found", ref.Name, ref.Namespace)
+	} else if err != nil {
+		return Layer{}, microerror.Mask(err)
+	}
+
+	// The chart configmap is read with the client of the cluster the app is
+	// deployed to so both apps must be deployed to the same cluster.
+	if key.InCluster(referenced) != key.InCluster(app) || key.KubeConfigSecretName(referenced) != key.KubeConfigSecretName(app) {
+		return Layer{}, microerror.Maskf(invalidRefError, "app %#q in namespace %#q is deployed to a different cluster", ref.Name, ref.Namespace)
+	}
+
+	configMap, err := r.chartConfigMap(ctx, clusterClient, referenced)
+	if err != nil {
+		return Layer{}, microerror.Mask(err)
+	}
+	configMapName := configMap.Name
+
+	data, err := valuesencoding.Decode(configMap.Annotations, []byte(configMap.Data["values"]))
+	if err != nil {
+		return Layer{}, microerror.Maskf(invalidRefError, "failed to decode configmap %#q: %s", configMapName, err)
+	}
+
+	values := map[string]interface{}{}
+	err = yaml.Unmarshal(data, &values)
+	if err != nil {
+		return Layer{}, microerror.Maskf(invalidRefError, "failed to parse configmap %#q: %s", configMapName, err)
+	}
+
+	layer := Layer{
+		Ref:             ref,
+		ResourceVersion: configMap.ResourceVersion,
+		Values:          values,
+	}
+
+	return layer, nil
+}
+
+// chartConfigMap returns the chart configmap of the app. When immutable
+// generations are enabled the newest generation is returned.
+func (r *Resolver) chartConfigMap(ctx context.Context, clusterClient kubernetes.Interface, app v1alpha1.App) (*corev1.ConfigMap, error) {
+	configMapName := key.ChartConfigMapName(app)
+	configMap, err := clusterClient.CoreV1().ConfigMaps(r.chartNamespace).Get(ctx, configMapName, metav1.GetOptions{})
+	if err == nil {
+		return configMap, nil
+	} else if !apierrors.IsNotFound(err) {
+		return nil, microerror.Mask(err)
+	}
+
+	list, err := clusterClient.CoreV1().ConfigMaps(r.chartNamespace).List(ctx, metav1.ListOptions{
+		LabelSelector: valuesgeneration.Selector(app.Name),
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	generations := make([]metav1.ObjectMeta, 0, len(list.Items))
+	for _, cm := range list.Items {
+		generations = append(generations, cm.ObjectMeta)
+	}
+
+	latest := valuesgeneration.Latest(generations)
+	for i := range list.Items {
+		if list.Items[i].Name == latest {
+			return &list.Items[i], nil
+		}
+	}
+
+	return nil, microerror.Maskf(notFoundError, "configmap %#q of app %#q in namespace %#q not found", configMapName, app.Name, app.Namespace)
+}
+
+func (r *Resolver) objectLayer(ctx context.Context, ref Ref) (Layer, error) {
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return Layer{}, microerror.Maskf(invalidRefError, "invalid apiVersion %#q: %s", ref.APIVersion, err)
+	}
+
+	mapping, err := r.k8sClient.CtrlClient().RESTMapper().RESTMapping(gv.WithKind(ref.ObjectKind).GroupKind(), gv.Version)
+	if err != nil {
+		return Layer{}, microerror.Maskf(invalidRefError, "unknown kind %#q in %#q: %s", ref.ObjectKind, ref.APIVersion, err)
+	}
+	if mapping.Resource.Group == "" && mapping.Resource.Resource == "secrets" {
+		return Layer{}, microerror.Maskf(invalidRefError, "object reference %#q must not reference a secret, use a secret extra config instead", ref.Name)
+	}
+
+	obj, err := r.k8sClient.DynClient().Resource(mapping.Resource).Namespace(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return Layer{}, microerror.Maskf(notFoundError, "%s %#q in namespace %#q not found", ref.ObjectKind, ref.Name, ref.Namespace)
+	} else if err != nil {
+		return Layer{}, microerror.Mask(err)
+	}
+
+	value, err := Field(obj.Object, ref.JSONPath)
+	if IsNotFound(err) {
+		return Layer{}, microerror.Maskf(notFoundError, "%s %#q in namespace %#q: %s", ref.ObjectKind, ref.Name, ref.Namespace, err)
+	} else if err != nil {
+		return Layer{}, microerror.Mask(err)
+	}
+
+	values, err := nest(value, ref.Target)
+	if err != nil {
+		return Layer{}, microerror.Mask(err)
+	}
+
+	layer := Layer{
+		Ref:             ref,
+		ResourceVersion: obj.GetResourceVersion(),
+		Values:          values,
+	}
+
+	return layer, nil
+}
+
+func (r *Resolver) secretStoreLayer(ctx context.Context, app v1alpha1.App, ref Ref) (Layer, error) {
+	if r.secretStore == nil {
+		return Layer{}, microerror.Maskf(invalidRefError, "secret store references are not supported since no secret store provider is configured")
+	}
+
+	err := r.checkPath(app, ref)
+	if err != nil {
+		return Layer{}, microerror.Mask(err)
+	}
+
+	value, version, err := r.secretStore.Resolve(ctx, ref.Provider, ref.Path)
+	if err != nil {
+		return Layer{}, microerror.Mask(err)
+	}
+
+	values, err := nest(value, ref.Target)
+	if err != nil {
+		return Layer{}, microerror.Mask(err)
+	}
+
+	layer := Layer{
+		Ref:             ref,
+		ResourceVersion: version,
+		Values:          values,
+	}
+
+	return layer, nil
+}
+
+func (r *Resolver) checkPath(app v1alpha1.App, ref Ref) error {
+	return CheckPath(r.secretStorePathPrefixes, app, ref)
+}
+
+// CheckPath rejects secret store references to paths outside the scope of
+// the namespace of the app CR so tenants cannot read the secrets of other
+// organizations. The scope is the path prefix configured for the provider of
+// the reference joined with the namespace.
+func CheckPath(prefixes map[string]string, app v1alpha1.App, ref Ref) error {
+	scope := path.Join(prefixes[ref.Provider], app.Namespace) + "/"
+	p := strings.TrimLeft(ref.Path, "/")
+	if path.Clean(p) == p && strings.HasPrefix(p, scope) {
+		return nil
+	}
+
+	return microerror.Maskf(invalidRefError, "path %#q of secret store reference must be below %#q", ref.Path, scope)
+}
+
+// Field returns the field of the object selected by the JSONPath
+// expression. The braces around the expression are optional.
+func Field(obj map[string]interface{}, expression string) (interface{}, error) {
+	if !strings.HasPrefix(expression, "{") {
+		expression = fmt.Sprintf("{%s}", expression)
+	}
+
+	j := jsonpath.New("valueref")
+	err := j.Parse(expression)
+	if err != nil {
+		return nil, microerror.Maskf(invalidRefError, "invalid jsonPath %#q: %s", expression, err)
+	}
+
+	results, err := j.FindResults(obj)
+	if err != nil {
+		return nil, microerror.Maskf(notFoundError, "jsonPath %#q: %s", expression, err)
+	}
+	if len(results) == 0 || len(results[0]) == 0 {
+		return nil, microerror.Maskf(notFoundError, "jsonPath %#q matched nothing", expression)
+	}
+
+	return results[0][0].Interface(), nil
+}
+
+// nest returns the value set at the dot separated target path.
+func nest(value interface{}, target string) (map[string]interface{}, error) {
+	if target == "" {
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, microerror.Maskf(invalidRefError, "target must be set when the referenced field is not an object")
+		}
+
+		return values, nil
+	}
+
+	keys := strings.Split(target, ".")
+	values := map[string]interface{}{}
+	current := values
+	for _, k := range keys[:len(keys)-1] {
+		next := map[string]interface{}{}
+		current[k] = next
+		current = next
+	}
+	current[keys[len(keys)-1]] = value
+
+	return values, nil
+}
+
+func validate(ref Ref) error {
+	if ref.Name == "" && ref.Kind != KindSecretStore {
+		return microerror.Maskf(invalidRefError, "name of %s reference must not be empty", ref.Kind)
+	}
+
+	switch ref.Kind {
+	case KindApp:
+	case KindSecretStore:
+		if ref.Provider == "" || ref.Path == "" {
+			return microerror.Maskf(invalidRefError, "provider and path of secret store reference must not be empty")
+		}
+	case KindObject:
+		if ref.APIVersion == "" || ref.ObjectKind == "" {
+			return microerror.Maskf(invalidRefError, "apiVersion and objectKind of object reference %#q must not be empty", ref.Name)
+		}
+		if ref.JSONPath == "" {
+			return microerror.Maskf(invalidRefError, "jsonPath of object reference %#q must not be empty", ref.Name)
+		}
+		if isCoreSecret(ref) {
+			return microerror.Maskf(invalidRefError, "object reference %#q must not reference a secret, use a secret extra config instead", ref.Name)
+		}
+	default:
+		return microerror.Maskf(invalidRefError, "kind must be %#q, %#q or %#q but got %#q", KindApp, KindObject, KindSecretStore, ref.Kind)
+	}
+
+	for _, k := range strings.Split(ref.Target, ".") {
+		if ref.Target != "" && k == "" {
+			return microerror.Maskf(invalidRefError, "invalid target %#q of reference %#q", ref.Target, ref.Name)
+		}
+	}
+
+	if ref.Priority < 0 || ref.Priority > v1alpha1.ConfigPriorityMaximum {
+		return microerror.Maskf(invalidRefError, "priority of reference %#q must be between 1 and %d", ref.Name, v1alpha1.ConfigPriorityMaximum)
+	}
+
+	return nil
+}
+
+// isCoreSecret returns true when the object reference selects a core secret.
+// Their values would end up in the chart configmap.
+func isCoreSecret(ref Ref) bool {
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return false
+	}
+
+	return gv.Group == "" && strings.EqualFold(ref.ObjectKind, "Secret")
+}
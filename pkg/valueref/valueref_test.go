@@ -0,0 +1,387 @@
+package valueref
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/k8sclient/v7/pkg/k8sclienttest"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgofake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake" //nolint:staticcheck
+)
+
+func Test_FromApp(t *testing.T) {
+	tests := []struct {
+		name         string
+		annotation   string
+		expectedRefs []Ref
+		errorMatcher func(error) bool
+	}{
+		{
+			name:         "case 0: no annotation",
+			expectedRefs: nil,
+		},
+		{
+			name: "case 1: refs are defaulted and ordered by priority",
+			annotation: `
+- kind: object
+  apiVersion: cluster.x-k8s.io/v1beta1
+  objectKind: Cluster
+  name: demo
+  jsonPath: "{.spec.clusterNetwork.pods.cidrBlocks[0]}"
+  target: cluster.podCIDR
+- kind: app
+  name: demo-database
+  namespace: org-other
+  priority: 10
+`,
+			expectedRefs: []Ref{
+				{
+					Kind:      KindApp,
+					Name:      "demo-database",
+					Namespace: "org-other",
+					Priority:  10,
+				},
+				{
+					Kind:       KindObject,
+					Name:       "demo",
+					Namespace:  "org-acme",
+					Priority:   v1alpha1.ConfigPriorityDefault,
+					APIVersion: "cluster.x-k8s.io/v1beta1",
+					ObjectKind: "Cluster",
+					JSONPath:   "{.spec.clusterNetwork.pods.cidrBlocks[0]}",
+					Target:     "cluster.podCIDR",
+				},
+			},
+		},
+		{
+			name:         "case 2: unknown kind",
+			annotation:   "- kind: url\n  name: demo\n",
+			errorMatcher: IsInvalidRef,
+		},
+		{
+			name:         "case 3: object ref without jsonPath",
+			annotation:   "- kind: object\n  apiVersion: v1\n  objectKind: Service\n  name: demo\n",
+			errorMatcher: IsInvalidRef,
+		},
+		{
+			name:         "case 4: invalid YAML",
+			annotation:   "kind: app",
+			errorMatcher: IsInvalidRef,
+		},
+		{
+			name:         "case 5: object ref to a secret",
+			annotation:   "- kind: object\n  apiVersion: v1\n  objectKind: Secret\n  name: demo\n  jsonPath: .data\n",
+			errorMatcher: IsInvalidRef,
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			app := v1alpha1.App{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "demo-app",
+					Namespace: "org-acme",
+				},
+			}
+			if tc.annotation != "" {
+				app.Annotations = map[string]string{
+					Annotation: tc.annotation,
+				}
+			}
+
+			refs, err := FromApp(app)
+			switch {
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case err != nil && !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if !reflect.DeepEqual(refs, tc.expectedRefs) {
+				t.Fatalf("want matching refs \n %s", cmp.Diff(refs, tc.expectedRefs))
+			}
+		})
+	}
+}
+
+func Test_Resolver_Layers(t *testing.T) {
+	tests := []struct {
+		name           string
+		annotation     string
+		namespaces     []string
+		expectedValues []map[string]interface{}
+		errorMatcher   func(error) bool
+	}{
+		{
+			name:       "case 0: app ref in the namespace of the app CR",
+			annotation: "- kind: app\n  name: demo-cache\n",
+			expectedValues: []map[string]interface{}{
+				{"demo-cache": "org-acme"},
+			},
+		},
+		{
+			name:         "case 1: app ref in another namespace",
+			annotation:   "- kind: app\n  name: demo-database\n  namespace: org-other\n",
+			errorMatcher: IsInvalidRef,
+		},
+		{
+			name:         "case 2: object ref in another namespace",
+			annotation:   "- kind: object\n  apiVersion: cluster.x-k8s.io/v1beta1\n  objectKind: Cluster\n  name: demo\n  namespace: org-other\n  jsonPath: .spec\n",
+			errorMatcher: IsInvalidRef,
+		},
+		{
+			name:       "case 3: app ref in an allowed namespace",
+			annotation: "- kind: app\n  name: demo-database\n  namespace: org-other\n",
+			namespaces: []string{"org-other"},
+			expectedValues: []map[string]interface{}{
+				{"demo-database": "org-other"},
+			},
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			s := runtime.NewScheme()
+			err := v1alpha1.AddToScheme(s)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			var objs []runtime.Object
+			var configMaps []runtime.Object
+			for _, ref := range []struct{ name, namespace string }{{"demo-cache", "org-acme"}, {"demo-database", "org-other"}} {
+				objs = append(objs, &v1alpha1.App{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      ref.name,
+						Namespace: ref.namespace,
+					},
+					Spec: v1alpha1.AppSpec{
+						KubeConfig: v1alpha1.AppSpecKubeConfig{
+							InCluster: true,
+						},
+					},
+				})
+				configMaps = append(configMaps, &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      ref.name + "-chart-values",
+						Namespace: "giantswarm",
+					},
+					Data: map[string]string{
+						"values": ref.name + ": " + ref.namespace + "\n",
+					},
+				})
+			}
+
+			clients := k8sclienttest.NewClients(k8sclienttest.ClientsConfig{
+				CtrlClient: fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(objs...).Build(),
+				K8sClient:  clientgofake.NewSimpleClientset(configMaps...),
+			})
+
+			c := Config{
+				K8sClient: clients,
+
+				ChartNamespace: "giantswarm",
+				Namespaces:     tc.namespaces,
+			}
+			r, err := New(c)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			app := v1alpha1.App{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "demo-app",
+					Namespace: "org-acme",
+					Annotations: map[string]string{
+						Annotation: tc.annotation,
+					},
+				},
+				Spec: v1alpha1.AppSpec{
+					KubeConfig: v1alpha1.AppSpecKubeConfig{
+						InCluster: true,
+					},
+				},
+			}
+
+			layers, err := r.Layers(context.Background(), app, clients.K8sClient(), TypeConfigMap)
+			switch {
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case err != nil && !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			var values []map[string]interface{}
+			for _, l := range layers {
+				values = append(values, l.Values)
+			}
+			if !reflect.DeepEqual(values, tc.expectedValues) {
+				t.Fatalf("want matching values \n %s", cmp.Diff(values, tc.expectedValues))
+			}
+		})
+	}
+}
+
+func Test_Resolver_checkPath(t *testing.T) {
+	tests := []struct {
+		name         string
+		provider     string
+		path         string
+		errorMatcher func(error) bool
+	}{
+		{
+			name:     "case 0: vault path below the prefix and namespace",
+			provider: "vault",
+			path:     "secret/data/org-acme/demo",
+		},
+		{
+			name:         "case 1: vault path of another namespace",
+			provider:     "vault",
+			path:         "secret/data/org-other/demo",
+			errorMatcher: IsInvalidRef,
+		},
+		{
+			name:         "case 2: vault path escaping the namespace",
+			provider:     "vault",
+			path:         "secret/data/org-acme/../org-other/demo",
+			errorMatcher: IsInvalidRef,
+		},
+		{
+			name:         "case 3: vault path without the prefix",
+			provider:     "vault",
+			path:         "org-acme/demo",
+			errorMatcher: IsInvalidRef,
+		},
+		{
+			name:     "case 4: file path below the namespace",
+			provider: "file",
+			path:     "org-acme/demo",
+		},
+		{
+			name:         "case 5: namespace itself",
+			provider:     "file",
+			path:         "org-acme",
+			errorMatcher: IsInvalidRef,
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			r := &Resolver{
+				secretStorePathPrefixes: map[string]string{
+					"vault": "secret/data",
+				},
+			}
+
+			app := v1alpha1.App{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "demo-app",
+					Namespace: "org-acme",
+				},
+			}
+			ref := Ref{
+				Kind:     KindSecretStore,
+				Provider: tc.provider,
+				Path:     tc.path,
+			}
+
+			err := r.checkPath(app, ref)
+			switch {
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case err != nil && !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+		})
+	}
+}
+
+func Test_Field(t *testing.T) {
+	obj := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"clusterNetwork": map[string]interface{}{
+				"pods": map[string]interface{}{
+					"cidrBlocks": []interface{}{"10.2.0.0/16"},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name           string
+		jsonPath       string
+		target         string
+		expectedValues map[string]interface{}
+		errorMatcher   func(error) bool
+	}{
+		{
+			name:     "case 0: scalar field set at target",
+			jsonPath: "{.spec.clusterNetwork.pods.cidrBlocks[0]}",
+			target:   "cluster.podCIDR",
+			expectedValues: map[string]interface{}{
+				"cluster": map[string]interface{}{
+					"podCIDR": "10.2.0.0/16",
+				},
+			},
+		},
+		{
+			name:     "case 1: object field merged at the root without braces",
+			jsonPath: ".spec.clusterNetwork",
+			expectedValues: map[string]interface{}{
+				"pods": map[string]interface{}{
+					"cidrBlocks": []interface{}{"10.2.0.0/16"},
+				},
+			},
+		},
+		{
+			name:         "case 2: missing field",
+			jsonPath:     "{.spec.controlPlaneEndpoint.host}",
+			target:       "host",
+			errorMatcher: IsNotFound,
+		},
+		{
+			name:         "case 3: scalar field without target",
+			jsonPath:     "{.spec.clusterNetwork.pods.cidrBlocks[0]}",
+			errorMatcher: IsInvalidRef,
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			var values map[string]interface{}
+			value, err := Field(obj, tc.jsonPath)
+			if err == nil {
+				values, err = nest(value, tc.target)
+			}
+			switch {
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case err != nil && !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if !reflect.DeepEqual(values, tc.expectedValues) {
+				t.Fatalf("want matching values \n %s", cmp.Diff(values, tc.expectedValues))
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+package valuesencoding
+
+import "github.com/giantswarm/microerror"
+
+var unknownEncodingError = &microerror.Error{
+	Kind: "unknownEncodingError",
+}
+
+// IsUnknownEncoding asserts unknownEncodingError.
+func IsUnknownEncoding(err error) bool {
+	return microerror.Cause(err) == unknownEncodingError
+}
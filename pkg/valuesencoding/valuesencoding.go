@@ -0,0 +1,79 @@
+// Package valuesencoding encodes the values of the configmaps and secrets
+// generated for app CRs when they are too large to be stored as they are.
+// Encoded values are gzipped and base64 encoded. The generated object and the
+// chart CR are annotated with the encoding so chart-operator and other
+// readers decode the values before they use them.
+package valuesencoding
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io"
+
+	"github.com/giantswarm/microerror"
+)
+
+const (
+	// Annotation is set on generated configmaps and secrets with encoded
+	// values.
+	Annotation = "app-operator.giantswarm.io/values-encoding"
+	// ChartConfigMapAnnotation and ChartSecretAnnotation are set on chart
+	// CRs pointed at configmaps and secrets with encoded values.
+	ChartConfigMapAnnotation = "chart-operator.giantswarm.io/configmap-values-encoding"
+	ChartSecretAnnotation    = "chart-operator.giantswarm.io/secret-values-encoding"
+
+	// Gzip is the encoding of gzipped and base64 encoded values.
+	Gzip = "gzip+base64"
+)
+
+// Encode gzips and base64 encodes the values.
+func Encode(values []byte) ([]byte, error) {
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+
+	_, err := w.Write(values)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	encoded := make([]byte, base64.StdEncoding.EncodedLen(b.Len()))
+	base64.StdEncoding.Encode(encoded, b.Bytes())
+
+	return encoded, nil
+}
+
+// Decode returns the values of a generated object with the given
+// annotations. Values without Annotation are returned as they are.
+func Decode(annotations map[string]string, values []byte) ([]byte, error) {
+	switch encoding := annotations[Annotation]; encoding {
+	case "":
+		return values, nil
+	case Gzip:
+	default:
+		return nil, microerror.Maskf(unknownEncodingError, "values encoding %#q is not supported", encoding)
+	}
+
+	compressed := make([]byte, base64.StdEncoding.DecodedLen(len(values)))
+	n, err := base64.StdEncoding.Decode(compressed, values)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(compressed[:n]))
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	defer func() { _ = r.Close() }()
+
+	decoded, err := io.ReadAll(r)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return decoded, nil
+}
@@ -0,0 +1,156 @@
+// Package valuesgeneration names and prunes immutable generations of the
+// configmaps and secrets generated for app CRs. Every change of the merged
+// values creates a new generation named after the hash of its content, e.g.
+// hello-world-chart-values-1f2e3d4c5b, instead of updating
+// hello-world-chart-values in place. The chart CR is pointed at the new
+// generation so older ones can be used to roll back.
+//
+// Generations are numbered in NumberAnnotation in the order they were
+// applied. A generation applied again, e.g. when values are reverted, gets
+// the next number so it is the latest again.
+//
+// A generation is rolled back to by setting PinnedConfigMapAnnotation or
+// PinnedSecretAnnotation of the app CR to its name.
+package valuesgeneration
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strconv"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// Label is set on every generation. Its value is the app CR name so the
+	// generations of an app can be listed.
+	Label = "app-operator.giantswarm.io/values-generation-of"
+	// NumberAnnotation holds the number of the generation. The latest
+	// generation has the highest number.
+	NumberAnnotation = "app-operator.giantswarm.io/values-generation-number"
+
+	// PinnedConfigMapAnnotation and PinnedSecretAnnotation of app CRs hold
+	// the name of the generation the chart CR is pointed at instead of the
+	// latest one.
+	PinnedConfigMapAnnotation = "application.giantswarm.io/pinned-values-configmap"
+	PinnedSecretAnnotation    = "application.giantswarm.io/pinned-values-secret"
+
+	hashLength = 10
+	// maxLabelValueLength is the maximum length of label values.
+	maxLabelValueLength = 63
+)
+
+// Name returns the name of the generation with the given content.
+func Name(base string, content []byte) string {
+	return fmt.Sprintf("%s-%s", base, hash(content))
+}
+
+// LabelValue returns the value of Label for the given app CR name. Names
+// exceeding the maximum length of label values are hashed.
+func LabelValue(appName string) string {
+	if len(appName) <= maxLabelValueLength {
+		return appName
+	}
+
+	return hash([]byte(appName))
+}
+
+// Selector returns the label selector matching the generations of the app
+// CR.
+func Selector(appName string) string {
+	return fmt.Sprintf("%s=%s", Label, LabelValue(appName))
+}
+
+// Number returns the number of the generation. It is zero when the
+// generation is not numbered.
+func Number(generation metav1.ObjectMeta) int {
+	n, err := strconv.Atoi(generation.Annotations[NumberAnnotation])
+	if err != nil {
+		return 0
+	}
+
+	return n
+}
+
+// SetNumber sets the number of the generation.
+func SetNumber(generation *metav1.ObjectMeta, n int) {
+	if generation.Annotations == nil {
+		generation.Annotations = map[string]string{}
+	}
+
+	generation.Annotations[NumberAnnotation] = strconv.Itoa(n)
+}
+
+// Next returns the number of the next generation.
+func Next(generations []metav1.ObjectMeta) int {
+	next := 1
+	for _, g := range generations {
+		if n := Number(g); n >= next {
+			next = n + 1
+		}
+	}
+
+	return next
+}
+
+// Latest returns the name of the newest generation. It is empty when there
+// are no generations.
+func Latest(generations []metav1.ObjectMeta) string {
+	sorted := newestFirst(generations)
+	if len(sorted) == 0 {
+		return ""
+	}
+
+	return sorted[0].Name
+}
+
+// Prune returns the names of the generations to delete. The retention newest
+// generations are kept and so are the generations named in keep, e.g. the
+// generation the chart CR is pointed at.
+func Prune(generations []metav1.ObjectMeta, retention int, keep ...string) []string {
+	kept := map[string]bool{}
+	for _, name := range keep {
+		if name != "" {
+			kept[name] = true
+		}
+	}
+
+	var names []string
+	for i, g := range newestFirst(generations) {
+		if i < retention || kept[g.Name] {
+			continue
+		}
+
+		names = append(names, g.Name)
+	}
+
+	return names
+}
+
+// newestFirst orders the generations by number. Generations which are not
+// numbered, e.g. created by previous versions, are ordered by creation time.
+// Timestamps only have a precision of seconds and are not updated when a
+// generation is applied again so they are not used otherwise.
+func newestFirst(generations []metav1.ObjectMeta) []metav1.ObjectMeta {
+	sorted := make([]metav1.ObjectMeta, len(generations))
+	copy(sorted, generations)
+	sort.Slice(sorted, func(i, j int) bool {
+		if a, b := Number(sorted[i]), Number(sorted[j]); a != b {
+			return a > b
+		}
+
+		a, b := sorted[i].CreationTimestamp, sorted[j].CreationTimestamp
+		if !a.Equal(&b) {
+			return b.Before(&a)
+		}
+
+		return sorted[i].Name > sorted[j].Name
+	})
+
+	return sorted
+}
+
+func hash(content []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(content))[:hashLength]
+}
@@ -0,0 +1,91 @@
+package valuesgeneration
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_Prune(t *testing.T) {
+	now := time.Now()
+	generations := []metav1.ObjectMeta{
+		{Name: "a", CreationTimestamp: metav1.NewTime(now.Add(-3 * time.Hour))},
+		{Name: "b", CreationTimestamp: metav1.NewTime(now.Add(-2 * time.Hour))},
+		{Name: "c", CreationTimestamp: metav1.NewTime(now.Add(-1 * time.Hour))},
+		{Name: "d", CreationTimestamp: metav1.NewTime(now)},
+	}
+
+	tests := []struct {
+		name          string
+		retention     int
+		keep          []string
+		expectedNames []string
+	}{
+		{
+			name:          "case 0: oldest generations are pruned",
+			retention:     2,
+			expectedNames: []string{"b", "a"},
+		},
+		{
+			name:          "case 1: kept generations are not pruned",
+			retention:     2,
+			keep:          []string{"a"},
+			expectedNames: []string{"b"},
+		},
+		{
+			name:      "case 2: nothing is pruned within retention",
+			retention: 5,
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			names := Prune(generations, tc.retention, tc.keep...)
+			if !reflect.DeepEqual(names, tc.expectedNames) {
+				t.Fatalf("names == %#v, want %#v", names, tc.expectedNames)
+			}
+		})
+	}
+}
+
+func Test_Latest(t *testing.T) {
+	now := time.Now()
+	generations := []metav1.ObjectMeta{
+		{Name: "a", CreationTimestamp: metav1.NewTime(now.Add(-2 * time.Hour))},
+		{Name: "b", CreationTimestamp: metav1.NewTime(now.Add(-1 * time.Hour))},
+	}
+	SetNumber(&generations[0], 3)
+	SetNumber(&generations[1], 2)
+
+	if latest := Latest(generations); latest != "a" {
+		t.Fatalf("latest == %#q, want %#q", latest, "a")
+	}
+	if next := Next(generations); next != 4 {
+		t.Fatalf("next == %d, want %d", next, 4)
+	}
+	if names := Prune(generations, 1); !reflect.DeepEqual(names, []string{"b"}) {
+		t.Fatalf("names == %#v, want %#v", names, []string{"b"})
+	}
+}
+
+func Test_Name(t *testing.T) {
+	a := Name("hello-world-chart-values", []byte("replicas: 1\n"))
+	b := Name("hello-world-chart-values", []byte("replicas: 2\n"))
+
+	if a == b {
+		t.Fatalf("names of different content must differ")
+	}
+	if a != Name("hello-world-chart-values", []byte("replicas: 1\n")) {
+		t.Fatalf("names of equal content must be equal")
+	}
+	if !strings.HasPrefix(a, "hello-world-chart-values-") {
+		t.Fatalf("name %#q must have the base name as prefix", a)
+	}
+	if len(LabelValue(strings.Repeat("a", 100))) > maxLabelValueLength {
+		t.Fatalf("label value must not exceed %d characters", maxLabelValueLength)
+	}
+}
@@ -0,0 +1,97 @@
+package valuesource
+
+import (
+	"encoding/json"
+
+	"github.com/giantswarm/microerror"
+)
+
+// ToAnnotation encodes the given sources as the value of Annotation.
+func ToAnnotation(sources []Source) (string, error) {
+	if sources == nil {
+		sources = []Source{}
+	}
+
+	bytes, err := json.Marshal(sources)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	return string(bytes), nil
+}
+
+// FromAnnotation decodes the sources from the annotations of a generated
+// configmap or secret. Objects without Annotation have no sources.
+func FromAnnotation(annotations map[string]string) ([]Source, error) {
+	value, ok := annotations[Annotation]
+	if !ok {
+		return nil, nil
+	}
+
+	var sources []Source
+	err := json.Unmarshal([]byte(value), &sources)
+	if err != nil {
+		return nil, microerror.Maskf(parsingError, "failed to parse annotation %#q: %s", Annotation, err)
+	}
+
+	return sources, nil
+}
+
+// WithoutAnnotation returns a copy of the given annotations without
+// Annotation. The resource versions of the sources change whenever a source
+// is written, even when the merged values stay the same, so generated
+// configmaps and secrets are compared without it.
+func WithoutAnnotation(annotations map[string]string) map[string]string {
+	if annotations == nil {
+		return nil
+	}
+
+	result := make(map[string]string, len(annotations))
+	for k, v := range annotations {
+		if k == Annotation {
+			continue
+		}
+		result[k] = v
+	}
+
+	return result
+}
+
+// IsFresh returns true when the given sources include the configmap or
+// secret with the given resource version. It is used to check whether
+// generated values already reflect a change to one of their sources. Value
+// references are checked with IsRefFresh.
+func IsFresh(sources []Source, sourceType, name, namespace, resourceVersion string) bool {
+	for _, s := range sources {
+		if isRef(s) {
+			continue
+		}
+		if s.Type == sourceType && s.Name == name && s.Namespace == namespace {
+			return s.ResourceVersion == resourceVersion
+		}
+	}
+
+	return false
+}
+
+// IsRefFresh returns true when the given sources include the value reference
+// with the resource version of ref. For secret store references the
+// resource version is the version of the resolved values.
+func IsRefFresh(sources []Source, ref Source) bool {
+	for _, s := range sources {
+		if s.Kind != ref.Kind || s.Name != ref.Name || s.Namespace != ref.Namespace {
+			continue
+		}
+		if s.APIVersion != ref.APIVersion || s.ObjectKind != ref.ObjectKind || s.Provider != ref.Provider {
+			continue
+		}
+
+		return s.ResourceVersion != "" && s.ResourceVersion == ref.ResourceVersion
+	}
+
+	return false
+}
+
+func isRef(s Source) bool {
+	return s.Kind == KindObjectRef || s.Kind == KindAppRef || s.Kind == KindSecretStore
+}
@@ -0,0 +1,103 @@
+package valuesource
+
+import (
+	"sort"
+	"strings"
+)
+
+// RedactedValue replaces secret values in rendered output.
+const RedactedValue = "<redacted>"
+
+// Provenance returns the source each merged value came from keyed by the JSON
+// pointer of the value, e.g. /image/tag. Maps are merged recursively while
+// any other value, including lists, replaces the value of earlier layers.
+func Provenance(layers []Layer) map[string]Source {
+	provenance := map[string]Source{}
+
+	for _, l := range layers {
+		setProvenance(provenance, "", l.Values, l.Source)
+	}
+
+	return provenance
+}
+
+// Paths returns the JSON pointers of the given provenance sorted.
+func Paths(provenance map[string]Source) []string {
+	var paths []string
+	for p := range provenance {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	return paths
+}
+
+// Redact returns a copy of the given values with all leaf values replaced so
+// only the structure of secret values is shown.
+func Redact(values map[string]interface{}) map[string]interface{} {
+	if values == nil {
+		return nil
+	}
+
+	redacted := map[string]interface{}{}
+	for k, v := range values {
+		if m, ok := v.(map[string]interface{}); ok {
+			redacted[k] = Redact(m)
+		} else {
+			redacted[k] = RedactedValue
+		}
+	}
+
+	return redacted
+}
+
+func setProvenance(provenance map[string]Source, prefix string, values map[string]interface{}, source Source) {
+	for k, v := range values {
+		path := prefix + "/" + escape(k)
+
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			// A non map value replaces everything below this path.
+			deletePrefix(provenance, path+"/")
+			provenance[path] = source
+			continue
+		}
+
+		// A map replaces a non map value set by an earlier layer.
+		delete(provenance, path)
+
+		if len(m) == 0 {
+			if !hasPrefix(provenance, path+"/") {
+				provenance[path] = source
+			}
+			continue
+		}
+
+		setProvenance(provenance, path, m, source)
+	}
+}
+
+func deletePrefix(provenance map[string]Source, prefix string) {
+	for p := range provenance {
+		if strings.HasPrefix(p, prefix) {
+			delete(provenance, p)
+		}
+	}
+}
+
+func hasPrefix(provenance map[string]Source, prefix string) bool {
+	for p := range provenance {
+		if strings.HasPrefix(p, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// escape escapes a key so it can be used as a JSON pointer reference token
+// as defined by RFC 6901.
+func escape(s string) string {
+	s = strings.ReplaceAll(s, "~", "~0")
+	return strings.ReplaceAll(s, "/", "~1")
+}
@@ -0,0 +1,284 @@
+// Package valuesource fetches the configmaps and secrets an app CR gets its
+// values from as ordered layers. The layers are merged in the same order as
+// the values package of github.com/giantswarm/app so it can be used to find
+// out which source a merged value came from.
+package valuesource
+
+import (
+	"context"
+	"sort"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/app/v8/pkg/key"
+	"github.com/giantswarm/microerror"
+	"github.com/imdario/mergo"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// Annotation is set on the configmaps and secrets generated for chart CRs.
+	// It lists the sources the values were merged from in merge order.
+	Annotation = "application.giantswarm.io/values-sources"
+
+	// KindCatalog is the kind of the configmap or secret referenced by the
+	// catalog CR.
+	KindCatalog = "catalog"
+	// KindApp is the kind of the configmap or secret referenced in
+	// .spec.config of the app CR. This is usually the cluster values.
+	KindApp = "app"
+	// KindUser is the kind of the configmap or secret referenced in
+	// .spec.userConfig of the app CR.
+	KindUser = "user"
+	// KindExtraConfig is the kind of the configmaps and secrets referenced in
+	// .spec.extraConfigs of the app CR.
+	KindExtraConfig = "extraConfig"
+	// KindObjectRef, KindAppRef and KindSecretStore are the kinds of the
+	// value references declared in the values-refs annotation of the app CR.
+	KindObjectRef   = "objectRef"
+	KindAppRef      = "appRef"
+	KindSecretStore = "secretStore"
+
+	// TypeConfigMap is the type of configmap sources.
+	TypeConfigMap = "configMap"
+	// TypeSecret is the type of secret sources.
+	TypeSecret = "secret"
+)
+
+// Source identifies a configmap, secret or value reference values are read
+// from.
+type Source struct {
+	Kind            string `json:"kind"`
+	Type            string `json:"type"`
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+	Priority        int    `json:"priority"`
+
+	// APIVersion and ObjectKind are only set for object references.
+	APIVersion string `json:"apiVersion,omitempty"`
+	ObjectKind string `json:"objectKind,omitempty"`
+	// Provider is only set for secret store references. Name is the path.
+	Provider string `json:"provider,omitempty"`
+}
+
+// Layer is a source with its parsed values.
+type Layer struct {
+	Source Source
+	Values map[string]interface{}
+}
+
+// Config represents the configuration used to create a new resolver.
+type Config struct {
+	K8sClient kubernetes.Interface
+}
+
+// Resolver fetches the value layers of app CRs.
+type Resolver struct {
+	k8sClient kubernetes.Interface
+}
+
+// New creates a new configured resolver.
+func New(config Config) (*Resolver, error) {
+	if config.K8sClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.K8sClient must not be empty", config)
+	}
+
+	r := &Resolver{
+		k8sClient: config.K8sClient,
+	}
+
+	return r, nil
+}
+
+// Sources returns the sources of the given type in the order they are
+// merged. Later sources take precedence.
+func Sources(app v1alpha1.App, catalog v1alpha1.Catalog, sourceType string) []Source {
+	var sources []Source
+
+	var catalogSource, appSource, userSource Source
+	var extraConfigs []v1alpha1.AppExtraConfig
+	{
+		if sourceType == TypeSecret {
+			catalogSource = Source{Name: key.CatalogSecretName(catalog), Namespace: key.CatalogSecretNamespace(catalog)}
+			appSource = Source{Name: key.AppSecretName(app), Namespace: key.AppSecretNamespace(app)}
+			userSource = Source{Name: key.UserSecretName(app), Namespace: key.UserSecretNamespace(app)}
+			extraConfigs = key.SecretExtraConfigs(app)
+		} else {
+			catalogSource = Source{Name: key.CatalogConfigMapName(catalog), Namespace: key.CatalogConfigMapNamespace(catalog)}
+			appSource = Source{Name: key.AppConfigMapName(app), Namespace: key.AppConfigMapNamespace(app)}
+			userSource = Source{Name: key.UserConfigMapName(app), Namespace: key.UserConfigMapNamespace(app)}
+			extraConfigs = key.ConfigMapExtraConfigs(app)
+		}
+	}
+
+	add := func(s Source, kind string, priority int) {
+		if s.Name == "" {
+			return
+		}
+
+		s.Kind = kind
+		s.Type = sourceType
+		s.Priority = priority
+		sources = append(sources, s)
+	}
+	addExtraConfigs := func(minExclusive, maxInclusive int) {
+		for _, e := range sortedExtraConfigs(extraConfigs, minExclusive, maxInclusive) {
+			add(Source{Name: e.Name, Namespace: e.Namespace}, KindExtraConfig, priority(e))
+		}
+	}
+
+	add(catalogSource, KindCatalog, v1alpha1.ConfigPriorityCatalog)
+	addExtraConfigs(v1alpha1.ConfigPriorityCatalog, v1alpha1.ConfigPriorityCluster)
+	add(appSource, KindApp, v1alpha1.ConfigPriorityCluster)
+	addExtraConfigs(v1alpha1.ConfigPriorityCluster, v1alpha1.ConfigPriorityUser)
+	add(userSource, KindUser, v1alpha1.ConfigPriorityUser)
+	addExtraConfigs(v1alpha1.ConfigPriorityUser, v1alpha1.ConfigPriorityMaximum)
+
+	return sources
+}
+
+// Resolve returns the sources of the given type in the order they are merged
+// with their current resource versions.
+func (r *Resolver) Resolve(ctx context.Context, app v1alpha1.App, catalog v1alpha1.Catalog, sourceType string) ([]Source, error) {
+	sources := Sources(app, catalog, sourceType)
+
+	for i, s := range sources {
+		_, resourceVersion, err := r.getData(ctx, s)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		sources[i].ResourceVersion = resourceVersion
+	}
+
+	return sources, nil
+}
+
+// Layers fetches the sources of the given type and returns them with their
+// parsed values in the order they are merged.
+func (r *Resolver) Layers(ctx context.Context, app v1alpha1.App, catalog v1alpha1.Catalog, sourceType string) ([]Layer, error) {
+	var layers []Layer
+
+	for _, s := range Sources(app, catalog, sourceType) {
+		data, resourceVersion, err := r.getData(ctx, s)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		values, err := parse(s, data)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		s.ResourceVersion = resourceVersion
+		layers = append(layers, Layer{
+			Source: s,
+			Values: values,
+		})
+	}
+
+	return layers, nil
+}
+
+// Merge merges the values of the layers in order the same way as the values
+// package of github.com/giantswarm/app. Later layers take precedence. The
+// merged values are nil when there are no layers. The values of the layers
+// are copied so they are not modified by merging.
+func Merge(layers []Layer) (map[string]interface{}, error) {
+	if len(layers) == 0 {
+		return nil, nil
+	}
+
+	merged := map[string]interface{}{}
+	for _, l := range layers {
+		err := mergo.Merge(&merged, runtime.DeepCopyJSON(l.Values), mergo.WithOverride)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	return merged, nil
+}
+
+func (r *Resolver) getData(ctx context.Context, s Source) (map[string]string, string, error) {
+	if s.Type == TypeSecret {
+		secret, err := r.k8sClient.CoreV1().Secrets(s.Namespace).Get(ctx, s.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil, "", microerror.Maskf(notFoundError, "secret %#q in namespace %#q not found", s.Name, s.Namespace)
+		} else if err != nil {
+			return nil, "", microerror.Mask(err)
+		}
+
+		data := map[string]string{}
+		for k, v := range secret.Data {
+			data[k] = string(v)
+		}
+
+		return data, secret.ResourceVersion, nil
+	}
+
+	configMap, err := r.k8sClient.CoreV1().ConfigMaps(s.Namespace).Get(ctx, s.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, "", microerror.Maskf(notFoundError, "configmap %#q in namespace %#q not found", s.Name, s.Namespace)
+	} else if err != nil {
+		return nil, "", microerror.Mask(err)
+	}
+
+	return configMap.Data, configMap.ResourceVersion, nil
+}
+
+// parse parses the values of a source. Catalog, app and user sources must
+// have exactly one key while for extra configs the last key wins, matching
+// the values package.
+func parse(s Source, data map[string]string) (map[string]interface{}, error) {
+	if len(data) == 0 {
+		return map[string]interface{}{}, nil
+	}
+	if s.Kind != KindExtraConfig && len(data) != 1 {
+		return nil, microerror.Maskf(parsingError, "expected %#q %s %#q has only one key but got %d", s.Kind, s.Type, s.Name, len(data))
+	}
+
+	var raw string
+	for _, v := range data {
+		raw = v
+	}
+
+	var values map[string]interface{}
+	err := yaml.Unmarshal([]byte(raw), &values)
+	if err != nil {
+		return nil, microerror.Maskf(parsingError, "failed to parse %s %#q in namespace %#q: %s", s.Type, s.Name, s.Namespace, err)
+	}
+	if values == nil {
+		values = map[string]interface{}{}
+	}
+
+	return values, nil
+}
+
+func priority(e v1alpha1.AppExtraConfig) int {
+	if e.Priority == 0 {
+		return v1alpha1.ConfigPriorityDefault
+	}
+
+	return e.Priority
+}
+
+func sortedExtraConfigs(extraConfigs []v1alpha1.AppExtraConfig, minExclusive, maxInclusive int) []v1alpha1.AppExtraConfig {
+	var result []v1alpha1.AppExtraConfig
+	for _, e := range extraConfigs {
+		p := priority(e)
+		if minExclusive < p && p <= maxInclusive {
+			result = append(result, e)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return priority(result[i]) < priority(result[j])
+	})
+
+	return result
+}
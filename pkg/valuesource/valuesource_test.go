@@ -0,0 +1,254 @@
+package valuesource
+
+import (
+	"context"
+	"testing"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/app/v8/pkg/values"
+	"github.com/giantswarm/micrologger/microloggertest"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgofake "k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_Resolver_Provenance(t *testing.T) {
+	tests := []struct {
+		name               string
+		app                v1alpha1.App
+		catalog            v1alpha1.Catalog
+		objs               []runtime.Object
+		expectedSources    []string
+		expectedProvenance map[string]string
+		errorMatcher       func(error) bool
+	}{
+		{
+			name: "case 0: no sources",
+			app:  newTestApp(),
+		},
+		{
+			name: "case 1: catalog, app, user and extra configs",
+			app: func() v1alpha1.App {
+				app := newTestApp()
+				app.Spec.Config.ConfigMap = v1alpha1.AppSpecConfigConfigMap{Name: "cluster-values", Namespace: "org-test"}
+				app.Spec.UserConfig.ConfigMap = v1alpha1.AppSpecUserConfigConfigMap{Name: "user-values", Namespace: "org-test"}
+				app.Spec.ExtraConfigs = []v1alpha1.AppExtraConfig{
+					{Name: "post-user", Namespace: "org-test", Priority: 120},
+					{Name: "pre-cluster", Namespace: "org-test"},
+					{Name: "post-cluster", Namespace: "org-test", Priority: 75},
+				}
+				return app
+			}(),
+			catalog: newTestCatalog("catalog-values"),
+			objs: []runtime.Object{
+				newTestConfigMap("catalog-values", "giantswarm", "image:\n  registry: quay.io\n  tag: 1.0.0\nreplicas: 1\n"),
+				newTestConfigMap("pre-cluster", "org-test", "replicas: 2\n"),
+				newTestConfigMap("cluster-values", "org-test", "image:\n  registry: gsoci.azurecr.io\n"),
+				newTestConfigMap("post-cluster", "org-test", "ingress:\n  hosts:\n  - a.example.com\n"),
+				newTestConfigMap("user-values", "org-test", "image: custom\n"),
+				newTestConfigMap("post-user", "org-test", "ingress:\n  enabled: true\n"),
+			},
+			expectedSources: []string{
+				"catalog/catalog-values",
+				"extraConfig/pre-cluster",
+				"app/cluster-values",
+				"extraConfig/post-cluster",
+				"user/user-values",
+				"extraConfig/post-user",
+			},
+			expectedProvenance: map[string]string{
+				"/image":           "user/user-values",
+				"/ingress/enabled": "extraConfig/post-user",
+				"/ingress/hosts":   "extraConfig/post-cluster",
+				"/replicas":        "extraConfig/pre-cluster",
+			},
+		},
+		{
+			name: "case 2: missing configmap",
+			app: func() v1alpha1.App {
+				app := newTestApp()
+				app.Spec.UserConfig.ConfigMap = v1alpha1.AppSpecUserConfigConfigMap{Name: "user-values", Namespace: "org-test"}
+				return app
+			}(),
+			errorMatcher: IsNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			k8sClient := clientgofake.NewClientset(tc.objs...)
+
+			r, err := New(Config{K8sClient: k8sClient})
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			layers, err := r.Layers(context.Background(), tc.app, tc.catalog, TypeConfigMap)
+			switch {
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case err != nil && !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+			if tc.errorMatcher != nil {
+				return
+			}
+
+			var sources []string
+			for _, l := range layers {
+				sources = append(sources, l.Source.Kind+"/"+l.Source.Name)
+			}
+			if !cmp.Equal(sources, tc.expectedSources) {
+				t.Fatalf("want matching sources \n %s", cmp.Diff(sources, tc.expectedSources))
+			}
+
+			var provenance map[string]string
+			for p, s := range Provenance(layers) {
+				if provenance == nil {
+					provenance = map[string]string{}
+				}
+				provenance[p] = s.Kind + "/" + s.Name
+			}
+			if !cmp.Equal(provenance, tc.expectedProvenance) {
+				t.Fatalf("want matching provenance \n %s", cmp.Diff(provenance, tc.expectedProvenance))
+			}
+
+			// The layers must merge to the same values as the values package.
+			v, err := values.New(values.Config{K8sClient: k8sClient, Logger: microloggertest.New()})
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+			expectedValues, err := v.MergeConfigMapData(context.Background(), tc.app, tc.catalog)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+			merged, err := Merge(layers)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+			if !cmp.Equal(merged, expectedValues) {
+				t.Fatalf("want matching values \n %s", cmp.Diff(merged, expectedValues))
+			}
+		})
+	}
+}
+
+func Test_Redact(t *testing.T) {
+	values := map[string]interface{}{
+		"password": "secret",
+		"tls": map[string]interface{}{
+			"crt": "abc",
+		},
+	}
+	expected := map[string]interface{}{
+		"password": RedactedValue,
+		"tls": map[string]interface{}{
+			"crt": RedactedValue,
+		},
+	}
+
+	redacted := Redact(values)
+	if !cmp.Equal(redacted, expected) {
+		t.Fatalf("want matching values \n %s", cmp.Diff(redacted, expected))
+	}
+}
+
+func newTestApp() v1alpha1.App {
+	return v1alpha1.App{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-app",
+			Namespace: "org-test",
+		},
+		Spec: v1alpha1.AppSpec{
+			Catalog: "giantswarm",
+			Name:    "test-app",
+		},
+	}
+}
+
+func newTestCatalog(configMapName string) v1alpha1.Catalog {
+	return v1alpha1.Catalog{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "giantswarm",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.CatalogSpec{
+			Config: &v1alpha1.CatalogSpecConfig{
+				ConfigMap: &v1alpha1.CatalogSpecConfigConfigMap{
+					Name:      configMapName,
+					Namespace: "giantswarm",
+				},
+			},
+		},
+	}
+}
+
+func newTestConfigMap(name, namespace, values string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Data: map[string]string{
+			"values": values,
+		},
+	}
+}
+
+func Test_Annotation(t *testing.T) {
+	sources := []Source{
+		{Kind: KindCatalog, Type: TypeConfigMap, Name: "catalog-values", Namespace: "giantswarm", ResourceVersion: "10", Priority: 0},
+		{Kind: KindUser, Type: TypeConfigMap, Name: "user-values", Namespace: "org-test", ResourceVersion: "20", Priority: 100},
+	}
+
+	value, err := ToAnnotation(sources)
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	result, err := FromAnnotation(map[string]string{Annotation: value})
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+	if !cmp.Equal(result, sources) {
+		t.Fatalf("want matching sources \n %s", cmp.Diff(result, sources))
+	}
+
+	if !IsFresh(result, TypeConfigMap, "user-values", "org-test", "20") {
+		t.Fatalf("expected sources to be fresh")
+	}
+	if IsFresh(result, TypeConfigMap, "user-values", "org-test", "21") {
+		t.Fatalf("expected sources to be stale for newer resource version")
+	}
+	if IsFresh(result, TypeSecret, "user-values", "org-test", "20") {
+		t.Fatalf("expected sources to be stale for unknown source")
+	}
+}
+
+func Test_IsRefFresh(t *testing.T) {
+	sources := []Source{
+		{Kind: KindUser, Type: TypeConfigMap, Name: "database", Namespace: "org-test", ResourceVersion: "20", Priority: 100},
+		{Kind: KindObjectRef, Type: TypeConfigMap, Name: "database", Namespace: "org-test", ResourceVersion: "30", Priority: 75, APIVersion: "example.com/v1", ObjectKind: "Database"},
+		{Kind: KindSecretStore, Type: TypeSecret, Name: "apps/database", ResourceVersion: "0123456789abcdef", Priority: 75, Provider: "vault"},
+	}
+
+	if IsFresh(sources, TypeConfigMap, "database", "org-test", "30") {
+		t.Fatalf("expected object reference not to be checked as configmap")
+	}
+	if !IsRefFresh(sources, Source{Kind: KindObjectRef, Name: "database", Namespace: "org-test", ResourceVersion: "30", APIVersion: "example.com/v1", ObjectKind: "Database"}) {
+		t.Fatalf("expected object reference to be fresh")
+	}
+	if IsRefFresh(sources, Source{Kind: KindObjectRef, Name: "database", Namespace: "org-test", ResourceVersion: "31", APIVersion: "example.com/v1", ObjectKind: "Database"}) {
+		t.Fatalf("expected object reference to be stale for newer resource version")
+	}
+	if IsRefFresh(sources, Source{Kind: KindObjectRef, Name: "database", Namespace: "org-test", ResourceVersion: "30", APIVersion: "example.com/v1", ObjectKind: "Cache"}) {
+		t.Fatalf("expected object reference to be stale for unknown kind")
+	}
+	if !IsRefFresh(sources, Source{Kind: KindSecretStore, Name: "apps/database", ResourceVersion: "0123456789abcdef", Provider: "vault"}) {
+		t.Fatalf("expected secret store reference to be fresh")
+	}
+}
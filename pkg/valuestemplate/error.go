@@ -0,0 +1,12 @@
+package valuestemplate
+
+import "github.com/giantswarm/microerror"
+
+var templateError = &microerror.Error{
+	Kind: "templateError",
+}
+
+// IsTemplate asserts templateError.
+func IsTemplate(err error) bool {
+	return microerror.Cause(err) == templateError
+}
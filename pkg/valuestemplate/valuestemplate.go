@@ -0,0 +1,218 @@
+// Package valuestemplate renders Go templates in the merged values of app
+// CRs. Templating is opt-in per app CR via Annotation so values which
+// already contain template delimiters, e.g. for charts rendering them with
+// tpl, are not changed.
+//
+// Every string value containing "{{" is rendered with a Context, e.g.
+//
+//	ingress:
+//	  host: "grafana.{{ .Cluster.ID }}.example.com"
+//	podLabels:
+//	  organization: "{{ .Cluster.Organization }}"
+//
+// With the strict mode referencing a missing label or annotation, or the
+// cluster ID or organization of an app CR without them, fails the rendering
+// instead of rendering an empty string.
+package valuestemplate
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/app/v8/pkg/key"
+	"github.com/giantswarm/microerror"
+)
+
+const (
+	// Annotation enables templating of the values of an app CR. Its value is
+	// one of ModeEnabled or ModeStrict.
+	Annotation = "application.giantswarm.io/values-templating"
+
+	// ModeEnabled renders templates and renders missing map keys as empty
+	// strings.
+	ModeEnabled = "enabled"
+	// ModeStrict renders templates and fails on missing map keys and empty
+	// cluster fields.
+	ModeStrict = "strict"
+)
+
+// Context is the data templates are rendered with. Its fields are part of
+// the API of the templating and must not be renamed.
+type Context struct {
+	App      App
+	Catalog  string
+	Cluster  Cluster
+	Provider string
+}
+
+// App holds the metadata of the app CR.
+type App struct {
+	Name        string
+	Namespace   string
+	Version     string
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+// Cluster holds the cluster the app CR belongs to as set in its labels.
+type Cluster struct {
+	ID           string
+	Organization string
+}
+
+// NewContext returns the template context of the app CR.
+func NewContext(app v1alpha1.App, provider string) Context {
+	return Context{
+		App: App{
+			Name:        app.Name,
+			Namespace:   app.Namespace,
+			Version:     key.Version(app),
+			Labels:      nonNil(app.Labels),
+			Annotations: nonNil(app.Annotations),
+		},
+		Catalog: key.CatalogName(app),
+		Cluster: Cluster{
+			ID:           key.ClusterID(app),
+			Organization: key.OrganizationID(app),
+		},
+		Provider: provider,
+	}
+}
+
+// Mode returns the templating mode of the app CR. It is empty when
+// templating is disabled.
+func Mode(app v1alpha1.App) (string, error) {
+	mode := app.GetAnnotations()[Annotation]
+	switch mode {
+	case "", "false":
+		return "", nil
+	case "true", ModeEnabled:
+		return ModeEnabled, nil
+	case ModeStrict:
+		return ModeStrict, nil
+	}
+
+	return "", microerror.Maskf(templateError, "annotation %#q must be %#q or %#q but got %#q", Annotation, ModeEnabled, ModeStrict, mode)
+}
+
+// Render renders the templates in the string values of the given values in
+// place. The JSON pointer of the first value failing to render is part of
+// the returned error.
+func Render(values map[string]interface{}, ctx Context, mode string) error {
+	var data interface{} = ctx
+	option := "missingkey=zero"
+	if mode == ModeStrict {
+		data = strictData(ctx)
+		option = "missingkey=error"
+	}
+
+	r := renderer{
+		data:   data,
+		option: option,
+	}
+
+	return r.renderMap("", values)
+}
+
+type renderer struct {
+	data   interface{}
+	option string
+}
+
+// strictData returns the context with the cluster as a map lacking the
+// empty fields. Referencing them fails like referencing missing labels
+// since missingkey=error only applies to maps.
+func strictData(ctx Context) map[string]interface{} {
+	cluster := map[string]interface{}{}
+	if ctx.Cluster.ID != "" {
+		cluster["ID"] = ctx.Cluster.ID
+	}
+	if ctx.Cluster.Organization != "" {
+		cluster["Organization"] = ctx.Cluster.Organization
+	}
+
+	return map[string]interface{}{
+		"App":      ctx.App,
+		"Catalog":  ctx.Catalog,
+		"Cluster":  cluster,
+		"Provider": ctx.Provider,
+	}
+}
+
+func (r renderer) renderMap(pointer string, values map[string]interface{}) error {
+	// Keys are sorted so the same error is reported on every reconciliation.
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v, err := r.render(fmt.Sprintf("%s/%s", pointer, escape(k)), values[k])
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		values[k] = v
+	}
+
+	return nil
+}
+
+func (r renderer) render(pointer string, value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		err := r.renderMap(pointer, v)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return v, nil
+	case []interface{}:
+		for i := range v {
+			rendered, err := r.render(fmt.Sprintf("%s/%d", pointer, i), v[i])
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+
+			v[i] = rendered
+		}
+
+		return v, nil
+	case string:
+		if !strings.Contains(v, "{{") {
+			return v, nil
+		}
+
+		t, err := template.New(pointer).Option(r.option).Parse(v)
+		if err != nil {
+			return nil, microerror.Maskf(templateError, "failed to parse template at %#q: %s", pointer, err)
+		}
+
+		var b bytes.Buffer
+		err = t.Execute(&b, r.data)
+		if err != nil {
+			return nil, microerror.Maskf(templateError, "failed to render template at %#q: %s", pointer, err)
+		}
+
+		return b.String(), nil
+	}
+
+	return value, nil
+}
+
+func escape(k string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(k, "~", "~0"), "/", "~1")
+}
+
+func nonNil(m map[string]string) map[string]string {
+	if m == nil {
+		return map[string]string{}
+	}
+
+	return m
+}
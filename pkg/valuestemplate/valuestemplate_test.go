@@ -0,0 +1,177 @@
+package valuestemplate
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_Render(t *testing.T) {
+	app := v1alpha1.App{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "grafana",
+			Namespace: "org-acme",
+			Labels: map[string]string{
+				"giantswarm.io/cluster":      "demo01",
+				"giantswarm.io/organization": "acme",
+			},
+		},
+		Spec: v1alpha1.AppSpec{
+			Catalog: "giantswarm",
+			Version: "1.2.3",
+		},
+	}
+
+	tests := []struct {
+		name           string
+		values         map[string]interface{}
+		labels         map[string]string
+		mode           string
+		expectedValues map[string]interface{}
+		errorMatcher   func(error) bool
+	}{
+		{
+			name: "case 0: nested strings and lists are rendered",
+			values: map[string]interface{}{
+				"ingress": map[string]interface{}{
+					"hosts": []interface{}{
+						"grafana.{{ .Cluster.ID }}.example.com",
+					},
+				},
+				"labels": map[string]interface{}{
+					"organization": "{{ .Cluster.Organization }}",
+				},
+				"provider": "{{ .Provider }}",
+				"replicas": 2,
+				"version":  "{{ .Catalog }}/{{ .App.Version }}",
+			},
+			mode: ModeEnabled,
+			expectedValues: map[string]interface{}{
+				"ingress": map[string]interface{}{
+					"hosts": []interface{}{
+						"grafana.demo01.example.com",
+					},
+				},
+				"labels": map[string]interface{}{
+					"organization": "acme",
+				},
+				"provider": "aws",
+				"replicas": 2,
+				"version":  "giantswarm/1.2.3",
+			},
+		},
+		{
+			name: "case 1: missing key is empty",
+			values: map[string]interface{}{
+				"team": "{{ .App.Labels.team }}",
+			},
+			mode: ModeEnabled,
+			expectedValues: map[string]interface{}{
+				"team": "",
+			},
+		},
+		{
+			name: "case 2: missing key fails in strict mode",
+			values: map[string]interface{}{
+				"team": "{{ .App.Labels.team }}",
+			},
+			mode:         ModeStrict,
+			errorMatcher: IsTemplate,
+		},
+		{
+			name: "case 3: invalid template",
+			values: map[string]interface{}{
+				"host": "{{ .Cluster.ID",
+			},
+			mode:         ModeEnabled,
+			errorMatcher: IsTemplate,
+		},
+		{
+			name: "case 4: unknown field",
+			values: map[string]interface{}{
+				"domain": "{{ .Cluster.BaseDomain }}",
+			},
+			mode:         ModeEnabled,
+			errorMatcher: IsTemplate,
+		},
+		{
+			name: "case 5: cluster fields are rendered in strict mode",
+			values: map[string]interface{}{
+				"host": "grafana.{{ .Cluster.ID }}.{{ .Cluster.Organization }}.example.com",
+				"team": "{{ .App.Labels.team }}",
+			},
+			labels: map[string]string{
+				"giantswarm.io/cluster":      "demo01",
+				"giantswarm.io/organization": "acme",
+				"team":                       "atlas",
+			},
+			mode: ModeStrict,
+			expectedValues: map[string]interface{}{
+				"host": "grafana.demo01.acme.example.com",
+				"team": "atlas",
+			},
+		},
+		{
+			name: "case 6: empty cluster ID fails in strict mode",
+			values: map[string]interface{}{
+				"host": "grafana.{{ .Cluster.ID }}.example.com",
+			},
+			labels: map[string]string{
+				"giantswarm.io/organization": "acme",
+			},
+			mode:         ModeStrict,
+			errorMatcher: IsTemplate,
+		},
+		{
+			name: "case 7: empty organization fails in strict mode",
+			values: map[string]interface{}{
+				"organization": "{{ .Cluster.Organization }}",
+			},
+			labels: map[string]string{
+				"giantswarm.io/cluster": "demo01",
+			},
+			mode:         ModeStrict,
+			errorMatcher: IsTemplate,
+		},
+		{
+			name: "case 8: empty cluster ID is rendered empty",
+			values: map[string]interface{}{
+				"host": "grafana.{{ .Cluster.ID }}.example.com",
+			},
+			labels: map[string]string{
+				"giantswarm.io/organization": "acme",
+			},
+			mode: ModeEnabled,
+			expectedValues: map[string]interface{}{
+				"host": "grafana..example.com",
+			},
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			cr := app.DeepCopy()
+			if tc.labels != nil {
+				cr.Labels = tc.labels
+			}
+
+			err := Render(tc.values, NewContext(*cr, "aws"), tc.mode)
+			switch {
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case err != nil && !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if tc.errorMatcher == nil && !reflect.DeepEqual(tc.values, tc.expectedValues) {
+				t.Fatalf("want matching values \n %s", cmp.Diff(tc.values, tc.expectedValues))
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+// Package dryrun implements the endpoint rendering the merged values of an
+// app CR, e.g.
+//
+//	curl -H "Authorization: Bearer $TOKEN" 'http://localhost:8000/dryrun/values?namespace=org-acme&name=my-app'
+//
+// The token is authenticated with a TokenReview and its user must be allowed
+// to get the app CR and every configmap, secret, app CR and object its values
+// are merged from.
+//
+// The endpoint is served on the plain HTTP port of app-operator so bearer
+// tokens are not encrypted in transit. It must only be reached through
+// kubectl port-forward, e.g.
+//
+//	kubectl -n giantswarm port-forward deployment/app-operator 8000
+package dryrun
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	kitendpoint "github.com/go-kit/kit/endpoint"
+	kithttp "github.com/go-kit/kit/transport/http"
+
+	"github.com/giantswarm/app-operator/v7/service/dryrun"
+)
+
+const (
+	// Method is the HTTP method this endpoint is registered for.
+	Method = "GET"
+	// Name identifies the endpoint. It is aligned to the package path.
+	Name = "dryrun"
+	// Path is the HTTP request path this endpoint is registered for.
+	Path = "/dryrun/values"
+)
+
+// Config represents the configuration used to create a dry run endpoint.
+type Config struct {
+	Logger  micrologger.Logger
+	Service *dryrun.Service
+}
+
+// Endpoint is the dry run endpoint.
+type Endpoint struct {
+	logger  micrologger.Logger
+	service *dryrun.Service
+}
+
+// New creates a new configured dry run endpoint.
+func New(config Config) (*Endpoint, error) {
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+	if config.Service == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Service must not be empty", config)
+	}
+
+	e := &Endpoint{
+		logger:  config.Logger,
+		service: config.Service,
+	}
+
+	return e, nil
+}
+
+func (e *Endpoint) Decoder() kithttp.DecodeRequestFunc {
+	return func(ctx context.Context, r *http.Request) (interface{}, error) {
+		request := dryrun.Request{
+			Name:      r.URL.Query().Get("name"),
+			Namespace: r.URL.Query().Get("namespace"),
+			Token:     bearerToken(r),
+		}
+
+		return request, nil
+	}
+}
+
+// bearerToken returns the token of the Authorization header. It is empty when
+// the header does not hold a bearer token.
+func bearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+
+	return strings.TrimSpace(token)
+}
+
+func (e *Endpoint) Encoder() kithttp.EncodeResponseFunc {
+	return func(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+		return json.NewEncoder(w).Encode(response)
+	}
+}
+
+func (e *Endpoint) Endpoint() kitendpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		response, err := e.service.Render(ctx, request.(dryrun.Request))
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return response, nil
+	}
+}
+
+func (e *Endpoint) Method() string {
+	return Method
+}
+
+func (e *Endpoint) Middlewares() []kitendpoint.Middleware {
+	return []kitendpoint.Middleware{}
+}
+
+func (e *Endpoint) Name() string {
+	return Name
+}
+
+func (e *Endpoint) Path() string {
+	return Path
+}
@@ -3,9 +3,11 @@ package endpoint
 import (
 	"github.com/giantswarm/microendpoint/endpoint/healthz"
 	"github.com/giantswarm/microendpoint/endpoint/version"
+	healthzservice "github.com/giantswarm/microendpoint/service/healthz"
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
 
+	"github.com/giantswarm/app-operator/v7/server/endpoint/dryrun"
 	"github.com/giantswarm/app-operator/v7/service"
 )
 
@@ -18,6 +20,8 @@ type Config struct {
 
 // Endpoint is the endpoint collection.
 type Endpoint struct {
+	// DryRun is nil unless the dry run service is enabled.
+	DryRun  *dryrun.Endpoint
 	Healthz *healthz.Endpoint
 	Version *version.Endpoint
 }
@@ -33,10 +37,27 @@ func New(config Config) (*Endpoint, error) {
 
 	var err error
 
+	// The leader check fails in followers so only the leader is ready
+	// when leader election is enabled.
+	var healthzServices []healthzservice.Service
+	if config.Service.Leader != nil {
+		c := healthzservice.Config{
+			Logger: config.Logger,
+		}
+
+		serviceHealthz, err := healthzservice.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		healthzServices = append(healthzServices, serviceHealthz, config.Service.Leader)
+	}
+
 	var healthzEndpoint *healthz.Endpoint
 	{
 		c := healthz.Config{
-			Logger: config.Logger,
+			Logger:   config.Logger,
+			Services: healthzServices,
 		}
 
 		healthzEndpoint, err = healthz.New(c)
@@ -58,7 +79,21 @@ func New(config Config) (*Endpoint, error) {
 		}
 	}
 
+	var dryRunEndpoint *dryrun.Endpoint
+	if config.Service.DryRun != nil {
+		c := dryrun.Config{
+			Logger:  config.Logger,
+			Service: config.Service.DryRun,
+		}
+
+		dryRunEndpoint, err = dryrun.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
 	endpoint := &Endpoint{
+		DryRun:  dryRunEndpoint,
 		Healthz: healthzEndpoint,
 		Version: versionEndpoint,
 	}
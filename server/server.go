@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/giantswarm/microerror"
 	microserver "github.com/giantswarm/microkit/server"
@@ -13,6 +14,7 @@ import (
 	"github.com/giantswarm/app-operator/v7/pkg/project"
 	"github.com/giantswarm/app-operator/v7/server/endpoint"
 	"github.com/giantswarm/app-operator/v7/service"
+	"github.com/giantswarm/app-operator/v7/service/dryrun"
 )
 
 // Config represents the configuration used to construct server object.
@@ -20,6 +22,9 @@ type Config struct {
 	Logger  micrologger.Logger
 	Service *service.Service
 
+	// ShutdownTimeout is the time in-flight reconciliations are drained
+	// for when the server is shut down.
+	ShutdownTimeout  time.Duration
 	Viper            *viper.Viper
 	WebhookAuthToken string
 }
@@ -35,6 +40,9 @@ func New(config Config) (microserver.Server, error) {
 		return nil, microerror.Maskf(invalidConfigError, "%T.Service must not be empty", config)
 	}
 
+	if config.ShutdownTimeout <= 0 {
+		return nil, microerror.Maskf(invalidConfigError, "%T.ShutdownTimeout must be greater than zero", config)
+	}
 	if config.Viper == nil {
 		return nil, microerror.Maskf(invalidConfigError, "%T.Viper must not be empty", config)
 	}
@@ -52,23 +60,32 @@ func New(config Config) (microserver.Server, error) {
 		}
 	}
 
+	endpoints := []microserver.Endpoint{
+		endpointCollection.Healthz,
+		endpointCollection.Version,
+	}
+	if endpointCollection.DryRun != nil {
+		endpoints = append(endpoints, endpointCollection.DryRun)
+	}
+
 	newServer := &server{
 		// Dependencies
-		logger: config.Logger,
+		logger:  config.Logger,
+		service: config.Service,
 
 		// Internals
 		bootOnce: sync.Once{},
 		config: microserver.Config{
-			Logger:      config.Logger,
-			ServiceName: project.Name(),
-			Viper:       config.Viper,
-			Endpoints: []microserver.Endpoint{
-				endpointCollection.Healthz,
-				endpointCollection.Version,
-			},
+			Logger:       config.Logger,
+			ServiceName:  project.Name(),
+			Viper:        config.Viper,
+			Endpoints:    endpoints,
 			ErrorEncoder: errorEncoder,
 		},
 		shutdownOnce: sync.Once{},
+
+		// Settings
+		shutdownTimeout: config.ShutdownTimeout,
 	}
 
 	return newServer, nil
@@ -76,12 +93,16 @@ func New(config Config) (microserver.Server, error) {
 
 type server struct {
 	// Dependencies
-	logger micrologger.Logger
+	logger  micrologger.Logger
+	service *service.Service
 
 	// Internals
 	bootOnce     sync.Once
 	config       microserver.Config
 	shutdownOnce sync.Once
+
+	// Settings
+	shutdownTimeout time.Duration
 }
 
 func (s *server) Boot() {
@@ -94,9 +115,17 @@ func (s *server) Config() microserver.Config {
 	return s.config
 }
 
+// Shutdown stops the controllers and watchers of the service and waits for
+// in-flight reconciliations to be drained for up to the shutdown timeout.
 func (s *server) Shutdown() {
 	s.shutdownOnce.Do(func() {
-		// Insert here custom shutdown logic for server/endpoint/middleware if needed.
+		ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+		defer cancel()
+
+		err := s.service.Shutdown(ctx)
+		if err != nil {
+			s.logger.Errorf(ctx, err, "failed to drain controllers and watchers")
+		}
 	})
 }
 
@@ -104,7 +133,23 @@ func errorEncoder(ctx context.Context, err error, w http.ResponseWriter) {
 	rErr := err.(microserver.ResponseError)
 	uErr := rErr.Underlying()
 
-	rErr.SetCode(microserver.CodeInternalError)
 	rErr.SetMessage(uErr.Error())
-	w.WriteHeader(http.StatusInternalServerError)
+
+	switch {
+	case dryrun.IsInvalidRequest(uErr):
+		rErr.SetCode(microserver.CodeInvalidInput)
+		w.WriteHeader(http.StatusBadRequest)
+	case dryrun.IsUnauthenticated(uErr):
+		rErr.SetCode(microserver.CodeInvalidCredentials)
+		w.WriteHeader(http.StatusUnauthorized)
+	case dryrun.IsForbidden(uErr):
+		rErr.SetCode(microserver.CodePermissionDenied)
+		w.WriteHeader(http.StatusForbidden)
+	case dryrun.IsNotFound(uErr):
+		rErr.SetCode(microserver.CodeResourceNotFound)
+		w.WriteHeader(http.StatusNotFound)
+	default:
+		rErr.SetCode(microserver.CodeInternalError)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
 }
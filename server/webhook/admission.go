@@ -0,0 +1,130 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// maxRequestSize limits the size of admission reviews. The API server
+	// limits objects to 3MiB so this is more than enough.
+	maxRequestSize = 4 * 1024 * 1024
+)
+
+// admitFunc handles an admission request and returns the response without
+// the UID which is set by serveAdmission.
+type admitFunc func(ctx context.Context, request *admissionv1.AdmissionRequest) (*admissionv1.AdmissionResponse, error)
+
+// patch is a single JSON patch operation as defined by RFC 6902.
+type patch struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+func (w *Webhook) serveAdmission(admit admitFunc) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		if r.Header.Get("Content-Type") != "application/json" {
+			http.Error(rw, "expected content type application/json", http.StatusUnsupportedMediaType)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestSize))
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var review admissionv1.AdmissionReview
+		err = json.Unmarshal(body, &review)
+		if err != nil {
+			http.Error(rw, fmt.Sprintf("failed to decode admission review: %s", err), http.StatusBadRequest)
+			return
+		}
+		if review.Request == nil {
+			http.Error(rw, "admission review does not contain a request", http.StatusBadRequest)
+			return
+		}
+
+		response, err := admit(ctx, review.Request)
+		if err != nil {
+			w.logger.Errorf(ctx, err, "failed to admit %#q %#q in namespace %#q", review.Request.Kind.Kind, review.Request.Name, review.Request.Namespace)
+
+			response = &admissionv1.AdmissionResponse{
+				Allowed: false,
+				Result: &metav1.Status{
+					Message: err.Error(),
+					Reason:  metav1.StatusReasonInternalError,
+				},
+			}
+		}
+		response.UID = review.Request.UID
+
+		review.Response = response
+		review.Request = nil
+
+		out, err := json.Marshal(review)
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		rw.Header().Set("Content-Type", "application/json")
+		_, err = rw.Write(out)
+		if err != nil {
+			w.logger.Errorf(ctx, err, "failed to write admission response")
+		}
+	})
+}
+
+func allowed() *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{
+		Allowed: true,
+	}
+}
+
+func denied(message string) *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{
+		Allowed: false,
+		Result: &metav1.Status{
+			Message: message,
+			Reason:  metav1.StatusReasonInvalid,
+		},
+	}
+}
+
+func patched(patches []patch) (*admissionv1.AdmissionResponse, error) {
+	if len(patches) == 0 {
+		return allowed(), nil
+	}
+
+	bytes, err := json.Marshal(patches)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	patchType := admissionv1.PatchTypeJSONPatch
+
+	return &admissionv1.AdmissionResponse{
+		Allowed:   true,
+		Patch:     bytes,
+		PatchType: &patchType,
+	}, nil
+}
+
+// escapeJSONPointer escapes a map key so it can be used as a JSON pointer
+// reference token as defined by RFC 6901.
+func escapeJSONPointer(s string) string {
+	s = strings.ReplaceAll(s, "~", "~0")
+	return strings.ReplaceAll(s, "/", "~1")
+}
@@ -0,0 +1,122 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/app/v8/pkg/key"
+	"github.com/giantswarm/app/v8/pkg/validation"
+	"github.com/giantswarm/k8smetadata/pkg/label"
+	"github.com/giantswarm/microerror"
+	admissionv1 "k8s.io/api/admission/v1"
+
+	"github.com/giantswarm/app-operator/v7/pkg/project"
+	"github.com/giantswarm/app-operator/v7/pkg/valueref"
+	"github.com/giantswarm/app-operator/v7/pkg/valuestemplate"
+)
+
+// mutateApp defaults the version label of in-cluster app CRs so they are
+// reconciled by the unique app-operator instance.
+func (w *Webhook) mutateApp(ctx context.Context, request *admissionv1.AdmissionRequest) (*admissionv1.AdmissionResponse, error) {
+	if request.Operation != admissionv1.Create && request.Operation != admissionv1.Update {
+		return allowed(), nil
+	}
+
+	app, err := decodeApp(request.Object.Raw)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var patches []patch
+
+	if key.InCluster(app) && key.VersionLabel(app) == "" {
+		if len(app.Labels) == 0 {
+			patches = append(patches, patch{
+				Op:    "add",
+				Path:  "/metadata/labels",
+				Value: map[string]string{},
+			})
+		}
+
+		patches = append(patches, patch{
+			Op:    "add",
+			Path:  fmt.Sprintf("/metadata/labels/%s", escapeJSONPointer(label.AppOperatorVersion)),
+			Value: project.ManagementClusterAppVersion(),
+		})
+	}
+
+	return patched(patches)
+}
+
+// validateApp validates app CRs using the same checks as the validation
+// resource so invalid CRs are rejected before they are stored.
+func (w *Webhook) validateApp(ctx context.Context, request *admissionv1.AdmissionRequest) (*admissionv1.AdmissionResponse, error) {
+	if request.Operation != admissionv1.Create && request.Operation != admissionv1.Update {
+		return allowed(), nil
+	}
+
+	app, err := decodeApp(request.Object.Raw)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	// Apps being deleted must not be blocked so their finalizers can be
+	// removed.
+	if key.IsDeleted(app) {
+		return allowed(), nil
+	}
+
+	_, err = w.appValidator.ValidateApp(ctx, app)
+	if isValidationFailure(err) {
+		return denied(err.Error()), nil
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	_, err = valueref.FromApp(app)
+	if valueref.IsInvalidRef(err) {
+		return denied(err.Error()), nil
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	_, err = valuestemplate.Mode(app)
+	if valuestemplate.IsTemplate(err) {
+		return denied(err.Error()), nil
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	if request.Operation == admissionv1.Update {
+		currentApp, err := decodeApp(request.OldObject.Raw)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		_, err = w.appValidator.ValidateAppUpdate(ctx, app, currentApp)
+		if isValidationFailure(err) {
+			return denied(err.Error()), nil
+		} else if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	return allowed(), nil
+}
+
+func decodeApp(raw []byte) (v1alpha1.App, error) {
+	var app v1alpha1.App
+
+	err := json.Unmarshal(raw, &app)
+	if err != nil {
+		return v1alpha1.App{}, microerror.Maskf(decodeFailedError, "app: %s", err)
+	}
+
+	return app, nil
+}
+
+func isValidationFailure(err error) bool {
+	return validation.IsValidationError(err) || validation.IsAppConfigMapNotFound(err) || validation.IsKubeConfigNotFound(err)
+}
@@ -0,0 +1,156 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/microerror"
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+const (
+	repositoryTypeHelm = "helm"
+	repositoryTypeOCI  = "oci"
+)
+
+// mutateCatalog defaults the storage type and the repositories of catalog
+// CRs that only set the deprecated storage field.
+func (w *Webhook) mutateCatalog(ctx context.Context, request *admissionv1.AdmissionRequest) (*admissionv1.AdmissionResponse, error) {
+	if request.Operation != admissionv1.Create && request.Operation != admissionv1.Update {
+		return allowed(), nil
+	}
+
+	catalog, err := decodeCatalog(request.Object.Raw)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var patches []patch
+
+	repositoryType := storageType(catalog.Spec.Storage)
+	if catalog.Spec.Storage.URL != "" && catalog.Spec.Storage.Type == "" {
+		patches = append(patches, patch{
+			Op:    "add",
+			Path:  "/spec/storage/type",
+			Value: repositoryType,
+		})
+	}
+
+	if len(catalog.Spec.Repositories) == 0 && catalog.Spec.Storage.URL != "" {
+		patches = append(patches, patch{
+			Op:   "add",
+			Path: "/spec/repositories",
+			Value: []v1alpha1.CatalogSpecRepository{
+				{
+					Type: repositoryType,
+					URL:  catalog.Spec.Storage.URL,
+				},
+			},
+		})
+	}
+
+	return patched(patches)
+}
+
+// validateCatalog rejects catalog CRs without repositories or with
+// repositories app-operator cannot pull from.
+func (w *Webhook) validateCatalog(ctx context.Context, request *admissionv1.AdmissionRequest) (*admissionv1.AdmissionResponse, error) {
+	if request.Operation != admissionv1.Create && request.Operation != admissionv1.Update {
+		return allowed(), nil
+	}
+
+	catalog, err := decodeCatalog(request.Object.Raw)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	if catalog.DeletionTimestamp != nil {
+		return allowed(), nil
+	}
+
+	problems := validateCatalogSpec(catalog.Spec)
+	if len(problems) > 0 {
+		return denied(fmt.Sprintf("catalog %#q is invalid: %s", catalog.Name, strings.Join(problems, ", "))), nil
+	}
+
+	return allowed(), nil
+}
+
+func validateCatalogSpec(spec v1alpha1.CatalogSpec) []string {
+	var problems []string
+
+	if len(spec.Repositories) == 0 && spec.Storage.URL == "" {
+		problems = append(problems, "one of .spec.repositories or .spec.storage.URL must be set")
+	}
+
+	if spec.Storage.URL != "" {
+		problems = append(problems, validateRepository(".spec.storage", storageType(spec.Storage), spec.Storage.URL)...)
+	}
+	for i, r := range spec.Repositories {
+		problems = append(problems, validateRepository(fmt.Sprintf(".spec.repositories[%d]", i), r.Type, r.URL)...)
+	}
+
+	if spec.Config != nil {
+		if spec.Config.ConfigMap != nil && (spec.Config.ConfigMap.Name == "" || spec.Config.ConfigMap.Namespace == "") {
+			problems = append(problems, ".spec.config.configMap name and namespace must be set")
+		}
+		if spec.Config.Secret != nil && (spec.Config.Secret.Name == "" || spec.Config.Secret.Namespace == "") {
+			problems = append(problems, ".spec.config.secret name and namespace must be set")
+		}
+	}
+
+	return problems
+}
+
+// storageType returns the type of the deprecated storage field. Catalog CRs
+// created before the field was validated may omit it so it defaults to
+// helm like in the mutating webhook.
+func storageType(storage v1alpha1.CatalogSpecStorage) string {
+	if storage.Type == "" {
+		return repositoryTypeHelm
+	}
+
+	return storage.Type
+}
+
+func validateRepository(path, repositoryType, rawURL string) []string {
+	var problems []string
+
+	if repositoryType != repositoryTypeHelm && repositoryType != repositoryTypeOCI {
+		problems = append(problems, fmt.Sprintf("%s.type must be one of %#q or %#q but got %#q", path, repositoryTypeHelm, repositoryTypeOCI, repositoryType))
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		problems = append(problems, fmt.Sprintf("%s.URL %#q is not a valid URL", path, rawURL))
+		return problems
+	}
+
+	switch repositoryType {
+	case repositoryTypeHelm:
+		if u.Scheme != "http" && u.Scheme != "https" {
+			problems = append(problems, fmt.Sprintf("%s.URL %#q must use scheme http or https for type %#q", path, rawURL, repositoryType))
+		}
+	case repositoryTypeOCI:
+		if u.Scheme != "oci" {
+			problems = append(problems, fmt.Sprintf("%s.URL %#q must use scheme oci for type %#q", path, rawURL, repositoryType))
+		}
+	}
+
+	return problems
+}
+
+func decodeCatalog(raw []byte) (v1alpha1.Catalog, error) {
+	var catalog v1alpha1.Catalog
+
+	err := json.Unmarshal(raw, &catalog)
+	if err != nil {
+		return v1alpha1.Catalog{}, microerror.Maskf(decodeFailedError, "catalog: %s", err)
+	}
+
+	return catalog, nil
+}
@@ -0,0 +1,54 @@
+package webhook
+
+import (
+	"crypto/tls"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/microerror"
+)
+
+// certLoader loads the TLS certificate from disk and reloads it when the
+// files change. Certificates mounted from secrets are rotated in place by the
+// kubelet so they must not be cached forever.
+type certLoader struct {
+	crtFile string
+	keyFile string
+
+	mutex   sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func newCertLoader(crtFile, keyFile string) *certLoader {
+	return &certLoader{
+		crtFile: crtFile,
+		keyFile: keyFile,
+	}
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (l *certLoader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	info, err := os.Stat(l.crtFile)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	if l.cert != nil && !info.ModTime().After(l.modTime) {
+		return l.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(l.crtFile, l.keyFile)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	l.cert = &cert
+	l.modTime = info.ModTime()
+
+	return l.cert, nil
+}
@@ -0,0 +1,21 @@
+package webhook
+
+import "github.com/giantswarm/microerror"
+
+var decodeFailedError = &microerror.Error{
+	Kind: "decodeFailedError",
+}
+
+// IsDecodeFailed asserts decodeFailedError.
+func IsDecodeFailed(err error) bool {
+	return microerror.Cause(err) == decodeFailedError
+}
+
+var invalidConfigError = &microerror.Error{
+	Kind: "invalidConfigError",
+}
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
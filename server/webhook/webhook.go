@@ -0,0 +1,144 @@
+// Package webhook implements the validating and mutating admission webhooks
+// for app and catalog CRs. App CRs are validated with the same validator used
+// by the validation resource so invalid CRs are rejected when they are
+// applied instead of failing during reconciliation.
+package webhook
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/giantswarm/app/v8/pkg/validation"
+	"github.com/giantswarm/k8sclient/v7/pkg/k8sclient"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+)
+
+const (
+	// MutateAppPath is the path of the mutating webhook for app CRs.
+	MutateAppPath = "/mutate/app"
+	// MutateCatalogPath is the path of the mutating webhook for catalog CRs.
+	MutateCatalogPath = "/mutate/catalog"
+	// ValidateAppPath is the path of the validating webhook for app CRs.
+	ValidateAppPath = "/validate/app"
+	// ValidateCatalogPath is the path of the validating webhook for catalog
+	// CRs.
+	ValidateCatalogPath = "/validate/catalog"
+
+	shutdownTimeout = 5 * time.Second
+)
+
+// Config represents the configuration used to create a new webhook server.
+type Config struct {
+	K8sClient k8sclient.Interface
+	Logger    micrologger.Logger
+
+	// CrtFile and KeyFile are the paths of the TLS certificate and key. They
+	// are usually mounted from a secret and reloaded when they change.
+	CrtFile       string
+	KeyFile       string
+	ListenAddress string
+	Provider      string
+}
+
+// Webhook serves the admission webhooks over HTTPS.
+type Webhook struct {
+	logger       micrologger.Logger
+	appValidator *validation.Validator
+
+	httpServer *http.Server
+}
+
+// New creates a new configured webhook server.
+func New(config Config) (*Webhook, error) {
+	if config.K8sClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.K8sClient must not be empty", config)
+	}
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+
+	if config.CrtFile == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.CrtFile must not be empty", config)
+	}
+	if config.KeyFile == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.KeyFile must not be empty", config)
+	}
+	if config.ListenAddress == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.ListenAddress must not be empty", config)
+	}
+	if config.Provider == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Provider must not be empty", config)
+	}
+
+	var err error
+
+	var appValidator *validation.Validator
+	{
+		c := validation.Config{
+			G8sClient: config.K8sClient.CtrlClient(),
+			K8sClient: config.K8sClient.K8sClient(),
+			Logger:    config.Logger,
+
+			IsAdmissionController: true,
+			Provider:              config.Provider,
+		}
+		appValidator, err = validation.NewValidator(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	certs := newCertLoader(config.CrtFile, config.KeyFile)
+
+	w := &Webhook{
+		logger:       config.Logger,
+		appValidator: appValidator,
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(MutateAppPath, w.serveAdmission(w.mutateApp))
+	mux.Handle(MutateCatalogPath, w.serveAdmission(w.mutateCatalog))
+	mux.Handle(ValidateAppPath, w.serveAdmission(w.validateApp))
+	mux.Handle(ValidateCatalogPath, w.serveAdmission(w.validateCatalog))
+
+	w.httpServer = &http.Server{
+		Addr:              config.ListenAddress,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		TLSConfig: &tls.Config{
+			GetCertificate: certs.GetCertificate,
+			MinVersion:     tls.VersionTLS12,
+		},
+	}
+
+	return w, nil
+}
+
+// Boot starts serving the webhooks and blocks until the context is canceled.
+func (w *Webhook) Boot(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		err := w.httpServer.Shutdown(shutdownCtx)
+		if err != nil {
+			w.logger.Errorf(ctx, err, "failed to shut down webhook server")
+		}
+	}()
+
+	w.logger.Debugf(ctx, "serving admission webhooks on %#q", w.httpServer.Addr)
+
+	// Certificate and key are provided by GetCertificate.
+	err := w.httpServer.ListenAndServeTLS("", "")
+	if errors.Is(err, http.ErrServerClosed) {
+		// fall through
+	} else if err != nil {
+		w.logger.Errorf(ctx, err, "failed to serve admission webhooks")
+	}
+}
@@ -0,0 +1,230 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/k8sclient/v7/pkg/k8sclienttest"
+	"github.com/giantswarm/micrologger/microloggertest"
+	"github.com/google/go-cmp/cmp"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgofake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake" //nolint:staticcheck
+)
+
+func Test_Webhook_Catalog(t *testing.T) {
+	tests := []struct {
+		name            string
+		path            string
+		catalog         v1alpha1.Catalog
+		expectedAllowed bool
+		expectedMessage string
+		expectedPatch   string
+	}{
+		{
+			name: "case 0: valid helm catalog is allowed",
+			path: ValidateCatalogPath,
+			catalog: newTestCatalog(v1alpha1.CatalogSpecRepository{
+				Type: "helm",
+				URL:  "https://giantswarm.github.io/giantswarm-catalog/",
+			}),
+			expectedAllowed: true,
+		},
+		{
+			name: "case 1: valid oci catalog is allowed",
+			path: ValidateCatalogPath,
+			catalog: newTestCatalog(v1alpha1.CatalogSpecRepository{
+				Type: "oci",
+				URL:  "oci://giantswarmpublic.azurecr.io/giantswarm-catalog/",
+			}),
+			expectedAllowed: true,
+		},
+		{
+			name:            "case 2: catalog without repositories is denied",
+			path:            ValidateCatalogPath,
+			catalog:         newTestCatalog(),
+			expectedAllowed: false,
+			expectedMessage: "catalog `giantswarm` is invalid: one of .spec.repositories or .spec.storage.URL must be set",
+		},
+		{
+			name: "case 3: catalog with mismatched scheme is denied",
+			path: ValidateCatalogPath,
+			catalog: newTestCatalog(v1alpha1.CatalogSpecRepository{
+				Type: "oci",
+				URL:  "https://giantswarm.github.io/giantswarm-catalog/",
+			}),
+			expectedAllowed: false,
+			expectedMessage: "catalog `giantswarm` is invalid: .spec.repositories[0].URL `https://giantswarm.github.io/giantswarm-catalog/` must use scheme oci for type `oci`",
+		},
+		{
+			name: "case 4: catalog with unknown type is denied",
+			path: ValidateCatalogPath,
+			catalog: newTestCatalog(v1alpha1.CatalogSpecRepository{
+				Type: "git",
+				URL:  "https://github.com/giantswarm/giantswarm-catalog",
+			}),
+			expectedAllowed: false,
+			expectedMessage: "catalog `giantswarm` is invalid: .spec.repositories[0].type must be one of `helm` or `oci` but got `git`",
+		},
+		{
+			name: "case 5: repositories are defaulted from storage",
+			path: MutateCatalogPath,
+			catalog: func() v1alpha1.Catalog {
+				c := newTestCatalog()
+				c.Spec.Storage = v1alpha1.CatalogSpecStorage{
+					URL: "https://giantswarm.github.io/giantswarm-catalog/",
+				}
+				return c
+			}(),
+			expectedAllowed: true,
+			expectedPatch:   `[{"op":"add","path":"/spec/storage/type","value":"helm"},{"op":"add","path":"/spec/repositories","value":[{"type":"helm","URL":"https://giantswarm.github.io/giantswarm-catalog/"}]}]`,
+		},
+		{
+			name: "case 6: catalog with repositories is not mutated",
+			path: MutateCatalogPath,
+			catalog: newTestCatalog(v1alpha1.CatalogSpecRepository{
+				Type: "helm",
+				URL:  "https://giantswarm.github.io/giantswarm-catalog/",
+			}),
+			expectedAllowed: true,
+		},
+		{
+			name: "case 7: catalog with storage without type is allowed",
+			path: ValidateCatalogPath,
+			catalog: func() v1alpha1.Catalog {
+				c := newTestCatalog()
+				c.Spec.Storage = v1alpha1.CatalogSpecStorage{
+					URL: "https://giantswarm.github.io/giantswarm-catalog/",
+				}
+				return c
+			}(),
+			expectedAllowed: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			response := admit(t, tc.path, tc.catalog)
+
+			if response.UID != "test-uid" {
+				t.Fatalf("expected UID %#q got %#q", "test-uid", response.UID)
+			}
+			if response.Allowed != tc.expectedAllowed {
+				t.Fatalf("expected allowed %t got %t", tc.expectedAllowed, response.Allowed)
+			}
+
+			var message string
+			if response.Result != nil {
+				message = response.Result.Message
+			}
+			if !cmp.Equal(message, tc.expectedMessage) {
+				t.Fatalf("want matching message \n %s", cmp.Diff(message, tc.expectedMessage))
+			}
+			if !cmp.Equal(string(response.Patch), tc.expectedPatch) {
+				t.Fatalf("want matching patch \n %s", cmp.Diff(string(response.Patch), tc.expectedPatch))
+			}
+		})
+	}
+}
+
+func Test_Webhook_MutateApp(t *testing.T) {
+	app := v1alpha1.App{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kiam",
+			Namespace: "giantswarm",
+		},
+		Spec: v1alpha1.AppSpec{
+			KubeConfig: v1alpha1.AppSpecKubeConfig{
+				InCluster: true,
+			},
+		},
+	}
+
+	response := admit(t, MutateAppPath, app)
+
+	expectedPatch := `[{"op":"add","path":"/metadata/labels","value":{}},{"op":"add","path":"/metadata/labels/app-operator.giantswarm.io~1version","value":"0.0.0"}]`
+	if !cmp.Equal(string(response.Patch), expectedPatch) {
+		t.Fatalf("want matching patch \n %s", cmp.Diff(string(response.Patch), expectedPatch))
+	}
+}
+
+func admit(t *testing.T, path string, obj interface{}) *admissionv1.AdmissionResponse {
+	t.Helper()
+
+	s := runtime.NewScheme()
+	err := v1alpha1.AddToScheme(s)
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	c := Config{
+		K8sClient: k8sclienttest.NewClients(k8sclienttest.ClientsConfig{
+			CtrlClient: fake.NewClientBuilder().WithScheme(s).Build(), //nolint:staticcheck
+			K8sClient:  clientgofake.NewClientset(),
+		}),
+		Logger: microloggertest.New(),
+
+		CrtFile:       "/etc/webhook/certs/tls.crt",
+		KeyFile:       "/etc/webhook/certs/tls.key",
+		ListenAddress: ":8443",
+		Provider:      "aws",
+	}
+	w, err := New(c)
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	review := admissionv1.AdmissionReview{
+		Request: &admissionv1.AdmissionRequest{
+			UID:       "test-uid",
+			Operation: admissionv1.Create,
+			Object:    runtime.RawExtension{Raw: raw},
+		},
+	}
+	body, err := json.Marshal(review)
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	w.httpServer.Handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status code %d got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	var result admissionv1.AdmissionReview
+	err = json.Unmarshal(rec.Body.Bytes(), &result)
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	return result.Response
+}
+
+func newTestCatalog(repositories ...v1alpha1.CatalogSpecRepository) v1alpha1.Catalog {
+	return v1alpha1.Catalog{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "giantswarm",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.CatalogSpec{
+			Title:        "Giant Swarm",
+			Repositories: repositories,
+		},
+	}
+}
@@ -20,16 +20,30 @@ import (
 	"github.com/giantswarm/app-operator/v7/service/controller/app/controllercontext"
 	"github.com/giantswarm/app-operator/v7/service/internal/clientcache"
 	"github.com/giantswarm/app-operator/v7/service/internal/indexcache"
+	"github.com/giantswarm/app-operator/v7/service/internal/recorder"
+	"github.com/giantswarm/app-operator/v7/service/internal/secretstore"
+	"github.com/giantswarm/app-operator/v7/service/internal/shard"
+	"github.com/giantswarm/app-operator/v7/service/internal/valuesschema"
 )
 
 const appControllerSuffix = "-app"
 
 type Config struct {
+	Event       recorder.Interface
 	Fs          afero.Fs
 	K8sClient   k8sclient.Interface
 	ClientCache *clientcache.Resource
 	IndexCache  indexcache.Interface
 	Logger      micrologger.Logger
+	// ValuesSchema is optional. When nil merged values are not validated
+	// against the values schema of the chart.
+	ValuesSchema valuesschema.Interface
+	// SecretStore is optional. When nil secret store value references fail
+	// to resolve.
+	SecretStore secretstore.Interface
+	// Sharder is optional. When set only the app CRs of the shard of this
+	// replica are reconciled.
+	Sharder shard.Interface
 
 	ChartNamespace               string
 	HTTPClientTimeout            time.Duration
@@ -41,6 +55,27 @@ type Config struct {
 	WatchNamespace               string
 	WorkloadClusterID            string
 	DependencyWaitTimeoutMinutes int
+	// ImmutableValues enables immutable, content-hashed generations of the
+	// generated configmaps and secrets. ValuesRetention generations are
+	// kept for rollbacks.
+	ImmutableValues bool
+	ValuesRetention int
+	// ValuesCompression enables gzip and base64 encoding of merged values
+	// approaching the size limit of configmaps and secrets.
+	ValuesCompression bool
+	// MaintenanceWindowSchedule, MaintenanceWindowDuration and
+	// MaintenanceWindowTimezone define the default maintenance window of
+	// app CRs. Updates are applied immediately when the schedule is empty.
+	MaintenanceWindowSchedule string
+	MaintenanceWindowDuration string
+	MaintenanceWindowTimezone string
+	// ValueRefNamespaces are the namespaces value references may point to
+	// besides the namespace of the app CR.
+	ValueRefNamespaces []string
+	// SecretStorePathPrefixes are the prefixes by provider the paths of
+	// secret store references must start with, followed by the namespace
+	// of the app CR.
+	SecretStorePathPrefixes map[string]string
 }
 
 type App struct {
@@ -53,6 +88,9 @@ func NewApp(config Config) (*App, error) {
 	if config.ClientCache == nil {
 		return nil, microerror.Maskf(invalidConfigError, "%T.ClientCache must not be empty", config)
 	}
+	if config.Event == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Event must not be empty", config)
+	}
 	if config.Fs == nil {
 		return nil, microerror.Maskf(invalidConfigError, "%T.Fs must not be empty", config)
 	}
@@ -110,11 +148,15 @@ func NewApp(config Config) (*App, error) {
 	var resources []resource.Interface
 	{
 		c := appResourcesConfig{
-			ClientCache: config.ClientCache,
-			FileSystem:  config.Fs,
-			IndexCache:  config.IndexCache,
-			K8sClient:   config.K8sClient,
-			Logger:      config.Logger,
+			ClientCache:  config.ClientCache,
+			Event:        config.Event,
+			FileSystem:   config.Fs,
+			IndexCache:   config.IndexCache,
+			K8sClient:    config.K8sClient,
+			Logger:       config.Logger,
+			ValuesSchema: config.ValuesSchema,
+			SecretStore:  config.SecretStore,
+			Sharder:      config.Sharder,
 
 			ChartNamespace:               config.ChartNamespace,
 			HTTPClientTimeout:            config.HTTPClientTimeout,
@@ -124,6 +166,14 @@ func NewApp(config Config) (*App, error) {
 			UniqueApp:                    config.UniqueApp,
 			WorkloadClusterID:            config.WorkloadClusterID,
 			DependencyWaitTimeoutMinutes: config.DependencyWaitTimeoutMinutes,
+			ImmutableValues:              config.ImmutableValues,
+			ValuesRetention:              config.ValuesRetention,
+			ValuesCompression:            config.ValuesCompression,
+			MaintenanceWindowSchedule:    config.MaintenanceWindowSchedule,
+			MaintenanceWindowDuration:    config.MaintenanceWindowDuration,
+			MaintenanceWindowTimezone:    config.MaintenanceWindowTimezone,
+			ValueRefNamespaces:           config.ValueRefNamespaces,
+			SecretStorePathPrefixes:      config.SecretStorePathPrefixes,
 		}
 
 		resources, err = newAppResources(c)
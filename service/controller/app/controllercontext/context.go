@@ -17,6 +17,7 @@ type Context struct {
 	Catalog v1alpha1.Catalog
 	Clients Clients
 	Status  Status
+	Values  Values
 }
 
 type Clients struct {
@@ -24,6 +25,20 @@ type Clients struct {
 	Helm helmclient.Interface
 }
 
+// Values holds the merged values generated by the configmap and secret
+// resources so they can be validated before the chart CR is created.
+// ConfigMapName and SecretName are the names of the generated objects the
+// chart CR is pointed at. ConfigMapEncoding and SecretEncoding are the
+// encodings of their values, if any.
+type Values struct {
+	ConfigMap         map[string]interface{}
+	ConfigMapEncoding string
+	ConfigMapName     string
+	Secret            map[string]interface{}
+	SecretEncoding    string
+	SecretName        string
+}
+
 type Status struct {
 	ChartStatus   ChartStatus
 	ClusterStatus ClusterStatus
@@ -5,6 +5,7 @@ import (
 	"reflect"
 
 	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/app/v8/pkg/key"
 	"github.com/giantswarm/microerror"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 
@@ -31,13 +32,26 @@ func (r *Resource) ApplyCreateChange(ctx context.Context, obj, createChange inte
 
 	err = cc.Clients.K8s.CtrlClient().Create(ctx, chart)
 	if apierrors.IsAlreadyExists(err) {
+		// The existing chart CR may point at another generation so nothing
+		// is pruned.
 		r.logger.Debugf(ctx, "already created Chart CR %#q in namespace %#q", chart.Name, chart.Namespace)
+		return nil
 	} else if err != nil {
 		return microerror.Mask(err)
 	}
 
 	r.logger.Debugf(ctx, "created Chart CR %#q in namespace %#q", chart.Name, chart.Namespace)
 
+	cr, err := key.ToApp(obj)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = r.pruneGenerations(ctx, cc.Clients.K8s.K8sClient(), cr, chart)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
 	return nil
 }
 
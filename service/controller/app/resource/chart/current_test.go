@@ -16,8 +16,10 @@ import (
 	clientgofake "k8s.io/client-go/kubernetes/fake"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake" //nolint:staticcheck
 
+	"github.com/giantswarm/app-operator/v7/pkg/maintenancewindow"
 	"github.com/giantswarm/app-operator/v7/service/controller/app/controllercontext"
 	"github.com/giantswarm/app-operator/v7/service/internal/indexcache/indexcachetest"
+	"github.com/giantswarm/app-operator/v7/service/internal/recorder"
 )
 
 func Test_CordonUntil(t *testing.T) {
@@ -105,13 +107,17 @@ func Test_CordonUntil(t *testing.T) {
 			s.AddKnownTypes(v1alpha1.SchemeGroupVersion, &v1alpha1.AppList{})
 
 			c := Config{
+				Event: recorder.New(recorder.Config{
+					K8sClient: k8sclienttest.NewClients(k8sclienttest.ClientsConfig{}),
+				}),
 				IndexCache: indexcachetest.New(indexcachetest.Config{
 					GetIndexResponse: nil,
 				}),
 
-				Logger:        microloggertest.New(),
-				CtrlClient:    fake.NewClientBuilder().WithScheme(s).Build(), //nolint:staticcheck
-				DynamicClient: dynamicfake.NewSimpleDynamicClient(s),
+				Logger:            microloggertest.New(),
+				MaintenanceWindow: &maintenancewindow.Policy{},
+				CtrlClient:        fake.NewClientBuilder().WithScheme(s).Build(), //nolint:staticcheck
+				DynamicClient:     dynamicfake.NewSimpleDynamicClient(s),
 
 				ChartNamespace:               "giantswarm",
 				DependencyWaitTimeoutMinutes: 30,
@@ -4,15 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"net/url"
-	"path"
 	"strings"
 	"time"
 
 	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
 	"github.com/giantswarm/app/v8/pkg/key"
-	"github.com/giantswarm/appcatalog"
-	"github.com/giantswarm/errors/tenant"
 	"github.com/giantswarm/k8smetadata/pkg/annotation"
 	"github.com/giantswarm/k8smetadata/pkg/label"
 	"github.com/giantswarm/microerror"
@@ -20,19 +16,18 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/giantswarm/app-operator/v7/pkg/project"
 	"github.com/giantswarm/app-operator/v7/pkg/status"
+	"github.com/giantswarm/app-operator/v7/pkg/valueref"
+	"github.com/giantswarm/app-operator/v7/pkg/valuesencoding"
+	"github.com/giantswarm/app-operator/v7/pkg/valuesgeneration"
 	"github.com/giantswarm/app-operator/v7/service/controller/app/controllercontext"
-	"github.com/giantswarm/app-operator/v7/service/internal/indexcache"
 )
 
 const (
-	chartPullFailedStatus = "chart-pull-failed"
-
 	annotationChartOperatorPause                = "chart-operator.giantswarm.io/paused"
 	annotationChartOperatorPauseReason          = "app-operator.giantswarm.io/pause-reason"
 	annotationChartOperatorPauseStarted         = "app-operator.giantswarm.io/pause-ts"
@@ -64,38 +59,37 @@ func (r *Resource) GetDesiredState(ctx context.Context, obj interface{}) (interf
 		return chartCR, nil
 	}
 
-	config, err := generateConfig(ctx, cc.Clients.K8s.K8sClient(), cr, cc.Catalog, r.chartNamespace)
-	if err != nil {
-		return nil, microerror.Mask(err)
+	// Values not matching the values schema of the chart are not written
+	// by the configmap and secret resources so the chart CR is not updated
+	// either.
+	if cc.Status.ChartStatus.Status == status.ValuesSchemaInvalidStatus {
+		r.logger.Debugf(ctx, "canceling resource since merged values do not match the values schema")
+		resourcecanceledcontext.SetCanceled(ctx)
+		return nil, nil
 	}
 
-	repositoryURL, err := r.pickRepositoryURL(ctx, cc, cr, chartName)
+	configMapName, secretName := r.valuesNames(cc, cr)
+
+	config, encodings, err := generateConfig(ctx, cc.Clients.K8s.K8sClient(), cr, cc.Catalog, r.chartNamespace, configMapName, secretName)
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
-	repositories := []string{repositoryURL}
 
-	if key.CatalogVisibility(cc.Catalog) != "internal" {
-		repositories = append(repositories, fallbackRepositories(cc.Catalog, repositoryURL)...)
-	}
-
-	var tarballURL, version string
-	for _, url := range repositories {
-		tarballURL, version, err = r.buildTarballURL(ctx, cc, cr, url)
-		if err == nil {
-			r.logger.Debugf(ctx, "found a working tarball URL in repository %#q", url)
-			break
-		} else {
-			r.logger.Errorf(ctx, err, "failed to resolve tarball URL for %#q repository", url)
-		}
-	}
+	chartTarball, err := r.tarballs.Resolve(ctx, cc.Clients.K8s.CtrlClient(), cr, cc.Catalog, chartName)
 	if err != nil {
-		setStatus(cc, err)
+		err = setStatus(cc, err)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
 		resourcecanceledcontext.SetCanceled(ctx)
 		return nil, nil
 	}
 
 	annotations := generateAnnotations(cr.GetAnnotations(), cr.Namespace, cr.Name)
+	for k, v := range encodings {
+		annotations[k] = v
+	}
 	depsNotInstalled, err := r.checkDependencies(ctx, cr)
 	if err != nil {
 		return nil, microerror.Mask(err)
@@ -129,8 +123,8 @@ func (r *Resource) GetDesiredState(ctx context.Context, obj interface{}) (interf
 			Rollback:   generateRollback(cr),
 			Uninstall:  generateUninstall(cr),
 			Upgrade:    generateUpgrade(cr),
-			TarballURL: tarballURL,
-			Version:    version,
+			TarballURL: chartTarball.URL,
+			Version:    chartTarball.Version,
 		},
 	}
 
@@ -256,131 +250,6 @@ func (r *Resource) checkDependencies(ctx context.Context, app v1alpha1.App) ([]s
 	return nil, nil
 }
 
-func (r *Resource) pickRepositoryURL(ctx context.Context, cc *controllercontext.Context, cr v1alpha1.App, chartName string) (string, error) {
-	switch len(cc.Catalog.Spec.Repositories) {
-	case 0:
-		return cc.Catalog.Spec.Storage.URL, nil
-	case 1:
-		return cc.Catalog.Spec.Repositories[0].URL, nil
-	}
-
-	var chart v1alpha1.Chart
-	err := cc.Clients.K8s.CtrlClient().Get(
-		ctx,
-		types.NamespacedName{Name: chartName, Namespace: r.chartNamespace},
-		&chart,
-	)
-	if apierrors.IsNotFound(err) || tenant.IsAPINotAvailable(err) {
-		// Repositories is guaranteed by Custom Resource Definition to have at least one entry.
-		return cc.Catalog.Spec.Repositories[0].URL, nil
-	} else if err != nil {
-		return "", microerror.Mask(err)
-	}
-
-	// Check currently selected repository
-	repositoryIndex := -1
-	for i, repo := range cc.Catalog.Spec.Repositories {
-		if strings.Contains(chart.Spec.TarballURL, repo.URL) {
-			repositoryIndex = i
-			break
-		}
-	}
-	if repositoryIndex == -1 {
-		// Could not match current tarballURL to any of Catalog's repositories.
-		// Maybe the list was updated. Let's pick any existing repository.
-		r.logger.Debugf(ctx, "could not match tarball URL %q to any of %q Catalog repositories; using default", chart.Spec.TarballURL, cc.Catalog.Name)
-		return cc.Catalog.Spec.Repositories[0].URL, nil
-	}
-
-	if chart.Status.Release.Status == chartPullFailedStatus {
-		// chart-operator had trouble pulling the chart -- this includes timeouts and chart not being found (404)
-		// Round-robin the repository.
-		repositoryIndex = (repositoryIndex + 1) % len(cc.Catalog.Spec.Repositories)
-	}
-	return cc.Catalog.Spec.Repositories[repositoryIndex].URL, nil
-}
-
-func (r *Resource) buildTarballURL(ctx context.Context, cc *controllercontext.Context, cr v1alpha1.App, repositoryURL string) (url string, version string, err error) {
-	if key.CatalogVisibility(cc.Catalog) == "internal" || isOCIRepositoryURL(repositoryURL) {
-		// For internal catalogs we generate the URL as its predictable
-		// and to avoid having chicken egg problems.
-		// For OCI repositories there is no discovery mechanism, so we just
-		// make an assumption about URL format.
-		url, err = appcatalog.NewTarballURL(repositoryURL, key.AppName(cr), key.Version(cr))
-		if err != nil {
-			return "", "", microerror.Mask(err)
-		}
-		version = key.Version(cr)
-		return url, version, nil
-	}
-
-	// For all other catalogs we check the index.yaml for compatibility
-	// with community catalogs.
-	index, err := r.indexCache.GetIndex(ctx, repositoryURL)
-	if err != nil {
-		r.logger.Errorf(ctx, err, "failed to get index.yaml from %q", repositoryURL)
-	}
-	if index == nil {
-		return "", "", microerror.Maskf(indexNotFoundError, "index %#v for %q is <nil>", index, repositoryURL)
-	}
-	if len(index.Entries) == 0 {
-		return "", "", microerror.Maskf(catalogEmptyError, "index %#v for %q has no entries", index, repositoryURL)
-	}
-
-	entries, ok := index.Entries[cr.Spec.Name]
-	if !ok {
-		return "", "", microerror.Maskf(appNotFoundError, "no entries for app %#q in index.yaml for %q", cr.Spec.Name, repositoryURL)
-	}
-
-	// We first try with the full version set in .spec.version of the app CR.
-	version = cr.Spec.Version
-	url, err = getEntryURL(entries, cr.Spec.Name, version)
-	if err != nil {
-		// We try again without the `v` prefix. This enables us to use the
-		// Flux Image Automation controller to automatically update apps.
-		version = strings.TrimPrefix(version, "v")
-
-		url, err = getEntryURL(entries, cr.Spec.Name, version)
-		if err != nil {
-			return "", "", microerror.Mask(err)
-		}
-	}
-
-	if url == "" {
-		return "", "", microerror.Maskf(appVersionNotFoundError, "found entry for app %#q but URL is not specified", cr.Spec.Name)
-	}
-
-	if !isValidURL(url) {
-		// URL may be relative. If so we join it to the Catalog Storage URL.
-		url, err = joinRelativeURL(repositoryURL, url)
-		if err != nil {
-			return "", "", microerror.Mask(err)
-		}
-	}
-
-	return url, version, err
-}
-
-func fallbackRepositories(catalog v1alpha1.Catalog, repositoryURL string) []string {
-	urls := []string{}
-	repositoryIndex := -1
-	for i, repo := range catalog.Spec.Repositories {
-		if repo.URL == repositoryURL {
-			repositoryIndex = i
-		}
-		urls = append(urls, repo.URL)
-	}
-	if repositoryIndex == -1 {
-		// could not find failed repositoryURL, let's just return the whole slice
-		return urls
-	}
-
-	// Return all repositoryURLs, starting with the one after repositoryURL and skip repositoryURL.
-	// example: urls=["a", "b", "c", "d"], repositoryURL="c" -> ["d", "a", "b"]
-	// example: urls=["x"], repositoryURL="x" -> []
-	return append(urls[repositoryIndex+1:], urls[:repositoryIndex]...)
-}
-
 func generateAnnotations(input map[string]string, appNamespace, appName string) map[string]string {
 	annotations := map[string]string{
 		annotation.AppNamespace: appNamespace,
@@ -397,16 +266,43 @@ func generateAnnotations(input map[string]string, appNamespace, appName string)
 	return annotations
 }
 
-func generateConfig(ctx context.Context, k8sClient kubernetes.Interface, cr v1alpha1.App, catalog v1alpha1.Catalog, chartNamespace string) (v1alpha1.ChartSpecConfig, error) {
+// valuesNames returns the names of the configmap and secret the chart CR is
+// pointed at. These are the objects generated by the configmap and secret
+// resources unless a generation is pinned in the app CR.
+func (r *Resource) valuesNames(cc *controllercontext.Context, cr v1alpha1.App) (string, string) {
+	configMapName := key.ChartConfigMapName(cr)
+	if cc.Values.ConfigMapName != "" {
+		configMapName = cc.Values.ConfigMapName
+	}
+	secretName := key.ChartSecretName(cr)
+	if cc.Values.SecretName != "" {
+		secretName = cc.Values.SecretName
+	}
+
+	if r.immutableValues {
+		if pinned := cr.GetAnnotations()[valuesgeneration.PinnedConfigMapAnnotation]; pinned != "" {
+			configMapName = pinned
+		}
+		if pinned := cr.GetAnnotations()[valuesgeneration.PinnedSecretAnnotation]; pinned != "" {
+			secretName = pinned
+		}
+	}
+
+	return configMapName, secretName
+}
+
+// generateConfig returns the config of the chart CR and the annotations
+// declaring the encoding of the referenced values, if any.
+func generateConfig(ctx context.Context, k8sClient kubernetes.Interface, cr v1alpha1.App, catalog v1alpha1.Catalog, chartNamespace, configMapName, secretName string) (v1alpha1.ChartSpecConfig, map[string]string, error) {
 	config := v1alpha1.ChartSpecConfig{}
+	encodings := map[string]string{}
 
 	if hasConfigMap(cr, catalog) {
-		configMapName := key.ChartConfigMapName(cr)
 		cm, err := k8sClient.CoreV1().ConfigMaps(chartNamespace).Get(ctx, configMapName, metav1.GetOptions{})
 		if apierrors.IsNotFound(err) {
 			// no-op
 		} else if err != nil {
-			return v1alpha1.ChartSpecConfig{}, microerror.Mask(err)
+			return v1alpha1.ChartSpecConfig{}, nil, microerror.Mask(err)
 		} else {
 			configMap := v1alpha1.ChartSpecConfigConfigMap{
 				Name:            configMapName,
@@ -415,16 +311,19 @@ func generateConfig(ctx context.Context, k8sClient kubernetes.Interface, cr v1al
 			}
 
 			config.ConfigMap = configMap
+
+			if encoding := cm.GetAnnotations()[valuesencoding.Annotation]; encoding != "" {
+				encodings[valuesencoding.ChartConfigMapAnnotation] = encoding
+			}
 		}
 	}
 
 	if hasSecret(cr, catalog) {
-		secretName := key.ChartSecretName(cr)
 		secret, err := k8sClient.CoreV1().Secrets(chartNamespace).Get(ctx, secretName, metav1.GetOptions{})
 		if apierrors.IsNotFound(err) {
 			// no-op
 		} else if err != nil {
-			return v1alpha1.ChartSpecConfig{}, microerror.Mask(err)
+			return v1alpha1.ChartSpecConfig{}, nil, microerror.Mask(err)
 		} else {
 			secretConfig := v1alpha1.ChartSpecConfigSecret{
 				Name:            secretName,
@@ -433,10 +332,14 @@ func generateConfig(ctx context.Context, k8sClient kubernetes.Interface, cr v1al
 			}
 
 			config.Secret = secretConfig
+
+			if encoding := secret.GetAnnotations()[valuesencoding.Annotation]; encoding != "" {
+				encodings[valuesencoding.ChartSecretAnnotation] = encoding
+			}
 		}
 	}
 
-	return config, nil
+	return config, encodings, nil
 }
 
 func generateInstall(cr v1alpha1.App) v1alpha1.ChartSpecInstall {
@@ -504,22 +407,8 @@ func getDependenciesFromCR(app v1alpha1.App) ([]string, error) {
 	return ret, nil
 }
 
-func getEntryURL(entries []indexcache.Entry, app, version string) (string, error) {
-	for _, e := range entries {
-		if e.Version == version {
-			if len(e.Urls) == 0 {
-				return "", microerror.Maskf(appVersionNotFoundError, "no URL in index.yaml for app %#q version %#q", app, version)
-			}
-
-			return e.Urls[0], nil
-		}
-	}
-
-	return "", microerror.Maskf(appVersionNotFoundError, "no app %#q in index.yaml with given version %#q", app, version)
-}
-
 func hasConfigMap(cr v1alpha1.App, catalog v1alpha1.Catalog) bool {
-	if key.AppConfigMapName(cr) != "" || key.CatalogConfigMapName(catalog) != "" || key.UserConfigMapName(cr) != "" || hasKindInExtraConfigs(cr, "configMap") {
+	if key.AppConfigMapName(cr) != "" || key.CatalogConfigMapName(catalog) != "" || key.UserConfigMapName(cr) != "" || hasKindInExtraConfigs(cr, "configMap") || valueref.HasRefs(cr, valueref.TypeConfigMap) {
 		return true
 	}
 
@@ -527,7 +416,7 @@ func hasConfigMap(cr v1alpha1.App, catalog v1alpha1.Catalog) bool {
 }
 
 func hasSecret(cr v1alpha1.App, catalog v1alpha1.Catalog) bool {
-	if key.AppSecretName(cr) != "" || key.CatalogSecretName(catalog) != "" || key.UserSecretName(cr) != "" || hasKindInExtraConfigs(cr, "secret") {
+	if key.AppSecretName(cr) != "" || key.CatalogSecretName(catalog) != "" || key.UserSecretName(cr) != "" || hasKindInExtraConfigs(cr, "secret") || valueref.HasRefs(cr, valueref.TypeSecret) {
 		return true
 	}
 
@@ -546,30 +435,6 @@ func hasKindInExtraConfigs(cr v1alpha1.App, kind string) bool {
 	return false
 }
 
-func isValidURL(input string) bool {
-	_, err := url.ParseRequestURI(input)
-	if err != nil {
-		return false
-	}
-
-	u, err := url.Parse(input)
-	if err != nil || u.Scheme == "" || u.Host == "" {
-		return false
-	}
-
-	return true
-}
-
-func joinRelativeURL(baseURL, relativeURL string) (string, error) {
-	u, err := url.Parse(baseURL)
-	if err != nil {
-		return "", microerror.Mask(err)
-	}
-
-	u.Path = path.Join(u.Path, relativeURL)
-	return u.String(), nil
-}
-
 // processLabels ensures the chart-operator.giantswarm.io/version label is
 // present and the app-operator.giantswarm.io/version label is removed. It
 // also ensures the giantswarm.io/managed-by label is accurate.
@@ -593,29 +458,18 @@ func processLabels(projectName string, inputLabels map[string]string) map[string
 	return labels
 }
 
-// isOCIRepositoryURL determines whether given URL points to OCI repository. To be used with repositoryURL variable.
-func isOCIRepositoryURL(repositoryURL string) bool {
-	if repositoryURL == "" {
-		return false
+// setStatus sets the status of non retryable errors in the controller
+// context. Errors classified as retryable are returned so the app CR is
+// requeued instead of reporting a transient error as failure. Unclassified
+// errors are reported with the unknown error status so users still see the
+// reason. They are retried when the app CR is resynced.
+func setStatus(cc *controllercontext.Context, err error) error {
+	class := errorClassifier.Classify(err)
+	if class.Retryable && class.Status != status.UnknownError {
+		return microerror.Mask(err)
 	}
-	u, err := url.Parse(repositoryURL)
-	if err != nil {
-		return false
-	}
-	return u.Scheme == "oci"
-}
 
-func setStatus(cc *controllercontext.Context, err error) {
-	switch microerror.Cause(err) {
-	case appNotFoundError:
-		addStatusToContext(cc, err.Error(), status.AppNotFoundStatus)
-	case appVersionNotFoundError:
-		addStatusToContext(cc, err.Error(), status.AppVersionNotFoundStatus)
-	case catalogEmptyError:
-		addStatusToContext(cc, err.Error(), status.CatalogEmptyStatus)
-	case indexNotFoundError:
-		addStatusToContext(cc, err.Error(), status.IndexNotFoundStatus)
-	default:
-		addStatusToContext(cc, err.Error(), status.UnknownError)
-	}
+	addStatusToContext(cc, class.Message(), class.Status)
+
+	return nil
 }
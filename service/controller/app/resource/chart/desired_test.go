@@ -2,15 +2,21 @@ package chart
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"net/url"
 	"reflect"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/app/v8/pkg/key"
 	"github.com/giantswarm/k8sclient/v7/pkg/k8sclienttest"
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger/microloggertest"
@@ -24,9 +30,12 @@ import (
 	clientgofake "k8s.io/client-go/kubernetes/fake"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake" //nolint:staticcheck
 
+	"github.com/giantswarm/app-operator/v7/pkg/maintenancewindow"
+	"github.com/giantswarm/app-operator/v7/pkg/status"
 	"github.com/giantswarm/app-operator/v7/service/controller/app/controllercontext"
 	"github.com/giantswarm/app-operator/v7/service/internal/indexcache"
 	"github.com/giantswarm/app-operator/v7/service/internal/indexcache/indexcachetest"
+	"github.com/giantswarm/app-operator/v7/service/internal/recorder"
 )
 
 func Test_Resource_GetDesiredState(t *testing.T) {
@@ -211,7 +220,7 @@ func Test_Resource_GetDesiredState(t *testing.T) {
 			},
 			expectedChart: &v1alpha1.Chart{},
 			expectedChartStatus: &controllercontext.ChartStatus{
-				Reason: "index not found error: index (*indexcache.Index)(nil) for \"\" is <nil>",
+				Reason: "index not found error: index (*indexcache.Index)(nil) for \"\" is <nil>; remediation: check the catalog URL is correct and serves an index.yaml",
 				Status: "index-not-found",
 			},
 			error: false,
@@ -715,7 +724,7 @@ func Test_Resource_GetDesiredState(t *testing.T) {
 			index:         newIndexWithApp("existing-app", "1.0.0", "https://giantswarm.github.io/app-catalog/existing-app-1.0.0.tgz"),
 			expectedChart: &v1alpha1.Chart{},
 			expectedChartStatus: &controllercontext.ChartStatus{
-				Reason: "app not found error: no entries for app `missing-app` in index.yaml for \"\"",
+				Reason: "app not found error: no entries for app `missing-app` in index.yaml for \"\"; remediation: check .spec.name matches an app in the catalog",
 				Status: "app-not-found",
 			},
 			error: false,
@@ -770,7 +779,7 @@ func Test_Resource_GetDesiredState(t *testing.T) {
 			index:         newIndexWithApp("existing-app", "1.0.0", "https://giantswarm.github.io/app-catalog/existing-app-1.0.0.tgz"),
 			expectedChart: &v1alpha1.Chart{},
 			expectedChartStatus: &controllercontext.ChartStatus{
-				Reason: "app version not found error: no app `existing-app` in index.yaml with given version `2.0.0`",
+				Reason: "app version not found error: no app `existing-app` in index.yaml with given version `2.0.0`; remediation: check .spec.version matches a version of the app in the catalog",
 				Status: "app-version-not-found",
 			},
 			error: false,
@@ -852,12 +861,16 @@ func Test_Resource_GetDesiredState(t *testing.T) {
 			s.AddKnownTypes(v1alpha1.SchemeGroupVersion, &v1alpha1.AppList{})
 
 			c := Config{
+				Event: recorder.New(recorder.Config{
+					K8sClient: k8sclienttest.NewClients(k8sclienttest.ClientsConfig{}),
+				}),
 				IndexCache: indexcachetest.New(indexcachetest.Config{
 					GetIndexResponse: tc.index,
 				}),
-				Logger:        microloggertest.New(),
-				CtrlClient:    fake.NewClientBuilder().WithScheme(s).Build(), //nolint:staticcheck
-				DynamicClient: dynamicfake.NewSimpleDynamicClient(s),
+				Logger:            microloggertest.New(),
+				MaintenanceWindow: &maintenancewindow.Policy{},
+				CtrlClient:        fake.NewClientBuilder().WithScheme(s).Build(), //nolint:staticcheck
+				DynamicClient:     dynamicfake.NewSimpleDynamicClient(s),
 
 				ChartNamespace:               "giantswarm",
 				DependencyWaitTimeoutMinutes: 30,
@@ -1355,10 +1368,14 @@ func Test_Resource_Bulid_TarballURL(t *testing.T) {
 			s.AddKnownTypes(v1alpha1.SchemeGroupVersion, &v1alpha1.AppList{})
 
 			c := Config{
-				IndexCache:    indexcachetest.NewMap(tc.indices),
-				Logger:        microloggertest.New(),
-				CtrlClient:    fake.NewClientBuilder().WithScheme(s).Build(), //nolint:staticcheck
-				DynamicClient: dynamicfake.NewSimpleDynamicClient(s),
+				Event: recorder.New(recorder.Config{
+					K8sClient: k8sclienttest.NewClients(k8sclienttest.ClientsConfig{}),
+				}),
+				IndexCache:        indexcachetest.NewMap(tc.indices),
+				Logger:            microloggertest.New(),
+				MaintenanceWindow: &maintenancewindow.Policy{},
+				CtrlClient:        fake.NewClientBuilder().WithScheme(s).Build(), //nolint:staticcheck
+				DynamicClient:     dynamicfake.NewSimpleDynamicClient(s),
 
 				ChartNamespace:               "giantswarm",
 				DependencyWaitTimeoutMinutes: 30,
@@ -1681,7 +1698,7 @@ func Test_generateConfig(t *testing.T) {
 
 			client := clientgofake.NewClientset(objs...)
 
-			result, err := generateConfig(context.Background(), client, tc.cr, tc.catalog, "giantswarm")
+			result, _, err := generateConfig(context.Background(), client, tc.cr, tc.catalog, "giantswarm", key.ChartConfigMapName(tc.cr), key.ChartSecretName(tc.cr))
 			if err != nil {
 				t.Fatalf("error == %#v, want nil", err)
 			}
@@ -2272,11 +2289,15 @@ func Test_checkDependencies(t *testing.T) {
 			}
 
 			c := Config{
+				Event: recorder.New(recorder.Config{
+					K8sClient: k8sclienttest.NewClients(k8sclienttest.ClientsConfig{}),
+				}),
 				IndexCache: indexcachetest.New(indexcachetest.Config{
 					GetIndexResponse: newIndexWithApp("existing-app", "1.0.0", "https://giantswarm.github.io/app-catalog/existing-app-1.0.0.tgz"),
 				}),
-				Logger:     microloggertest.New(),
-				CtrlClient: fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(objs...).Build(),
+				Logger:            microloggertest.New(),
+				MaintenanceWindow: &maintenancewindow.Policy{},
+				CtrlClient:        fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(objs...).Build(),
 				DynamicClient: dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
 					runtime.NewScheme(),
 					map[schema.GroupVersionResource]string{
@@ -2322,3 +2343,63 @@ func Test_checkDependencies(t *testing.T) {
 		})
 	}
 }
+
+func Test_setStatus(t *testing.T) {
+	unknownError := &microerror.Error{
+		Kind: "unknownError",
+	}
+
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus string
+		errorMatcher   func(error) bool
+	}{
+		{
+			name:           "case 0: non retryable error is set in the status",
+			err:            microerror.Mask(&url.Error{Op: "Get", URL: "ftp://giantswarm.github.io/app-catalog/index.yaml", Err: errors.New("unsupported protocol scheme \"ftp\"")}),
+			expectedStatus: status.CatalogUnreachableStatus,
+		},
+		{
+			name:           "case 1: unclassified error is set in the status",
+			err:            microerror.Maskf(unknownError, "unexpected failure"),
+			expectedStatus: status.UnknownError,
+		},
+		{
+			name: "case 2: refused connection is retryable",
+			err:  microerror.Mask(&url.Error{Op: "Get", URL: "https://giantswarm.github.io/app-catalog/index.yaml", Err: &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}}),
+			errorMatcher: func(err error) bool {
+				return errors.Is(err, syscall.ECONNREFUSED)
+			},
+		},
+		{
+			name: "case 3: timeout is retryable",
+			err:  microerror.Mask(&url.Error{Op: "Get", URL: "https://giantswarm.github.io/app-catalog/index.yaml", Err: context.DeadlineExceeded}),
+			errorMatcher: func(err error) bool {
+				return errors.Is(err, context.DeadlineExceeded)
+			},
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			cc := &controllercontext.Context{}
+
+			err := setStatus(cc, tc.err)
+			switch {
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case err != nil && !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if cc.Status.ChartStatus.Status != tc.expectedStatus {
+				t.Fatalf("status == %#q, want %#q", cc.Status.ChartStatus.Status, tc.expectedStatus)
+			}
+		})
+	}
+}
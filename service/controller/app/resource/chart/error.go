@@ -1,6 +1,16 @@
 package chart
 
-import "github.com/giantswarm/microerror"
+import (
+	"errors"
+	"net/url"
+
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/app-operator/v7/pkg/errorclass"
+	"github.com/giantswarm/app-operator/v7/pkg/status"
+	"github.com/giantswarm/app-operator/v7/service/internal/indexcache"
+	"github.com/giantswarm/app-operator/v7/service/internal/tarball"
+)
 
 var invalidConfigError = &microerror.Error{
 	Kind: "invalidConfigError",
@@ -11,31 +21,6 @@ func IsInvalidConfig(err error) bool {
 	return microerror.Cause(err) == invalidConfigError
 }
 
-var appNotFoundError = &microerror.Error{
-	Kind: "appNotFoundError",
-}
-
-var appVersionNotFoundError = &microerror.Error{
-	Kind: "appVersionNotFoundError",
-}
-
-var catalogEmptyError = &microerror.Error{
-	Kind: "catalogEmptyError",
-}
-
-var indexNotFoundError = &microerror.Error{
-	Kind: "indexNotFoundError",
-}
-
-// IsNotFound asserts:
-// appVersionNotFoundError OR appNotFoundError OR catalogEmptyError.
-func IsNotFound(err error) bool {
-	return microerror.Cause(err) == appNotFoundError ||
-		microerror.Cause(err) == appVersionNotFoundError ||
-		microerror.Cause(err) == catalogEmptyError ||
-		microerror.Cause(err) == indexNotFoundError
-}
-
 var wrongTypeError = &microerror.Error{
 	Kind: "wrongTypeError",
 }
@@ -44,3 +29,50 @@ var wrongTypeError = &microerror.Error{
 func IsWrongType(err error) bool {
 	return microerror.Cause(err) == wrongTypeError
 }
+
+// errorClassifier maps errors resolving the chart tarball URL to the status
+// set in the app CR.
+var errorClassifier = errorclass.New(
+	errorclass.Rule{
+		Match:       tarball.IsAppNotFound,
+		Status:      status.AppNotFoundStatus,
+		Remediation: "check .spec.name matches an app in the catalog",
+	},
+	errorclass.Rule{
+		Match:       tarball.IsAppVersionNotFound,
+		Status:      status.AppVersionNotFoundStatus,
+		Remediation: "check .spec.version matches a version of the app in the catalog",
+	},
+	errorclass.Rule{
+		Match:       tarball.IsCatalogEmpty,
+		Status:      status.CatalogEmptyStatus,
+		Remediation: "check the catalog index.yaml has entries",
+	},
+	errorclass.Rule{
+		Match: func(err error) bool {
+			return tarball.IsIndexNotFound(err) || indexcache.IsNotFound(err)
+		},
+		Status:      status.IndexNotFoundStatus,
+		Remediation: "check the catalog URL is correct and serves an index.yaml",
+	},
+	errorclass.Rule{
+		Match:       errorclass.IsTLSError,
+		Status:      status.TLSErrorStatus,
+		Remediation: "check the certificate of the catalog repository is valid",
+	},
+	errorclass.Rule{
+		Match:     errorclass.IsNetworkError,
+		Status:    status.CatalogUnreachableStatus,
+		Retryable: true,
+	},
+	// Errors which are not transient, e.g. unsupported URL schemes or
+	// unexpected status codes, are reported.
+	errorclass.Rule{
+		Match: func(err error) bool {
+			var urlErr *url.Error
+			return errors.As(err, &urlErr) || indexcache.IsUnexpectedStatusCode(err)
+		},
+		Status:      status.CatalogUnreachableStatus,
+		Remediation: "check the catalog repository is reachable from the management cluster",
+	},
+)
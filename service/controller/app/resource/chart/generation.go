@@ -0,0 +1,93 @@
+package chart
+
+import (
+	"context"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/app/v8/pkg/key"
+	"github.com/giantswarm/microerror"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/giantswarm/app-operator/v7/pkg/valuesgeneration"
+)
+
+// pruneGenerations deletes the configmap and secret generations of the app
+// CR beyond the retention. It is called once the chart CR was created or
+// updated so the generations it points at and the pinned ones are always
+// kept. The configmap and secret updated in place before generations were
+// enabled are deleted as well once the chart CR no longer points at them.
+func (r *Resource) pruneGenerations(ctx context.Context, k8sClient kubernetes.Interface, cr v1alpha1.App, chart *v1alpha1.Chart) error {
+	if !r.immutableValues {
+		return nil
+	}
+
+	{
+		list, err := k8sClient.CoreV1().ConfigMaps(r.chartNamespace).List(ctx, metav1.ListOptions{
+			LabelSelector: valuesgeneration.Selector(cr.Name),
+		})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		generations := make([]metav1.ObjectMeta, 0, len(list.Items))
+		for _, cm := range list.Items {
+			generations = append(generations, cm.ObjectMeta)
+		}
+
+		names := pruneNames(generations, r.valuesRetention, key.ChartConfigMapName(cr), chart.Spec.Config.ConfigMap.Name, cr.GetAnnotations()[valuesgeneration.PinnedConfigMapAnnotation])
+
+		for _, name := range names {
+			err = k8sClient.CoreV1().ConfigMaps(r.chartNamespace).Delete(ctx, name, metav1.DeleteOptions{})
+			if apierrors.IsNotFound(err) {
+				continue
+			} else if err != nil {
+				return microerror.Mask(err)
+			}
+
+			r.logger.Debugf(ctx, "deleted configmap generation %#q in namespace %#q", name, r.chartNamespace)
+		}
+	}
+
+	{
+		list, err := k8sClient.CoreV1().Secrets(r.chartNamespace).List(ctx, metav1.ListOptions{
+			LabelSelector: valuesgeneration.Selector(cr.Name),
+		})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		generations := make([]metav1.ObjectMeta, 0, len(list.Items))
+		for _, s := range list.Items {
+			generations = append(generations, s.ObjectMeta)
+		}
+
+		names := pruneNames(generations, r.valuesRetention, key.ChartSecretName(cr), chart.Spec.Config.Secret.Name, cr.GetAnnotations()[valuesgeneration.PinnedSecretAnnotation])
+
+		for _, name := range names {
+			err = k8sClient.CoreV1().Secrets(r.chartNamespace).Delete(ctx, name, metav1.DeleteOptions{})
+			if apierrors.IsNotFound(err) {
+				continue
+			} else if err != nil {
+				return microerror.Mask(err)
+			}
+
+			r.logger.Debugf(ctx, "deleted secret generation %#q in namespace %#q", name, r.chartNamespace)
+		}
+	}
+
+	return nil
+}
+
+// pruneNames returns the names of the generations to delete. The generation
+// the chart CR points at and the pinned one are kept. The base object is
+// only deleted when the chart CR points at a generation.
+func pruneNames(generations []metav1.ObjectMeta, retention int, base, current, pinned string) []string {
+	names := valuesgeneration.Prune(generations, retention, current, pinned)
+	if current != "" && current != base && pinned != base {
+		names = append(names, base)
+	}
+
+	return names
+}
@@ -13,8 +13,12 @@ import (
 	"k8s.io/client-go/dynamic"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/giantswarm/app-operator/v7/pkg/maintenancewindow"
+	"github.com/giantswarm/app-operator/v7/pkg/valuesencoding"
 	"github.com/giantswarm/app-operator/v7/service/controller/app/controllercontext"
 	"github.com/giantswarm/app-operator/v7/service/internal/indexcache"
+	"github.com/giantswarm/app-operator/v7/service/internal/recorder"
+	"github.com/giantswarm/app-operator/v7/service/internal/tarball"
 )
 
 const (
@@ -29,33 +33,48 @@ const (
 // Config represents the configuration used to create a new chart resource.
 type Config struct {
 	// Dependencies.
+	Event         recorder.Interface
 	IndexCache    indexcache.Interface
 	Logger        micrologger.Logger
 	CtrlClient    client.Client
 	DynamicClient dynamic.Interface
+	// MaintenanceWindow defers updates of Chart CRs to maintenance windows.
+	MaintenanceWindow *maintenancewindow.Policy
 
 	// Settings.
 	ChartNamespace               string
 	WorkloadClusterID            string
 	DependencyWaitTimeoutMinutes int
+	// ImmutableValues honors the generations pinned in app CR annotations
+	// and prunes the generations beyond ValuesRetention once the chart CR
+	// points at the latest one.
+	ImmutableValues bool
+	ValuesRetention int
 }
 
 // Resource implements the chart resource.
 type Resource struct {
 	// Dependencies.
-	indexCache    indexcache.Interface
-	logger        micrologger.Logger
-	ctrlClient    client.Client
-	dynamicClient dynamic.Interface
+	event             recorder.Interface
+	logger            micrologger.Logger
+	ctrlClient        client.Client
+	dynamicClient     dynamic.Interface
+	maintenanceWindow *maintenancewindow.Policy
+	tarballs          *tarball.Resolver
 
 	// Settings.
 	chartNamespace               string
 	workloadClusterID            string
 	dependencyWaitTimeoutMinutes int
+	immutableValues              bool
+	valuesRetention              int
 }
 
 // New creates a new configured chart resource.
 func New(config Config) (*Resource, error) {
+	if config.Event == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Event must not be empty", config)
+	}
 	if config.IndexCache == nil {
 		return nil, microerror.Maskf(invalidConfigError, "%T.IndexCache$ must not be empty", config)
 	}
@@ -68,6 +87,9 @@ func New(config Config) (*Resource, error) {
 	if config.DynamicClient == nil {
 		return nil, microerror.Maskf(invalidConfigError, "%T.DynamicClient must not be empty", config)
 	}
+	if config.MaintenanceWindow == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.MaintenanceWindow must not be empty", config)
+	}
 	if config.DependencyWaitTimeoutMinutes <= 0 {
 		return nil, microerror.Maskf(invalidConfigError, "%T.DependencyWaitTimeoutMinutes must be greater than 0", config)
 	}
@@ -75,16 +97,39 @@ func New(config Config) (*Resource, error) {
 	if config.ChartNamespace == "" {
 		return nil, microerror.Maskf(invalidConfigError, "%T.ChartNamespace must not be empty", config)
 	}
+	if config.ImmutableValues && config.ValuesRetention < 1 {
+		return nil, microerror.Maskf(invalidConfigError, "%T.ValuesRetention must be greater than zero", config)
+	}
+
+	var tarballs *tarball.Resolver
+	{
+		c := tarball.Config{
+			IndexCache: config.IndexCache,
+			Logger:     config.Logger,
+
+			ChartNamespace: config.ChartNamespace,
+		}
+
+		var err error
+		tarballs, err = tarball.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
 
 	r := &Resource{
-		indexCache:    config.IndexCache,
-		logger:        config.Logger,
-		ctrlClient:    config.CtrlClient,
-		dynamicClient: config.DynamicClient,
+		event:             config.Event,
+		logger:            config.Logger,
+		ctrlClient:        config.CtrlClient,
+		dynamicClient:     config.DynamicClient,
+		maintenanceWindow: config.MaintenanceWindow,
+		tarballs:          tarballs,
 
 		chartNamespace:               config.ChartNamespace,
 		workloadClusterID:            config.WorkloadClusterID,
 		dependencyWaitTimeoutMinutes: config.DependencyWaitTimeoutMinutes,
+		immutableValues:              config.ImmutableValues,
+		valuesRetention:              config.ValuesRetention,
 	}
 
 	return r, nil
@@ -170,6 +215,10 @@ func (r *Resource) copyAnnotations(current, desired *v1alpha1.Chart) {
 		} else if k == annotationChartOperatorPause {
 			// Pause annotation is specially managed.
 			continue
+		} else if k == valuesencoding.ChartConfigMapAnnotation || k == valuesencoding.ChartSecretAnnotation {
+			// Encoding annotations are removed when values are no longer
+			// encoded.
+			continue
 		} else if !strings.HasPrefix(k, annotation.ChartOperatorPrefix) {
 			continue
 		}
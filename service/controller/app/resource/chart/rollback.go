@@ -0,0 +1,76 @@
+package chart
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/app/v8/pkg/key"
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/app-operator/v7/pkg/rollback"
+)
+
+// applyRollbackPolicy tracks the releases of the chart CR for app CRs with
+// the auto-rollback policy enabled. When the pending release failed the
+// desired chart CR is pointed at the last good release. The state is kept in
+// an annotation of the chart CR and dropped when the policy is disabled.
+func (r *Resource) applyRollbackPolicy(ctx context.Context, obj, currentResource, desiredResource interface{}) error {
+	cr, err := key.ToApp(obj)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if !rollback.IsEnabled(cr) || key.IsDeleted(cr) {
+		return nil
+	}
+
+	currentChart, err := toChart(currentResource)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	desiredChart, err := toChart(desiredResource)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if desiredChart.Name == "" {
+		return nil
+	}
+
+	var current *v1alpha1.Chart
+	if !reflect.DeepEqual(currentChart, &v1alpha1.Chart{}) {
+		current = currentChart
+	}
+
+	state, err := rollback.FromChart(*currentChart)
+	if rollback.IsInvalidState(err) {
+		r.logger.Debugf(ctx, "resetting invalid rollback state of chart %#q: %s", currentChart.Name, err)
+		state = rollback.State{}
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	next, release, rolledBack := rollback.Next(state, current, rollback.ReleaseOf(*desiredChart), time.Now())
+
+	rollback.Apply(desiredChart, release)
+
+	value, err := rollback.ToAnnotation(next)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	if desiredChart.Annotations == nil {
+		desiredChart.Annotations = map[string]string{}
+	}
+	desiredChart.Annotations[rollback.StateAnnotation] = value
+
+	if rolledBack {
+		message := rollback.Message(next)
+
+		r.logger.Debugf(ctx, "rolling back chart %#q: %s", desiredChart.Name, message)
+		r.event.Warn(ctx, &cr, "RolledBack", "%s", message)
+	}
+
+	return nil
+}
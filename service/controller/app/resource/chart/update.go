@@ -6,10 +6,12 @@ import (
 	"reflect"
 
 	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/app/v8/pkg/key"
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/operatorkit/v7/pkg/resource/crud"
 	"github.com/google/go-cmp/cmp"
 
+	"github.com/giantswarm/app-operator/v7/pkg/chartdrift"
 	"github.com/giantswarm/app-operator/v7/service/controller/app/controllercontext"
 )
 
@@ -38,16 +40,44 @@ func (r *Resource) ApplyUpdateChange(ctx context.Context, obj, updateChange inte
 
 	r.logger.Debugf(ctx, "updated Chart CR %#q in namespace %#q", chart.Name, chart.Namespace)
 
+	cr, err := key.ToApp(obj)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = r.pruneGenerations(ctx, cc.Clients.K8s.K8sClient(), cr, chart)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
 	return nil
 }
 
 func (r *Resource) NewUpdatePatch(ctx context.Context, obj, currentChart, desiredChart interface{}) (*crud.Patch, error) {
+	err := r.applyRollbackPolicy(ctx, obj, currentChart, desiredChart)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	// The hash of the applied spec is recorded after the rollback policy
+	// so changes to the chart CR made in the workload cluster can be
+	// detected by the chartstatus watcher.
+	{
+		desired, err := toChart(desiredChart)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		if desired.Name != "" {
+			chartdrift.SetHash(desired)
+		}
+	}
+
 	create, err := r.newCreateChange(ctx, currentChart, desiredChart)
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
 
-	update, err := r.newUpdateChange(ctx, currentChart, desiredChart)
+	update, err := r.newUpdateChange(ctx, obj, currentChart, desiredChart)
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
@@ -59,7 +89,7 @@ func (r *Resource) NewUpdatePatch(ctx context.Context, obj, currentChart, desire
 	return patch, nil
 }
 
-func (r *Resource) newUpdateChange(ctx context.Context, currentResource, desiredResource interface{}) (interface{}, error) {
+func (r *Resource) newUpdateChange(ctx context.Context, obj, currentResource, desiredResource interface{}) (interface{}, error) {
 	currentChart, err := toChart(currentResource)
 	if err != nil {
 		return nil, microerror.Mask(err)
@@ -84,11 +114,32 @@ func (r *Resource) newUpdateChange(ctx context.Context, currentResource, desired
 	currentChart = copyChart(currentChart)
 	r.copyAnnotations(currentChart, desiredChart)
 
+	// Chart CRs not updated since drift detection was added have no spec
+	// hash. It is recorded with their next change instead of updating all
+	// of them at once when app-operator is upgraded.
+	hash, ok := desiredChart.Annotations[chartdrift.SpecHashAnnotation]
+	if _, recorded := currentChart.Annotations[chartdrift.SpecHashAnnotation]; ok && !recorded {
+		annotations := map[string]string{}
+		for k, v := range currentChart.Annotations {
+			annotations[k] = v
+		}
+		annotations[chartdrift.SpecHashAnnotation] = hash
+		currentChart.Annotations = annotations
+	}
+
 	if !reflect.DeepEqual(currentChart, desiredChart) {
 		if diff := cmp.Diff(currentChart, desiredChart); diff != "" {
 			r.logger.LogCtx(ctx, "level", "debug", "message", fmt.Sprintf("chart %#q has to be updated", currentChart.Name), "diff", fmt.Sprintf("(-current +desired):\n%s", diff))
 		}
 
+		deferred, err := r.deferChange(ctx, obj)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		if deferred {
+			return updateChart, nil
+		}
+
 		updateChart = desiredChart.DeepCopy()
 		updateChart.ResourceVersion = resourceVersion
 		updateChart.Finalizers = finalizers
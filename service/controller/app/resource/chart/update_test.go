@@ -17,13 +17,17 @@ import (
 	clientgofake "k8s.io/client-go/kubernetes/fake"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake" //nolint:staticcheck
 
+	"github.com/giantswarm/app-operator/v7/pkg/chartdrift"
+	"github.com/giantswarm/app-operator/v7/pkg/maintenancewindow"
 	"github.com/giantswarm/app-operator/v7/service/controller/app/controllercontext"
 	"github.com/giantswarm/app-operator/v7/service/internal/indexcache/indexcachetest"
+	"github.com/giantswarm/app-operator/v7/service/internal/recorder"
 )
 
 func Test_Resource_newUpdateChange(t *testing.T) {
 	tests := []struct {
 		name          string
+		app           v1alpha1.App
 		currentChart  *v1alpha1.Chart
 		desiredChart  *v1alpha1.Chart
 		expectedChart *v1alpha1.Chart
@@ -361,6 +365,117 @@ func Test_Resource_newUpdateChange(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "missing spec hash does not update the chart",
+			currentChart: &v1alpha1.Chart{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "my-cool-prometheus",
+					Namespace: "giantswarm",
+				},
+				Spec: v1alpha1.ChartSpec{
+					Name:    "my-cool-prometheus",
+					Version: "1.0.0",
+				},
+			},
+			desiredChart: &v1alpha1.Chart{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "Chart",
+					APIVersion: "application.giantswarm.io",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "my-cool-prometheus",
+					Namespace: "giantswarm",
+					Annotations: map[string]string{
+						chartdrift.SpecHashAnnotation: "0123456789abcdef",
+					},
+				},
+				Spec: v1alpha1.ChartSpec{
+					Name:    "my-cool-prometheus",
+					Version: "1.0.0",
+				},
+			},
+			expectedChart: &v1alpha1.Chart{},
+		},
+		{
+			name: "missing spec hash is recorded when the chart is updated",
+			currentChart: &v1alpha1.Chart{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "my-cool-prometheus",
+					Namespace: "giantswarm",
+				},
+				Spec: v1alpha1.ChartSpec{
+					Name:    "my-cool-prometheus",
+					Version: "0.0.9",
+				},
+			},
+			desiredChart: &v1alpha1.Chart{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "Chart",
+					APIVersion: "application.giantswarm.io",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "my-cool-prometheus",
+					Namespace: "giantswarm",
+					Annotations: map[string]string{
+						chartdrift.SpecHashAnnotation: "0123456789abcdef",
+					},
+				},
+				Spec: v1alpha1.ChartSpec{
+					Name:    "my-cool-prometheus",
+					Version: "1.0.0",
+				},
+			},
+			expectedChart: &v1alpha1.Chart{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "Chart",
+					APIVersion: "application.giantswarm.io",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "my-cool-prometheus",
+					Namespace: "giantswarm",
+					Annotations: map[string]string{
+						chartdrift.SpecHashAnnotation: "0123456789abcdef",
+					},
+				},
+				Spec: v1alpha1.ChartSpec{
+					Name:    "my-cool-prometheus",
+					Version: "1.0.0",
+				},
+			},
+		},
+		{
+			name: "update is deferred outside of the maintenance window",
+			app: v1alpha1.App{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						// February 31st never comes so the window is never open.
+						maintenancewindow.ScheduleAnnotation: "0 0 31 2 *",
+						maintenancewindow.DurationAnnotation: "2h",
+					},
+				},
+			},
+			currentChart: &v1alpha1.Chart{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "my-cool-prometheus",
+					Namespace: "giantswarm",
+				},
+				Spec: v1alpha1.ChartSpec{
+					Name:    "my-cool-prometheus",
+					Version: "0.0.9",
+				},
+			},
+			desiredChart: &v1alpha1.Chart{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "my-cool-prometheus",
+					Namespace: "giantswarm",
+				},
+				Spec: v1alpha1.ChartSpec{
+					Name:    "my-cool-prometheus",
+					Version: "1.0.0",
+				},
+			},
+			expectedChart: &v1alpha1.Chart{},
+		},
 	}
 
 	for i, tc := range tests {
@@ -371,10 +486,14 @@ func Test_Resource_newUpdateChange(t *testing.T) {
 			s.AddKnownTypes(v1alpha1.SchemeGroupVersion, &v1alpha1.AppList{})
 
 			c := Config{
-				IndexCache:    indexcachetest.New(indexcachetest.Config{}),
-				Logger:        microloggertest.New(),
-				CtrlClient:    fake.NewFakeClient(), //nolint:staticcheck
-				DynamicClient: dynamicfake.NewSimpleDynamicClient(s),
+				Event: recorder.New(recorder.Config{
+					K8sClient: k8sclienttest.NewClients(k8sclienttest.ClientsConfig{}),
+				}),
+				IndexCache:        indexcachetest.New(indexcachetest.Config{}),
+				Logger:            microloggertest.New(),
+				MaintenanceWindow: &maintenancewindow.Policy{},
+				CtrlClient:        fake.NewFakeClient(), //nolint:staticcheck
+				DynamicClient:     dynamicfake.NewSimpleDynamicClient(s),
 
 				ChartNamespace:               "giantswarm",
 				DependencyWaitTimeoutMinutes: 30,
@@ -400,7 +519,7 @@ func Test_Resource_newUpdateChange(t *testing.T) {
 				ctx = controllercontext.NewContext(context.Background(), c)
 			}
 
-			result, err := r.newUpdateChange(ctx, tc.currentChart, tc.desiredChart)
+			result, err := r.newUpdateChange(ctx, &tc.app, tc.currentChart, tc.desiredChart)
 			switch {
 			case err != nil && !tc.error:
 				t.Fatalf("error == %#v, want nil", err)
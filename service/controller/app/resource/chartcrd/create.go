@@ -87,7 +87,7 @@ func (r *Resource) EnsureCreated(ctx context.Context, obj interface{}) error {
 		r.logger.Debugf(ctx, "canceling resource")
 		return nil
 	} else if err != nil {
-		return microerror.Mask(err)
+		return r.handleError(ctx, cc, err)
 	}
 
 	r.logger.Debugf(ctx, "ensured chart CRD in workload cluster %#q", key.ClusterID(cr))
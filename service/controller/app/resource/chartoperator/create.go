@@ -52,7 +52,7 @@ func (r Resource) EnsureCreated(ctx context.Context, obj interface{}) error {
 		} else if apierrors.IsNotFound(err) {
 			// no-op
 		} else if err != nil {
-			return microerror.Mask(err)
+			return r.handleError(ctx, cc, err)
 		}
 
 		r.logger.Debugf(ctx, "did not find %#q deployment", deploymentName)
@@ -92,18 +92,18 @@ func (r Resource) EnsureCreated(ctx context.Context, obj interface{}) error {
 
 				return nil
 			} else if err != nil {
-				return microerror.Mask(err)
+				return r.handleError(ctx, cc, err)
 			}
 
 			r.logger.Debugf(ctx, "installed release %#q", releaseName)
 		} else if err != nil {
-			return microerror.Mask(err)
+			return r.handleError(ctx, cc, err)
 		}
 		r.logger.Debugf(ctx, "found release %#q", releaseName)
 
 		releaseContent, err := cc.Clients.Helm.GetReleaseContent(ctx, key.Namespace(cr), releaseName)
 		if err != nil {
-			return microerror.Mask(err)
+			return r.handleError(ctx, cc, err)
 		}
 
 		switch releaseContent.Status {
@@ -113,7 +113,7 @@ func (r Resource) EnsureCreated(ctx context.Context, obj interface{}) error {
 
 			err = r.updateChartOperator(ctx, cr)
 			if err != nil {
-				return microerror.Mask(err)
+				return r.handleError(ctx, cc, err)
 			}
 
 			r.logger.Debugf(ctx, "updated release %#q", releaseName)
@@ -123,12 +123,12 @@ func (r Resource) EnsureCreated(ctx context.Context, obj interface{}) error {
 
 			err = r.uninstallChartOperator(ctx, cr)
 			if err != nil {
-				return microerror.Mask(err)
+				return r.handleError(ctx, cc, err)
 			}
 
 			err = r.deleteFinalizers(ctx, cr)
 			if err != nil {
-				return microerror.Mask(err)
+				return r.handleError(ctx, cc, err)
 			}
 
 			r.logger.Debugf(ctx, "deleted release %#q", releaseName)
@@ -140,7 +140,7 @@ func (r Resource) EnsureCreated(ctx context.Context, obj interface{}) error {
 			// and then annotate them to trigger reconciliation and speed up bootstrapping.
 			err = r.triggerReconciliation(ctx, cr)
 			if err != nil {
-				return microerror.Mask(err)
+				return r.handleError(ctx, cc, err)
 			}
 
 			r.logger.Debugf(ctx, "triggered charts reconciliation")
@@ -1,6 +1,40 @@
 package chartoperator
 
-import "github.com/giantswarm/microerror"
+import (
+	"github.com/giantswarm/app/v8/pkg/values"
+	"github.com/giantswarm/helmclient/v4/pkg/helmclient"
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/app-operator/v7/pkg/errorclass"
+	"github.com/giantswarm/app-operator/v7/pkg/status"
+)
+
+// errorClassifier maps errors bootstrapping chart-operator to the status set
+// in the app CR.
+var errorClassifier = errorclass.New(
+	errorclass.Rule{
+		Match:     helmclient.IsPullChartTimeout,
+		Status:    status.UnknownError,
+		Retryable: true,
+	},
+	errorclass.Rule{
+		Match: func(err error) bool {
+			return helmclient.IsPullChartNotFound(err) || helmclient.IsTarballNotFound(err)
+		},
+		Status:      status.AppVersionNotFoundStatus,
+		Remediation: "check .spec.version of the chart-operator app CR exists in the catalog",
+	},
+	errorclass.Rule{
+		Match:       values.IsNotFound,
+		Status:      status.ResourceNotFoundStatus,
+		Remediation: "check the configmaps and secrets referenced by the chart-operator app CR exist",
+	},
+	errorclass.Rule{
+		Match:       values.IsParsingError,
+		Status:      status.ConfigmapMergeFailedStatus,
+		Remediation: "check the values referenced by the chart-operator app CR are valid YAML",
+	},
+)
 
 var invalidConfigError = &microerror.Error{
 	Kind: "invalidConfigError",
@@ -15,6 +15,7 @@ import (
 	"github.com/giantswarm/k8smetadata/pkg/label"
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
+	"github.com/giantswarm/operatorkit/v7/pkg/controller/context/resourcecanceledcontext"
 	"github.com/spf13/afero"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -339,3 +340,31 @@ func (r Resource) deleteFinalizers(ctx context.Context, cr v1alpha1.App) error {
 
 	return nil
 }
+
+// addStatusToContext adds the status to the controller context. It will be
+// used to set the CR status in the status resource.
+func addStatusToContext(cc *controllercontext.Context, reason, status string) {
+	cc.Status = controllercontext.Status{
+		ChartStatus: controllercontext.ChartStatus{
+			Reason: reason,
+			Status: status,
+		},
+	}
+}
+
+// handleError classifies the error. Retryable errors are returned so the
+// reconciliation is retried. Non retryable errors are added to the controller
+// context so they are set in the app CR status by the status resource.
+func (r Resource) handleError(ctx context.Context, cc *controllercontext.Context, err error) error {
+	class := errorClassifier.Classify(err)
+	if class.Retryable {
+		return microerror.Mask(err)
+	}
+
+	r.logger.LogCtx(ctx, "level", "warning", "message", "failed to bootstrap chart-operator", "reason", class.Reason)
+	addStatusToContext(cc, class.Message(), class.Status)
+
+	r.logger.Debugf(ctx, "canceling resource")
+	resourcecanceledcontext.SetCanceled(ctx)
+	return nil
+}
@@ -0,0 +1,186 @@
+package clients
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/helmclient/v4/pkg/helmclienttest"
+	"github.com/giantswarm/k8sclient/v7/pkg/k8sclienttest"
+	"github.com/giantswarm/k8smetadata/pkg/label"
+	"github.com/giantswarm/micrologger/microloggertest"
+	"github.com/spf13/afero"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgofake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/giantswarm/app-operator/v7/pkg/kubeconfigsource"
+	"github.com/giantswarm/app-operator/v7/pkg/status"
+	"github.com/giantswarm/app-operator/v7/service/controller/app/controllercontext"
+	"github.com/giantswarm/app-operator/v7/service/internal/clientcache"
+)
+
+// kubeConfig points at a workload cluster API which refuses connections.
+const kubeConfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: abc12
+  cluster:
+    server: https://127.0.0.1:1
+contexts:
+- name: abc12
+  context:
+    cluster: abc12
+    user: abc12
+current-context: abc12
+users:
+- name: abc12
+  user:
+    token: token
+`
+
+func Test_Resource_EnsureCreated(t *testing.T) {
+	tests := []struct {
+		name string
+		app  v1alpha1.App
+		// unavailable lets a request of the clients of the workload
+		// cluster fail so its circuit breaker opens.
+		unavailable        bool
+		expectedHasClients bool
+		expectedStatus     string
+	}{
+		{
+			name:               "case 0: clients of the workload cluster are added",
+			app:                newApp(nil),
+			expectedHasClients: true,
+		},
+		{
+			name:           "case 1: open circuit breaker is set in the status",
+			app:            newApp(nil),
+			unavailable:    true,
+			expectedStatus: status.ClusterUnavailableStatus,
+		},
+		{
+			name: "case 2: untrusted kubeconfig source is set in the status",
+			app: newApp(map[string]string{
+				kubeconfigsource.Annotation: kubeconfigsource.Exec,
+			}),
+			expectedStatus: status.KubeConfigSourceInvalidStatus,
+		},
+		{
+			name: "case 3: unknown kubeconfig source is set in the status",
+			app: newApp(map[string]string{
+				kubeconfigsource.Annotation: "vault",
+			}),
+			expectedStatus: status.KubeConfigSourceInvalidStatus,
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "abc12-kubeconfig",
+					Namespace: "org-acme",
+				},
+				Data: map[string][]byte{
+					"kubeConfig": []byte(kubeConfig),
+				},
+			}
+			k8sClient := k8sclienttest.NewClients(k8sclienttest.ClientsConfig{
+				CtrlClient: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(secret).Build(),
+				K8sClient:  clientgofake.NewClientset(secret),
+			})
+
+			clientCache, err := clientcache.New(clientcache.Config{
+				Fs:        afero.NewMemMapFs(),
+				K8sClient: k8sClient,
+				Logger:    microloggertest.New(),
+
+				HTTPClientTimeout:      time.Second,
+				CircuitBreakerCooldown: time.Minute,
+				FailureThreshold:       1,
+				MaxSize:                10,
+			})
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			r, err := New(Config{
+				ClientCache: clientCache,
+				HelmClient:  helmclienttest.New(helmclienttest.Config{}),
+				K8sClient:   k8sClient,
+				Logger:      microloggertest.New(),
+			})
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			if tc.unavailable {
+				ctx := controllercontext.NewContext(context.Background(), controllercontext.Context{})
+				err = r.EnsureCreated(ctx, &tc.app)
+				if err != nil {
+					t.Fatalf("error == %#v, want nil", err)
+				}
+
+				cc, err := controllercontext.FromContext(ctx)
+				if err != nil {
+					t.Fatalf("error == %#v, want nil", err)
+				}
+
+				_, err = cc.Clients.K8s.K8sClient().Discovery().ServerVersion()
+				if err == nil {
+					t.Fatalf("error == nil, want non-nil")
+				}
+			}
+
+			ctx := controllercontext.NewContext(context.Background(), controllercontext.Context{})
+			err = r.EnsureCreated(ctx, &tc.app)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			cc, err := controllercontext.FromContext(ctx)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			if cc.Status.ChartStatus.Status != tc.expectedStatus {
+				t.Fatalf("status == %#q, want %#q", cc.Status.ChartStatus.Status, tc.expectedStatus)
+			}
+			if hasClients := cc.Clients.K8s != nil; hasClients != tc.expectedHasClients {
+				t.Fatalf("hasClients == %t, want %t", hasClients, tc.expectedHasClients)
+			}
+			if cc.Status.ClusterStatus.IsUnavailable == tc.expectedHasClients {
+				t.Fatalf("IsUnavailable == %t, want %t", cc.Status.ClusterStatus.IsUnavailable, !tc.expectedHasClients)
+			}
+		})
+	}
+}
+
+func newApp(annotations map[string]string) v1alpha1.App {
+	return v1alpha1.App{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: annotations,
+			Name:        "nginx",
+			Namespace:   "org-acme",
+			Labels: map[string]string{
+				label.Cluster: "abc12",
+			},
+		},
+		Spec: v1alpha1.AppSpec{
+			KubeConfig: v1alpha1.AppSpecKubeConfig{
+				Secret: v1alpha1.AppSpecKubeConfigSecret{
+					Name:      "abc12-kubeconfig",
+					Namespace: "org-acme",
+				},
+			},
+		},
+	}
+}
@@ -1,6 +1,37 @@
 package clients
 
-import "github.com/giantswarm/microerror"
+import (
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/app-operator/v7/pkg/errorclass"
+	"github.com/giantswarm/app-operator/v7/pkg/kubeconfigsource"
+	"github.com/giantswarm/app-operator/v7/pkg/status"
+	"github.com/giantswarm/app-operator/v7/service/internal/clientcache"
+)
+
+// errorClassifier maps errors getting the clients of the workload cluster to
+// the status set in the app CR.
+var errorClassifier = errorclass.New(
+	// The circuit breaker closes again after its cooldown so the app CR is
+	// reconciled with its next resync instead of being retried right away.
+	errorclass.Rule{
+		Match:       clientcache.IsCircuitOpen,
+		Status:      status.ClusterUnavailableStatus,
+		Remediation: "check the API of the workload cluster is reachable, it is connected to again once the circuit breaker cooldown passed",
+	},
+	errorclass.Rule{
+		Match:       kubeconfigsource.IsUntrustedSource,
+		Status:      status.KubeConfigSourceInvalidStatus,
+		Remediation: "remove the " + kubeconfigsource.Annotation + " annotation or ask for the namespace of the app CR to be trusted",
+	},
+	errorclass.Rule{
+		Match: func(err error) bool {
+			return kubeconfigsource.IsUnknownSource(err) || kubeconfigsource.IsMissingCluster(err)
+		},
+		Status:      status.KubeConfigSourceInvalidStatus,
+		Remediation: "check the " + kubeconfigsource.Annotation + " annotation and the cluster label of the app CR",
+	},
+)
 
 var invalidConfigError = &microerror.Error{
 	Kind: "invalidConfigError",
@@ -69,6 +69,38 @@ func (*Resource) Name() string {
 	return Name
 }
 
+// addStatusToContext adds the status to the controller context. It will be
+// used to set the CR status in the status resource. The workload cluster is
+// marked unavailable since there are no clients for it.
+func addStatusToContext(cc *controllercontext.Context, reason, status string) {
+	cc.Status = controllercontext.Status{
+		ChartStatus: controllercontext.ChartStatus{
+			Reason: reason,
+			Status: status,
+		},
+		ClusterStatus: controllercontext.ClusterStatus{
+			IsUnavailable: true,
+		},
+	}
+}
+
+// handleError classifies the error. Retryable errors are returned so the
+// reconciliation is retried. Non retryable errors, e.g. an open circuit
+// breaker or an untrusted kubeconfig source, are added to the controller
+// context so they are set in the app CR status by the status resource.
+func (r *Resource) handleError(ctx context.Context, cc *controllercontext.Context, err error) error {
+	class := errorClassifier.Classify(err)
+	if class.Retryable {
+		return microerror.Mask(err)
+	}
+
+	r.logger.LogCtx(ctx, "level", "warning", "message", "failed to get clients of workload cluster", "reason", class.Reason)
+	addStatusToContext(cc, class.Message(), class.Status)
+
+	r.logger.Debugf(ctx, "canceling resource")
+	return nil
+}
+
 // addClientsToContext adds g8s and k8s clients based on the kubeconfig
 // settings for the app CR.
 func (r *Resource) addClientsToContext(ctx context.Context, cr v1alpha1.App) error {
@@ -91,13 +123,13 @@ func (r *Resource) addClientsToContext(ctx context.Context, cr v1alpha1.App) err
 		return nil
 	}
 
-	clients, err := r.clientCache.GetClients(ctx, &cr.Spec.KubeConfig)
-	if kubeconfig.IsNotFoundError(err) {
+	clients, err := r.clientCache.GetClients(ctx, cr)
+	if kubeconfig.IsNotFoundError(err) || clientcache.IsNotFound(err) {
 		// Set status so we don't try to connect to the workload cluster
 		// again in this reconciliation loop.
 		cc.Status.ClusterStatus.IsUnavailable = true
 
-		r.logger.Debugf(ctx, "kubeconfig secret not found")
+		r.logger.Debugf(ctx, "kubeconfig not found")
 		r.logger.Debugf(ctx, "canceling resource")
 		return nil
 	} else if tenant.IsAPINotAvailable(err) {
@@ -109,7 +141,7 @@ func (r *Resource) addClientsToContext(ctx context.Context, cr v1alpha1.App) err
 		r.logger.Debugf(ctx, "canceling resource")
 		return nil
 	} else if err != nil {
-		return microerror.Mask(err)
+		return r.handleError(ctx, cc, err)
 	}
 
 	cc.Clients = controllercontext.Clients{
@@ -12,6 +12,7 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"github.com/giantswarm/app-operator/v7/pkg/valuesgeneration"
 	"github.com/giantswarm/app-operator/v7/service/controller/app/controllercontext"
 )
 
@@ -51,6 +52,10 @@ func (r *Resource) GetCurrentState(ctx context.Context, obj interface{}) (interf
 		return nil, nil
 	}
 
+	if r.immutableValues {
+		name = valuesgeneration.Selector(cr.Name)
+	}
+
 	r.logger.Debugf(ctx, "finding configmap %#q in namespace %#q", name, r.chartNamespace)
 
 	ch := make(chan struct{})
@@ -58,7 +63,15 @@ func (r *Resource) GetCurrentState(ctx context.Context, obj interface{}) (interf
 	var configmap *corev1.ConfigMap
 
 	go func() {
-		configmap, err = cc.Clients.K8s.K8sClient().CoreV1().ConfigMaps(r.chartNamespace).Get(ctx, name, metav1.GetOptions{})
+		if r.immutableValues {
+			// The newest generation is the current configmap.
+			configmap, err = r.latestGeneration(ctx, cc.Clients.K8s.K8sClient(), cr)
+			if err == nil && configmap == nil {
+				err = apierrors.NewNotFound(corev1.Resource("configmaps"), name)
+			}
+		} else {
+			configmap, err = cc.Clients.K8s.K8sClient().CoreV1().ConfigMaps(r.chartNamespace).Get(ctx, name, metav1.GetOptions{})
+		}
 		close(ch)
 	}()
 
@@ -3,6 +3,7 @@ package configmap
 import (
 	"context"
 
+	"github.com/giantswarm/app/v8/pkg/key"
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/operatorkit/v7/pkg/resource/crud"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -33,6 +34,20 @@ func (r *Resource) ApplyDeleteChange(ctx context.Context, obj, deleteChange inte
 		} else {
 			r.logger.Debugf(ctx, "deleted Chart CR %#q in namespace %#q", configMap.Name, configMap.Namespace)
 		}
+
+		if r.immutableValues {
+			cr, err := key.ToApp(obj)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			err = r.deleteGenerations(ctx, cc.Clients.K8s.K8sClient(), cr)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			r.logger.Debugf(ctx, "deleted configmap generations of app %#q", cr.Name)
+		}
 	}
 
 	return nil
@@ -59,7 +74,7 @@ func (r *Resource) newDeleteChange(ctx context.Context, obj, currentState, desir
 	return desiredConfigMap, nil
 }
 
-func (r *Resource) newDeleteChangeForUpdate(ctx context.Context, currentState, desiredState interface{}) (interface{}, error) {
+func (r *Resource) newDeleteChangeForUpdate(ctx context.Context, obj, currentState, desiredState interface{}) (interface{}, error) {
 	currentConfigMap, err := toConfigMap(currentState)
 	if err != nil {
 		return nil, microerror.Mask(err)
@@ -73,6 +88,14 @@ func (r *Resource) newDeleteChangeForUpdate(ctx context.Context, currentState, d
 	r.logger.Debugf(ctx, "finding out if the configmap has to be deleted")
 
 	if !isEmpty(currentConfigMap) && isEmpty(desiredConfigMap) {
+		deferred, err := r.deferChange(ctx, obj)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		if deferred {
+			return nil, nil
+		}
+
 		r.logger.Debugf(ctx, "the configmap has to be deleted")
 		return currentConfigMap, nil
 	}
@@ -7,7 +7,6 @@ import (
 	"testing"
 
 	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
-	"github.com/giantswarm/app/v8/pkg/values"
 	"github.com/giantswarm/k8sclient/v7/pkg/k8sclienttest"
 	"github.com/giantswarm/k8smetadata/pkg/annotation"
 	"github.com/giantswarm/k8smetadata/pkg/label"
@@ -18,15 +17,22 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	clientgofake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
+	"github.com/giantswarm/app-operator/v7/pkg/maintenancewindow"
+	"github.com/giantswarm/app-operator/v7/pkg/valueref"
+	"github.com/giantswarm/app-operator/v7/pkg/valuesource"
 	"github.com/giantswarm/app-operator/v7/service/controller/app/controllercontext"
+	"github.com/giantswarm/app-operator/v7/service/internal/recorder"
+	"github.com/giantswarm/app-operator/v7/service/internal/valuessize"
 )
 
 func Test_Resource_GetDesiredState(t *testing.T) {
 	tests := []struct {
 		name               string
 		obj                *v1alpha1.App
+		apps               []*v1alpha1.App
 		catalog            v1alpha1.Catalog
 		configMaps         []*corev1.ConfigMap
 		expectedConfigMap  *corev1.ConfigMap
@@ -84,8 +90,9 @@ func Test_Resource_GetDesiredState(t *testing.T) {
 						"values": "cluster: yaml\n",
 					},
 					ObjectMeta: metav1.ObjectMeta{
-						Name:      "test-cluster-values",
-						Namespace: "giantswarm",
+						Name:            "test-cluster-values",
+						Namespace:       "giantswarm",
+						ResourceVersion: "123",
 					},
 				},
 			},
@@ -97,7 +104,8 @@ func Test_Resource_GetDesiredState(t *testing.T) {
 					Name:      "my-prometheus-chart-values",
 					Namespace: "giantswarm",
 					Annotations: map[string]string{
-						annotation.Notes: "DO NOT EDIT. Values managed by app-operator.",
+						annotation.Notes:       "DO NOT EDIT. Values managed by app-operator.",
+						valuesource.Annotation: `[{"kind":"app","type":"configMap","name":"test-cluster-values","namespace":"giantswarm","resourceVersion":"123","priority":50}]`,
 					},
 					Labels: map[string]string{
 						label.ManagedBy: "app-operator",
@@ -143,7 +151,8 @@ func Test_Resource_GetDesiredState(t *testing.T) {
 					Name:      "test-app-chart-values",
 					Namespace: "giantswarm",
 					Annotations: map[string]string{
-						annotation.Notes: "DO NOT EDIT. Values managed by app-operator.",
+						annotation.Notes:       "DO NOT EDIT. Values managed by app-operator.",
+						valuesource.Annotation: `[{"kind":"user","type":"configMap","name":"test-app-user-values","namespace":"giantswarm","priority":100}]`,
 					},
 					Labels: map[string]string{
 						label.ManagedBy: "app-operator",
@@ -200,7 +209,8 @@ func Test_Resource_GetDesiredState(t *testing.T) {
 					Name:      "test-app-chart-values",
 					Namespace: "giantswarm",
 					Annotations: map[string]string{
-						annotation.Notes: "DO NOT EDIT. Values managed by app-operator.",
+						annotation.Notes:       "DO NOT EDIT. Values managed by app-operator.",
+						valuesource.Annotation: `[{"kind":"user","type":"configMap","name":"custom-values","namespace":"giantswarm","priority":100}]`,
 					},
 					Labels: map[string]string{
 						label.ManagedBy: "app-operator",
@@ -266,7 +276,8 @@ func Test_Resource_GetDesiredState(t *testing.T) {
 					Name:      "test-app-chart-values",
 					Namespace: "giantswarm",
 					Annotations: map[string]string{
-						annotation.Notes: "DO NOT EDIT. Values managed by app-operator.",
+						annotation.Notes:       "DO NOT EDIT. Values managed by app-operator.",
+						valuesource.Annotation: `[{"kind":"user","type":"configMap","name":"custom-values","namespace":"giantswarm","priority":100}]`,
 					},
 					Labels: map[string]string{
 						label.ManagedBy: "app-operator",
@@ -280,6 +291,80 @@ func Test_Resource_GetDesiredState(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "case 5: values of referenced app are merged by priority",
+			obj: &v1alpha1.App{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "my-prometheus",
+					Namespace: "giantswarm",
+					Annotations: map[string]string{
+						valueref.Annotation: "- kind: app\n  name: my-database\n",
+					},
+				},
+				Spec: v1alpha1.AppSpec{
+					Catalog:   "app-catalog",
+					Name:      "prometheus",
+					Namespace: "monitoring",
+					Config: v1alpha1.AppSpecConfig{
+						ConfigMap: v1alpha1.AppSpecConfigConfigMap{
+							Name:      "test-cluster-values",
+							Namespace: "giantswarm",
+						},
+					},
+				},
+			},
+			apps: []*v1alpha1.App{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "my-database",
+						Namespace: "giantswarm",
+					},
+				},
+			},
+			catalog: v1alpha1.Catalog{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test-catalog",
+				},
+			},
+			configMaps: []*corev1.ConfigMap{
+				{
+					Data: map[string]string{
+						"values": "cluster: yaml\n",
+					},
+					ObjectMeta: metav1.ObjectMeta{
+						Name:            "test-cluster-values",
+						Namespace:       "giantswarm",
+						ResourceVersion: "123",
+					},
+				},
+				{
+					Data: map[string]string{
+						"values": "cluster: database\ndatabase:\n  host: db\n",
+					},
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "my-database-chart-values",
+						Namespace: "giantswarm",
+					},
+				},
+			},
+			expectedConfigMap: &corev1.ConfigMap{
+				Data: map[string]string{
+					"values": "cluster: yaml\ndatabase:\n  host: db\n",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "my-prometheus-chart-values",
+					Namespace: "giantswarm",
+					Annotations: map[string]string{
+						annotation.Notes:       "DO NOT EDIT. Values managed by app-operator.",
+						valuesource.Annotation: `[{"kind":"appRef","type":"configMap","name":"my-database","namespace":"giantswarm","priority":25},{"kind":"app","type":"configMap","name":"test-cluster-values","namespace":"giantswarm","resourceVersion":"123","priority":50}]`,
+					},
+					Labels: map[string]string{
+						label.ManagedBy: "app-operator",
+					},
+				},
+			},
+			expectedUserConfig: &v1alpha1.AppSpecUserConfig{},
+		},
 	}
 
 	var err error
@@ -295,7 +380,11 @@ func Test_Resource_GetDesiredState(t *testing.T) {
 			}
 
 			k8sClient := clientgofake.NewClientset(objs...)
-			ctrlClient := fake.NewClientBuilder().WithScheme(s).WithObjects(tc.obj).Build()
+			ctrlObjs := []client.Object{tc.obj}
+			for _, app := range tc.apps {
+				ctrlObjs = append(ctrlObjs, app)
+			}
+			ctrlClient := fake.NewClientBuilder().WithScheme(s).WithObjects(ctrlObjs...).Build()
 
 			var ctx context.Context
 			{
@@ -311,24 +400,60 @@ func Test_Resource_GetDesiredState(t *testing.T) {
 				ctx = controllercontext.NewContext(context.Background(), c)
 			}
 
-			var valuesService *values.Values
+			var valueRefs *valueref.Resolver
+			{
+				c := valueref.Config{
+					K8sClient: k8sclienttest.NewClients(k8sclienttest.ClientsConfig{
+						CtrlClient: ctrlClient,
+						K8sClient:  k8sClient,
+					}),
+
+					ChartNamespace: "giantswarm",
+				}
+
+				valueRefs, err = valueref.New(c)
+				if err != nil {
+					t.Fatalf("error == %#v, want nil", err)
+				}
+			}
+
+			var valueSources *valuesource.Resolver
 			{
-				c := values.Config{
+				c := valuesource.Config{
 					K8sClient: k8sClient,
-					Logger:    microloggertest.New(),
 				}
 
-				valuesService, err = values.New(c)
+				valueSources, err = valuesource.New(c)
+				if err != nil {
+					t.Fatalf("error == %#v, want nil", err)
+				}
+			}
+
+			var valuesSize *valuessize.Guard
+			{
+				c := valuessize.Config{
+					Event: recorder.New(recorder.Config{
+						K8sClient: k8sclienttest.NewClients(k8sclienttest.ClientsConfig{}),
+					}),
+
+					Type: "configmap",
+				}
+
+				valuesSize, err = valuessize.New(c)
 				if err != nil {
 					t.Fatalf("error == %#v, want nil", err)
 				}
 			}
 
 			c := Config{
-				Logger: microloggertest.New(),
-				Values: valuesService,
+				Logger:            microloggertest.New(),
+				MaintenanceWindow: &maintenancewindow.Policy{},
+				ValueRefs:         valueRefs,
+				ValueSources:      valueSources,
+				ValuesSize:        valuesSize,
 
 				ChartNamespace: "giantswarm",
+				Provider:       "aws",
 			}
 			r, err := New(c)
 			if err != nil {
@@ -1,6 +1,50 @@
 package configmap
 
-import "github.com/giantswarm/microerror"
+import (
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/app-operator/v7/pkg/errorclass"
+	"github.com/giantswarm/app-operator/v7/pkg/status"
+	"github.com/giantswarm/app-operator/v7/pkg/valueref"
+	"github.com/giantswarm/app-operator/v7/pkg/valuesource"
+	"github.com/giantswarm/app-operator/v7/pkg/valuestemplate"
+	"github.com/giantswarm/app-operator/v7/service/internal/valuessize"
+)
+
+// errorClassifier maps errors merging the configmaps to the status set in the app
+// CR.
+var errorClassifier = errorclass.New(
+	errorclass.Rule{
+		Match:       valuessize.IsTooLarge,
+		Status:      status.ValuesTooLargeStatus,
+		Remediation: "reduce the values referenced by the app CR or enable values compression with the app.valuesCompression setting",
+	},
+	errorclass.Rule{
+		Match:       valuesource.IsNotFound,
+		Status:      status.ConfigmapMergeFailedStatus,
+		Remediation: "check the configmaps referenced by the app CR and its catalog exist",
+	},
+	errorclass.Rule{
+		Match:       valuesource.IsParsingError,
+		Status:      status.ConfigmapMergeFailedStatus,
+		Remediation: "check the values in the configmaps referenced by the app CR are valid YAML",
+	},
+	errorclass.Rule{
+		Match:       valuestemplate.IsTemplate,
+		Status:      status.ConfigmapMergeFailedStatus,
+		Remediation: "check the templates in the values and the " + valuestemplate.Annotation + " annotation of the app CR",
+	},
+	errorclass.Rule{
+		Match:       valueref.IsInvalidRef,
+		Status:      status.ConfigmapMergeFailedStatus,
+		Remediation: "check the references in the " + valueref.Annotation + " annotation of the app CR",
+	},
+	errorclass.Rule{
+		Match:       valueref.IsNotFound,
+		Status:      status.ConfigmapMergeFailedStatus,
+		Remediation: "check the objects and apps referenced in the " + valueref.Annotation + " annotation of the app CR exist",
+	},
+)
 
 var executionFailedError = &microerror.Error{
 	Kind: "executionFailed",
@@ -0,0 +1,149 @@
+package configmap
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/micrologger/microloggertest"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgofake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/giantswarm/app-operator/v7/pkg/valuesencoding"
+	"github.com/giantswarm/app-operator/v7/pkg/valuesgeneration"
+	"github.com/giantswarm/app-operator/v7/pkg/valuesource"
+)
+
+func Test_Resource_applyGeneration(t *testing.T) {
+	cr := v1alpha1.App{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-app",
+			Namespace: "org-acme",
+		},
+	}
+
+	tests := []struct {
+		name                string
+		sources             string
+		encode              bool
+		values              string
+		expectedGenerations []string
+		expectedAnnotations map[string]string
+	}{
+		{
+			name:                "case 0: changed sources annotation does not create a generation",
+			sources:             `[{"kind":"user","resourceVersion":"2"}]`,
+			values:              "replicas: 1\n",
+			expectedGenerations: []string{valuesgeneration.Name("my-app-chart-values", []byte("replicas: 1\n"))},
+			expectedAnnotations: map[string]string{
+				valuesgeneration.NumberAnnotation: "1",
+				valuesource.Annotation:            `[{"kind":"user","resourceVersion":"1"}]`,
+			},
+		},
+		{
+			name:                "case 1: encoded values do not create a generation",
+			sources:             `[{"kind":"user","resourceVersion":"2"}]`,
+			encode:              true,
+			values:              "replicas: 1\n",
+			expectedGenerations: []string{valuesgeneration.Name("my-app-chart-values", []byte("replicas: 1\n"))},
+			expectedAnnotations: map[string]string{
+				valuesgeneration.NumberAnnotation: "1",
+				valuesource.Annotation:            `[{"kind":"user","resourceVersion":"1"}]`,
+			},
+		},
+		{
+			name:    "case 2: changed values create a generation",
+			sources: `[{"kind":"user","resourceVersion":"2"}]`,
+			values:  "replicas: 2\n",
+			expectedGenerations: []string{
+				valuesgeneration.Name("my-app-chart-values", []byte("replicas: 1\n")),
+				valuesgeneration.Name("my-app-chart-values", []byte("replicas: 2\n")),
+			},
+			expectedAnnotations: map[string]string{
+				valuesgeneration.NumberAnnotation: "1",
+				valuesource.Annotation:            `[{"kind":"user","resourceVersion":"1"}]`,
+			},
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			current := newTestGeneration(cr, `[{"kind":"user","resourceVersion":"1"}]`, "replicas: 1\n")
+			valuesgeneration.SetNumber(&current.ObjectMeta, 1)
+
+			k8sClient := clientgofake.NewClientset(current)
+
+			desired := newTestGeneration(cr, tc.sources, tc.values)
+			if tc.encode {
+				encoded, err := valuesencoding.Encode([]byte(tc.values))
+				if err != nil {
+					t.Fatalf("error == %#v, want nil", err)
+				}
+				desired.Data["values"] = string(encoded)
+				desired.Annotations[valuesencoding.Annotation] = valuesencoding.Gzip
+			}
+
+			r := &Resource{
+				logger: microloggertest.New(),
+
+				chartNamespace: "giantswarm",
+			}
+
+			err := r.applyGeneration(context.Background(), k8sClient, cr, desired)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			list, err := k8sClient.CoreV1().ConfigMaps("giantswarm").List(context.Background(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			var generations []string
+			for _, cm := range list.Items {
+				generations = append(generations, cm.Name)
+				if cm.Name != current.Name {
+					continue
+				}
+				if diff := cmp.Diff(tc.expectedAnnotations, cm.Annotations); diff != "" {
+					t.Fatalf("want matching annotations \n %s", diff)
+				}
+				if cm.Data["values"] != "replicas: 1\n" {
+					t.Fatalf("values == %#q, want %#q", cm.Data["values"], "replicas: 1\n")
+				}
+			}
+
+			sort.Strings(generations)
+			sort.Strings(tc.expectedGenerations)
+			if diff := cmp.Diff(tc.expectedGenerations, generations); diff != "" {
+				t.Fatalf("want matching generations \n %s", diff)
+			}
+		})
+	}
+}
+
+func newTestGeneration(cr v1alpha1.App, sources, values string) *corev1.ConfigMap {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-app-chart-values",
+			Namespace: "giantswarm",
+			Annotations: map[string]string{
+				valuesource.Annotation: sources,
+			},
+			Labels: map[string]string{},
+		},
+		Data: map[string]string{
+			"values": values,
+		},
+	}
+
+	toGeneration(cr, configMap, []byte(values))
+
+	return configMap
+}
@@ -4,12 +4,20 @@ import (
 	"reflect"
 
 	"github.com/ghodss/yaml"
-	"github.com/giantswarm/app/v8/pkg/values"
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
 	corev1 "k8s.io/api/core/v1"
 
+	"github.com/giantswarm/app-operator/v7/pkg/maintenancewindow"
+	"github.com/giantswarm/app-operator/v7/pkg/valueref"
+	"github.com/giantswarm/app-operator/v7/pkg/valuesencoding"
+	"github.com/giantswarm/app-operator/v7/pkg/valuesource"
 	"github.com/giantswarm/app-operator/v7/service/controller/app/controllercontext"
+	"github.com/giantswarm/app-operator/v7/service/internal/indexcache"
+	"github.com/giantswarm/app-operator/v7/service/internal/valuesmerge"
+	"github.com/giantswarm/app-operator/v7/service/internal/valuesschema"
+	"github.com/giantswarm/app-operator/v7/service/internal/valuessize"
+	"github.com/giantswarm/app-operator/v7/service/internal/valuesvalidation"
 )
 
 const (
@@ -20,21 +28,40 @@ const (
 // Config represents the configuration used to create a new configmap resource.
 type Config struct {
 	// Dependencies.
-	Logger micrologger.Logger
-	Values *values.Values
+	// IndexCache is only used to validate values when ValuesSchema is set.
+	IndexCache indexcache.Interface
+	Logger     micrologger.Logger
+	// MaintenanceWindow defers updates of the values to maintenance windows.
+	MaintenanceWindow *maintenancewindow.Policy
+	ValueRefs         *valueref.Resolver
+	ValueSources      *valuesource.Resolver
+	ValuesSize        *valuessize.Guard
+	// ValuesSchema is optional. When nil merged values are not validated.
+	ValuesSchema valuesschema.Interface
 
 	// Settings.
 	ChartNamespace string
+	// ImmutableValues enables immutable generations of the configmap named
+	// after the hash of the values. They are pruned by the chart resource.
+	ImmutableValues bool
+	// Provider is exposed to values templates.
+	Provider          string
+	WorkloadClusterID string
 }
 
 // Resource implements the configmap resource.
 type Resource struct {
 	// Dependencies.
-	logger micrologger.Logger
-	values *values.Values
+	logger            micrologger.Logger
+	maintenanceWindow *maintenancewindow.Policy
+	valuesMerge       *valuesmerge.Merger
+	valuesSize        *valuessize.Guard
+	// valuesValidation is nil unless a values schema is configured.
+	valuesValidation *valuesvalidation.Validator
 
 	// Settings.
-	chartNamespace string
+	chartNamespace  string
+	immutableValues bool
 }
 
 // New creates a new configured configmap resource.
@@ -42,19 +69,69 @@ func New(config Config) (*Resource, error) {
 	if config.Logger == nil {
 		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
 	}
-	if config.Values == nil {
-		return nil, microerror.Maskf(invalidConfigError, "%T.Values must not be empty", config)
+	if config.MaintenanceWindow == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.MaintenanceWindow must not be empty", config)
+	}
+	if config.ValueRefs == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.ValueRefs must not be empty", config)
+	}
+	if config.ValueSources == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.ValueSources must not be empty", config)
+	}
+	if config.ValuesSize == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.ValuesSize must not be empty", config)
 	}
 
 	if config.ChartNamespace == "" {
 		return nil, microerror.Maskf(invalidConfigError, "%T.ChartNamespace must not be empty", config)
 	}
+	if config.Provider == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Provider must not be empty", config)
+	}
 
-	r := &Resource{
-		logger: config.Logger,
-		values: config.Values,
+	var valuesMerge *valuesmerge.Merger
+	{
+		c := valuesmerge.Config{
+			ValueRefs:    config.ValueRefs,
+			ValueSources: config.ValueSources,
+
+			Provider: config.Provider,
+		}
 
-		chartNamespace: config.ChartNamespace,
+		var err error
+		valuesMerge, err = valuesmerge.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	var valuesValidation *valuesvalidation.Validator
+	if config.ValuesSchema != nil {
+		c := valuesvalidation.Config{
+			IndexCache:   config.IndexCache,
+			Logger:       config.Logger,
+			ValuesSchema: config.ValuesSchema,
+
+			ChartNamespace:    config.ChartNamespace,
+			WorkloadClusterID: config.WorkloadClusterID,
+		}
+
+		var err error
+		valuesValidation, err = valuesvalidation.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	r := &Resource{
+		logger:            config.Logger,
+		maintenanceWindow: config.MaintenanceWindow,
+		valuesMerge:       valuesMerge,
+		valuesSize:        config.ValuesSize,
+		valuesValidation:  valuesValidation,
+
+		chartNamespace:  config.ChartNamespace,
+		immutableValues: config.ImmutableValues,
 	}
 
 	return r, nil
@@ -76,7 +153,9 @@ func addStatusToContext(cc *controllercontext.Context, reason, status string) {
 }
 
 // equals asseses the equality of ConfigMaps with regards to distinguishing
-// fields.
+// fields. The values sources annotation is left out so a source written
+// without changing the merged values does not rewrite the object and upgrade
+// the chart.
 func equals(a, b *corev1.ConfigMap) bool {
 	if a.Name != b.Name {
 		return false
@@ -84,7 +163,7 @@ func equals(a, b *corev1.ConfigMap) bool {
 	if a.Namespace != b.Namespace {
 		return false
 	}
-	if !reflect.DeepEqual(a.Annotations, b.Annotations) {
+	if !reflect.DeepEqual(valuesource.WithoutAnnotation(a.Annotations), valuesource.WithoutAnnotation(b.Annotations)) {
 		return false
 	}
 
@@ -93,12 +172,22 @@ func equals(a, b *corev1.ConfigMap) bool {
 		source = make(map[string]interface{})
 		dest = make(map[string]interface{})
 
-		err := yaml.Unmarshal([]byte(a.Data["values"]), &source)
+		// Encoded values are compared decoded.
+		sourceValues, err := valuesencoding.Decode(a.Annotations, []byte(a.Data["values"]))
+		if err != nil {
+			return false
+		}
+		destValues, err := valuesencoding.Decode(b.Annotations, []byte(b.Data["values"]))
+		if err != nil {
+			return false
+		}
+
+		err = yaml.Unmarshal(sourceValues, &source)
 		if err != nil {
 			return false
 		}
 
-		err = yaml.Unmarshal([]byte(b.Data["values"]), &dest)
+		err = yaml.Unmarshal(destValues, &dest)
 		if err != nil {
 			return false
 		}
@@ -0,0 +1,65 @@
+package configmap
+
+import (
+	"strconv"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/giantswarm/app-operator/v7/pkg/valuesource"
+)
+
+func Test_equals(t *testing.T) {
+	tests := []struct {
+		name     string
+		current  *corev1.ConfigMap
+		desired  *corev1.ConfigMap
+		expected bool
+	}{
+		{
+			name:     "case 0: equal configmaps",
+			current:  newTestConfigMap(`[{"kind":"user","resourceVersion":"1"}]`, "replicas: 1\n"),
+			desired:  newTestConfigMap(`[{"kind":"user","resourceVersion":"1"}]`, "replicas: 1\n"),
+			expected: true,
+		},
+		{
+			name:     "case 1: configmaps with changed source resource versions are equal",
+			current:  newTestConfigMap(`[{"kind":"user","resourceVersion":"1"}]`, "replicas: 1\n"),
+			desired:  newTestConfigMap(`[{"kind":"user","resourceVersion":"2"}]`, "replicas: 1\n"),
+			expected: true,
+		},
+		{
+			name:     "case 2: configmaps with changed values are not equal",
+			current:  newTestConfigMap(`[{"kind":"user","resourceVersion":"1"}]`, "replicas: 1\n"),
+			desired:  newTestConfigMap(`[{"kind":"user","resourceVersion":"2"}]`, "replicas: 2\n"),
+			expected: false,
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			result := equals(tc.current, tc.desired)
+			if result != tc.expected {
+				t.Fatalf("equals == %t, want %t", result, tc.expected)
+			}
+		})
+	}
+}
+
+func newTestConfigMap(sources, values string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-app-chart-values",
+			Namespace: "giantswarm",
+			Annotations: map[string]string{
+				valuesource.Annotation: sources,
+			},
+		},
+		Data: map[string]string{
+			"values": values,
+		},
+	}
+}
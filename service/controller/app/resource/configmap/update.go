@@ -3,6 +3,7 @@ package configmap
 import (
 	"context"
 
+	"github.com/giantswarm/app/v8/pkg/key"
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/operatorkit/v7/pkg/resource/crud"
 	corev1 "k8s.io/api/core/v1"
@@ -18,13 +19,27 @@ func (r *Resource) ApplyUpdateChange(ctx context.Context, obj, updateChange inte
 	}
 
 	if !isEmpty(configMap) {
-		r.logger.Debugf(ctx, "updating configmap %#q in namespace %#q", configMap.Name, configMap.Namespace)
-
 		cc, err := controllercontext.FromContext(ctx)
 		if err != nil {
 			return microerror.Mask(err)
 		}
 
+		if r.immutableValues {
+			cr, err := key.ToApp(obj)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			err = r.applyGeneration(ctx, cc.Clients.K8s.K8sClient(), cr, configMap)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			return nil
+		}
+
+		r.logger.Debugf(ctx, "updating configmap %#q in namespace %#q", configMap.Name, configMap.Namespace)
+
 		_, err = cc.Clients.K8s.K8sClient().CoreV1().ConfigMaps(configMap.Namespace).Update(ctx, configMap, metav1.UpdateOptions{})
 		if err != nil {
 			return microerror.Mask(err)
@@ -42,12 +57,12 @@ func (r *Resource) NewUpdatePatch(ctx context.Context, obj, currentConfigMap, de
 		return nil, microerror.Mask(err)
 	}
 
-	update, err := r.newUpdateChange(ctx, currentConfigMap, desiredConfigMap)
+	update, err := r.newUpdateChange(ctx, obj, currentConfigMap, desiredConfigMap)
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
 
-	delete, err := r.newDeleteChangeForUpdate(ctx, currentConfigMap, desiredConfigMap)
+	delete, err := r.newDeleteChangeForUpdate(ctx, obj, currentConfigMap, desiredConfigMap)
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
@@ -60,7 +75,7 @@ func (r *Resource) NewUpdatePatch(ctx context.Context, obj, currentConfigMap, de
 	return patch, nil
 }
 
-func (r *Resource) newUpdateChange(ctx context.Context, currentResource, desiredResource interface{}) (interface{}, error) {
+func (r *Resource) newUpdateChange(ctx context.Context, obj, currentResource, desiredResource interface{}) (interface{}, error) {
 	currentConfigMap, err := toConfigMap(currentResource)
 	if err != nil {
 		return nil, microerror.Mask(err)
@@ -74,8 +89,35 @@ func (r *Resource) newUpdateChange(ctx context.Context, currentResource, desired
 	r.logger.Debugf(ctx, "finding out if the configmap has to be updated")
 
 	updateConfigMap := &corev1.ConfigMap{}
+
+	// Generations are applied whenever one exists so their metadata is
+	// updated even when the values did not change.
+	if r.immutableValues && !isEmpty(currentConfigMap) && !isEmpty(desiredConfigMap) {
+		// A new generation is only created when the values changed.
+		if currentConfigMap.Name != desiredConfigMap.Name {
+			deferred, err := r.deferChange(ctx, obj)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+			if deferred {
+				return updateConfigMap, nil
+			}
+		}
+
+		r.logger.Debugf(ctx, "the configmap generation has to be applied")
+		return desiredConfigMap, nil
+	}
+
 	isModified := !isEmpty(currentConfigMap) && !equals(currentConfigMap, desiredConfigMap)
 	if isModified {
+		deferred, err := r.deferChange(ctx, obj)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		if deferred {
+			return updateConfigMap, nil
+		}
+
 		r.logger.Debugf(ctx, "the configmap has to be updated")
 
 		updateConfigMap = desiredConfigMap.DeepCopy()
@@ -0,0 +1,46 @@
+package configmap
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/app-operator/v7/pkg/status"
+	"github.com/giantswarm/app-operator/v7/pkg/valuesource"
+	"github.com/giantswarm/app-operator/v7/service/controller/app/controllercontext"
+	"github.com/giantswarm/app-operator/v7/service/internal/valuesschema"
+)
+
+// validateValues validates the merged values against the values schema of
+// the chart before they are written. The configmap resource runs before the
+// secret resource so the secret values are merged here as well. It returns
+// false and sets the status when the values are invalid.
+func (r *Resource) validateValues(ctx context.Context, cc *controllercontext.Context, cr v1alpha1.App, configMapValues map[string]interface{}) (bool, error) {
+	if r.valuesValidation == nil {
+		return true, nil
+	}
+
+	secretValues, err := r.valuesMerge.Merge(ctx, cr, cc.Catalog, cc.Clients.K8s.K8sClient(), valuesource.TypeSecret)
+	if err != nil {
+		// Errors merging the secret values are reported by the secret
+		// resource.
+		r.logger.Debugf(ctx, "skipping values validation since secret values could not be merged")
+		return true, nil
+	}
+
+	violations, err := r.valuesValidation.Validate(ctx, cc.Clients.K8s.CtrlClient(), cr, cc.Catalog, configMapValues, secretValues.Values)
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+	if len(violations) == 0 {
+		return true, nil
+	}
+
+	r.logger.Debugf(ctx, "merged values do not match the values schema: %s", valuesschema.Message(violations))
+	reason := fmt.Sprintf("Merged values do not match the values schema of the chart: %s. Fix the values in the referenced configmaps and secrets.", valuesschema.Message(violations))
+	addStatusToContext(cc, reason, status.ValuesSchemaInvalidStatus)
+
+	return false, nil
+}
@@ -0,0 +1,85 @@
+package cordon
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/giantswarm/app/v8/pkg/key"
+	"github.com/giantswarm/k8smetadata/pkg/annotation"
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/app-operator/v7/pkg/status"
+	"github.com/giantswarm/app-operator/v7/service/controller/app/controllercontext"
+)
+
+// EnsureCreated sets the cordoned status while the cordon-until annotation of
+// the app CR is in the future and schedules removing the cordon annotations
+// when it passes. Invalid annotations set the cordon-failed status.
+func (r *Resource) EnsureCreated(ctx context.Context, obj interface{}) error {
+	cr, err := key.ToApp(obj)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	cc, err := controllercontext.FromContext(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	currentStatus := cr.Status.Release.Status
+
+	if key.CordonUntil(cr) == "" {
+		r.stopTimer(cr.Namespace, cr.Name)
+		deleteMetric(cr.Namespace, cr.Name)
+
+		if currentStatus == status.CordonStatus || currentStatus == status.CordonFailedStatus {
+			r.event.Emit(ctx, &cr, "Uncordoned", "app %#q is no longer cordoned", cr.Name)
+		}
+
+		return nil
+	}
+
+	until, err := time.Parse(time.RFC3339, key.CordonUntil(cr))
+	if err != nil {
+		reason := fmt.Sprintf("annotation %s value %#q must be a RFC3339 time, e.g. 2030-01-02T15:04:05Z", annotation.AppOperatorCordonUntil, key.CordonUntil(cr))
+
+		r.logger.Debugf(ctx, "app %#q has an invalid cordon: %s", cr.Name, reason)
+
+		if currentStatus != status.CordonFailedStatus {
+			r.event.Warn(ctx, &cr, "CordonFailed", "%s", reason)
+		}
+
+		r.stopTimer(cr.Namespace, cr.Name)
+		setMetric(cr.Namespace, cr.Name, status.CordonFailedStatus)
+		addStatusToContext(cc, reason, status.CordonFailedStatus)
+
+		return nil
+	}
+
+	if !time.Now().Before(until) {
+		err = r.uncordon(ctx, cr)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		return nil
+	}
+
+	reason := key.CordonReason(cr)
+	if reason == "" {
+		reason = fmt.Sprintf("cordoned until %s", until.Format(time.RFC3339))
+	}
+
+	r.logger.Debugf(ctx, "app %#q is cordoned until %s", cr.Name, until.Format(time.RFC3339))
+
+	if currentStatus != status.CordonStatus {
+		r.event.Emit(ctx, &cr, "Cordoned", "app %#q is cordoned until %s: %s", cr.Name, until.Format(time.RFC3339), reason)
+	}
+
+	r.scheduleUncordon(cr.Namespace, cr.Name, until)
+	setMetric(cr.Namespace, cr.Name, status.CordonStatus)
+	addStatusToContext(cc, reason, status.CordonStatus)
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package cordon
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/k8sclient/v7/pkg/k8sclienttest"
+	"github.com/giantswarm/k8smetadata/pkg/annotation"
+	"github.com/giantswarm/micrologger/microloggertest"
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/giantswarm/app-operator/v7/pkg/status"
+	"github.com/giantswarm/app-operator/v7/service/controller/app/controllercontext"
+	"github.com/giantswarm/app-operator/v7/service/internal/recorder"
+)
+
+func Test_Resource_EnsureCreated(t *testing.T) {
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	newApp := func(annotations map[string]string, releaseStatus string) *v1alpha1.App {
+		return &v1alpha1.App{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "test-app",
+				Namespace:   "default",
+				Annotations: annotations,
+			},
+			Status: v1alpha1.AppStatus{
+				Release: v1alpha1.AppStatusRelease{
+					Status: releaseStatus,
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name                string
+		app                 *v1alpha1.App
+		expectedStatus      controllercontext.ChartStatus
+		expectedAnnotations map[string]string
+		expectedTimer       bool
+	}{
+		{
+			name: "case 0: app without cordon",
+			app:  newApp(nil, "deployed"),
+		},
+		{
+			name: "case 1: cordoned app",
+			app: newApp(map[string]string{
+				annotation.AppOperatorCordonReason: "maintenance",
+				annotation.AppOperatorCordonUntil:  future,
+			}, "deployed"),
+			expectedStatus: controllercontext.ChartStatus{
+				Reason: "maintenance",
+				Status: status.CordonStatus,
+			},
+			expectedAnnotations: map[string]string{
+				annotation.AppOperatorCordonReason: "maintenance",
+				annotation.AppOperatorCordonUntil:  future,
+			},
+			expectedTimer: true,
+		},
+		{
+			name: "case 2: cordoned app without reason",
+			app: newApp(map[string]string{
+				annotation.AppOperatorCordonUntil: future,
+			}, status.CordonStatus),
+			expectedStatus: controllercontext.ChartStatus{
+				Reason: "cordoned until " + future,
+				Status: status.CordonStatus,
+			},
+			expectedAnnotations: map[string]string{
+				annotation.AppOperatorCordonUntil: future,
+			},
+			expectedTimer: true,
+		},
+		{
+			name: "case 3: invalid cordon-until",
+			app: newApp(map[string]string{
+				annotation.AppOperatorCordonUntil: "2030-01-02",
+			}, "deployed"),
+			expectedStatus: controllercontext.ChartStatus{
+				Reason: "annotation app-operator.giantswarm.io/cordon-until value `2030-01-02` must be a RFC3339 time, e.g. 2030-01-02T15:04:05Z",
+				Status: status.CordonFailedStatus,
+			},
+			expectedAnnotations: map[string]string{
+				annotation.AppOperatorCordonUntil: "2030-01-02",
+			},
+		},
+		{
+			name: "case 4: expired cordon is removed",
+			app: newApp(map[string]string{
+				annotation.AppOperatorCordonReason: "maintenance",
+				annotation.AppOperatorCordonUntil:  "2021-01-02T15:04:05Z",
+				"giantswarm.io/sample":             "kept",
+			}, status.CordonStatus),
+			expectedAnnotations: map[string]string{
+				"giantswarm.io/sample": "kept",
+			},
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			s := runtime.NewScheme()
+			err := v1alpha1.AddToScheme(s)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			ctrlClient := fake.NewClientBuilder().WithScheme(s).WithObjects(tc.app).Build()
+
+			c := Config{
+				CtrlClient: ctrlClient,
+				Event: recorder.New(recorder.Config{
+					K8sClient: k8sclienttest.NewClients(k8sclienttest.ClientsConfig{}),
+				}),
+				Logger: microloggertest.New(),
+			}
+			r, err := New(c)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			ctx := controllercontext.NewContext(context.Background(), controllercontext.Context{})
+
+			var app v1alpha1.App
+			err = ctrlClient.Get(ctx, types.NamespacedName{Namespace: tc.app.Namespace, Name: tc.app.Name}, &app)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			err = r.EnsureCreated(ctx, &app)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			cc, err := controllercontext.FromContext(ctx)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			if diff := cmp.Diff(tc.expectedStatus, cc.Status.ChartStatus); diff != "" {
+				t.Fatalf("want matching status \n %s", diff)
+			}
+
+			err = ctrlClient.Get(ctx, types.NamespacedName{Namespace: tc.app.Namespace, Name: tc.app.Name}, &app)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+			if diff := cmp.Diff(tc.expectedAnnotations, app.Annotations); diff != "" {
+				t.Fatalf("want matching annotations \n %s", diff)
+			}
+
+			_, timer := r.timers[timerID(tc.app.Namespace, tc.app.Name)]
+			if timer != tc.expectedTimer {
+				t.Fatalf("timer == %t, want %t", timer, tc.expectedTimer)
+			}
+
+			err = r.EnsureDeleted(ctx, &app)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package cordon
+
+import (
+	"context"
+
+	"github.com/giantswarm/app/v8/pkg/key"
+	"github.com/giantswarm/microerror"
+)
+
+// EnsureDeleted stops uncordoning the app CR and removes it from the metric.
+func (r *Resource) EnsureDeleted(ctx context.Context, obj interface{}) error {
+	cr, err := key.ToApp(obj)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	r.stopTimer(cr.Namespace, cr.Name)
+	deleteMetric(cr.Namespace, cr.Name)
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package cordon
+
+import "github.com/prometheus/client_golang/prometheus"
+
+const (
+	PrometheusNamespace = "app_operator"
+	PrometheusSubsystem = "cordon"
+)
+
+var (
+	cordonedGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: PrometheusNamespace,
+			Subsystem: PrometheusSubsystem,
+			Name:      "apps",
+			Help:      "App CRs which are currently cordoned. The status label is cordoned or cordon-failed.",
+		},
+		[]string{"app", "namespace", "status"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(cordonedGauge)
+}
@@ -0,0 +1,85 @@
+package cordon
+
+import (
+	"sync"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/app-operator/v7/service/controller/app/controllercontext"
+	"github.com/giantswarm/app-operator/v7/service/internal/recorder"
+)
+
+const (
+	// Name is the identifier of the resource.
+	Name = "cordon"
+)
+
+// Config represents the configuration used to create a new cordon resource.
+type Config struct {
+	// Dependencies.
+	CtrlClient client.Client
+	Event      recorder.Interface
+	Logger     micrologger.Logger
+}
+
+// Resource implements the cordon resource. It validates the cordon
+// annotations of app CRs, sets the cordoned and cordon-failed statuses and
+// removes the annotations once the cordon-until time has passed.
+type Resource struct {
+	// Dependencies.
+	ctrlClient client.Client
+	event      recorder.Interface
+	logger     micrologger.Logger
+
+	// Internals.
+	mutex sync.Mutex
+	// timers uncordon app CRs when their cordon expires. They are keyed by
+	// the namespace and name of the app CR.
+	timers map[string]*uncordonTimer
+}
+
+type uncordonTimer struct {
+	timer *time.Timer
+	until time.Time
+}
+
+// New creates a new configured cordon resource.
+func New(config Config) (*Resource, error) {
+	if config.CtrlClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.CtrlClient must not be empty", config)
+	}
+	if config.Event == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Event must not be empty", config)
+	}
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+
+	r := &Resource{
+		ctrlClient: config.CtrlClient,
+		event:      config.Event,
+		logger:     config.Logger,
+
+		timers: map[string]*uncordonTimer{},
+	}
+
+	return r, nil
+}
+
+func (r *Resource) Name() string {
+	return Name
+}
+
+// addStatusToContext adds the status to the controller context. It will be
+// used to set the CR status in the status resource.
+func addStatusToContext(cc *controllercontext.Context, reason, status string) {
+	cc.Status = controllercontext.Status{
+		ChartStatus: controllercontext.ChartStatus{
+			Reason: reason,
+			Status: status,
+		},
+	}
+}
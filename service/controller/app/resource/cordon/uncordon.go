@@ -0,0 +1,124 @@
+package cordon
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/app/v8/pkg/key"
+	"github.com/giantswarm/k8smetadata/pkg/annotation"
+	"github.com/giantswarm/microerror"
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// uncordon removes the cordon annotations of the app CR. Updating the app CR
+// triggers a reconciliation which applies the changes held back by the
+// cordon.
+func (r *Resource) uncordon(ctx context.Context, cr v1alpha1.App) error {
+	r.logger.Debugf(ctx, "uncordoning app %#q, cordon expired at %s", cr.Name, key.CordonUntil(cr))
+
+	patched := cr.DeepCopy()
+	delete(patched.Annotations, annotation.AppOperatorCordonReason)
+	delete(patched.Annotations, annotation.AppOperatorCordonUntil)
+
+	err := r.ctrlClient.Patch(ctx, patched, client.MergeFrom(&cr))
+	if apierrors.IsNotFound(err) {
+		r.logger.Debugf(ctx, "app %#q is already deleted", cr.Name)
+	} else if err != nil {
+		return microerror.Mask(err)
+	} else {
+		r.event.Emit(ctx, &cr, "Uncordoned", "cordon of app %#q expired at %s", cr.Name, key.CordonUntil(cr))
+	}
+
+	r.stopTimer(cr.Namespace, cr.Name)
+	deleteMetric(cr.Namespace, cr.Name)
+
+	r.logger.Debugf(ctx, "uncordoned app %#q", cr.Name)
+
+	return nil
+}
+
+// uncordonExpired uncordons the app CR when its cordon expired. It is called
+// by the timers scheduled for cordoned app CRs.
+func (r *Resource) uncordonExpired(namespace, name string) {
+	ctx := context.Background()
+
+	var cr v1alpha1.App
+	err := r.ctrlClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &cr)
+	if apierrors.IsNotFound(err) {
+		r.stopTimer(namespace, name)
+		deleteMetric(namespace, name)
+		return
+	} else if err != nil {
+		r.logger.Errorf(ctx, err, "failed to get app '%s/%s'", namespace, name)
+		return
+	}
+
+	// The annotation may have been changed since the timer was scheduled.
+	// Cordons which did not expire are handled by the next reconciliation.
+	until, err := time.Parse(time.RFC3339, key.CordonUntil(cr))
+	if err != nil || time.Now().Before(until) {
+		return
+	}
+
+	err = r.uncordon(ctx, cr)
+	if err != nil {
+		r.logger.Errorf(ctx, err, "failed to uncordon app '%s/%s'", namespace, name)
+	}
+}
+
+// scheduleUncordon uncordons the app CR when until passes. Timers of app CRs
+// whose cordon-until changed are replaced.
+func (r *Resource) scheduleUncordon(namespace, name string, until time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	id := timerID(namespace, name)
+
+	t, ok := r.timers[id]
+	if ok && t.until.Equal(until) {
+		return
+	}
+	if ok {
+		t.timer.Stop()
+	}
+
+	r.timers[id] = &uncordonTimer{
+		timer: time.AfterFunc(time.Until(until), func() {
+			r.uncordonExpired(namespace, name)
+		}),
+		until: until,
+	}
+}
+
+func (r *Resource) stopTimer(namespace, name string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	id := timerID(namespace, name)
+
+	t, ok := r.timers[id]
+	if !ok {
+		return
+	}
+
+	t.timer.Stop()
+	delete(r.timers, id)
+}
+
+func timerID(namespace, name string) string {
+	return fmt.Sprintf("%s/%s", namespace, name)
+}
+
+func setMetric(namespace, name, status string) {
+	deleteMetric(namespace, name)
+	cordonedGauge.WithLabelValues(name, namespace, status).Set(1)
+}
+
+func deleteMetric(namespace, name string) {
+	cordonedGauge.DeletePartialMatch(prometheus.Labels{"app": name, "namespace": namespace})
+}
@@ -7,9 +7,11 @@ import (
 	"github.com/giantswarm/errors/tenant"
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/operatorkit/v7/pkg/controller/context/resourcecanceledcontext"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"github.com/giantswarm/app-operator/v7/pkg/valuesgeneration"
 	"github.com/giantswarm/app-operator/v7/service/controller/app/controllercontext"
 )
 
@@ -49,9 +51,22 @@ func (r *Resource) GetCurrentState(ctx context.Context, obj interface{}) (interf
 		return nil, nil
 	}
 
+	if r.immutableValues {
+		name = valuesgeneration.Selector(cr.Name)
+	}
+
 	r.logger.Debugf(ctx, "finding secret %#q in namespace %#q", name, r.chartNamespace)
 
-	secret, err := cc.Clients.K8s.K8sClient().CoreV1().Secrets(r.chartNamespace).Get(ctx, name, metav1.GetOptions{})
+	var secret *corev1.Secret
+	if r.immutableValues {
+		// The newest generation is the current secret.
+		secret, err = r.latestGeneration(ctx, cc.Clients.K8s.K8sClient(), cr)
+		if err == nil && secret == nil {
+			err = apierrors.NewNotFound(corev1.Resource("secrets"), name)
+		}
+	} else {
+		secret, err = cc.Clients.K8s.K8sClient().CoreV1().Secrets(r.chartNamespace).Get(ctx, name, metav1.GetOptions{})
+	}
 	if apierrors.IsNotFound(err) {
 		// Return early as secret does not exist.
 		r.logger.Debugf(ctx, "did not find secret %#q in namespace %#q", name, r.chartNamespace)
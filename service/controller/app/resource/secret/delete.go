@@ -3,6 +3,7 @@ package secret
 import (
 	"context"
 
+	"github.com/giantswarm/app/v8/pkg/key"
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/operatorkit/v7/pkg/resource/crud"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -33,6 +34,20 @@ func (r *Resource) ApplyDeleteChange(ctx context.Context, obj, deleteChange inte
 		} else {
 			r.logger.Debugf(ctx, "deleted secret %#q in namespace %#q", secret.Name, secret.Namespace)
 		}
+
+		if r.immutableValues {
+			cr, err := key.ToApp(obj)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			err = r.deleteGenerations(ctx, cc.Clients.K8s.K8sClient(), cr)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			r.logger.Debugf(ctx, "deleted secret generations of app %#q", cr.Name)
+		}
 	}
 
 	return nil
@@ -59,7 +74,7 @@ func (r *Resource) newDeleteChange(ctx context.Context, obj, currentState, desir
 	return desiredSecret, nil
 }
 
-func (r *Resource) newDeleteChangeForUpdate(ctx context.Context, currentState, desiredState interface{}) (interface{}, error) {
+func (r *Resource) newDeleteChangeForUpdate(ctx context.Context, obj, currentState, desiredState interface{}) (interface{}, error) {
 	currentSecret, err := toSecret(currentState)
 	if err != nil {
 		return nil, microerror.Mask(err)
@@ -73,6 +88,14 @@ func (r *Resource) newDeleteChangeForUpdate(ctx context.Context, currentState, d
 	r.logger.Debugf(ctx, "finding out if the secret has to be deleted")
 
 	if !isEmpty(currentSecret) && isEmpty(desiredSecret) {
+		deferred, err := r.deferChange(ctx, obj)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		if deferred {
+			return nil, nil
+		}
+
 		r.logger.Debugf(ctx, "the secret has to be deleted")
 		return currentSecret, nil
 	}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 
 	"github.com/giantswarm/app/v8/pkg/key"
-	"github.com/giantswarm/app/v8/pkg/values"
 	"github.com/giantswarm/k8smetadata/pkg/annotation"
 	"github.com/giantswarm/k8smetadata/pkg/label"
 	"github.com/giantswarm/microerror"
@@ -15,8 +14,9 @@ import (
 	"sigs.k8s.io/yaml"
 
 	"github.com/giantswarm/app-operator/v7/pkg/project"
-	"github.com/giantswarm/app-operator/v7/pkg/status"
+	"github.com/giantswarm/app-operator/v7/pkg/valuesource"
 	"github.com/giantswarm/app-operator/v7/service/controller/app/controllercontext"
+	"github.com/giantswarm/app-operator/v7/service/internal/valuessize"
 )
 
 func (r *Resource) GetDesiredState(ctx context.Context, obj interface{}) (interface{}, error) {
@@ -30,6 +30,8 @@ func (r *Resource) GetDesiredState(ctx context.Context, obj interface{}) (interf
 	}
 
 	if key.IsDeleted(cr) {
+		r.valuesSize.Delete(cr)
+
 		// Return empty chart secret so it is deleted.
 		secret := &corev1.Secret{
 			ObjectMeta: metav1.ObjectMeta{
@@ -54,23 +56,32 @@ func (r *Resource) GetDesiredState(ctx context.Context, obj interface{}) (interf
 		}
 	}
 
-	mergedData, err := r.values.MergeSecretData(ctx, cr, cc.Catalog)
-	if values.IsNotFound(err) {
-		r.logger.LogCtx(ctx, "level", "warning", "message", "dependent secrets are not found")
-		addStatusToContext(cc, err.Error(), status.SecretMergeFailedStatus)
+	merged, err := r.valuesMerge.Merge(ctx, cr, cc.Catalog, cc.Clients.K8s.K8sClient(), valuesource.TypeSecret)
+	if err != nil {
+		class := errorClassifier.Classify(err)
+		if class.Retryable {
+			return nil, microerror.Mask(err)
+		}
+
+		r.logger.LogCtx(ctx, "level", "warning", "message", "failed to merge secrets", "reason", class.Reason)
+		addStatusToContext(cc, class.Message(), class.Status)
 
 		r.logger.Debugf(ctx, "canceling resource")
 		resourcecanceledcontext.SetCanceled(ctx)
 		return nil, nil
-	} else if values.IsParsingError(err) {
-		r.logger.LogCtx(ctx, "level", "warning", "message", "failed to merging secrets")
-		addStatusToContext(cc, err.Error(), status.SecretMergeFailedStatus)
+	}
+
+	mergedData := merged.Values
+	cc.Values.Secret = mergedData
 
+	valid, err := r.validateValues(ctx, cc, cr, mergedData)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	if !valid {
 		r.logger.Debugf(ctx, "canceling resource")
 		resourcecanceledcontext.SetCanceled(ctx)
 		return nil, nil
-	} else if err != nil {
-		return nil, microerror.Mask(err)
 	}
 
 	if mergedData == nil {
@@ -83,6 +94,14 @@ func (r *Resource) GetDesiredState(ctx context.Context, obj interface{}) (interf
 		return nil, microerror.Mask(err)
 	}
 
+	// The sources including value references are recorded so users can see
+	// which inputs produced the current values and the watcher can skip
+	// changes the values already reflect.
+	sourcesAnnotation, err := valuesource.ToAnnotation(merged.Sources())
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
 	secret := &corev1.Secret{
 		Data: map[string][]byte{
 			"values": bytes,
@@ -91,7 +110,8 @@ func (r *Resource) GetDesiredState(ctx context.Context, obj interface{}) (interf
 			Name:      key.ChartSecretName(cr),
 			Namespace: r.chartNamespace,
 			Annotations: map[string]string{
-				annotation.Notes: fmt.Sprintf("DO NOT EDIT. Values managed by %s.", project.Name()),
+				annotation.Notes:       fmt.Sprintf("DO NOT EDIT. Values managed by %s.", project.Name()),
+				valuesource.Annotation: sourcesAnnotation,
 			},
 			Labels: map[string]string{
 				label.ManagedBy: project.Name(),
@@ -99,5 +119,26 @@ func (r *Resource) GetDesiredState(ctx context.Context, obj interface{}) (interf
 		},
 	}
 
+	encoding, err := r.valuesSize.Apply(ctx, &cr, secret)
+	if valuessize.IsTooLarge(err) {
+		class := errorClassifier.Classify(err)
+		r.logger.LogCtx(ctx, "level", "warning", "message", "generated secret is too large", "reason", class.Reason)
+		addStatusToContext(cc, class.Message(), class.Status)
+
+		r.logger.Debugf(ctx, "canceling resource")
+		resourcecanceledcontext.SetCanceled(ctx)
+		return nil, nil
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	if r.immutableValues {
+		toGeneration(cr, secret, bytes)
+	}
+
+	// The chart resource points the chart CR at the generated secret.
+	cc.Values.SecretName = secret.Name
+	cc.Values.SecretEncoding = encoding
+
 	return secret, nil
 }
@@ -2,15 +2,16 @@ package secret
 
 import (
 	"context"
+	"fmt"
 	"reflect"
 	"strconv"
 	"testing"
 
 	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
-	"github.com/giantswarm/app/v8/pkg/values"
 	"github.com/giantswarm/k8sclient/v7/pkg/k8sclienttest"
 	"github.com/giantswarm/k8smetadata/pkg/annotation"
 	"github.com/giantswarm/k8smetadata/pkg/label"
+	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger/microloggertest"
 	"github.com/google/go-cmp/cmp"
 	corev1 "k8s.io/api/core/v1"
@@ -20,7 +21,12 @@ import (
 	clientgofake "k8s.io/client-go/kubernetes/fake"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
+	"github.com/giantswarm/app-operator/v7/pkg/maintenancewindow"
+	"github.com/giantswarm/app-operator/v7/pkg/valueref"
+	"github.com/giantswarm/app-operator/v7/pkg/valuesource"
 	"github.com/giantswarm/app-operator/v7/service/controller/app/controllercontext"
+	"github.com/giantswarm/app-operator/v7/service/internal/recorder"
+	"github.com/giantswarm/app-operator/v7/service/internal/valuessize"
 )
 
 func Test_Resource_GetDesiredState(t *testing.T) {
@@ -84,8 +90,9 @@ func Test_Resource_GetDesiredState(t *testing.T) {
 						"secrets": []byte("cluster: yaml\n"),
 					},
 					ObjectMeta: metav1.ObjectMeta{
-						Name:      "test-cluster-secrets",
-						Namespace: "giantswarm",
+						Name:            "test-cluster-secrets",
+						Namespace:       "giantswarm",
+						ResourceVersion: "123",
 					},
 				},
 			},
@@ -97,7 +104,8 @@ func Test_Resource_GetDesiredState(t *testing.T) {
 					Name:      "my-prometheus-chart-secrets",
 					Namespace: "giantswarm",
 					Annotations: map[string]string{
-						annotation.Notes: "DO NOT EDIT. Values managed by app-operator.",
+						annotation.Notes:       "DO NOT EDIT. Values managed by app-operator.",
+						valuesource.Annotation: `[{"kind":"app","type":"secret","name":"test-cluster-secrets","namespace":"giantswarm","resourceVersion":"123","priority":50}]`,
 					},
 					Labels: map[string]string{
 						label.ManagedBy: "app-operator",
@@ -143,7 +151,8 @@ func Test_Resource_GetDesiredState(t *testing.T) {
 					Name:      "test-app-chart-secrets",
 					Namespace: "giantswarm",
 					Annotations: map[string]string{
-						annotation.Notes: "DO NOT EDIT. Values managed by app-operator.",
+						annotation.Notes:       "DO NOT EDIT. Values managed by app-operator.",
+						valuesource.Annotation: `[{"kind":"user","type":"secret","name":"test-app-user-secrets","namespace":"giantswarm","priority":100}]`,
 					},
 					Labels: map[string]string{
 						label.ManagedBy: "app-operator",
@@ -200,7 +209,8 @@ func Test_Resource_GetDesiredState(t *testing.T) {
 					Name:      "test-app-chart-secrets",
 					Namespace: "giantswarm",
 					Annotations: map[string]string{
-						annotation.Notes: "DO NOT EDIT. Values managed by app-operator.",
+						annotation.Notes:       "DO NOT EDIT. Values managed by app-operator.",
+						valuesource.Annotation: `[{"kind":"user","type":"secret","name":"custom-secrets","namespace":"giantswarm","priority":100}]`,
 					},
 					Labels: map[string]string{
 						label.ManagedBy: "app-operator",
@@ -266,7 +276,69 @@ func Test_Resource_GetDesiredState(t *testing.T) {
 					Name:      "test-app-chart-secrets",
 					Namespace: "giantswarm",
 					Annotations: map[string]string{
-						annotation.Notes: "DO NOT EDIT. Values managed by app-operator.",
+						annotation.Notes:       "DO NOT EDIT. Values managed by app-operator.",
+						valuesource.Annotation: `[{"kind":"user","type":"secret","name":"custom-secrets","namespace":"giantswarm","priority":100}]`,
+					},
+					Labels: map[string]string{
+						label.ManagedBy: "app-operator",
+					},
+				},
+			},
+			expectedUserConfig: &v1alpha1.AppSpecUserConfig{
+				Secret: v1alpha1.AppSpecUserConfigSecret{
+					Name:      "custom-secrets",
+					Namespace: "giantswarm",
+				},
+			},
+		},
+		{
+			name: "case 5: values of secret store reference are merged below user secrets",
+			obj: &v1alpha1.App{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "my-database",
+					Namespace: "giantswarm",
+					Annotations: map[string]string{
+						valueref.Annotation: "- kind: secretStore\n  provider: file\n  path: giantswarm/database\n  target: auth\n  priority: 60\n",
+					},
+				},
+				Spec: v1alpha1.AppSpec{
+					Catalog:   "app-catalog",
+					Name:      "database",
+					Namespace: "monitoring",
+					UserConfig: v1alpha1.AppSpecUserConfig{
+						Secret: v1alpha1.AppSpecUserConfigSecret{
+							Name:      "custom-secrets",
+							Namespace: "giantswarm",
+						},
+					},
+				},
+			},
+			catalog: v1alpha1.Catalog{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test-catalog",
+				},
+			},
+			secrets: []*corev1.Secret{
+				{
+					Data: map[string][]byte{
+						"values": []byte("auth:\n  username: admin\n"),
+					},
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "custom-secrets",
+						Namespace: "giantswarm",
+					},
+				},
+			},
+			expectedSecret: &corev1.Secret{
+				Data: map[string][]byte{
+					"values": []byte("auth:\n  password: secret\n  username: admin\n"),
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "my-database-chart-secrets",
+					Namespace: "giantswarm",
+					Annotations: map[string]string{
+						annotation.Notes:       "DO NOT EDIT. Values managed by app-operator.",
+						valuesource.Annotation: `[{"kind":"secretStore","type":"secret","name":"giantswarm/database","namespace":"","resourceVersion":"1","priority":60,"provider":"file"},{"kind":"user","type":"secret","name":"custom-secrets","namespace":"giantswarm","priority":100}]`,
 					},
 					Labels: map[string]string{
 						label.ManagedBy: "app-operator",
@@ -311,24 +383,66 @@ func Test_Resource_GetDesiredState(t *testing.T) {
 				ctx = controllercontext.NewContext(context.Background(), c)
 			}
 
-			var valuesService *values.Values
+			var valueSources *valuesource.Resolver
 			{
-				c := values.Config{
+				c := valuesource.Config{
 					K8sClient: k8sClient,
-					Logger:    microloggertest.New(),
 				}
 
-				valuesService, err = values.New(c)
+				valueSources, err = valuesource.New(c)
+				if err != nil {
+					t.Fatalf("error == %#v, want nil", err)
+				}
+			}
+
+			var valueRefs *valueref.Resolver
+			{
+				c := valueref.Config{
+					K8sClient: k8sclienttest.NewClients(k8sclienttest.ClientsConfig{
+						CtrlClient: ctrlClient,
+						K8sClient:  k8sClient,
+					}),
+					SecretStore: secretStore{
+						"file/giantswarm/database": {
+							"password": "secret",
+							"username": "root",
+						},
+					},
+
+					ChartNamespace: "giantswarm",
+				}
+
+				valueRefs, err = valueref.New(c)
+				if err != nil {
+					t.Fatalf("error == %#v, want nil", err)
+				}
+			}
+
+			var valuesSize *valuessize.Guard
+			{
+				c := valuessize.Config{
+					Event: recorder.New(recorder.Config{
+						K8sClient: k8sclienttest.NewClients(k8sclienttest.ClientsConfig{}),
+					}),
+
+					Type: "secret",
+				}
+
+				valuesSize, err = valuessize.New(c)
 				if err != nil {
 					t.Fatalf("error == %#v, want nil", err)
 				}
 			}
 
 			c := Config{
-				Logger: microloggertest.New(),
-				Values: valuesService,
+				Logger:            microloggertest.New(),
+				MaintenanceWindow: &maintenancewindow.Policy{},
+				ValueRefs:         valueRefs,
+				ValueSources:      valueSources,
+				ValuesSize:        valuesSize,
 
 				ChartNamespace: "giantswarm",
+				Provider:       "aws",
 			}
 			r, err := New(c)
 			if err != nil {
@@ -388,3 +502,15 @@ func toStringMap(input map[string][]byte) map[string]string {
 
 	return result
 }
+
+// secretStore is a secret store holding values by provider and path.
+type secretStore map[string]map[string]interface{}
+
+func (s secretStore) Resolve(ctx context.Context, provider, path string) (map[string]interface{}, string, error) {
+	values, ok := s[fmt.Sprintf("%s/%s", provider, path)]
+	if !ok {
+		return nil, "", microerror.Maskf(notFoundError, "%s/%s", provider, path)
+	}
+
+	return runtime.DeepCopyJSON(values), "1", nil
+}
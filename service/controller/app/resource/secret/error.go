@@ -1,6 +1,56 @@
 package secret
 
-import "github.com/giantswarm/microerror"
+import (
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/app-operator/v7/pkg/errorclass"
+	"github.com/giantswarm/app-operator/v7/pkg/status"
+	"github.com/giantswarm/app-operator/v7/pkg/valueref"
+	"github.com/giantswarm/app-operator/v7/pkg/valuesource"
+	"github.com/giantswarm/app-operator/v7/pkg/valuestemplate"
+	"github.com/giantswarm/app-operator/v7/service/internal/secretstore"
+	"github.com/giantswarm/app-operator/v7/service/internal/valuessize"
+)
+
+// errorClassifier maps errors merging the secrets to the status set in the app
+// CR.
+var errorClassifier = errorclass.New(
+	errorclass.Rule{
+		Match:       valuessize.IsTooLarge,
+		Status:      status.ValuesTooLargeStatus,
+		Remediation: "reduce the values referenced by the app CR or enable values compression with the app.valuesCompression setting",
+	},
+	errorclass.Rule{
+		Match:       valuesource.IsNotFound,
+		Status:      status.SecretMergeFailedStatus,
+		Remediation: "check the secrets referenced by the app CR and its catalog exist",
+	},
+	errorclass.Rule{
+		Match:       valuesource.IsParsingError,
+		Status:      status.SecretMergeFailedStatus,
+		Remediation: "check the values in the secrets referenced by the app CR are valid YAML",
+	},
+	errorclass.Rule{
+		Match:       valueref.IsInvalidRef,
+		Status:      status.SecretMergeFailedStatus,
+		Remediation: "check the references in the " + valueref.Annotation + " annotation of the app CR",
+	},
+	errorclass.Rule{
+		Match:       secretstore.IsNotFound,
+		Status:      status.SecretMergeFailedStatus,
+		Remediation: "check the values referenced in the " + valueref.Annotation + " annotation of the app CR exist in the secret store",
+	},
+	errorclass.Rule{
+		Match:       secretstore.IsUnknownProvider,
+		Status:      status.SecretMergeFailedStatus,
+		Remediation: "check the provider of the secret store references in the " + valueref.Annotation + " annotation of the app CR is configured",
+	},
+	errorclass.Rule{
+		Match:       valuestemplate.IsTemplate,
+		Status:      status.SecretMergeFailedStatus,
+		Remediation: "check the templates in the values and the " + valuestemplate.Annotation + " annotation of the app CR",
+	},
+)
 
 var executionFailedError = &microerror.Error{
 	Kind: "executionFailed",
@@ -0,0 +1,137 @@
+package secret
+
+import (
+	"context"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/app/v8/pkg/key"
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/giantswarm/app-operator/v7/pkg/valuesencoding"
+	"github.com/giantswarm/app-operator/v7/pkg/valuesgeneration"
+)
+
+// toGeneration turns the desired secret into an immutable generation
+// named after the hash of the merged values. The name does not depend on the
+// annotations or the encoding of the values so only changed values create a
+// new generation.
+func toGeneration(cr v1alpha1.App, secret *corev1.Secret, values []byte) {
+	immutable := true
+
+	secret.Name = valuesgeneration.Name(key.ChartSecretName(cr), values)
+	secret.Labels[valuesgeneration.Label] = valuesgeneration.LabelValue(cr.Name)
+	secret.Immutable = &immutable
+}
+
+// latestGeneration returns the newest generation of the app CR. It is nil
+// when there are no generations.
+func (r *Resource) latestGeneration(ctx context.Context, k8sClient kubernetes.Interface, cr v1alpha1.App) (*corev1.Secret, error) {
+	list, err := k8sClient.CoreV1().Secrets(r.chartNamespace).List(ctx, metav1.ListOptions{
+		LabelSelector: valuesgeneration.Selector(cr.Name),
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	generations := make([]metav1.ObjectMeta, 0, len(list.Items))
+	for _, s := range list.Items {
+		generations = append(generations, s.ObjectMeta)
+	}
+
+	latest := valuesgeneration.Latest(generations)
+	for i := range list.Items {
+		if list.Items[i].Name == latest {
+			return &list.Items[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+// applyGeneration creates the desired generation unless it already exists,
+// e.g. when the values are reverted to a previous generation. Only metadata
+// of existing generations is updated since their data is immutable. The
+// generation is numbered as the latest one. Older generations are pruned by
+// the chart resource once the chart CR points at the desired generation.
+func (r *Resource) applyGeneration(ctx context.Context, k8sClient kubernetes.Interface, cr v1alpha1.App, desired *corev1.Secret) error {
+	list, err := k8sClient.CoreV1().Secrets(desired.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: valuesgeneration.Selector(cr.Name),
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	generations := make([]metav1.ObjectMeta, 0, len(list.Items))
+	var current *corev1.Secret
+	for i, s := range list.Items {
+		generations = append(generations, s.ObjectMeta)
+		if s.Name == desired.Name {
+			current = &list.Items[i]
+		}
+	}
+
+	if current == nil {
+		valuesgeneration.SetNumber(&desired.ObjectMeta, valuesgeneration.Next(generations))
+
+		r.logger.Debugf(ctx, "creating secret generation %#q in namespace %#q", desired.Name, desired.Namespace)
+
+		_, err = k8sClient.CoreV1().Secrets(desired.Namespace).Create(ctx, desired, metav1.CreateOptions{})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		r.logger.Debugf(ctx, "created secret generation %#q in namespace %#q", desired.Name, desired.Namespace)
+
+		return nil
+	}
+
+	// A generation which is not the latest one is applied again so it gets
+	// the next number.
+	number := valuesgeneration.Number(current.ObjectMeta)
+	if valuesgeneration.Latest(generations) != current.Name || number == 0 {
+		number = valuesgeneration.Next(generations)
+	}
+	valuesgeneration.SetNumber(&desired.ObjectMeta, number)
+
+	// The existing generation has the same values but they may be stored
+	// with another encoding, e.g. when the values are compressed because the
+	// annotations grew. The stored data is kept since it is immutable.
+	desired.Data = current.Data
+	if encoding, ok := current.Annotations[valuesencoding.Annotation]; ok {
+		desired.Annotations[valuesencoding.Annotation] = encoding
+	} else {
+		delete(desired.Annotations, valuesencoding.Annotation)
+	}
+
+	if !equals(current, desired) {
+		r.logger.Debugf(ctx, "updating metadata of secret generation %#q in namespace %#q", desired.Name, desired.Namespace)
+
+		update := current.DeepCopy()
+		update.Annotations = desired.Annotations
+		update.Labels = desired.Labels
+
+		_, err = k8sClient.CoreV1().Secrets(desired.Namespace).Update(ctx, update, metav1.UpdateOptions{})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		r.logger.Debugf(ctx, "updated metadata of secret generation %#q in namespace %#q", desired.Name, desired.Namespace)
+	}
+
+	return nil
+}
+
+// deleteGenerations deletes all generations of the app CR.
+func (r *Resource) deleteGenerations(ctx context.Context, k8sClient kubernetes.Interface, cr v1alpha1.App) error {
+	err := k8sClient.CoreV1().Secrets(r.chartNamespace).DeleteCollection(ctx, metav1.DeleteOptions{}, metav1.ListOptions{
+		LabelSelector: valuesgeneration.Selector(cr.Name),
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
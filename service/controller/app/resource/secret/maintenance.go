@@ -0,0 +1,40 @@
+package secret
+
+import (
+	"context"
+	"time"
+
+	"github.com/giantswarm/app/v8/pkg/key"
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/app-operator/v7/pkg/status"
+	"github.com/giantswarm/app-operator/v7/service/controller/app/controllercontext"
+)
+
+// deferChange returns whether changes of the secret are deferred to the
+// next maintenance window of the app CR. The reason is added to the
+// controller context so it is shown in the app CR status.
+func (r *Resource) deferChange(ctx context.Context, obj interface{}) (bool, error) {
+	cr, err := key.ToApp(obj)
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	deferred, reason, err := r.maintenanceWindow.Deferred(cr, time.Now())
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+	if !deferred {
+		return false, nil
+	}
+
+	cc, err := controllercontext.FromContext(ctx)
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	r.logger.Debugf(ctx, "deferring changes of the secret: %s", reason)
+	addStatusToContext(cc, reason, status.PendingMaintenanceWindowStatus)
+
+	return true, nil
+}
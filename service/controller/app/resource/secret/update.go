@@ -3,6 +3,7 @@ package secret
 import (
 	"context"
 
+	"github.com/giantswarm/app/v8/pkg/key"
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/operatorkit/v7/pkg/resource/crud"
 	corev1 "k8s.io/api/core/v1"
@@ -18,13 +19,27 @@ func (r *Resource) ApplyUpdateChange(ctx context.Context, obj, updateChange inte
 	}
 
 	if !isEmpty(secret) {
-		r.logger.Debugf(ctx, "updating secret %#q in namespace %#q", secret.Name, secret.Namespace)
-
 		cc, err := controllercontext.FromContext(ctx)
 		if err != nil {
 			return microerror.Mask(err)
 		}
 
+		if r.immutableValues {
+			cr, err := key.ToApp(obj)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			err = r.applyGeneration(ctx, cc.Clients.K8s.K8sClient(), cr, secret)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			return nil
+		}
+
+		r.logger.Debugf(ctx, "updating secret %#q in namespace %#q", secret.Name, secret.Namespace)
+
 		_, err = cc.Clients.K8s.K8sClient().CoreV1().Secrets(secret.Namespace).Update(ctx, secret, metav1.UpdateOptions{})
 		if err != nil {
 			return microerror.Mask(err)
@@ -42,12 +57,12 @@ func (r *Resource) NewUpdatePatch(ctx context.Context, obj, currentSecret, desir
 		return nil, microerror.Mask(err)
 	}
 
-	update, err := r.newUpdateChange(ctx, currentSecret, desiredSecret)
+	update, err := r.newUpdateChange(ctx, obj, currentSecret, desiredSecret)
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
 
-	delete, err := r.newDeleteChangeForUpdate(ctx, currentSecret, desiredSecret)
+	delete, err := r.newDeleteChangeForUpdate(ctx, obj, currentSecret, desiredSecret)
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
@@ -60,7 +75,7 @@ func (r *Resource) NewUpdatePatch(ctx context.Context, obj, currentSecret, desir
 	return patch, nil
 }
 
-func (r *Resource) newUpdateChange(ctx context.Context, currentResource, desiredResource interface{}) (interface{}, error) {
+func (r *Resource) newUpdateChange(ctx context.Context, obj, currentResource, desiredResource interface{}) (interface{}, error) {
 	currentSecret, err := toSecret(currentResource)
 	if err != nil {
 		return nil, microerror.Mask(err)
@@ -74,8 +89,35 @@ func (r *Resource) newUpdateChange(ctx context.Context, currentResource, desired
 	r.logger.Debugf(ctx, "finding out if the secret has to be updated")
 
 	updateSecret := &corev1.Secret{}
+
+	// Generations are applied whenever one exists so their metadata is
+	// updated even when the values did not change.
+	if r.immutableValues && !isEmpty(currentSecret) && !isEmpty(desiredSecret) {
+		// A new generation is only created when the values changed.
+		if currentSecret.Name != desiredSecret.Name {
+			deferred, err := r.deferChange(ctx, obj)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+			if deferred {
+				return updateSecret, nil
+			}
+		}
+
+		r.logger.Debugf(ctx, "the secret generation has to be applied")
+		return desiredSecret, nil
+	}
+
 	isModified := !isEmpty(currentSecret) && !equals(currentSecret, desiredSecret)
 	if isModified {
+		deferred, err := r.deferChange(ctx, obj)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		if deferred {
+			return updateSecret, nil
+		}
+
 		r.logger.Debugf(ctx, "the secret has to be updated")
 
 		updateSecret = desiredSecret.DeepCopy()
@@ -0,0 +1,47 @@
+package secret
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/app-operator/v7/pkg/status"
+	"github.com/giantswarm/app-operator/v7/service/controller/app/controllercontext"
+	"github.com/giantswarm/app-operator/v7/service/internal/valuesschema"
+)
+
+// validateValues validates the merged values against the values schema of
+// the chart before they are written. The configmap values were merged by
+// the configmap resource. When it found them invalid the secret is not
+// written either and when it failed to merge them validation is skipped
+// since the failure is reported already. It returns false and sets the
+// status when the values are invalid.
+func (r *Resource) validateValues(ctx context.Context, cc *controllercontext.Context, cr v1alpha1.App, secretValues map[string]interface{}) (bool, error) {
+	if r.valuesValidation == nil {
+		return true, nil
+	}
+
+	switch cc.Status.ChartStatus.Status {
+	case "":
+	case status.ValuesSchemaInvalidStatus:
+		return false, nil
+	default:
+		return true, nil
+	}
+
+	violations, err := r.valuesValidation.Validate(ctx, cc.Clients.K8s.CtrlClient(), cr, cc.Catalog, cc.Values.ConfigMap, secretValues)
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+	if len(violations) == 0 {
+		return true, nil
+	}
+
+	r.logger.Debugf(ctx, "merged values do not match the values schema: %s", valuesschema.Message(violations))
+	reason := fmt.Sprintf("Merged values do not match the values schema of the chart: %s. Fix the values in the referenced configmaps and secrets.", valuesschema.Message(violations))
+	addStatusToContext(cc, reason, status.ValuesSchemaInvalidStatus)
+
+	return false, nil
+}
@@ -0,0 +1,144 @@
+package secret
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/k8sclient/v7/pkg/k8sclienttest"
+	"github.com/giantswarm/micrologger/microloggertest"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake" //nolint:staticcheck
+
+	"github.com/giantswarm/app-operator/v7/pkg/status"
+	"github.com/giantswarm/app-operator/v7/service/controller/app/controllercontext"
+	"github.com/giantswarm/app-operator/v7/service/internal/indexcache"
+	"github.com/giantswarm/app-operator/v7/service/internal/indexcache/indexcachetest"
+	"github.com/giantswarm/app-operator/v7/service/internal/valuesschema"
+	"github.com/giantswarm/app-operator/v7/service/internal/valuesvalidation"
+)
+
+func Test_Resource_validateValues(t *testing.T) {
+	const secretValue = "hunter2-do-not-leak"
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/values.schema.json", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{
+			"type": "object",
+			"properties": {
+				"db": {
+					"type": "object",
+					"properties": {
+						"password": {"type": "string", "pattern": "^[0-9]+$", "maxLength": 4}
+					}
+				}
+			}
+		}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	repositoryURL := "https://example.com/catalog"
+	index := &indexcache.Index{
+		Entries: map[string][]indexcache.Entry{
+			"test-app": {
+				{
+					Annotations: map[string]string{
+						"io.giantswarm.application.values-schema": server.URL + "/values.schema.json",
+					},
+					Urls:    []string{"oci://example.com/test-app:1.0.0"},
+					Version: "1.0.0",
+				},
+			},
+		},
+	}
+
+	schemas, err := valuesschema.New(valuesschema.Config{
+		Logger: microloggertest.New(),
+
+		HTTPClientTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	valuesValidation, err := valuesvalidation.New(valuesvalidation.Config{
+		IndexCache: indexcachetest.NewMap(map[string]indexcachetest.Config{
+			repositoryURL: {GetIndexResponse: index},
+		}),
+		Logger:       microloggertest.New(),
+		ValuesSchema: schemas,
+
+		ChartNamespace: "giantswarm",
+	})
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	r := &Resource{
+		logger:           microloggertest.New(),
+		valuesValidation: valuesValidation,
+	}
+
+	cr := v1alpha1.App{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-app",
+			Namespace: "giantswarm",
+		},
+		Spec: v1alpha1.AppSpec{
+			Catalog: "giantswarm",
+			Name:    "test-app",
+			Version: "1.0.0",
+			KubeConfig: v1alpha1.AppSpecKubeConfig{
+				InCluster: true,
+			},
+		},
+	}
+	cc := &controllercontext.Context{
+		Catalog: v1alpha1.Catalog{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "giantswarm",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.CatalogSpec{
+				Storage: v1alpha1.CatalogSpecStorage{
+					URL: repositoryURL,
+				},
+			},
+		},
+		Clients: controllercontext.Clients{
+			K8s: k8sclienttest.NewClients(k8sclienttest.ClientsConfig{
+				CtrlClient: fake.NewClientBuilder().WithScheme(runtime.NewScheme()).Build(),
+			}),
+		},
+	}
+	secretValues := map[string]interface{}{
+		"db": map[string]interface{}{
+			"password": secretValue,
+		},
+	}
+
+	valid, err := r.validateValues(context.Background(), cc, cr, secretValues)
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+	if valid {
+		t.Fatalf("valid == true, want false")
+	}
+
+	reason := cc.Status.ChartStatus.Reason
+	if cc.Status.ChartStatus.Status != status.ValuesSchemaInvalidStatus {
+		t.Fatalf("status == %#q, want %#q", cc.Status.ChartStatus.Status, status.ValuesSchemaInvalidStatus)
+	}
+	if !strings.Contains(reason, "/db/password: pattern") {
+		t.Fatalf("reason %#q must name the pointer and keyword", reason)
+	}
+	if strings.Contains(reason, secretValue) {
+		t.Fatalf("reason %#q must not contain the secret value", reason)
+	}
+}
@@ -0,0 +1,75 @@
+package sharding
+
+import (
+	"context"
+
+	"github.com/giantswarm/app/v8/pkg/key"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	"github.com/giantswarm/operatorkit/v7/pkg/controller/context/reconciliationcanceledcontext"
+
+	"github.com/giantswarm/app-operator/v7/service/internal/shard"
+)
+
+const (
+	// Name is the identifier of the resource.
+	Name = "sharding"
+)
+
+type Config struct {
+	Logger  micrologger.Logger
+	Sharder shard.Interface
+}
+
+// Resource cancels the reconciliation of app CRs which belong to the shard
+// of another replica. Deletions are canceled as well so the finalizer is
+// removed by the replica owning the app CR.
+type Resource struct {
+	logger  micrologger.Logger
+	sharder shard.Interface
+}
+
+func New(config Config) (*Resource, error) {
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+	if config.Sharder == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Sharder must not be empty", config)
+	}
+
+	r := &Resource{
+		logger:  config.Logger,
+		sharder: config.Sharder,
+	}
+
+	return r, nil
+}
+
+func (r *Resource) EnsureCreated(ctx context.Context, obj interface{}) error {
+	return r.ensureOwned(ctx, obj)
+}
+
+func (r *Resource) EnsureDeleted(ctx context.Context, obj interface{}) error {
+	return r.ensureOwned(ctx, obj)
+}
+
+// Name returns the resource name.
+func (r *Resource) Name() string {
+	return Name
+}
+
+func (r *Resource) ensureOwned(ctx context.Context, obj interface{}) error {
+	cr, err := key.ToApp(obj)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if !r.sharder.Owns(cr) {
+		r.logger.Debugf(ctx, "app CR belongs to the shard of another replica")
+		r.logger.Debugf(ctx, "canceling reconciliation")
+		reconciliationcanceledcontext.SetCanceled(ctx)
+		return nil
+	}
+
+	return nil
+}
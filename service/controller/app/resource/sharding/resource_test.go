@@ -0,0 +1,78 @@
+package sharding
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/micrologger/microloggertest"
+	"github.com/giantswarm/operatorkit/v7/pkg/controller/context/reconciliationcanceledcontext"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type fakeSharder struct {
+	owned map[string]bool
+}
+
+func (s fakeSharder) Owns(app v1alpha1.App) bool {
+	return s.owned[app.Name]
+}
+
+func (s fakeSharder) Subscribe(f func()) {}
+
+func Test_Resource_EnsureCreated(t *testing.T) {
+	tests := []struct {
+		name             string
+		app              string
+		expectedCanceled bool
+	}{
+		{
+			name:             "case 0: owned app CR is reconciled",
+			app:              "kiam",
+			expectedCanceled: false,
+		},
+		{
+			name:             "case 1: app CR of another replica is canceled",
+			app:              "nginx",
+			expectedCanceled: true,
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			c := Config{
+				Logger: microloggertest.New(),
+				Sharder: fakeSharder{
+					owned: map[string]bool{"kiam": true},
+				},
+			}
+			r, err := New(c)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			app := &v1alpha1.App{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      tc.app,
+					Namespace: "org-acme",
+				},
+			}
+
+			for _, f := range []func(context.Context, interface{}) error{r.EnsureCreated, r.EnsureDeleted} {
+				ctx := reconciliationcanceledcontext.NewContext(context.Background(), make(chan struct{}))
+
+				err = f(ctx, app)
+				if err != nil {
+					t.Fatalf("error == %#v, want nil", err)
+				}
+
+				if reconciliationcanceledcontext.IsCanceled(ctx) != tc.expectedCanceled {
+					t.Fatalf("canceled == %t, want %t", reconciliationcanceledcontext.IsCanceled(ctx), tc.expectedCanceled)
+				}
+			}
+		})
+	}
+}
@@ -10,6 +10,7 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
 
+	"github.com/giantswarm/app-operator/v7/pkg/rollback"
 	"github.com/giantswarm/app-operator/v7/service/controller/app/controllercontext"
 )
 
@@ -84,6 +85,7 @@ func (r *Resource) EnsureCreated(ctx context.Context, obj interface{}) error {
 		if chartStatus.Release.LastDeployed != nil {
 			desiredStatus.Release.LastDeployed = *chartStatus.Release.LastDeployed
 		}
+		desiredStatus = rollback.AppStatus(chart, desiredStatus)
 	}
 
 	if !equals(desiredStatus, key.AppStatus(cr)) {
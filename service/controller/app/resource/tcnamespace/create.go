@@ -72,7 +72,7 @@ func (r *Resource) EnsureCreated(ctx context.Context, obj interface{}) error {
 		r.logger.Debugf(ctx, "canceling resource")
 		return nil
 	} else if err != nil {
-		return microerror.Mask(err)
+		return r.handleError(ctx, cc, err)
 	}
 
 	r.logger.Debugf(ctx, "creating namespace %#q in workload cluster %#q", ns.Name, key.ClusterID(cr))
@@ -108,7 +108,7 @@ func (r *Resource) EnsureCreated(ctx context.Context, obj interface{}) error {
 		r.logger.Debugf(ctx, "canceling resource")
 		return nil
 	} else if err != nil {
-		return microerror.Mask(err)
+		return r.handleError(ctx, cc, err)
 	}
 
 	r.logger.Debugf(ctx, "created namespace %#q in workload cluster %#q", ns.Name, key.ClusterID(cr))
@@ -1,6 +1,14 @@
 package tcnamespace
 
-import "github.com/giantswarm/microerror"
+import (
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/app-operator/v7/pkg/errorclass"
+)
+
+// errorClassifier maps errors in the workload cluster to the status set in
+// the app CR. Only the common rules apply.
+var errorClassifier = errorclass.New()
 
 var invalidConfigError = &microerror.Error{
 	Kind: "invalidConfigError",
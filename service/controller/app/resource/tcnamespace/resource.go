@@ -1,8 +1,13 @@
 package tcnamespace
 
 import (
+	"context"
+
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
+	"github.com/giantswarm/operatorkit/v7/pkg/controller/context/resourcecanceledcontext"
+
+	"github.com/giantswarm/app-operator/v7/service/controller/app/controllercontext"
 )
 
 const (
@@ -35,3 +40,31 @@ func New(config Config) (*Resource, error) {
 func (r Resource) Name() string {
 	return Name
 }
+
+// addStatusToContext adds the status to the controller context. It will be
+// used to set the CR status in the status resource.
+func addStatusToContext(cc *controllercontext.Context, reason, status string) {
+	cc.Status = controllercontext.Status{
+		ChartStatus: controllercontext.ChartStatus{
+			Reason: reason,
+			Status: status,
+		},
+	}
+}
+
+// handleError classifies the error. Retryable errors are returned so the
+// reconciliation is retried. Non retryable errors are added to the controller
+// context so they are set in the app CR status by the status resource.
+func (r *Resource) handleError(ctx context.Context, cc *controllercontext.Context, err error) error {
+	class := errorClassifier.Classify(err)
+	if class.Retryable {
+		return microerror.Mask(err)
+	}
+
+	r.logger.LogCtx(ctx, "level", "warning", "message", "failed to create namespace in workload cluster", "reason", class.Reason)
+	addStatusToContext(cc, class.Message(), class.Status)
+
+	r.logger.Debugf(ctx, "canceling resource")
+	resourcecanceledcontext.SetCanceled(ctx)
+	return nil
+}
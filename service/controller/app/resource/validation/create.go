@@ -10,7 +10,8 @@ import (
 	"github.com/giantswarm/operatorkit/v7/pkg/controller/context/reconciliationcanceledcontext"
 	"k8s.io/apimachinery/pkg/types"
 
-	"github.com/giantswarm/app-operator/v7/pkg/status"
+	"github.com/giantswarm/app-operator/v7/pkg/errorclass"
+	"github.com/giantswarm/app-operator/v7/pkg/maintenancewindow"
 )
 
 func (r *Resource) EnsureCreated(ctx context.Context, obj interface{}) error {
@@ -20,10 +21,18 @@ func (r *Resource) EnsureCreated(ctx context.Context, obj interface{}) error {
 	}
 
 	_, err = r.appValidator.ValidateApp(ctx, cr)
+	if err == nil {
+		_, err = maintenancewindow.FromApp(cr)
+	}
 	if err != nil {
+		class := errorClassifier.Classify(err)
+		if class.Retryable {
+			return microerror.Mask(err)
+		}
+
 		r.logger.LogCtx(ctx, "level", "warning", "message", fmt.Sprintf("validation error %s", err.Error()))
 
-		err = r.updateAppStatus(ctx, cr, err.Error())
+		err = r.updateAppStatus(ctx, cr, class)
 		if err != nil {
 			return microerror.Mask(err)
 		}
@@ -36,7 +45,7 @@ func (r *Resource) EnsureCreated(ctx context.Context, obj interface{}) error {
 	return nil
 }
 
-func (r *Resource) updateAppStatus(ctx context.Context, cr v1alpha1.App, reason string) error {
+func (r *Resource) updateAppStatus(ctx context.Context, cr v1alpha1.App, class errorclass.Class) error {
 	r.logger.Debugf(ctx, "setting status for app %#q in namespace %#q", cr.Name, cr.Namespace)
 
 	var currentCR v1alpha1.App
@@ -53,8 +62,8 @@ func (r *Resource) updateAppStatus(ctx context.Context, cr v1alpha1.App, reason
 
 	currentCR.Status = v1alpha1.AppStatus{
 		Release: v1alpha1.AppStatusRelease{
-			Reason: reason,
-			Status: status.ResourceNotFoundStatus,
+			Reason: class.Message(),
+			Status: class.Status,
 		},
 	}
 
@@ -1,7 +1,37 @@
 package validation
 
 import (
+	"github.com/giantswarm/app/v8/pkg/validation"
 	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/app-operator/v7/pkg/errorclass"
+	"github.com/giantswarm/app-operator/v7/pkg/maintenancewindow"
+	"github.com/giantswarm/app-operator/v7/pkg/status"
+)
+
+// errorClassifier maps errors validating the app CR to the status set in the
+// app CR.
+var errorClassifier = errorclass.New(
+	errorclass.Rule{
+		Match:       validation.IsAppConfigMapNotFound,
+		Status:      status.ResourceNotFoundStatus,
+		Remediation: "check the configmap referenced in .spec.config exists",
+	},
+	errorclass.Rule{
+		Match:       validation.IsKubeConfigNotFound,
+		Status:      status.ResourceNotFoundStatus,
+		Remediation: "check the kubeconfig secret referenced in .spec.kubeConfig exists",
+	},
+	errorclass.Rule{
+		Match:       validation.IsValidationError,
+		Status:      status.ValidationFailedStatus,
+		Remediation: "fix the app CR spec according to the validation error",
+	},
+	errorclass.Rule{
+		Match:       maintenancewindow.IsInvalidWindow,
+		Status:      status.ValidationFailedStatus,
+		Remediation: "fix the maintenance window annotations of the app CR",
+	},
 )
 
 var invalidConfigError = &microerror.Error{
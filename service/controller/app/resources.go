@@ -14,6 +14,9 @@ import (
 	"github.com/giantswarm/operatorkit/v7/pkg/resource/wrapper/retryresource"
 	"github.com/spf13/afero"
 
+	"github.com/giantswarm/app-operator/v7/pkg/maintenancewindow"
+	"github.com/giantswarm/app-operator/v7/pkg/valueref"
+	"github.com/giantswarm/app-operator/v7/pkg/valuesource"
 	"github.com/giantswarm/app-operator/v7/service/controller/app/resource/appfinalizermigration"
 	"github.com/giantswarm/app-operator/v7/service/controller/app/resource/appnamespace"
 	"github.com/giantswarm/app-operator/v7/service/controller/app/resource/catalog"
@@ -22,21 +25,35 @@ import (
 	"github.com/giantswarm/app-operator/v7/service/controller/app/resource/chartoperator"
 	"github.com/giantswarm/app-operator/v7/service/controller/app/resource/clients"
 	"github.com/giantswarm/app-operator/v7/service/controller/app/resource/configmap"
+	"github.com/giantswarm/app-operator/v7/service/controller/app/resource/cordon"
 	"github.com/giantswarm/app-operator/v7/service/controller/app/resource/secret"
+	"github.com/giantswarm/app-operator/v7/service/controller/app/resource/sharding"
 	"github.com/giantswarm/app-operator/v7/service/controller/app/resource/status"
 	"github.com/giantswarm/app-operator/v7/service/controller/app/resource/tcnamespace"
 	"github.com/giantswarm/app-operator/v7/service/controller/app/resource/validation"
 	"github.com/giantswarm/app-operator/v7/service/internal/clientcache"
 	"github.com/giantswarm/app-operator/v7/service/internal/indexcache"
+	"github.com/giantswarm/app-operator/v7/service/internal/recorder"
+	"github.com/giantswarm/app-operator/v7/service/internal/secretstore"
+	"github.com/giantswarm/app-operator/v7/service/internal/shard"
+	"github.com/giantswarm/app-operator/v7/service/internal/valuesschema"
+	"github.com/giantswarm/app-operator/v7/service/internal/valuessize"
 )
 
 type appResourcesConfig struct {
 	// Dependencies.
 	ClientCache *clientcache.Resource
+	Event       recorder.Interface
 	FileSystem  afero.Fs
 	IndexCache  indexcache.Interface
 	K8sClient   k8sclient.Interface
 	Logger      micrologger.Logger
+	// ValuesSchema is optional.
+	ValuesSchema valuesschema.Interface
+	// SecretStore is optional.
+	SecretStore secretstore.Interface
+	// Sharder is optional.
+	Sharder shard.Interface
 
 	// Settings.
 	ChartNamespace               string
@@ -47,6 +64,14 @@ type appResourcesConfig struct {
 	UniqueApp                    bool
 	WorkloadClusterID            string
 	DependencyWaitTimeoutMinutes int
+	ImmutableValues              bool
+	ValuesRetention              int
+	ValuesCompression            bool
+	MaintenanceWindowSchedule    string
+	MaintenanceWindowDuration    string
+	MaintenanceWindowTimezone    string
+	ValueRefNamespaces           []string
+	SecretStorePathPrefixes      map[string]string
 }
 
 func newAppResources(config appResourcesConfig) ([]resource.Interface, error) {
@@ -56,6 +81,9 @@ func newAppResources(config appResourcesConfig) ([]resource.Interface, error) {
 	if config.ClientCache == nil {
 		return nil, microerror.Maskf(invalidConfigError, "%T.ClientCache must not be empty", config)
 	}
+	if config.Event == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Event must not be empty", config)
+	}
 	if config.FileSystem == nil {
 		return nil, microerror.Maskf(invalidConfigError, "%T.Fs must not be empty", config)
 	}
@@ -99,6 +127,40 @@ func newAppResources(config appResourcesConfig) ([]resource.Interface, error) {
 		}
 	}
 
+	var valueSources *valuesource.Resolver
+	{
+		c := valuesource.Config{
+			K8sClient: config.K8sClient.K8sClient(),
+		}
+
+		valueSources, err = valuesource.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	var valueRefs *valueref.Resolver
+	{
+		c := valueref.Config{
+			K8sClient: config.K8sClient,
+
+			ChartNamespace: config.ChartNamespace,
+			Namespaces:     config.ValueRefNamespaces,
+
+			SecretStorePathPrefixes: config.SecretStorePathPrefixes,
+		}
+		// The store is only set when configured so a nil store is not
+		// passed as a non-nil interface.
+		if config.SecretStore != nil {
+			c.SecretStore = config.SecretStore
+		}
+
+		valueRefs, err = valueref.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
 	var catalogResource resource.Interface
 	{
 		c := catalog.Config{
@@ -153,17 +215,35 @@ func newAppResources(config appResourcesConfig) ([]resource.Interface, error) {
 		}
 	}
 
+	var maintenanceWindow *maintenancewindow.Policy
+	{
+		c := maintenancewindow.Config{
+			Schedule: config.MaintenanceWindowSchedule,
+			Duration: config.MaintenanceWindowDuration,
+			Timezone: config.MaintenanceWindowTimezone,
+		}
+
+		maintenanceWindow, err = maintenancewindow.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
 	var chartResource resource.Interface
 	{
 		c := chart.Config{
-			IndexCache:    config.IndexCache,
-			Logger:        config.Logger,
-			CtrlClient:    config.K8sClient.CtrlClient(),
-			DynamicClient: config.K8sClient.DynClient(),
+			Event:             config.Event,
+			IndexCache:        config.IndexCache,
+			Logger:            config.Logger,
+			MaintenanceWindow: maintenanceWindow,
+			CtrlClient:        config.K8sClient.CtrlClient(),
+			DynamicClient:     config.K8sClient.DynClient(),
 
 			ChartNamespace:               config.ChartNamespace,
 			WorkloadClusterID:            config.WorkloadClusterID,
 			DependencyWaitTimeoutMinutes: config.DependencyWaitTimeoutMinutes,
+			ImmutableValues:              config.ImmutableValues,
+			ValuesRetention:              config.ValuesRetention,
 		}
 
 		ops, err := chart.New(c)
@@ -222,13 +302,36 @@ func newAppResources(config appResourcesConfig) ([]resource.Interface, error) {
 		}
 	}
 
+	var configMapValuesSize *valuessize.Guard
+	{
+		c := valuessize.Config{
+			Event: config.Event,
+
+			Compression: config.ValuesCompression,
+			Type:        "configmap",
+		}
+
+		configMapValuesSize, err = valuessize.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
 	var configMapResource resource.Interface
 	{
 		c := configmap.Config{
-			Logger: config.Logger,
-			Values: valuesService,
+			IndexCache:        config.IndexCache,
+			Logger:            config.Logger,
+			MaintenanceWindow: maintenanceWindow,
+			ValueRefs:         valueRefs,
+			ValueSources:      valueSources,
+			ValuesSchema:      config.ValuesSchema,
+			ValuesSize:        configMapValuesSize,
 
-			ChartNamespace: config.ChartNamespace,
+			ChartNamespace:    config.ChartNamespace,
+			ImmutableValues:   config.ImmutableValues,
+			Provider:          config.Provider,
+			WorkloadClusterID: config.WorkloadClusterID,
 		}
 
 		ops, err := configmap.New(c)
@@ -242,13 +345,36 @@ func newAppResources(config appResourcesConfig) ([]resource.Interface, error) {
 		}
 	}
 
+	var secretValuesSize *valuessize.Guard
+	{
+		c := valuessize.Config{
+			Event: config.Event,
+
+			Compression: config.ValuesCompression,
+			Type:        "secret",
+		}
+
+		secretValuesSize, err = valuessize.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
 	var secretResource resource.Interface
 	{
 		c := secret.Config{
-			Logger: config.Logger,
-			Values: valuesService,
+			IndexCache:        config.IndexCache,
+			Logger:            config.Logger,
+			MaintenanceWindow: maintenanceWindow,
+			ValueRefs:         valueRefs,
+			ValueSources:      valueSources,
+			ValuesSchema:      config.ValuesSchema,
+			ValuesSize:        secretValuesSize,
 
-			ChartNamespace: config.ChartNamespace,
+			ChartNamespace:    config.ChartNamespace,
+			ImmutableValues:   config.ImmutableValues,
+			Provider:          config.Provider,
+			WorkloadClusterID: config.WorkloadClusterID,
 		}
 
 		ops, err := secret.New(c)
@@ -290,6 +416,20 @@ func newAppResources(config appResourcesConfig) ([]resource.Interface, error) {
 		}
 	}
 
+	var cordonResource resource.Interface
+	{
+		c := cordon.Config{
+			CtrlClient: config.K8sClient.CtrlClient(),
+			Event:      config.Event,
+			Logger:     config.Logger,
+		}
+
+		cordonResource, err = cordon.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
 	var validationResource resource.Interface
 	{
 		c := validation.Config{
@@ -311,6 +451,10 @@ func newAppResources(config appResourcesConfig) ([]resource.Interface, error) {
 		// validationResource checks CRs for validation errors and sets the CR status.
 		validationResource,
 
+		// cordonResource sets the cordon status and uncordons CRs when
+		// their cordon expires.
+		cordonResource,
+
 		// appFinalizerResource check CRs for legacy finalizers and removes them.
 		appFinalizerResource,
 
@@ -331,6 +475,22 @@ func newAppResources(config appResourcesConfig) ([]resource.Interface, error) {
 		statusResource,
 	}
 
+	if config.Sharder != nil {
+		c := sharding.Config{
+			Logger:  config.Logger,
+			Sharder: config.Sharder,
+		}
+
+		shardingResource, err := sharding.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		// shardingResource cancels the reconciliation of app CRs of other
+		// replicas so it must be first.
+		resources = append([]resource.Interface{shardingResource}, resources...)
+	}
+
 	{
 		c := retryresource.WrapConfig{
 			Logger: config.Logger,
@@ -0,0 +1,76 @@
+package wave
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"time"
+
+	applicationv1alpha1 "github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/microerror"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// EnsureCreated starts the next wave of the AppRollout CR once all app CRs of
+// the current wave are deployed and records the progress in its status.
+func (r *Resource) EnsureCreated(ctx context.Context, obj interface{}) error {
+	cr, err := toAppRollout(obj)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if cr.DeletionTimestamp != nil {
+		return nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(&cr.Spec.Selector)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	var apps applicationv1alpha1.AppList
+	err = r.k8sClient.CtrlClient().List(ctx, &apps, client.MatchingLabelsSelector{Selector: selector})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	sort.Slice(apps.Items, func(i, j int) bool {
+		return appName(apps.Items[i]) < appName(apps.Items[j])
+	})
+
+	status, batch := plan(cr, apps.Items, time.Now())
+
+	for _, app := range batch {
+		if app.Spec.Version == cr.Spec.Version {
+			continue
+		}
+
+		r.logger.Debugf(ctx, "setting version of app %#q in namespace %#q to %#q", app.Name, app.Namespace, cr.Spec.Version)
+
+		patched := app.DeepCopy()
+		patched.Spec.Version = cr.Spec.Version
+
+		err = r.k8sClient.CtrlClient().Patch(ctx, patched, client.MergeFrom(&app))
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		r.logger.Debugf(ctx, "set version of app %#q in namespace %#q to %#q", app.Name, app.Namespace, cr.Spec.Version)
+	}
+
+	if !reflect.DeepEqual(status, cr.Status) {
+		r.logger.Debugf(ctx, "setting status of rollout %#q: %s", cr.Name, status.Reason)
+
+		cr.Status = status
+
+		err = r.k8sClient.CtrlClient().Status().Update(ctx, &cr)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		r.logger.Debugf(ctx, "set status of rollout %#q", cr.Name)
+	}
+
+	return nil
+}
@@ -0,0 +1,9 @@
+package wave
+
+import "context"
+
+// EnsureDeleted is a no-op. Deleting an AppRollout CR stops the rollout and
+// keeps the versions of the app CRs already updated.
+func (r *Resource) EnsureDeleted(ctx context.Context, obj interface{}) error {
+	return nil
+}
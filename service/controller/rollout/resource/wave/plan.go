@@ -0,0 +1,152 @@
+package wave
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	applicationv1alpha1 "github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/giantswarm/app-operator/v7/pkg/apis/rollout/v1alpha1"
+	"github.com/giantswarm/app-operator/v7/pkg/status"
+)
+
+const (
+	defaultTimeout = 30 * time.Minute
+
+	deployedStatus = "deployed"
+	failedStatus   = "failed"
+)
+
+// plan returns the status of the rollout and the app CRs of the next wave
+// given the currently selected app CRs. apps must be sorted by namespace and
+// name so waves are stable across reconciliations.
+func plan(rollout v1alpha1.AppRollout, apps []applicationv1alpha1.App, now time.Time) (v1alpha1.AppRolloutStatus, []applicationv1alpha1.App) {
+	spec := rollout.Spec
+	next := *rollout.Status.DeepCopy()
+
+	if next.ObservedGeneration != rollout.Generation {
+		// The spec changed so the rollout starts over.
+		next = v1alpha1.AppRolloutStatus{
+			ObservedGeneration: rollout.Generation,
+		}
+	}
+	if next.Phase == v1alpha1.PhaseCompleted || next.Phase == v1alpha1.PhaseHalted {
+		return next, nil
+	}
+
+	timeout := defaultTimeout
+	if spec.Timeout != nil {
+		timeout = spec.Timeout.Duration
+	}
+	timedOut := next.WaveStarted != nil && now.Sub(next.WaveStarted.Time) > timeout
+
+	updated := map[string]bool{}
+	for _, name := range next.Updated {
+		updated[name] = true
+	}
+
+	var deployed, failed, waiting []string
+	var remaining []applicationv1alpha1.App
+	for _, app := range apps {
+		name := appName(app)
+
+		switch {
+		case !updated[name]:
+			remaining = append(remaining, app)
+		case isDeployed(app, spec.Version):
+			deployed = append(deployed, name)
+		case isFailed(app) || timedOut:
+			failed = append(failed, name)
+		default:
+			waiting = append(waiting, name)
+		}
+	}
+
+	next.Deployed = deployed
+	// Failures are kept so app CRs which failed in earlier waves still
+	// count towards MaxFailures.
+	next.Failed = union(next.Failed, failed)
+	next.Phase = v1alpha1.PhaseProgressing
+
+	if len(next.Failed) > spec.MaxFailures {
+		next.Phase = v1alpha1.PhaseHalted
+		next.Reason = fmt.Sprintf("%d app CRs failed which is more than the %d tolerated: %s", len(next.Failed), spec.MaxFailures, strings.Join(next.Failed, ", "))
+		return next, nil
+	}
+	if len(waiting) > 0 {
+		next.Reason = fmt.Sprintf("Waiting for %d app CRs of wave %d to be deployed", len(waiting), next.Wave)
+		return next, nil
+	}
+	if len(remaining) == 0 {
+		next.Phase = v1alpha1.PhaseCompleted
+		next.Reason = fmt.Sprintf("Version %#q rolled out to %d app CRs", spec.Version, len(deployed))
+		return next, nil
+	}
+
+	size := waveSize(spec.Waves, next.Wave, len(apps))
+	if size <= 0 || size > len(remaining) {
+		size = len(remaining)
+	}
+
+	batch := remaining[:size]
+	for _, app := range batch {
+		next.Updated = append(next.Updated, appName(app))
+	}
+	sort.Strings(next.Updated)
+
+	next.Wave++
+	next.WaveStarted = &metav1.Time{Time: now}
+	next.Reason = fmt.Sprintf("Started wave %d with %d app CRs", next.Wave, len(batch))
+
+	return next, batch
+}
+
+// waveSize returns the number of app CRs of the wave with the given index.
+// Waves beyond the configured ones cover all remaining app CRs and return 0.
+func waveSize(waves []v1alpha1.AppRolloutWave, index, total int) int {
+	if index >= len(waves) {
+		return 0
+	}
+
+	wave := waves[index]
+	if wave.Count > 0 {
+		return wave.Count
+	}
+
+	// Percentages are rounded up so every wave updates at least one app CR.
+	return (total*wave.Percentage + 99) / 100
+}
+
+func appName(app applicationv1alpha1.App) string {
+	return fmt.Sprintf("%s/%s", app.Namespace, app.Name)
+}
+
+// isDeployed returns whether the app CR is deployed with the version. The
+// version in the status has no v prefix.
+func isDeployed(app applicationv1alpha1.App, version string) bool {
+	return app.Status.Release.Status == deployedStatus && strings.TrimPrefix(app.Status.Version, "v") == strings.TrimPrefix(version, "v")
+}
+
+func isFailed(app applicationv1alpha1.App) bool {
+	s := app.Status.Release.Status
+
+	return s == failedStatus || s == status.RolledBackStatus || status.FailedStatus[s]
+}
+
+func union(a, b []string) []string {
+	set := map[string]bool{}
+	for _, s := range append(a, b...) {
+		set[s] = true
+	}
+
+	var result []string
+	for s := range set {
+		result = append(result, s)
+	}
+	sort.Strings(result)
+
+	return result
+}
@@ -0,0 +1,261 @@
+package wave
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	applicationv1alpha1 "github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/giantswarm/app-operator/v7/pkg/apis/rollout/v1alpha1"
+)
+
+func Test_plan(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	started := metav1.NewTime(now.Add(-10 * time.Minute))
+	nowTime := metav1.NewTime(now)
+
+	newApp := func(namespace, version, releaseStatus, statusVersion string) applicationv1alpha1.App {
+		return applicationv1alpha1.App{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "prometheus",
+				Namespace: namespace,
+			},
+			Spec: applicationv1alpha1.AppSpec{
+				Version: version,
+			},
+			Status: applicationv1alpha1.AppStatus{
+				Release: applicationv1alpha1.AppStatusRelease{
+					Status: releaseStatus,
+				},
+				Version: statusVersion,
+			},
+		}
+	}
+
+	spec := v1alpha1.AppRolloutSpec{
+		Version: "1.1.0",
+		Waves: []v1alpha1.AppRolloutWave{
+			{
+				Count: 1,
+			},
+			{
+				Percentage: 50,
+			},
+		},
+	}
+
+	tests := []struct {
+		name           string
+		status         v1alpha1.AppRolloutStatus
+		apps           []applicationv1alpha1.App
+		expectedStatus v1alpha1.AppRolloutStatus
+		expectedBatch  []string
+	}{
+		{
+			name: "case 0: first wave is started",
+			apps: []applicationv1alpha1.App{
+				newApp("org-a", "1.0.0", "deployed", "1.0.0"),
+				newApp("org-b", "1.0.0", "deployed", "1.0.0"),
+				newApp("org-c", "1.0.0", "deployed", "1.0.0"),
+				newApp("org-d", "1.0.0", "deployed", "1.0.0"),
+			},
+			expectedStatus: v1alpha1.AppRolloutStatus{
+				ObservedGeneration: 1,
+				Phase:              v1alpha1.PhaseProgressing,
+				Reason:             "Started wave 1 with 1 app CRs",
+				Wave:               1,
+				WaveStarted:        &nowTime,
+				Updated:            []string{"org-a/prometheus"},
+			},
+			expectedBatch: []string{"org-a/prometheus"},
+		},
+		{
+			name: "case 1: rollout waits for the current wave",
+			status: v1alpha1.AppRolloutStatus{
+				ObservedGeneration: 1,
+				Wave:               1,
+				WaveStarted:        &started,
+				Updated:            []string{"org-a/prometheus"},
+			},
+			apps: []applicationv1alpha1.App{
+				newApp("org-a", "1.1.0", "pending-upgrade", "1.0.0"),
+				newApp("org-b", "1.0.0", "deployed", "1.0.0"),
+			},
+			expectedStatus: v1alpha1.AppRolloutStatus{
+				ObservedGeneration: 1,
+				Phase:              v1alpha1.PhaseProgressing,
+				Reason:             "Waiting for 1 app CRs of wave 1 to be deployed",
+				Wave:               1,
+				WaveStarted:        &started,
+				Updated:            []string{"org-a/prometheus"},
+			},
+		},
+		{
+			name: "case 2: percentage wave is started when the current wave is deployed",
+			status: v1alpha1.AppRolloutStatus{
+				ObservedGeneration: 1,
+				Wave:               1,
+				WaveStarted:        &started,
+				Updated:            []string{"org-a/prometheus"},
+			},
+			apps: []applicationv1alpha1.App{
+				newApp("org-a", "1.1.0", "deployed", "1.1.0"),
+				newApp("org-b", "1.0.0", "deployed", "1.0.0"),
+				newApp("org-c", "1.0.0", "deployed", "1.0.0"),
+				newApp("org-d", "1.0.0", "deployed", "1.0.0"),
+			},
+			expectedStatus: v1alpha1.AppRolloutStatus{
+				ObservedGeneration: 1,
+				Phase:              v1alpha1.PhaseProgressing,
+				Reason:             "Started wave 2 with 2 app CRs",
+				Wave:               2,
+				WaveStarted:        &nowTime,
+				Updated:            []string{"org-a/prometheus", "org-b/prometheus", "org-c/prometheus"},
+				Deployed:           []string{"org-a/prometheus"},
+			},
+			expectedBatch: []string{"org-b/prometheus", "org-c/prometheus"},
+		},
+		{
+			name: "case 3: final wave covers the remaining app CRs",
+			status: v1alpha1.AppRolloutStatus{
+				ObservedGeneration: 1,
+				Wave:               2,
+				WaveStarted:        &started,
+				Updated:            []string{"org-a/prometheus", "org-b/prometheus"},
+			},
+			apps: []applicationv1alpha1.App{
+				newApp("org-a", "1.1.0", "deployed", "1.1.0"),
+				newApp("org-b", "1.1.0", "deployed", "1.1.0"),
+				newApp("org-c", "1.0.0", "deployed", "1.0.0"),
+				newApp("org-d", "1.0.0", "deployed", "1.0.0"),
+			},
+			expectedStatus: v1alpha1.AppRolloutStatus{
+				ObservedGeneration: 1,
+				Phase:              v1alpha1.PhaseProgressing,
+				Reason:             "Started wave 3 with 2 app CRs",
+				Wave:               3,
+				WaveStarted:        &nowTime,
+				Updated:            []string{"org-a/prometheus", "org-b/prometheus", "org-c/prometheus", "org-d/prometheus"},
+				Deployed:           []string{"org-a/prometheus", "org-b/prometheus"},
+			},
+			expectedBatch: []string{"org-c/prometheus", "org-d/prometheus"},
+		},
+		{
+			name: "case 4: rollout is completed",
+			status: v1alpha1.AppRolloutStatus{
+				ObservedGeneration: 1,
+				Wave:               1,
+				WaveStarted:        &started,
+				Updated:            []string{"org-a/prometheus"},
+			},
+			apps: []applicationv1alpha1.App{
+				newApp("org-a", "1.1.0", "deployed", "1.1.0"),
+			},
+			expectedStatus: v1alpha1.AppRolloutStatus{
+				ObservedGeneration: 1,
+				Phase:              v1alpha1.PhaseCompleted,
+				Reason:             "Version `1.1.0` rolled out to 1 app CRs",
+				Wave:               1,
+				WaveStarted:        &started,
+				Updated:            []string{"org-a/prometheus"},
+				Deployed:           []string{"org-a/prometheus"},
+			},
+		},
+		{
+			name: "case 5: rollout is halted when an app CR fails",
+			status: v1alpha1.AppRolloutStatus{
+				ObservedGeneration: 1,
+				Wave:               1,
+				WaveStarted:        &started,
+				Updated:            []string{"org-a/prometheus"},
+			},
+			apps: []applicationv1alpha1.App{
+				newApp("org-a", "1.1.0", "failed", "1.0.0"),
+				newApp("org-b", "1.0.0", "deployed", "1.0.0"),
+			},
+			expectedStatus: v1alpha1.AppRolloutStatus{
+				ObservedGeneration: 1,
+				Phase:              v1alpha1.PhaseHalted,
+				Reason:             "1 app CRs failed which is more than the 0 tolerated: org-a/prometheus",
+				Wave:               1,
+				WaveStarted:        &started,
+				Updated:            []string{"org-a/prometheus"},
+				Failed:             []string{"org-a/prometheus"},
+			},
+		},
+		{
+			name: "case 6: app CRs exceeding the timeout fail",
+			status: v1alpha1.AppRolloutStatus{
+				ObservedGeneration: 1,
+				Wave:               1,
+				WaveStarted:        &metav1.Time{Time: now.Add(-time.Hour)},
+				Updated:            []string{"org-a/prometheus"},
+			},
+			apps: []applicationv1alpha1.App{
+				newApp("org-a", "1.1.0", "pending-upgrade", "1.0.0"),
+			},
+			expectedStatus: v1alpha1.AppRolloutStatus{
+				ObservedGeneration: 1,
+				Phase:              v1alpha1.PhaseHalted,
+				Reason:             "1 app CRs failed which is more than the 0 tolerated: org-a/prometheus",
+				Wave:               1,
+				WaveStarted:        &metav1.Time{Time: now.Add(-time.Hour)},
+				Updated:            []string{"org-a/prometheus"},
+				Failed:             []string{"org-a/prometheus"},
+			},
+		},
+		{
+			name: "case 7: halted rollout is restarted when the spec changes",
+			status: v1alpha1.AppRolloutStatus{
+				ObservedGeneration: 0,
+				Phase:              v1alpha1.PhaseHalted,
+				Wave:               1,
+				Updated:            []string{"org-a/prometheus"},
+				Failed:             []string{"org-a/prometheus"},
+			},
+			apps: []applicationv1alpha1.App{
+				newApp("org-a", "1.0.5", "failed", "1.0.5"),
+			},
+			expectedStatus: v1alpha1.AppRolloutStatus{
+				ObservedGeneration: 1,
+				Phase:              v1alpha1.PhaseProgressing,
+				Reason:             "Started wave 1 with 1 app CRs",
+				Wave:               1,
+				WaveStarted:        &nowTime,
+				Updated:            []string{"org-a/prometheus"},
+			},
+			expectedBatch: []string{"org-a/prometheus"},
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			rollout := v1alpha1.AppRollout{
+				ObjectMeta: metav1.ObjectMeta{
+					Generation: 1,
+				},
+				Spec:   spec,
+				Status: tc.status,
+			}
+
+			status, batch := plan(rollout, tc.apps, now)
+
+			if diff := cmp.Diff(tc.expectedStatus, status); diff != "" {
+				t.Fatalf("want matching status \n %s", diff)
+			}
+
+			var names []string
+			for _, app := range batch {
+				names = append(names, appName(app))
+			}
+			if diff := cmp.Diff(tc.expectedBatch, names); diff != "" {
+				t.Fatalf("want matching batch \n %s", diff)
+			}
+		})
+	}
+}
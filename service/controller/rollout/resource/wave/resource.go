@@ -0,0 +1,55 @@
+package wave
+
+import (
+	"github.com/giantswarm/k8sclient/v7/pkg/k8sclient"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+
+	"github.com/giantswarm/app-operator/v7/pkg/apis/rollout/v1alpha1"
+)
+
+const (
+	Name = "wave"
+)
+
+type Config struct {
+	K8sClient k8sclient.Interface
+	Logger    micrologger.Logger
+}
+
+// Resource rolls the version of AppRollout CRs out to the selected app CRs
+// wave by wave.
+type Resource struct {
+	k8sClient k8sclient.Interface
+	logger    micrologger.Logger
+}
+
+func New(config Config) (*Resource, error) {
+	if config.K8sClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.K8sClient must not be empty", config)
+	}
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+
+	r := &Resource{
+		k8sClient: config.K8sClient,
+		logger:    config.Logger,
+	}
+
+	return r, nil
+}
+
+func (r Resource) Name() string {
+	return Name
+}
+
+// toAppRollout converts the input into an AppRollout.
+func toAppRollout(v interface{}) (v1alpha1.AppRollout, error) {
+	p, ok := v.(*v1alpha1.AppRollout)
+	if !ok {
+		return v1alpha1.AppRollout{}, microerror.Maskf(wrongTypeError, "expected '%T', got '%T'", &v1alpha1.AppRollout{}, v)
+	}
+
+	return *p, nil
+}
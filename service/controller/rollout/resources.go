@@ -0,0 +1,67 @@
+package rollout
+
+import (
+	"github.com/giantswarm/k8sclient/v7/pkg/k8sclient"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	"github.com/giantswarm/operatorkit/v7/pkg/resource"
+	"github.com/giantswarm/operatorkit/v7/pkg/resource/wrapper/metricsresource"
+	"github.com/giantswarm/operatorkit/v7/pkg/resource/wrapper/retryresource"
+
+	"github.com/giantswarm/app-operator/v7/service/controller/rollout/resource/wave"
+)
+
+type rolloutResourcesConfig struct {
+	// Dependencies.
+	K8sClient k8sclient.Interface
+	Logger    micrologger.Logger
+}
+
+// newRolloutResources returns a configured AppRollout controller ResourceSet.
+func newRolloutResources(config rolloutResourcesConfig) ([]resource.Interface, error) {
+	var err error
+
+	// Dependencies.
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+
+	var waveResource resource.Interface
+	{
+		c := wave.Config{
+			K8sClient: config.K8sClient,
+			Logger:    config.Logger,
+		}
+
+		waveResource, err = wave.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	resources := []resource.Interface{
+		waveResource,
+	}
+
+	{
+		c := retryresource.WrapConfig{
+			Logger: config.Logger,
+		}
+
+		resources, err = retryresource.Wrap(resources, c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	{
+		c := metricsresource.WrapConfig{}
+
+		resources, err = metricsresource.Wrap(resources, c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	return resources, nil
+}
@@ -0,0 +1,86 @@
+package rollout
+
+import (
+	"time"
+
+	"github.com/giantswarm/k8sclient/v7/pkg/k8sclient"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	"github.com/giantswarm/operatorkit/v7/pkg/controller"
+	"github.com/giantswarm/operatorkit/v7/pkg/resource"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/app-operator/v7/pkg/apis/rollout/v1alpha1"
+	"github.com/giantswarm/app-operator/v7/pkg/project"
+)
+
+const rolloutControllerSuffix = "-rollout"
+
+type Config struct {
+	K8sClient k8sclient.Interface
+	Logger    micrologger.Logger
+
+	ResyncPeriod time.Duration
+}
+
+// Rollout reconciles AppRollout CRs. It is only booted by the unique
+// instance.
+type Rollout struct {
+	*controller.Controller
+}
+
+func NewRollout(config Config) (*Rollout, error) {
+	var err error
+
+	if config.K8sClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.K8sClient must not be empty", config)
+	}
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+
+	if config.ResyncPeriod == 0 {
+		return nil, microerror.Maskf(invalidConfigError, "%T.ResyncPeriod must not be empty", config)
+	}
+
+	var resources []resource.Interface
+	{
+		c := rolloutResourcesConfig{
+			K8sClient: config.K8sClient,
+			Logger:    config.Logger,
+		}
+
+		resources, err = newRolloutResources(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	var rolloutController *controller.Controller
+	{
+		c := controller.Config{
+			K8sClient: config.K8sClient,
+			Logger:    config.Logger,
+			Resources: resources,
+			NewRuntimeObjectFunc: func() client.Object {
+				return new(v1alpha1.AppRollout)
+			},
+
+			Name: project.Name() + rolloutControllerSuffix,
+			// App CRs do not trigger reconciliations of their rollouts so
+			// waves are checked every resync period.
+			ResyncPeriod: config.ResyncPeriod,
+		}
+
+		rolloutController, err = controller.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	r := &Rollout{
+		Controller: rolloutController,
+	}
+
+	return r, nil
+}
@@ -0,0 +1,50 @@
+package dryrun
+
+import (
+	"github.com/giantswarm/microerror"
+)
+
+var invalidConfigError = &microerror.Error{
+	Kind: "invalidConfigError",
+}
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+var invalidRequestError = &microerror.Error{
+	Kind: "invalidRequestError",
+}
+
+// IsInvalidRequest asserts invalidRequestError.
+func IsInvalidRequest(err error) bool {
+	return microerror.Cause(err) == invalidRequestError
+}
+
+var notFoundError = &microerror.Error{
+	Kind: "notFoundError",
+}
+
+// IsNotFound asserts notFoundError.
+func IsNotFound(err error) bool {
+	return microerror.Cause(err) == notFoundError
+}
+
+var forbiddenError = &microerror.Error{
+	Kind: "forbiddenError",
+}
+
+// IsForbidden asserts forbiddenError.
+func IsForbidden(err error) bool {
+	return microerror.Cause(err) == forbiddenError
+}
+
+var unauthenticatedError = &microerror.Error{
+	Kind: "unauthenticatedError",
+}
+
+// IsUnauthenticated asserts unauthenticatedError.
+func IsUnauthenticated(err error) bool {
+	return microerror.Cause(err) == unauthenticatedError
+}
@@ -0,0 +1,10 @@
+package dryrun
+
+// Request identifies the app CR to render the values for.
+type Request struct {
+	Name      string
+	Namespace string
+	// Token is the bearer token of the caller. The caller must be allowed
+	// to get the app CR and the sources of its values.
+	Token string
+}
@@ -0,0 +1,26 @@
+package dryrun
+
+import (
+	"github.com/giantswarm/app-operator/v7/pkg/valuesource"
+)
+
+// Response is the result of rendering the values of an app CR.
+type Response struct {
+	App       string `json:"app"`
+	Namespace string `json:"namespace"`
+	Catalog   string `json:"catalog"`
+
+	ConfigMap Values `json:"configMap"`
+	// Secret values are redacted so only their structure and provenance is
+	// shown.
+	Secret Values `json:"secret"`
+}
+
+// Values holds the merged values of one type together with the sources they
+// were merged from in merge order and the source of each value keyed by its
+// JSON pointer.
+type Values struct {
+	Values     map[string]interface{}        `json:"values"`
+	Sources    []valuesource.Source          `json:"sources"`
+	Provenance map[string]valuesource.Source `json:"provenance"`
+}
@@ -0,0 +1,398 @@
+// Package dryrun renders the values app-operator would generate for an app
+// CR without writing anything. It is used to debug the precedence of values
+// across catalog, app, user and extra config configmaps and secrets and
+// value references. Callers must be allowed to get the app CR and all
+// sources of its values.
+package dryrun
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/app/v8/pkg/key"
+	"github.com/giantswarm/k8sclient/v7/pkg/k8sclient"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/giantswarm/app-operator/v7/pkg/valueref"
+	"github.com/giantswarm/app-operator/v7/pkg/valuesource"
+	"github.com/giantswarm/app-operator/v7/pkg/valuestemplate"
+	"github.com/giantswarm/app-operator/v7/service/internal/clientcache"
+	"github.com/giantswarm/app-operator/v7/service/internal/secretstore"
+	"github.com/giantswarm/app-operator/v7/service/internal/valuesmerge"
+)
+
+// Config represents the configuration used to create a dry run service.
+type Config struct {
+	// ClientCache provides the clients of the workload clusters app CRs
+	// referencing the values of other app CRs are deployed to.
+	ClientCache *clientcache.Resource
+	K8sClient   k8sclient.Interface
+	Logger      micrologger.Logger
+	// SecretStore is optional. Secret store references fail to resolve when
+	// it is nil.
+	SecretStore valueref.SecretStore
+
+	ChartNamespace string
+	// Provider is exposed to values templates.
+	Provider string
+	// ValueRefNamespaces are the namespaces value references may point to
+	// besides the namespace of the app CR.
+	ValueRefNamespaces []string
+	// SecretStorePathPrefixes are the prefixes by provider the paths of
+	// secret store references must start with, followed by the namespace
+	// of the app CR.
+	SecretStorePathPrefixes map[string]string
+}
+
+// Service renders the merged values of app CRs.
+type Service struct {
+	clientCache *clientcache.Resource
+	k8sClient   k8sclient.Interface
+	logger      micrologger.Logger
+
+	valuesMerge *valuesmerge.Merger
+}
+
+// New creates a new configured dry run service.
+func New(config Config) (*Service, error) {
+	if config.ClientCache == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.ClientCache must not be empty", config)
+	}
+	if config.K8sClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.K8sClient must not be empty", config)
+	}
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+
+	if config.ChartNamespace == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.ChartNamespace must not be empty", config)
+	}
+	if config.Provider == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Provider must not be empty", config)
+	}
+
+	var err error
+
+	var valueSources *valuesource.Resolver
+	{
+		c := valuesource.Config{
+			K8sClient: config.K8sClient.K8sClient(),
+		}
+
+		valueSources, err = valuesource.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	var valueRefs *valueref.Resolver
+	{
+		c := valueref.Config{
+			K8sClient:   config.K8sClient,
+			SecretStore: config.SecretStore,
+
+			ChartNamespace: config.ChartNamespace,
+			Namespaces:     config.ValueRefNamespaces,
+
+			SecretStorePathPrefixes: config.SecretStorePathPrefixes,
+		}
+
+		valueRefs, err = valueref.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	var valuesMerge *valuesmerge.Merger
+	{
+		c := valuesmerge.Config{
+			ValueRefs:    valueRefs,
+			ValueSources: valueSources,
+
+			Provider: config.Provider,
+		}
+
+		valuesMerge, err = valuesmerge.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	s := &Service{
+		clientCache: config.ClientCache,
+		k8sClient:   config.K8sClient,
+		logger:      config.Logger,
+
+		valuesMerge: valuesMerge,
+	}
+
+	return s, nil
+}
+
+// Render merges the values of the app CR in the request with the same merge
+// path as the configmap and secret resources, including value references,
+// secret stores and templates, and annotates each value with its source.
+// Secret values are redacted. The caller must be allowed to get the app CR
+// and every configmap, secret, app CR and object the values are merged from.
+func (s *Service) Render(ctx context.Context, request Request) (*Response, error) {
+	if request.Name == "" || request.Namespace == "" {
+		return nil, microerror.Maskf(invalidRequestError, "name and namespace must not be empty")
+	}
+
+	user, err := s.authenticate(ctx, request.Token)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	err = s.authorize(ctx, user, appAttributes(request.Name, request.Namespace))
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var app v1alpha1.App
+	err = s.k8sClient.CtrlClient().Get(ctx, types.NamespacedName{Name: request.Name, Namespace: request.Namespace}, &app)
+	if apierrors.IsNotFound(err) {
+		return nil, microerror.Maskf(notFoundError, "app %#q in namespace %#q", request.Name, request.Namespace)
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	catalog, err := s.getCatalog(ctx, app)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	clusterClient, err := s.clusterClient(ctx, app)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	configMapValues, err := s.valuesMerge.Merge(ctx, app, catalog, clusterClient, valuesource.TypeConfigMap)
+	if err != nil {
+		return nil, mask(err)
+	}
+
+	secretValues, err := s.valuesMerge.Merge(ctx, app, catalog, clusterClient, valuesource.TypeSecret)
+	if err != nil {
+		return nil, mask(err)
+	}
+
+	// Values are only returned when the caller could read all their sources
+	// directly so the endpoint does not expose configmaps and secrets the
+	// caller has no access to.
+	for _, l := range append(configMapValues.Layers, secretValues.Layers...) {
+		attributes, err := s.sourceAttributes(l.Source)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		if attributes == nil {
+			continue
+		}
+
+		err = s.authorize(ctx, user, *attributes)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	response := &Response{
+		App:       app.Name,
+		Namespace: app.Namespace,
+		Catalog:   catalog.Name,
+
+		ConfigMap: newValues(configMapValues.Values, configMapValues.Layers),
+		Secret:    newValues(valuesource.Redact(secretValues.Values), secretValues.Layers),
+	}
+
+	return response, nil
+}
+
+// authenticate returns the user of the bearer token of the request using a
+// TokenReview.
+func (s *Service) authenticate(ctx context.Context, token string) (authenticationv1.UserInfo, error) {
+	if token == "" {
+		return authenticationv1.UserInfo{}, microerror.Maskf(unauthenticatedError, "bearer token must not be empty")
+	}
+
+	tokenReview := &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{
+			Token: token,
+		},
+	}
+	tokenReview, err := s.k8sClient.K8sClient().AuthenticationV1().TokenReviews().Create(ctx, tokenReview, metav1.CreateOptions{})
+	if err != nil {
+		return authenticationv1.UserInfo{}, microerror.Mask(err)
+	}
+	if !tokenReview.Status.Authenticated {
+		return authenticationv1.UserInfo{}, microerror.Maskf(unauthenticatedError, "bearer token is invalid")
+	}
+
+	return tokenReview.Status.User, nil
+}
+
+// authorize checks the user is allowed to get the resource using a
+// SubjectAccessReview.
+func (s *Service) authorize(ctx context.Context, user authenticationv1.UserInfo, attributes authorizationv1.ResourceAttributes) error {
+	extra := map[string]authorizationv1.ExtraValue{}
+	for k, v := range user.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+
+	accessReview := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: &attributes,
+			User:               user.Username,
+			Groups:             user.Groups,
+			Extra:              extra,
+			UID:                user.UID,
+		},
+	}
+	accessReview, err := s.k8sClient.K8sClient().AuthorizationV1().SubjectAccessReviews().Create(ctx, accessReview, metav1.CreateOptions{})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	if !accessReview.Status.Allowed {
+		resource := attributes.Resource
+		if attributes.Group != "" {
+			resource = fmt.Sprintf("%s.%s", attributes.Resource, attributes.Group)
+		}
+
+		return microerror.Maskf(forbiddenError, "user %#q must be allowed to get %s %#q in namespace %#q", user.Username, resource, attributes.Name, attributes.Namespace)
+	}
+
+	return nil
+}
+
+// sourceAttributes returns the resource the caller must be allowed to get
+// to see the values of the source. It is nil for secret store references
+// since they are not Kubernetes resources and their values are redacted.
+func (s *Service) sourceAttributes(source valuesource.Source) (*authorizationv1.ResourceAttributes, error) {
+	switch source.Kind {
+	case valuesource.KindSecretStore:
+		return nil, nil
+	case valuesource.KindAppRef:
+		attributes := appAttributes(source.Name, source.Namespace)
+		return &attributes, nil
+	case valuesource.KindObjectRef:
+		gv, err := schema.ParseGroupVersion(source.APIVersion)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		mapping, err := s.k8sClient.CtrlClient().RESTMapper().RESTMapping(gv.WithKind(source.ObjectKind).GroupKind(), gv.Version)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		attributes := &authorizationv1.ResourceAttributes{
+			Namespace: source.Namespace,
+			Verb:      "get",
+			Group:     mapping.Resource.Group,
+			Resource:  mapping.Resource.Resource,
+			Name:      source.Name,
+		}
+		return attributes, nil
+	}
+
+	resource := "configmaps"
+	if source.Type == valuesource.TypeSecret {
+		resource = "secrets"
+	}
+
+	attributes := &authorizationv1.ResourceAttributes{
+		Namespace: source.Namespace,
+		Verb:      "get",
+		Resource:  resource,
+		Name:      source.Name,
+	}
+
+	return attributes, nil
+}
+
+func appAttributes(name, namespace string) authorizationv1.ResourceAttributes {
+	return authorizationv1.ResourceAttributes{
+		Namespace: namespace,
+		Verb:      "get",
+		Group:     v1alpha1.SchemeGroupVersion.Group,
+		Resource:  "apps",
+		Name:      name,
+	}
+}
+
+// clusterClient returns the client of the cluster the app CR is deployed to
+// the same way the clients resource does.
+func (s *Service) clusterClient(ctx context.Context, app v1alpha1.App) (kubernetes.Interface, error) {
+	if key.InCluster(app) {
+		return s.k8sClient.K8sClient(), nil
+	}
+
+	clients, err := s.clientCache.GetClients(ctx, app)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return clients.K8sClient.K8sClient(), nil
+}
+
+// getCatalog looks up the catalog CR of the app CR the same way the catalog
+// resource does.
+func (s *Service) getCatalog(ctx context.Context, app v1alpha1.App) (v1alpha1.Catalog, error) {
+	namespaces := []string{metav1.NamespaceDefault, "giantswarm"}
+	if key.CatalogNamespace(app) != "" {
+		namespaces = []string{key.CatalogNamespace(app)}
+	}
+
+	for _, ns := range namespaces {
+		var catalog v1alpha1.Catalog
+		err := s.k8sClient.CtrlClient().Get(ctx, types.NamespacedName{Name: key.CatalogName(app), Namespace: ns}, &catalog)
+		if apierrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return v1alpha1.Catalog{}, microerror.Mask(err)
+		}
+
+		return catalog, nil
+	}
+
+	return v1alpha1.Catalog{}, microerror.Maskf(notFoundError, "catalog %#q", key.CatalogName(app))
+}
+
+// mask converts missing sources into not found errors and failing templates
+// and invalid references into invalid request errors so they are reported to
+// the caller as such.
+func mask(err error) error {
+	if valuesource.IsNotFound(err) || valueref.IsNotFound(err) || secretstore.IsNotFound(err) {
+		return microerror.Maskf(notFoundError, "%s", err.Error())
+	}
+	if valuestemplate.IsTemplate(err) || valueref.IsInvalidRef(err) {
+		return microerror.Maskf(invalidRequestError, "%s", err.Error())
+	}
+
+	return microerror.Mask(err)
+}
+
+func newValues(merged map[string]interface{}, layers []valuesource.Layer) Values {
+	sources := []valuesource.Source{}
+	for _, l := range layers {
+		sources = append(sources, l.Source)
+	}
+
+	if merged == nil {
+		merged = map[string]interface{}{}
+	}
+
+	return Values{
+		Values:     merged,
+		Sources:    sources,
+		Provenance: valuesource.Provenance(layers),
+	}
+}
@@ -0,0 +1,271 @@
+package dryrun
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/k8sclient/v7/pkg/k8sclient"
+	"github.com/giantswarm/k8sclient/v7/pkg/k8sclienttest"
+	"github.com/giantswarm/micrologger/microloggertest"
+	"github.com/google/go-cmp/cmp"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgofake "k8s.io/client-go/kubernetes/fake"
+	clientgotesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake" //nolint:staticcheck
+
+	"github.com/giantswarm/app-operator/v7/pkg/valueref"
+	"github.com/giantswarm/app-operator/v7/pkg/valuesource"
+	"github.com/giantswarm/app-operator/v7/service/internal/valuesmerge"
+)
+
+func Test_Service_authorize(t *testing.T) {
+	tests := []struct {
+		name          string
+		request       Request
+		authenticated bool
+		allowed       bool
+		errorMatcher  func(error) bool
+	}{
+		{
+			name:         "case 0: request without token is unauthenticated",
+			request:      Request{Name: "my-app", Namespace: "org-acme"},
+			errorMatcher: IsUnauthenticated,
+		},
+		{
+			name:         "case 1: invalid token is unauthenticated",
+			request:      Request{Name: "my-app", Namespace: "org-acme", Token: "invalid"},
+			errorMatcher: IsUnauthenticated,
+		},
+		{
+			name:          "case 2: user not allowed to get the app is forbidden",
+			request:       Request{Name: "my-app", Namespace: "org-acme", Token: "token"},
+			authenticated: true,
+			errorMatcher:  IsForbidden,
+		},
+		{
+			name:          "case 3: user allowed to get the app is authorized",
+			request:       Request{Name: "my-app", Namespace: "org-acme", Token: "token"},
+			authenticated: true,
+			allowed:       true,
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			var accessReview *authorizationv1.SubjectAccessReview
+
+			k8sClient := clientgofake.NewClientset()
+			k8sClient.PrependReactor("create", "tokenreviews", func(action clientgotesting.Action) (bool, runtime.Object, error) {
+				review := action.(clientgotesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+				review.Status.Authenticated = tc.authenticated
+				review.Status.User = authenticationv1.UserInfo{Username: "jane", Groups: []string{"org-acme-admins"}}
+				return true, review, nil
+			})
+			k8sClient.PrependReactor("create", "subjectaccessreviews", func(action clientgotesting.Action) (bool, runtime.Object, error) {
+				accessReview = action.(clientgotesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+				accessReview.Status.Allowed = tc.allowed
+				return true, accessReview, nil
+			})
+
+			s := &Service{
+				k8sClient: k8sclienttest.NewClients(k8sclienttest.ClientsConfig{
+					K8sClient: k8sClient,
+				}),
+			}
+
+			user, err := s.authenticate(context.Background(), tc.request.Token)
+			if err == nil {
+				err = s.authorize(context.Background(), user, appAttributes(tc.request.Name, tc.request.Namespace))
+			}
+			switch {
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case err != nil && !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if accessReview != nil {
+				attributes := accessReview.Spec.ResourceAttributes
+				if accessReview.Spec.User != "jane" || attributes.Verb != "get" || attributes.Resource != "apps" || attributes.Namespace != tc.request.Namespace || attributes.Name != tc.request.Name {
+					t.Fatalf("unexpected subject access review %#v", accessReview.Spec)
+				}
+			}
+		})
+	}
+}
+
+func Test_Service_Render(t *testing.T) {
+	tests := []struct {
+		name             string
+		forbidden        string
+		expectedValues   map[string]interface{}
+		expectedReviewed []string
+		errorMatcher     func(error) bool
+	}{
+		{
+			name: "case 0: values are rendered when all sources may be read",
+			expectedValues: map[string]interface{}{
+				"cluster":  "user",
+				"replicas": float64(1),
+			},
+			expectedReviewed: []string{"apps/my-app", "configmaps/cluster-values", "configmaps/user-values"},
+		},
+		{
+			name:             "case 1: values are not rendered when a source may not be read",
+			forbidden:        "user-values",
+			expectedReviewed: []string{"apps/my-app", "configmaps/cluster-values", "configmaps/user-values"},
+			errorMatcher:     IsForbidden,
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			app := &v1alpha1.App{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "my-app",
+					Namespace: "org-acme",
+				},
+				Spec: v1alpha1.AppSpec{
+					Catalog: "giantswarm",
+					Config: v1alpha1.AppSpecConfig{
+						ConfigMap: v1alpha1.AppSpecConfigConfigMap{
+							Name:      "cluster-values",
+							Namespace: "org-acme",
+						},
+					},
+					KubeConfig: v1alpha1.AppSpecKubeConfig{
+						InCluster: true,
+					},
+					UserConfig: v1alpha1.AppSpecUserConfig{
+						ConfigMap: v1alpha1.AppSpecUserConfigConfigMap{
+							Name:      "user-values",
+							Namespace: "org-acme",
+						},
+					},
+				},
+			}
+			catalog := &v1alpha1.Catalog{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "giantswarm",
+					Namespace: "default",
+				},
+			}
+
+			var reviewed []string
+
+			k8sClient := clientgofake.NewClientset(
+				newConfigMap("cluster-values", "org-acme", "cluster: cluster\nreplicas: 1\n"),
+				newConfigMap("user-values", "org-acme", "cluster: user\n"),
+			)
+			k8sClient.PrependReactor("create", "tokenreviews", func(action clientgotesting.Action) (bool, runtime.Object, error) {
+				review := action.(clientgotesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+				review.Status.Authenticated = true
+				review.Status.User = authenticationv1.UserInfo{Username: "jane"}
+				return true, review, nil
+			})
+			k8sClient.PrependReactor("create", "subjectaccessreviews", func(action clientgotesting.Action) (bool, runtime.Object, error) {
+				review := action.(clientgotesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+				attributes := review.Spec.ResourceAttributes
+				reviewed = append(reviewed, attributes.Resource+"/"+attributes.Name)
+				review.Status.Allowed = attributes.Name != tc.forbidden
+				return true, review, nil
+			})
+
+			scheme := runtime.NewScheme()
+			err := v1alpha1.AddToScheme(scheme)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			clients := k8sclienttest.NewClients(k8sclienttest.ClientsConfig{
+				CtrlClient: fake.NewClientBuilder().WithScheme(scheme).WithObjects(app, catalog).Build(),
+				K8sClient:  k8sClient,
+			})
+
+			s := newTestService(t, clients)
+
+			response, err := s.Render(context.Background(), Request{Name: "my-app", Namespace: "org-acme", Token: "token"})
+			switch {
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case err != nil && !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if diff := cmp.Diff(tc.expectedReviewed, reviewed); diff != "" {
+				t.Fatalf("want matching reviews \n %s", diff)
+			}
+			if tc.errorMatcher != nil {
+				return
+			}
+			if diff := cmp.Diff(tc.expectedValues, response.ConfigMap.Values); diff != "" {
+				t.Fatalf("want matching values \n %s", diff)
+			}
+		})
+	}
+}
+
+func newTestService(t *testing.T, clients k8sclient.Interface) *Service {
+	t.Helper()
+
+	valueRefs, err := valueref.New(valueref.Config{
+		K8sClient: clients,
+
+		ChartNamespace: "giantswarm",
+	})
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	valueSources, err := valuesource.New(valuesource.Config{
+		K8sClient: clients.K8sClient(),
+	})
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	valuesMerge, err := valuesmerge.New(valuesmerge.Config{
+		ValueRefs:    valueRefs,
+		ValueSources: valueSources,
+
+		Provider: "aws",
+	})
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	s := &Service{
+		k8sClient: clients,
+		logger:    microloggertest.New(),
+
+		valuesMerge: valuesMerge,
+	}
+
+	return s
+}
+
+func newConfigMap(name, namespace, values string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Data: map[string]string{
+			"values": values,
+		},
+	}
+}
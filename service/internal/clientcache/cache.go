@@ -1,25 +1,39 @@
 package clientcache
 
 import (
+	"container/list"
 	"context"
-	"fmt"
+	"sync"
 	"time"
 
 	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
 	"github.com/giantswarm/errors/tenant"
 	"github.com/giantswarm/helmclient/v4/pkg/helmclient"
 	"github.com/giantswarm/k8sclient/v7/pkg/k8sclient"
-	"github.com/giantswarm/kubeconfig/v4"
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
-	gocache "github.com/patrickmn/go-cache"
 	"github.com/spf13/afero"
 	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	"github.com/giantswarm/app-operator/v7/pkg/kubeconfigsource"
 )
 
 const (
+	// expiration is the maximum age of cached clients. Clients are replaced
+	// earlier when their kubeconfig changed.
 	expiration = 10 * time.Minute
+	// versionCheckInterval is the time after which the configuration cached
+	// clients were created from is checked for changes again. It is checked
+	// earlier when the workload cluster rejected a request as unauthorized.
+	versionCheckInterval = time.Minute
+)
+
+const (
+	evictionReasonExpired     = "expired"
+	evictionReasonRotated     = "rotated"
+	evictionReasonSize        = "size"
+	evictionReasonUnavailable = "unavailable"
 )
 
 type Config struct {
@@ -31,18 +45,63 @@ type Config struct {
 	// Settings.
 	HTTPClientTimeout time.Duration
 	DisableCache      bool
+	// CircuitBreakerCooldown is the time no clients are created for a
+	// workload cluster after its circuit breaker opened. One failure after
+	// the cooldown opens it again.
+	CircuitBreakerCooldown time.Duration
+	// FailureThreshold is the number of consecutive requests failing because
+	// the workload cluster API is not available after which its clients are
+	// evicted and its circuit breaker opens.
+	FailureThreshold int
+	// MaxSize is the maximum number of workload clusters clients are cached
+	// for. The least recently used clients are evicted when it is exceeded.
+	MaxSize int
+
+	// DefaultSource is the kubeconfig source of app CRs without the
+	// kubeconfigsource.Annotation. It defaults to kubeconfigsource.Secret.
+	DefaultSource string
+	// ExecArgs and ExecCommand configure the exec credential plugin of the
+	// exec source. The projected token is used as it is without command.
+	ExecArgs    []string
+	ExecCommand string
+	// KubeConfigFile is the kubeconfig file of the file source.
+	KubeConfigFile string
+	// TokenFile is the projected service account token of the exec source.
+	TokenFile string
+	// TrustedNamespaces are the namespaces whose app CRs may use the exec
+	// and file sources, selected with kubeconfigsource.Annotation or as the
+	// DefaultSource. These sources use the credentials of app-operator.
+	TrustedNamespaces []string
 }
 
 type Resource struct {
 	// Dependencies.
-	cache     *gocache.Cache
 	fs        afero.Fs
 	k8sClient k8sclient.Interface
 	logger    micrologger.Logger
 
+	// newClients creates the clients of the workload cluster. It is
+	// generateClients unless replaced in tests.
+	newClients func(ctx context.Context, t Target) (clients, error)
+
+	// mutex guards the cached clients and the circuit breakers.
+	mutex    sync.Mutex
+	entries  map[string]*list.Element
+	lru      *list.List
+	breakers map[string]*breaker
+
 	// Settings.
-	httpClientTimeout time.Duration
-	disableCache      bool
+	httpClientTimeout      time.Duration
+	disableCache           bool
+	circuitBreakerCooldown time.Duration
+	failureThreshold       int
+	maxSize                int
+	defaultSource          string
+	execArgs               []string
+	execCommand            string
+	kubeConfigFile         string
+	tokenFile              string
+	trustedNamespaces      []string
 }
 
 type clients struct {
@@ -50,6 +109,25 @@ type clients struct {
 	HelmClient helmclient.Interface
 }
 
+// entry holds the clients of a workload cluster and the version of the
+// configuration they were created from. The version is checked again once
+// the check interval passed or the entry was invalidated.
+type entry struct {
+	key         string
+	clients     clients
+	created     time.Time
+	checked     time.Time
+	invalidated bool
+	version     string
+}
+
+// breaker counts consecutive failures of requests to a workload cluster. It
+// is open while opened is set and the cooldown did not pass.
+type breaker struct {
+	failures int
+	opened   time.Time
+}
+
 // New creates a new configured clients resource.
 func New(config Config) (*Resource, error) {
 	if config.Fs == nil {
@@ -65,90 +143,319 @@ func New(config Config) (*Resource, error) {
 	if config.HTTPClientTimeout == 0 {
 		return nil, microerror.Maskf(invalidConfigError, "%T.HTTPClientTimeout must not be empty", config)
 	}
+	if config.CircuitBreakerCooldown <= 0 {
+		return nil, microerror.Maskf(invalidConfigError, "%T.CircuitBreakerCooldown must be greater than zero", config)
+	}
+	if config.FailureThreshold <= 0 {
+		return nil, microerror.Maskf(invalidConfigError, "%T.FailureThreshold must be greater than zero", config)
+	}
+	if config.MaxSize <= 0 {
+		return nil, microerror.Maskf(invalidConfigError, "%T.MaxSize must be greater than zero", config)
+	}
+	if config.DefaultSource != "" {
+		err := kubeconfigsource.Validate(config.DefaultSource)
+		if err != nil {
+			return nil, microerror.Maskf(invalidConfigError, "%T.DefaultSource: %s", config, err)
+		}
+	}
 
 	r := &Resource{
 		// Dependencies.
-		cache:     gocache.New(expiration, expiration/2),
 		fs:        config.Fs,
 		k8sClient: config.K8sClient,
 		logger:    config.Logger,
 
+		entries:  map[string]*list.Element{},
+		lru:      list.New(),
+		breakers: map[string]*breaker{},
+
 		// Settings
-		httpClientTimeout: config.HTTPClientTimeout,
-		disableCache:      config.DisableCache,
+		httpClientTimeout:      config.HTTPClientTimeout,
+		disableCache:           config.DisableCache,
+		circuitBreakerCooldown: config.CircuitBreakerCooldown,
+		failureThreshold:       config.FailureThreshold,
+		maxSize:                config.MaxSize,
+		defaultSource:          config.DefaultSource,
+		execArgs:               config.ExecArgs,
+		execCommand:            config.ExecCommand,
+		kubeConfigFile:         config.KubeConfigFile,
+		tokenFile:              config.TokenFile,
+		trustedNamespaces:      config.TrustedNamespaces,
 	}
+	r.newClients = r.generateClients
 
 	return r, nil
 }
 
-func (r *Resource) GetClients(ctx context.Context, kubeConfig *v1alpha1.AppSpecKubeConfig) (*clients, error) {
-	k := fmt.Sprintf("%s/%s", kubeConfig.Secret.Namespace, kubeConfig.Secret.Name)
+// GetClients returns the clients of the workload cluster of the app CR for
+// its kubeconfig source.
+func (r *Resource) GetClients(ctx context.Context, app v1alpha1.App) (*clients, error) {
+	t, err := r.Target(app)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
 
-	if !r.disableCache {
-		if v, ok := r.cache.Get(k); ok {
-			c, ok := v.(clients)
-			if !ok {
-				return nil, microerror.Maskf(wrongTypeError, "expected '%T', got '%T'", clients{}, v)
-			}
+	c, err := r.GetClientsForTarget(ctx, t)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
 
-			return &c, nil
-		}
+	return c, nil
+}
+
+// GetClientsForTarget returns the clients of the workload cluster. Cached
+// clients are replaced when the configuration they were created from, e.g.
+// the resource version of the kubeconfig secret, changed. It is only checked
+// after versionCheckInterval or once the clients were invalidated. A
+// circuitOpenError is returned without creating clients while the circuit
+// breaker of the workload cluster is open.
+func (r *Resource) GetClientsForTarget(ctx context.Context, t Target) (*clients, error) {
+	k := t.key()
+
+	err := r.allow(k)
+	if err != nil {
+		return nil, microerror.Mask(err)
 	}
 
-	var c clients
-	{
-		k8sClient, err := r.generateK8sClient(ctx, kubeConfig)
+	if r.disableCache {
+		c, err := r.newClients(ctx, t)
 		if err != nil {
 			return nil, microerror.Mask(err)
 		}
 
-		helmClient, err := r.generateHelmClient(k8sClient)
-		if err != nil {
-			return nil, microerror.Mask(err)
-		}
+		return &c, nil
+	}
 
-		c = clients{
-			K8sClient:  k8sClient,
-			HelmClient: helmClient,
-		}
+	if c, ok := r.getChecked(k); ok {
+		return &c, nil
+	}
+
+	version, err := r.version(ctx, t)
+	if err != nil {
+		return nil, microerror.Mask(err)
 	}
 
-	r.cache.SetDefault(k, c)
+	if c, ok := r.get(k, version); ok {
+		return &c, nil
+	}
+
+	c, err := r.newClients(ctx, t)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	r.add(k, c, version)
 
 	return &c, nil
 }
 
-func (r *Resource) generateK8sClient(ctx context.Context, config *v1alpha1.AppSpecKubeConfig) (k8sclient.Interface, error) {
-	var err error
+// allow returns a circuitOpenError while the circuit breaker of the workload
+// cluster is open. After the cooldown the breaker is half open so clients
+// are created again and the next failure opens it again.
+func (r *Resource) allow(key string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
 
-	var kubeConfig kubeconfig.Interface
-	{
-		c := kubeconfig.Config{
-			K8sClient: r.k8sClient.K8sClient(),
-			Logger:    r.logger,
-		}
+	b, ok := r.breakers[key]
+	if !ok || b.opened.IsZero() {
+		return nil
+	}
 
-		kubeConfig, err = kubeconfig.New(c)
-		if err != nil {
-			return nil, microerror.Mask(err)
-		}
+	if time.Since(b.opened) < r.circuitBreakerCooldown {
+		return microerror.Maskf(circuitOpenError, "workload cluster of kubeconfig %#q is not available", key)
 	}
 
-	var restConfig *rest.Config
-	{
-		restConfig, err = kubeConfig.NewRESTConfigForApp(ctx, config.Secret.Name, config.Secret.Namespace)
-		if kubeconfig.IsNotFoundError(err) {
-			return nil, microerror.Mask(err)
-		} else if err != nil {
-			return nil, microerror.Mask(err)
-		}
+	b.opened = time.Time{}
+	b.failures = r.failureThreshold - 1
+	openCircuitBreakersGauge.Dec()
+
+	return nil
+}
+
+// getChecked returns the cached clients while their version needs no check.
+func (r *Resource) getChecked(key string) (clients, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	el, ok := r.entries[key]
+	if !ok {
+		return clients{}, false
+	}
+
+	e := el.Value.(*entry)
+	if e.invalidated || time.Since(e.checked) > versionCheckInterval || time.Since(e.created) > expiration {
+		return clients{}, false
+	}
+
+	r.lru.MoveToFront(el)
+
+	return e.clients, true
+}
+
+// get returns the cached clients unless they expired or were created from
+// another version of their configuration.
+func (r *Resource) get(key, version string) (clients, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	el, ok := r.entries[key]
+	if !ok {
+		return clients{}, false
+	}
+
+	e := el.Value.(*entry)
+	if time.Since(e.created) > expiration {
+		r.evict(key, evictionReasonExpired)
+		return clients{}, false
+	}
+	if e.version != version {
+		r.logger.Debugf(context.Background(), "kubeconfig of %#q changed, replacing its clients", key)
+		r.evict(key, evictionReasonRotated)
+		return clients{}, false
+	}
+
+	e.checked = time.Now()
+	e.invalidated = false
+	r.lru.MoveToFront(el)
+
+	return e.clients, true
+}
+
+// add caches the clients and evicts the least recently used clients when
+// the cache is full.
+func (r *Resource) add(key string, c clients, version string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.entries[key]; ok {
+		r.evict(key, evictionReasonRotated)
+	}
+
+	now := time.Now()
+	r.entries[key] = r.lru.PushFront(&entry{
+		key:     key,
+		clients: c,
+		created: now,
+		checked: now,
+		version: version,
+	})
+
+	for r.lru.Len() > r.maxSize {
+		oldest := r.lru.Back().Value.(*entry)
+		r.evict(oldest.key, evictionReasonSize)
+	}
+
+	clientsGauge.Set(float64(r.lru.Len()))
+}
+
+// evict removes the cached clients. The mutex must be held.
+func (r *Resource) evict(key, reason string) {
+	el, ok := r.entries[key]
+	if !ok {
+		return
+	}
+
+	r.lru.Remove(el)
+	delete(r.entries, key)
+
+	evictionsCounter.WithLabelValues(reason).Inc()
+	clientsGauge.Set(float64(r.lru.Len()))
+}
+
+// invalidate makes the version of the cached clients be checked with their
+// next use, e.g. since their credentials were rejected.
+func (r *Resource) invalidate(key string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	el, ok := r.entries[key]
+	if !ok {
+		return
 	}
 
+	el.Value.(*entry).invalidated = true
+}
+
+// recordFailure counts a request which failed because the workload cluster
+// API is not available. The clients are evicted and the circuit breaker
+// opens once the failure threshold is reached.
+func (r *Resource) recordFailure(key string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	b, ok := r.breakers[key]
+	if !ok {
+		b = &breaker{}
+		r.breakers[key] = b
+	}
+	if !b.opened.IsZero() {
+		return
+	}
+
+	b.failures++
+	if b.failures < r.failureThreshold {
+		return
+	}
+
+	b.opened = time.Now()
+	openCircuitBreakersGauge.Inc()
+	r.evict(key, evictionReasonUnavailable)
+
+	r.logger.Debugf(context.Background(), "workload cluster of kubeconfig %#q is not available after %d failures, pausing for %s", key, b.failures, r.circuitBreakerCooldown)
+}
+
+// recordSuccess resets the failures of the workload cluster since its API
+// responded.
+func (r *Resource) recordSuccess(key string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	b, ok := r.breakers[key]
+	if !ok || !b.opened.IsZero() {
+		return
+	}
+
+	delete(r.breakers, key)
+}
+
+func (r *Resource) generateClients(ctx context.Context, t Target) (clients, error) {
+	k8sClient, err := r.generateK8sClient(ctx, t)
+	if tenant.IsAPINotAvailable(err) {
+		r.recordFailure(t.key())
+		return clients{}, microerror.Mask(err)
+	} else if err != nil {
+		return clients{}, microerror.Mask(err)
+	}
+
+	helmClient, err := r.generateHelmClient(k8sClient)
+	if err != nil {
+		return clients{}, microerror.Mask(err)
+	}
+
+	c := clients{
+		K8sClient:  k8sClient,
+		HelmClient: helmClient,
+	}
+
+	return c, nil
+}
+
+func (r *Resource) generateK8sClient(ctx context.Context, t Target) (k8sclient.Interface, error) {
+	restConfig, err := r.restConfig(ctx, t)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	// Requests of all clients of the workload cluster are observed so
+	// repeated failures open its circuit breaker.
+	restConfig = rest.CopyConfig(restConfig)
+	restConfig.Wrap(r.wrapTransport(t.key()))
+
 	var k8sClient k8sclient.Interface
 	{
 		c := k8sclient.ClientsConfig{
 			Logger:     r.logger,
-			RestConfig: rest.CopyConfig(restConfig),
+			RestConfig: restConfig,
 		}
 
 		k8sClient, err = k8sclient.NewClients(c)
@@ -0,0 +1,366 @@
+package clientcache
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/k8sclient/v7/pkg/k8sclienttest"
+	"github.com/giantswarm/micrologger/microloggertest"
+	"github.com/spf13/afero"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+type step struct {
+	// secret is the name of the kubeconfig secret GetClients is called
+	// with.
+	secret string
+	// rotate updates the secret before GetClients is called.
+	rotate bool
+	// unauthorized rejects a request of the cached clients as unauthorized
+	// before GetClients is called.
+	unauthorized bool
+	// due lets the version check interval of the cached clients pass
+	// before GetClients is called.
+	due bool
+}
+
+func Test_Resource_GetClients(t *testing.T) {
+	tests := []struct {
+		name            string
+		maxSize         int
+		steps           []step
+		expectedChecks  int
+		expectedCreated int
+	}{
+		{
+			name:    "case 0: cached clients are reused while the secret is unchanged",
+			maxSize: 10,
+			steps: []step{
+				{secret: "a"},
+				{secret: "a"},
+				{secret: "a"},
+			},
+			expectedChecks:  1,
+			expectedCreated: 1,
+		},
+		{
+			name:    "case 1: clients are replaced once the version check interval passed after the secret was rotated",
+			maxSize: 10,
+			steps: []step{
+				{secret: "a"},
+				{secret: "a", rotate: true},
+				{secret: "a", due: true},
+				{secret: "a"},
+			},
+			expectedChecks:  2,
+			expectedCreated: 2,
+		},
+		{
+			name:    "case 2: least recently used clients are evicted when the cache is full",
+			maxSize: 2,
+			steps: []step{
+				{secret: "a"},
+				{secret: "b"},
+				{secret: "a"},
+				{secret: "c"},
+				{secret: "a"},
+				{secret: "b"},
+			},
+			expectedChecks:  4,
+			expectedCreated: 4,
+		},
+		{
+			name:    "case 3: clients are replaced once a request was unauthorized after the secret was rotated",
+			maxSize: 10,
+			steps: []step{
+				{secret: "a"},
+				{secret: "a", rotate: true, unauthorized: true},
+				{secret: "a"},
+			},
+			expectedChecks:  2,
+			expectedCreated: 2,
+		},
+		{
+			name:    "case 4: cached clients are reused when the version check interval passed and the secret is unchanged",
+			maxSize: 10,
+			steps: []step{
+				{secret: "a"},
+				{secret: "a", due: true},
+				{secret: "a"},
+			},
+			expectedChecks:  2,
+			expectedCreated: 1,
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			// Only the metadata of the secrets is read to check the
+			// version of the cached clients.
+			var checks int
+			ctrlClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+				newSecret("a"),
+				newSecret("b"),
+				newSecret("c"),
+			).WithInterceptorFuncs(interceptor.Funcs{
+				Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+					if _, ok := obj.(*metav1.PartialObjectMetadata); ok {
+						checks++
+					}
+					return c.Get(ctx, key, obj, opts...)
+				},
+			}).Build()
+
+			r := newTestResource(t, ctrlClient, tc.maxSize)
+
+			var created int
+			r.newClients = func(ctx context.Context, t Target) (clients, error) {
+				created++
+				return clients{}, nil
+			}
+
+			ctx := context.Background()
+
+			for _, s := range tc.steps {
+				if s.rotate {
+					secret := &corev1.Secret{}
+					err := ctrlClient.Get(ctx, client.ObjectKey{Name: s.secret, Namespace: "org-acme"}, secret)
+					if err != nil {
+						t.Fatalf("error == %#v, want nil", err)
+					}
+
+					secret.Data = map[string][]byte{"value": []byte("rotated")}
+					err = ctrlClient.Update(ctx, secret)
+					if err != nil {
+						t.Fatalf("error == %#v, want nil", err)
+					}
+				}
+
+				key := "org-acme/" + s.secret
+				if s.unauthorized {
+					rt := r.wrapTransport(key)(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+						return &http.Response{StatusCode: http.StatusUnauthorized}, nil
+					}))
+
+					req, err := http.NewRequest(http.MethodGet, "https://api.abc12.example.com/api/v1/namespaces", nil)
+					if err != nil {
+						t.Fatalf("error == %#v, want nil", err)
+					}
+
+					_, _ = rt.RoundTrip(req)
+				}
+				if s.due {
+					r.entries[key].Value.(*entry).checked = time.Now().Add(-2 * versionCheckInterval)
+				}
+
+				_, err := r.GetClients(ctx, newApp(s.secret))
+				if err != nil {
+					t.Fatalf("error == %#v, want nil", err)
+				}
+			}
+
+			if checks != tc.expectedChecks {
+				t.Fatalf("checks == %d, want %d", checks, tc.expectedChecks)
+			}
+			if created != tc.expectedCreated {
+				t.Fatalf("created == %d, want %d", created, tc.expectedCreated)
+			}
+			if r.lru.Len() > tc.maxSize {
+				t.Fatalf("r.lru.Len() == %d, want at most %d", r.lru.Len(), tc.maxSize)
+			}
+		})
+	}
+}
+
+func Test_Resource_CircuitBreaker(t *testing.T) {
+	ctrlClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(newSecret("a")).Build()
+
+	r := newTestResource(t, ctrlClient, 10)
+	r.newClients = func(ctx context.Context, t Target) (clients, error) {
+		return clients{}, nil
+	}
+
+	ctx := context.Background()
+	key := "org-acme/a"
+
+	_, err := r.GetClients(ctx, newApp("a"))
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	// A response resets the failures so the breaker only opens after
+	// consecutive failures.
+	r.recordFailure(key)
+	r.recordFailure(key)
+	r.recordSuccess(key)
+	r.recordFailure(key)
+	r.recordFailure(key)
+
+	_, err = r.GetClients(ctx, newApp("a"))
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	r.recordFailure(key)
+
+	_, err = r.GetClients(ctx, newApp("a"))
+	if !IsCircuitOpen(err) {
+		t.Fatalf("error == %#v, want circuitOpenError", err)
+	}
+	if _, ok := r.entries[key]; ok {
+		t.Fatalf("clients of %#q were not evicted", key)
+	}
+
+	// After the cooldown clients are created again and one failure opens
+	// the breaker again.
+	r.breakers[key].opened = time.Now().Add(-2 * r.circuitBreakerCooldown)
+
+	_, err = r.GetClients(ctx, newApp("a"))
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	r.recordFailure(key)
+
+	_, err = r.GetClients(ctx, newApp("a"))
+	if !IsCircuitOpen(err) {
+		t.Fatalf("error == %#v, want circuitOpenError", err)
+	}
+}
+
+func Test_transport(t *testing.T) {
+	tests := []struct {
+		name             string
+		method           string
+		err              error
+		expectedFailures int
+	}{
+		{
+			name:             "case 0: unavailable API is counted as failure",
+			method:           http.MethodGet,
+			err:              errors.New("dial tcp 10.0.0.1:443: i/o timeout"),
+			expectedFailures: 2,
+		},
+		{
+			name:             "case 1: canceled request is not counted as failure",
+			method:           http.MethodGet,
+			err:              context.Canceled,
+			expectedFailures: 1,
+		},
+		{
+			name:             "case 2: response resets the failures",
+			method:           http.MethodGet,
+			expectedFailures: 0,
+		},
+		{
+			name:             "case 3: unavailable API is counted as failure for requests without method",
+			method:           "",
+			err:              errors.New("dial tcp 10.0.0.1:443: i/o timeout"),
+			expectedFailures: 2,
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			ctrlClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+			r := newTestResource(t, ctrlClient, 10)
+
+			// The API failed once before.
+			key := "org-acme/a"
+			r.breakers[key] = &breaker{failures: 1}
+
+			rt := r.wrapTransport(key)(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				if tc.err != nil {
+					return nil, tc.err
+				}
+
+				return &http.Response{StatusCode: http.StatusOK}, nil
+			}))
+
+			req, err := http.NewRequest(http.MethodGet, "https://api.abc12.example.com/api/v1/namespaces", nil)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+			req.Method = tc.method
+
+			_, _ = rt.RoundTrip(req)
+
+			var failures int
+			if b, ok := r.breakers[key]; ok {
+				failures = b.failures
+			}
+			if failures != tc.expectedFailures {
+				t.Fatalf("failures == %d, want %d", failures, tc.expectedFailures)
+			}
+		})
+	}
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func newTestResource(t *testing.T, ctrlClient client.Client, maxSize int) *Resource {
+	t.Helper()
+
+	r, err := New(Config{
+		Fs: afero.NewMemMapFs(),
+		K8sClient: k8sclienttest.NewClients(k8sclienttest.ClientsConfig{
+			CtrlClient: ctrlClient,
+		}),
+		Logger: microloggertest.New(),
+
+		HTTPClientTimeout:      time.Second,
+		CircuitBreakerCooldown: time.Minute,
+		FailureThreshold:       3,
+		MaxSize:                maxSize,
+	})
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	return r
+}
+
+// newApp returns an app CR using the kubeconfig secret.
+func newApp(secret string) v1alpha1.App {
+	return v1alpha1.App{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "nginx",
+			Namespace: "org-acme",
+		},
+		Spec: v1alpha1.AppSpec{
+			KubeConfig: v1alpha1.AppSpecKubeConfig{
+				Secret: v1alpha1.AppSpecKubeConfigSecret{
+					Name:      secret,
+					Namespace: "org-acme",
+				},
+			},
+		},
+	}
+}
+
+func newSecret(name string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "org-acme",
+		},
+	}
+}
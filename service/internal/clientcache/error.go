@@ -19,3 +19,21 @@ var wrongTypeError = &microerror.Error{
 func IsWrongType(err error) bool {
 	return microerror.Cause(err) == wrongTypeError
 }
+
+var circuitOpenError = &microerror.Error{
+	Kind: "circuitOpenError",
+}
+
+// IsCircuitOpen asserts circuitOpenError.
+func IsCircuitOpen(err error) bool {
+	return microerror.Cause(err) == circuitOpenError
+}
+
+var notFoundError = &microerror.Error{
+	Kind: "notFoundError",
+}
+
+// IsNotFound asserts notFoundError.
+func IsNotFound(err error) bool {
+	return microerror.Cause(err) == notFoundError
+}
@@ -0,0 +1,42 @@
+package clientcache
+
+import "github.com/prometheus/client_golang/prometheus"
+
+const (
+	PrometheusNamespace = "app_operator"
+	PrometheusSubsystem = "client_cache"
+)
+
+var (
+	clientsGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: PrometheusNamespace,
+			Subsystem: PrometheusSubsystem,
+			Name:      "clients",
+			Help:      "Workload clusters clients are currently cached for.",
+		},
+	)
+	evictionsCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: PrometheusNamespace,
+			Subsystem: PrometheusSubsystem,
+			Name:      "evictions_total",
+			Help:      "Number of times cached clients were evicted by reason.",
+		},
+		[]string{"reason"},
+	)
+	openCircuitBreakersGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: PrometheusNamespace,
+			Subsystem: PrometheusSubsystem,
+			Name:      "open_circuit_breakers",
+			Help:      "Workload clusters no clients are created for because their API is not available.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(clientsGauge)
+	prometheus.MustRegister(evictionsCounter)
+	prometheus.MustRegister(openCircuitBreakersGauge)
+}
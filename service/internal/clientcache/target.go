@@ -0,0 +1,296 @@
+package clientcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/app/v8/pkg/key"
+	"github.com/giantswarm/kubeconfig/v4"
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	capiv1beta1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/app-operator/v7/pkg/kubeconfigsource"
+)
+
+const (
+	// execAPIVersion is the API version of the exec credential plugins.
+	execAPIVersion = "client.authentication.k8s.io/v1"
+	// tokenFileEnvVar is set for exec credential plugins to the path of the
+	// projected service account token they exchange.
+	tokenFileEnvVar = "TOKEN_FILE"
+)
+
+// Target is the workload cluster clients are created for.
+type Target struct {
+	// Source is the kubeconfig source of the workload cluster. App CRs
+	// with the CAPI source target the kubeconfig secret of their cluster
+	// so Source is never kubeconfigsource.CAPI.
+	Source string
+	// Name and Namespace are the kubeconfig secret for the secret source,
+	// the Cluster API cluster for the exec source and the name of the
+	// context in the kubeconfig file for the file source.
+	Name      string
+	Namespace string
+}
+
+func (t Target) String() string {
+	switch t.Source {
+	case kubeconfigsource.Exec:
+		return fmt.Sprintf("cluster %s/%s", t.Namespace, t.Name)
+	case kubeconfigsource.File:
+		return fmt.Sprintf("kubeconfig file context %s", t.Name)
+	default:
+		return fmt.Sprintf("kubeconfig %s/%s", t.Namespace, t.Name)
+	}
+}
+
+// key is the key of the clients and the circuit breaker of the target.
+// Kubeconfig secrets are keyed by their namespace and name.
+func (t Target) key() string {
+	if t.Source == kubeconfigsource.Secret {
+		return fmt.Sprintf("%s/%s", t.Namespace, t.Name)
+	}
+
+	return fmt.Sprintf("%s:%s/%s", t.Source, t.Namespace, t.Name)
+}
+
+// Target returns the workload cluster of the app CR for its kubeconfig
+// source. Name is empty for the secret source when the app CR has no
+// kubeconfig secret.
+func (r *Resource) Target(app v1alpha1.App) (Target, error) {
+	source, err := kubeconfigsource.Source(app, r.defaultSource, r.trustedNamespaces)
+	if err != nil {
+		return Target{}, microerror.Mask(err)
+	}
+
+	if source == kubeconfigsource.Secret {
+		t := Target{
+			Source:    kubeconfigsource.Secret,
+			Name:      key.KubeConfigSecretName(app),
+			Namespace: key.KubeConfigSecretNamespace(app),
+		}
+
+		return t, nil
+	}
+
+	clusterName, err := kubeconfigsource.ClusterName(app)
+	if err != nil {
+		return Target{}, microerror.Mask(err)
+	}
+
+	var t Target
+	switch source {
+	case kubeconfigsource.CAPI:
+		t = Target{
+			Source:    kubeconfigsource.Secret,
+			Name:      kubeconfigsource.CAPISecretName(clusterName),
+			Namespace: app.Namespace,
+		}
+	case kubeconfigsource.Exec:
+		t = Target{
+			Source:    kubeconfigsource.Exec,
+			Name:      clusterName,
+			Namespace: app.Namespace,
+		}
+	case kubeconfigsource.File:
+		t = Target{
+			Source: kubeconfigsource.File,
+			Name:   clusterName,
+		}
+	}
+
+	return t, nil
+}
+
+// version changes when the configuration the clients of the target are
+// created from changed so they are replaced.
+func (r *Resource) version(ctx context.Context, t Target) (string, error) {
+	switch t.Source {
+	case kubeconfigsource.Exec:
+		clusterVersion, err := r.resourceVersion(ctx, &capiv1beta1.Cluster{}, t.Name, t.Namespace)
+		if err != nil {
+			return "", microerror.Mask(err)
+		}
+		caVersion, err := r.secretResourceVersion(ctx, kubeconfigsource.CAPICASecretName(t.Name), t.Namespace)
+		if err != nil {
+			return "", microerror.Mask(err)
+		}
+
+		return fmt.Sprintf("%s/%s", clusterVersion, caVersion), nil
+	case kubeconfigsource.File:
+		info, err := os.Stat(r.kubeConfigFile)
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", nil
+		} else if err != nil {
+			return "", microerror.Mask(err)
+		}
+
+		return info.ModTime().String(), nil
+	default:
+		v, err := r.secretResourceVersion(ctx, t.Name, t.Namespace)
+		if err != nil {
+			return "", microerror.Mask(err)
+		}
+
+		return v, nil
+	}
+}
+
+// secretResourceVersion returns the resource version of the secret. Only its
+// metadata is fetched. It is empty when the secret does not exist so
+// creating clients fails with the error of the source.
+func (r *Resource) secretResourceVersion(ctx context.Context, name, namespace string) (string, error) {
+	secret := &metav1.PartialObjectMetadata{}
+	secret.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Secret"))
+
+	v, err := r.resourceVersion(ctx, secret, name, namespace)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	return v, nil
+}
+
+// resourceVersion returns the resource version of the object. It is empty
+// when the object does not exist.
+func (r *Resource) resourceVersion(ctx context.Context, obj client.Object, name, namespace string) (string, error) {
+	err := r.k8sClient.CtrlClient().Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, obj)
+	if apierrors.IsNotFound(err) {
+		return "", nil
+	} else if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	return obj.GetResourceVersion(), nil
+}
+
+// restConfig returns the REST config of the target for its kubeconfig
+// source.
+func (r *Resource) restConfig(ctx context.Context, t Target) (*rest.Config, error) {
+	switch t.Source {
+	case kubeconfigsource.Exec:
+		restConfig, err := r.execRESTConfig(ctx, t)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return restConfig, nil
+	case kubeconfigsource.File:
+		restConfig, err := r.fileRESTConfig(t)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return restConfig, nil
+	default:
+		c := kubeconfig.Config{
+			K8sClient: r.k8sClient.K8sClient(),
+			Logger:    r.logger,
+		}
+
+		kubeConfig, err := kubeconfig.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		restConfig, err := kubeConfig.NewRESTConfigForApp(ctx, t.Name, t.Namespace)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return restConfig, nil
+	}
+}
+
+// execRESTConfig connects to the control plane endpoint of the Cluster API
+// cluster. Its certificate authority is trusted. Requests are authenticated
+// with the projected service account token or with the credentials an exec
+// plugin exchanges it for.
+func (r *Resource) execRESTConfig(ctx context.Context, t Target) (*rest.Config, error) {
+	if r.tokenFile == "" {
+		return nil, microerror.Maskf(invalidConfigError, "token file must be configured for kubeconfig source %#q", kubeconfigsource.Exec)
+	}
+
+	cluster := &capiv1beta1.Cluster{}
+	err := r.k8sClient.CtrlClient().Get(ctx, types.NamespacedName{Name: t.Name, Namespace: t.Namespace}, cluster)
+	if apierrors.IsNotFound(err) {
+		return nil, microerror.Maskf(notFoundError, "cluster %s/%s", t.Namespace, t.Name)
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	endpoint := cluster.Spec.ControlPlaneEndpoint
+	if endpoint.Host == "" {
+		return nil, microerror.Maskf(notFoundError, "control plane endpoint of cluster %s/%s", t.Namespace, t.Name)
+	}
+
+	ca := &corev1.Secret{}
+	err = r.k8sClient.CtrlClient().Get(ctx, types.NamespacedName{Name: kubeconfigsource.CAPICASecretName(t.Name), Namespace: t.Namespace}, ca)
+	if apierrors.IsNotFound(err) {
+		return nil, microerror.Maskf(notFoundError, "certificate authority secret of cluster %s/%s", t.Namespace, t.Name)
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	restConfig := &rest.Config{
+		Host: fmt.Sprintf("https://%s", endpoint.String()),
+		TLSClientConfig: rest.TLSClientConfig{
+			CAData: ca.Data[corev1.TLSCertKey],
+		},
+	}
+
+	if r.execCommand == "" {
+		restConfig.BearerTokenFile = r.tokenFile
+	} else {
+		restConfig.ExecProvider = &clientcmdapi.ExecConfig{
+			APIVersion: execAPIVersion,
+			Command:    r.execCommand,
+			Args:       r.execArgs,
+			Env: []clientcmdapi.ExecEnvVar{
+				{
+					Name:  tokenFileEnvVar,
+					Value: r.tokenFile,
+				},
+			},
+			InteractiveMode: clientcmdapi.NeverExecInteractiveMode,
+		}
+	}
+
+	return restConfig, nil
+}
+
+// fileRESTConfig uses the context named after the cluster in the local
+// kubeconfig file.
+func (r *Resource) fileRESTConfig(t Target) (*rest.Config, error) {
+	if r.kubeConfigFile == "" {
+		return nil, microerror.Maskf(invalidConfigError, "kubeconfig file must be configured for kubeconfig source %#q", kubeconfigsource.File)
+	}
+
+	rules := &clientcmd.ClientConfigLoadingRules{
+		ExplicitPath: r.kubeConfigFile,
+	}
+	overrides := &clientcmd.ConfigOverrides{
+		CurrentContext: t.Name,
+	}
+
+	restConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, microerror.Maskf(notFoundError, "kubeconfig file %#q", r.kubeConfigFile)
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return restConfig, nil
+}
@@ -0,0 +1,342 @@
+package clientcache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/k8smetadata/pkg/label"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	capiv1beta1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/giantswarm/app-operator/v7/pkg/kubeconfigsource"
+)
+
+func Test_Resource_Target(t *testing.T) {
+	tests := []struct {
+		name              string
+		app               v1alpha1.App
+		defaultSource     string
+		trustedNamespaces []string
+		expectedTarget    Target
+		errorMatcher      func(error) bool
+	}{
+		{
+			name: "case 0: kubeconfig secret of the app CR by default",
+			app:  newApp("abc01-kubeconfig"),
+			expectedTarget: Target{
+				Source:    kubeconfigsource.Secret,
+				Name:      "abc01-kubeconfig",
+				Namespace: "org-acme",
+			},
+		},
+		{
+			name:          "case 1: Cluster API kubeconfig secret of the cluster by flag",
+			app:           newClusterApp("abc01", ""),
+			defaultSource: kubeconfigsource.CAPI,
+			expectedTarget: Target{
+				Source:    kubeconfigsource.Secret,
+				Name:      "abc01-kubeconfig",
+				Namespace: "org-acme",
+			},
+		},
+		{
+			name:              "case 2: annotation overrides the flag in trusted namespaces",
+			app:               newClusterApp("abc01", kubeconfigsource.Exec),
+			defaultSource:     kubeconfigsource.CAPI,
+			trustedNamespaces: []string{"org-acme"},
+			expectedTarget: Target{
+				Source:    kubeconfigsource.Exec,
+				Name:      "abc01",
+				Namespace: "org-acme",
+			},
+		},
+		{
+			name:              "case 3: context of the kubeconfig file is the cluster",
+			app:               newClusterApp("abc01", kubeconfigsource.File),
+			trustedNamespaces: []string{"org-acme"},
+			expectedTarget: Target{
+				Source: kubeconfigsource.File,
+				Name:   "abc01",
+			},
+		},
+		{
+			name:         "case 4: cluster label is required",
+			app:          newClusterApp("", kubeconfigsource.CAPI),
+			errorMatcher: kubeconfigsource.IsMissingCluster,
+		},
+		{
+			name:         "case 5: unknown source",
+			app:          newClusterApp("abc01", "vault"),
+			errorMatcher: kubeconfigsource.IsUnknownSource,
+		},
+		{
+			name:              "case 6: exec source is not selected by annotation in untrusted namespaces",
+			app:               newClusterApp("abc01", kubeconfigsource.Exec),
+			trustedNamespaces: []string{"giantswarm"},
+			errorMatcher:      kubeconfigsource.IsUntrustedSource,
+		},
+		{
+			name:         "case 7: file source is not selected by annotation in untrusted namespaces",
+			app:          newClusterApp("abc01", kubeconfigsource.File),
+			errorMatcher: kubeconfigsource.IsUntrustedSource,
+		},
+		{
+			name:              "case 8: exec source is used in trusted namespaces by flag",
+			app:               newClusterApp("abc01", ""),
+			defaultSource:     kubeconfigsource.Exec,
+			trustedNamespaces: []string{"org-acme"},
+			expectedTarget: Target{
+				Source:    kubeconfigsource.Exec,
+				Name:      "abc01",
+				Namespace: "org-acme",
+			},
+		},
+		{
+			name:              "case 9: exec source is not used by flag in untrusted namespaces",
+			app:               newClusterApp("abc01", ""),
+			defaultSource:     kubeconfigsource.Exec,
+			trustedNamespaces: []string{"giantswarm"},
+			errorMatcher:      kubeconfigsource.IsUntrustedSource,
+		},
+		{
+			name:          "case 10: file source is not used by flag in untrusted namespaces",
+			app:           newClusterApp("abc01", ""),
+			defaultSource: kubeconfigsource.File,
+			errorMatcher:  kubeconfigsource.IsUntrustedSource,
+		},
+		{
+			name:          "case 11: Cluster API source by annotation overrides the exec source by flag in untrusted namespaces",
+			app:           newClusterApp("abc01", kubeconfigsource.CAPI),
+			defaultSource: kubeconfigsource.Exec,
+			expectedTarget: Target{
+				Source:    kubeconfigsource.Secret,
+				Name:      "abc01-kubeconfig",
+				Namespace: "org-acme",
+			},
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			r := newTestResource(t, fake.NewClientBuilder().Build(), 10)
+			r.defaultSource = tc.defaultSource
+			r.trustedNamespaces = tc.trustedNamespaces
+
+			target, err := r.Target(tc.app)
+			switch {
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case err != nil && !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if diff := cmp.Diff(tc.expectedTarget, target); diff != "" {
+				t.Fatalf("want matching target \n %s", diff)
+			}
+		})
+	}
+}
+
+func Test_Resource_execRESTConfig(t *testing.T) {
+	tests := []struct {
+		name                string
+		objs                []client.Object
+		execCommand         string
+		expectedHost        string
+		expectedTokenFile   string
+		expectedExecCommand string
+		errorMatcher        func(error) bool
+	}{
+		{
+			name: "case 0: projected token is used without exec command",
+			objs: []client.Object{
+				newCluster("abc01", "api.abc01.example.com"),
+				newCASecret("abc01"),
+			},
+			expectedHost:      "https://api.abc01.example.com:6443",
+			expectedTokenFile: "/var/run/secrets/workload-cluster/token",
+		},
+		{
+			name: "case 1: projected token is exchanged by the exec command",
+			objs: []client.Object{
+				newCluster("abc01", "api.abc01.example.com"),
+				newCASecret("abc01"),
+			},
+			execCommand:         "/usr/local/bin/token-exchange",
+			expectedHost:        "https://api.abc01.example.com:6443",
+			expectedExecCommand: "/usr/local/bin/token-exchange",
+		},
+		{
+			name: "case 2: control plane endpoint is not set yet",
+			objs: []client.Object{
+				newCluster("abc01", ""),
+				newCASecret("abc01"),
+			},
+			errorMatcher: IsNotFound,
+		},
+		{
+			name:         "case 3: cluster does not exist",
+			errorMatcher: IsNotFound,
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			s := runtime.NewScheme()
+			err := clientgoscheme.AddToScheme(s)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+			err = capiv1beta1.AddToScheme(s)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			r := newTestResource(t, fake.NewClientBuilder().WithScheme(s).WithObjects(tc.objs...).Build(), 10)
+			r.execCommand = tc.execCommand
+			r.tokenFile = "/var/run/secrets/workload-cluster/token"
+
+			restConfig, err := r.restConfig(context.Background(), Target{Source: kubeconfigsource.Exec, Name: "abc01", Namespace: "org-acme"})
+			switch {
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case err != nil && !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+			if err != nil {
+				return
+			}
+
+			if restConfig.Host != tc.expectedHost {
+				t.Fatalf("restConfig.Host == %#q, want %#q", restConfig.Host, tc.expectedHost)
+			}
+			if string(restConfig.CAData) != "ca" {
+				t.Fatalf("restConfig.CAData == %#q, want %#q", restConfig.CAData, "ca")
+			}
+			if restConfig.BearerTokenFile != tc.expectedTokenFile {
+				t.Fatalf("restConfig.BearerTokenFile == %#q, want %#q", restConfig.BearerTokenFile, tc.expectedTokenFile)
+			}
+
+			var execCommand string
+			if restConfig.ExecProvider != nil {
+				execCommand = restConfig.ExecProvider.Command
+			}
+			if execCommand != tc.expectedExecCommand {
+				t.Fatalf("exec command == %#q, want %#q", execCommand, tc.expectedExecCommand)
+			}
+		})
+	}
+}
+
+func Test_Resource_fileRESTConfig(t *testing.T) {
+	kubeConfigFile := filepath.Join(t.TempDir(), "kubeconfig")
+	err := os.WriteFile(kubeConfigFile, []byte(testKubeConfig), 0600)
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	r := newTestResource(t, fake.NewClientBuilder().Build(), 10)
+	r.kubeConfigFile = kubeConfigFile
+
+	restConfig, err := r.restConfig(context.Background(), Target{Source: kubeconfigsource.File, Name: "abc01"})
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+	if restConfig.Host != "https://api.abc01.example.com" {
+		t.Fatalf("restConfig.Host == %#q, want %#q", restConfig.Host, "https://api.abc01.example.com")
+	}
+
+	_, err = r.restConfig(context.Background(), Target{Source: kubeconfigsource.File, Name: "xyz02"})
+	if err == nil {
+		t.Fatalf("error == nil, want non-nil")
+	}
+
+	r.kubeConfigFile = filepath.Join(t.TempDir(), "missing")
+
+	_, err = r.restConfig(context.Background(), Target{Source: kubeconfigsource.File, Name: "abc01"})
+	if !IsNotFound(err) {
+		t.Fatalf("error == %#v, want notFoundError", err)
+	}
+}
+
+const testKubeConfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: abc01
+  cluster:
+    server: https://api.abc01.example.com
+- name: xyz02
+  cluster:
+    server: https://api.xyz02.example.com
+contexts:
+- name: abc01
+  context:
+    cluster: abc01
+    user: dev
+users:
+- name: dev
+  user:
+    token: dev
+`
+
+// newClusterApp returns an app CR of the cluster with the kubeconfig source
+// annotation. Both are omitted when empty.
+func newClusterApp(clusterName, source string) v1alpha1.App {
+	app := newApp("")
+	if clusterName != "" {
+		app.Labels = map[string]string{
+			label.Cluster: clusterName,
+		}
+	}
+	if source != "" {
+		app.Annotations = map[string]string{
+			kubeconfigsource.Annotation: source,
+		}
+	}
+
+	return app
+}
+
+func newCluster(name, host string) *capiv1beta1.Cluster {
+	cluster := &capiv1beta1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "org-acme",
+		},
+	}
+	if host != "" {
+		cluster.Spec.ControlPlaneEndpoint = capiv1beta1.APIEndpoint{
+			Host: host,
+			Port: 6443,
+		}
+	}
+
+	return cluster
+}
+
+func newCASecret(clusterName string) *corev1.Secret {
+	secret := newSecret(kubeconfigsource.CAPICASecretName(clusterName))
+	secret.Data = map[string][]byte{
+		corev1.TLSCertKey: []byte("ca"),
+	}
+
+	return secret
+}
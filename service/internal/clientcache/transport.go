@@ -0,0 +1,72 @@
+package clientcache
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/giantswarm/errors/tenant"
+)
+
+// transport reports the outcome of requests to a workload cluster to the
+// cache so its circuit breaker opens when its API is not available and its
+// kubeconfig is checked for changes when credentials are rejected.
+type transport struct {
+	key      string
+	resource *Resource
+	next     http.RoundTripper
+}
+
+// wrapTransport returns a function wrapping the transports of the clients of
+// the workload cluster of the kubeconfig secret.
+func (r *Resource) wrapTransport(key string) func(rt http.RoundTripper) http.RoundTripper {
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return &transport{
+			key:      key,
+			resource: r,
+			next:     rt,
+		}
+	}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.next.RoundTrip(req)
+	if err == nil {
+		// Any response means the API is available. Credentials are
+		// rejected when the kubeconfig was rotated so it is checked again.
+		t.resource.recordSuccess(t.key)
+		if res.StatusCode == http.StatusUnauthorized {
+			t.resource.invalidate(t.key)
+		}
+		return res, nil
+	}
+
+	// Canceled requests say nothing about the availability of the API.
+	if errors.Is(err, context.Canceled) {
+		return res, err
+	}
+
+	// Errors are matched the way the HTTP client returns them.
+	urlErr := &url.Error{
+		Op:  urlErrorOp(req.Method),
+		URL: req.URL.String(),
+		Err: err,
+	}
+	if tenant.IsAPINotAvailable(urlErr) {
+		t.resource.recordFailure(t.key)
+	}
+
+	return res, err
+}
+
+// urlErrorOp returns the operation of the url.Error the HTTP client returns
+// for the method. An empty method is a GET request.
+func urlErrorOp(method string) string {
+	if method == "" {
+		return "Get"
+	}
+
+	return method[:1] + strings.ToLower(method[1:])
+}
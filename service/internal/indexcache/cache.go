@@ -74,6 +74,12 @@ func (r *Resource) GetIndex(ctx context.Context, storageURL string) (*Index, err
 	}
 	defer func() { _ = resp.Body.Close() }()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, microerror.Maskf(notFoundError, "index %#q returned status code %d", indexURL, resp.StatusCode)
+	} else if resp.StatusCode != http.StatusOK {
+		return nil, microerror.Maskf(unexpectedStatusCodeError, "index %#q returned status code %d", indexURL, resp.StatusCode)
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, microerror.Mask(err)
@@ -11,6 +11,24 @@ func IsInvalidConfig(err error) bool {
 	return microerror.Cause(err) == invalidConfigError
 }
 
+var notFoundError = &microerror.Error{
+	Kind: "notFoundError",
+}
+
+// IsNotFound asserts notFoundError.
+func IsNotFound(err error) bool {
+	return microerror.Cause(err) == notFoundError
+}
+
+var unexpectedStatusCodeError = &microerror.Error{
+	Kind: "unexpectedStatusCodeError",
+}
+
+// IsUnexpectedStatusCode asserts unexpectedStatusCodeError.
+func IsUnexpectedStatusCode(err error) bool {
+	return microerror.Cause(err) == unexpectedStatusCodeError
+}
+
 var wrongTypeError = &microerror.Error{
 	Kind: "wrongTypeError",
 }
@@ -5,6 +5,7 @@ type Index struct {
 }
 
 type Entry struct {
-	Urls    []string `json:"urls"`
-	Version string   `json:"version"`
+	Annotations map[string]string `json:"annotations"`
+	Urls        []string          `json:"urls"`
+	Version     string            `json:"version"`
 }
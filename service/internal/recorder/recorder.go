@@ -43,6 +43,12 @@ func (r *K8sEventsRecorder) Emit(ctx context.Context, obj pkgruntime.Object, rea
 	r.Eventf(obj, corev1.EventTypeNormal, reason, upper(message), args...)
 }
 
+// Warn writes warning events for conditions users should act on before they
+// cause failures.
+func (r *K8sEventsRecorder) Warn(ctx context.Context, obj pkgruntime.Object, reason, message string, args ...interface{}) {
+	r.Eventf(obj, corev1.EventTypeWarning, reason, upper(message), args...)
+}
+
 // upper is a helper function to uppercase first letter of the event message
 func upper(in string) string {
 	out := []rune(in)
@@ -9,4 +9,7 @@ import (
 type Interface interface {
 	// Emit is used to create Kubernetes events.
 	Emit(ctx context.Context, obj pkgruntime.Object, reason, message string, args ...interface{})
+	// Warn is used to create Kubernetes warning events for conditions users
+	// should act on before they cause failures.
+	Warn(ctx context.Context, obj pkgruntime.Object, reason, message string, args ...interface{})
 }
@@ -0,0 +1,40 @@
+package secretstore
+
+import "github.com/giantswarm/microerror"
+
+var invalidConfigError = &microerror.Error{
+	Kind: "invalidConfigError",
+}
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+// NotFoundError is returned by providers when nothing is stored at a path.
+var NotFoundError = &microerror.Error{
+	Kind: "notFoundError",
+}
+
+// IsNotFound asserts NotFoundError.
+func IsNotFound(err error) bool {
+	return microerror.Cause(err) == NotFoundError
+}
+
+var unknownProviderError = &microerror.Error{
+	Kind: "unknownProviderError",
+}
+
+// IsUnknownProvider asserts unknownProviderError.
+func IsUnknownProvider(err error) bool {
+	return microerror.Cause(err) == unknownProviderError
+}
+
+var wrongTypeError = &microerror.Error{
+	Kind: "wrongTypeError",
+}
+
+// IsWrongType asserts wrongTypeError.
+func IsWrongType(err error) bool {
+	return microerror.Cause(err) == wrongTypeError
+}
@@ -0,0 +1,74 @@
+// Package file implements a secret store provider reading YAML files below a
+// root directory. It is meant for tests and local development where no
+// secret store is available, e.g. with the path org-acme/demo resolving to
+// the file <root>/org-acme/demo.yaml.
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+	"github.com/spf13/afero"
+	"sigs.k8s.io/yaml"
+
+	"github.com/giantswarm/app-operator/v7/service/internal/secretstore"
+)
+
+// Name is the name of the provider used in secret store value references.
+const Name = "file"
+
+type Config struct {
+	Fs   afero.Fs
+	Root string
+}
+
+type Provider struct {
+	fs   afero.Fs
+	root string
+}
+
+func New(config Config) (*Provider, error) {
+	if config.Fs == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Fs must not be empty", config)
+	}
+	if config.Root == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Root must not be empty", config)
+	}
+
+	p := &Provider{
+		fs:   config.Fs,
+		root: filepath.Clean(config.Root),
+	}
+
+	return p, nil
+}
+
+func (p *Provider) Get(ctx context.Context, path string) (map[string]interface{}, error) {
+	name := filepath.Join(p.root, filepath.FromSlash(path))
+	if filepath.Ext(name) == "" {
+		name += ".yaml"
+	}
+
+	// Paths are set in app CRs so they must not read files outside the root.
+	if !strings.HasPrefix(name, p.root+string(filepath.Separator)) {
+		return nil, microerror.Maskf(invalidPathError, "path %#q is outside of the root directory", path)
+	}
+
+	b, err := afero.ReadFile(p.fs, name)
+	if os.IsNotExist(err) {
+		return nil, microerror.Maskf(secretstore.NotFoundError, "file %#q does not exist", name)
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	values := map[string]interface{}{}
+	err = yaml.Unmarshal(b, &values)
+	if err != nil {
+		return nil, microerror.Maskf(invalidPathError, "file %#q does not contain a YAML map: %s", name, err)
+	}
+
+	return values, nil
+}
@@ -0,0 +1,20 @@
+package secretstore
+
+import "context"
+
+// Provider is a backend storing secret values, e.g. vault.
+type Provider interface {
+	// Get returns the values stored at the given path. It returns an error
+	// matched by IsNotFound when nothing is stored at the path.
+	Get(ctx context.Context, path string) (map[string]interface{}, error)
+}
+
+type Interface interface {
+	// Resolve returns the values stored at the path of the given provider
+	// and their version. Values are cached until they expire.
+	Resolve(ctx context.Context, provider, path string) (map[string]interface{}, string, error)
+	// Refresh reads the values stored at the path of the given provider
+	// bypassing the cache. It returns the version of the values and whether
+	// they changed since they were resolved last.
+	Refresh(ctx context.Context, provider, path string) (string, bool, error)
+}
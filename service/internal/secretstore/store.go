@@ -0,0 +1,157 @@
+// Package secretstore resolves values stored in external secret stores so
+// they do not have to be stored in secrets in the management cluster. Each
+// store is a Provider registered by name, e.g. vault, which is the provider
+// set in secret store value references.
+package secretstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	gocache "github.com/patrickmn/go-cache"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+type Config struct {
+	Logger    micrologger.Logger
+	Providers map[string]Provider
+
+	// TTL is how long resolved values are cached.
+	TTL time.Duration
+}
+
+type Store struct {
+	cache     *gocache.Cache
+	logger    micrologger.Logger
+	providers map[string]Provider
+	ttl       time.Duration
+
+	// versions holds the last version of every path. Unlike the cache it
+	// does not expire so changes are detected after values expired.
+	versions sync.Map
+}
+
+type entry struct {
+	values  map[string]interface{}
+	version string
+}
+
+func New(config Config) (*Store, error) {
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+	if len(config.Providers) == 0 {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Providers must not be empty", config)
+	}
+	for name, p := range config.Providers {
+		if p == nil {
+			return nil, microerror.Maskf(invalidConfigError, "%T.Providers[%#q] must not be empty", config, name)
+		}
+	}
+	if config.TTL <= 0 {
+		return nil, microerror.Maskf(invalidConfigError, "%T.TTL must be greater than zero", config)
+	}
+
+	s := &Store{
+		cache:     gocache.New(config.TTL, config.TTL/2),
+		logger:    config.Logger,
+		providers: config.Providers,
+		ttl:       config.TTL,
+	}
+
+	return s, nil
+}
+
+// Providers returns the names of the registered providers.
+func (s *Store) Providers() []string {
+	names := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func (s *Store) Resolve(ctx context.Context, provider, path string) (map[string]interface{}, string, error) {
+	if v, ok := s.cache.Get(cacheKey(provider, path)); ok {
+		e, ok := v.(entry)
+		if !ok {
+			return nil, "", microerror.Maskf(wrongTypeError, "expected '%T', got '%T'", entry{}, v)
+		}
+
+		// Values are copied since they are merged into the values of apps.
+		return runtime.DeepCopyJSON(e.values), e.version, nil
+	}
+
+	e, err := s.get(ctx, provider, path)
+	if err != nil {
+		return nil, "", microerror.Mask(err)
+	}
+
+	return runtime.DeepCopyJSON(e.values), e.version, nil
+}
+
+func (s *Store) Refresh(ctx context.Context, provider, path string) (string, bool, error) {
+	previous, ok := s.versions.Load(cacheKey(provider, path))
+
+	e, err := s.get(ctx, provider, path)
+	if err != nil {
+		return "", false, microerror.Mask(err)
+	}
+
+	return e.version, ok && previous != e.version, nil
+}
+
+func (s *Store) get(ctx context.Context, provider, path string) (entry, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return entry{}, microerror.Maskf(unknownProviderError, "secret store provider %#q is not configured, configured providers are %s", provider, strings.Join(s.Providers(), ", "))
+	}
+
+	s.logger.Debugf(ctx, "getting values at %#q from secret store %#q", path, provider)
+
+	values, err := p.Get(ctx, path)
+	if IsNotFound(err) {
+		return entry{}, microerror.Maskf(NotFoundError, "no values at %#q in secret store %#q", path, provider)
+	} else if err != nil {
+		return entry{}, microerror.Mask(err)
+	}
+
+	version, err := hash(values)
+	if err != nil {
+		return entry{}, microerror.Mask(err)
+	}
+
+	e := entry{
+		values:  values,
+		version: version,
+	}
+	s.cache.Set(cacheKey(provider, path), e, s.ttl)
+	s.versions.Store(cacheKey(provider, path), version)
+
+	return e, nil
+}
+
+func cacheKey(provider, path string) string {
+	return fmt.Sprintf("%s/%s", provider, path)
+}
+
+// hash returns the version of the values. Maps are marshaled with sorted
+// keys so equal values always have the same version.
+func hash(values map[string]interface{}) (string, error) {
+	b, err := json.Marshal(values)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256(b))[:16], nil
+}
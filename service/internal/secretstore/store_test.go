@@ -0,0 +1,114 @@
+package secretstore_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/afero"
+
+	"github.com/giantswarm/app-operator/v7/service/internal/secretstore"
+	"github.com/giantswarm/app-operator/v7/service/internal/secretstore/file"
+)
+
+func Test_Store(t *testing.T) {
+	ctx := context.Background()
+
+	fs := afero.NewMemMapFs()
+	err := afero.WriteFile(fs, "/secrets/org-acme/demo.yaml", []byte("password: secret\n"), 0600)
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+	err = afero.WriteFile(fs, "/outside.yaml", []byte("password: secret\n"), 0600)
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	p, err := file.New(file.Config{
+		Fs:   fs,
+		Root: "/secrets",
+	})
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	s, err := secretstore.New(secretstore.Config{
+		Logger: microloggertest.New(),
+		Providers: map[string]secretstore.Provider{
+			"file": p,
+		},
+		TTL: time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	values, version, err := s.Resolve(ctx, "file", "org-acme/demo")
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+	expectedValues := map[string]interface{}{
+		"password": "secret",
+	}
+	if !reflect.DeepEqual(values, expectedValues) {
+		t.Fatalf("want matching values \n %s", cmp.Diff(values, expectedValues))
+	}
+
+	// Values are cached so a changed file is only seen when refreshing.
+	err = afero.WriteFile(fs, "/secrets/org-acme/demo.yaml", []byte("password: rotated\n"), 0600)
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+	values, _, err = s.Resolve(ctx, "file", "org-acme/demo")
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+	if values["password"] != "secret" {
+		t.Fatalf("password == %#v, want cached value", values["password"])
+	}
+
+	refreshedVersion, changed, err := s.Refresh(ctx, "file", "org-acme/demo")
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+	if refreshedVersion == version {
+		t.Fatalf("version == %#q, want changed version", refreshedVersion)
+	}
+	if !changed {
+		t.Fatalf("changed == false, want true")
+	}
+	_, changed, err = s.Refresh(ctx, "file", "org-acme/demo")
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+	if changed {
+		t.Fatalf("changed == true, want false")
+	}
+
+	values, version, err = s.Resolve(ctx, "file", "org-acme/demo")
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+	if values["password"] != "rotated" {
+		t.Fatalf("password == %#v, want refreshed value", values["password"])
+	}
+	if version != refreshedVersion {
+		t.Fatalf("version == %#q, want %#q", version, refreshedVersion)
+	}
+
+	_, _, err = s.Resolve(ctx, "file", "org-acme/missing")
+	if !secretstore.IsNotFound(err) {
+		t.Fatalf("error == %#v, want not found", err)
+	}
+	_, _, err = s.Resolve(ctx, "file", "../outside")
+	if !file.IsInvalidPath(err) {
+		t.Fatalf("error == %#v, want invalid path", err)
+	}
+	_, _, err = s.Resolve(ctx, "vault", "org-acme/demo")
+	if !secretstore.IsUnknownProvider(err) {
+		t.Fatalf("error == %#v, want unknown provider", err)
+	}
+}
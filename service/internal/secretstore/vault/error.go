@@ -0,0 +1,21 @@
+package vault
+
+import "github.com/giantswarm/microerror"
+
+var invalidConfigError = &microerror.Error{
+	Kind: "invalidConfigError",
+}
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+var unexpectedStatusCodeError = &microerror.Error{
+	Kind: "unexpectedStatusCodeError",
+}
+
+// IsUnexpectedStatusCode asserts unexpectedStatusCodeError.
+func IsUnexpectedStatusCode(err error) bool {
+	return microerror.Cause(err) == unexpectedStatusCodeError
+}
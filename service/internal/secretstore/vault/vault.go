@@ -0,0 +1,116 @@
+// Package vault implements a secret store provider reading secrets from the
+// HTTP API of Vault. Both versions of the KV secrets engine are supported,
+// e.g. the path secret/data/org-acme/demo reads the secret org-acme/demo of
+// a KV version 2 engine mounted at secret.
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/spf13/afero"
+
+	"github.com/giantswarm/app-operator/v7/service/internal/secretstore"
+)
+
+// Name is the name of the provider used in secret store value references.
+const Name = "vault"
+
+type Config struct {
+	Fs afero.Fs
+
+	Address string
+	// TokenFile is read on every request so rotated tokens are used.
+	TokenFile         string
+	HTTPClientTimeout time.Duration
+}
+
+type Provider struct {
+	fs         afero.Fs
+	httpClient *http.Client
+
+	address   string
+	tokenFile string
+}
+
+func New(config Config) (*Provider, error) {
+	if config.Fs == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Fs must not be empty", config)
+	}
+	if config.Address == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Address must not be empty", config)
+	}
+	if config.TokenFile == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.TokenFile must not be empty", config)
+	}
+	if config.HTTPClientTimeout == 0 {
+		return nil, microerror.Maskf(invalidConfigError, "%T.HTTPClientTimeout must not be empty", config)
+	}
+
+	p := &Provider{
+		fs: config.Fs,
+		httpClient: &http.Client{
+			Timeout: config.HTTPClientTimeout,
+		},
+
+		address:   strings.TrimRight(config.Address, "/"),
+		tokenFile: config.TokenFile,
+	}
+
+	return p, nil
+}
+
+type response struct {
+	Data map[string]interface{} `json:"data"`
+}
+
+func (p *Provider) Get(ctx context.Context, path string) (map[string]interface{}, error) {
+	token, err := afero.ReadFile(p.fs, p.tokenFile)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	url := fmt.Sprintf("%s/v1/%s", p.address, strings.TrimLeft(path, "/"))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	req.Header.Set("X-Vault-Token", strings.TrimSpace(string(token)))
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, microerror.Maskf(secretstore.NotFoundError, "vault returned status code %d for %#q", resp.StatusCode, path)
+	} else if resp.StatusCode != http.StatusOK {
+		return nil, microerror.Maskf(unexpectedStatusCodeError, "vault returned status code %d for %#q", resp.StatusCode, path)
+	}
+
+	var r response
+	err = json.NewDecoder(resp.Body).Decode(&r)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	// Secrets of the KV version 2 engine are nested in data.data next to
+	// their metadata.
+	if data, ok := r.Data["data"].(map[string]interface{}); ok {
+		if _, ok := r.Data["metadata"]; ok {
+			return data, nil
+		}
+	}
+	if r.Data == nil {
+		return map[string]interface{}{}, nil
+	}
+
+	return r.Data, nil
+}
@@ -0,0 +1,32 @@
+package shard
+
+import "github.com/prometheus/client_golang/prometheus"
+
+const (
+	PrometheusNamespace = "app_operator"
+	PrometheusSubsystem = "shard"
+)
+
+var (
+	membersGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: PrometheusNamespace,
+			Subsystem: PrometheusSubsystem,
+			Name:      "members",
+			Help:      "Replicas app CRs are currently sharded across.",
+		},
+	)
+	rebalanceCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: PrometheusNamespace,
+			Subsystem: PrometheusSubsystem,
+			Name:      "rebalances_total",
+			Help:      "Number of times app CRs were rebalanced because replicas joined or left.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(membersGauge)
+	prometheus.MustRegister(rebalanceCounter)
+}
@@ -0,0 +1,61 @@
+package shard
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+	"strconv"
+)
+
+// virtualNodes is the number of points of each member on the ring. More
+// points spread the keys more evenly across members.
+const virtualNodes = 128
+
+// ring is a consistent hash ring. A key belongs to the first member point
+// following the hash of the key. When a member joins or leaves only the keys
+// of the points next to its own points move.
+type ring struct {
+	points  []uint64
+	members map[uint64]string
+}
+
+func newRing(members []string) *ring {
+	r := &ring{
+		members: map[uint64]string{},
+	}
+
+	for _, m := range members {
+		for i := 0; i < virtualNodes; i++ {
+			p := hash(m + "#" + strconv.Itoa(i))
+			r.points = append(r.points, p)
+			r.members[p] = m
+		}
+	}
+
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+
+	return r
+}
+
+// member returns the member the key belongs to. It is empty when the ring
+// has no members.
+func (r *ring) member(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+
+	h := hash(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+
+	return r.members[r.points[i]]
+}
+
+// hash uses sha256 rather than fnv since the names of replicas and the
+// keys only differ in a few characters which fnv does not spread evenly.
+func hash(s string) uint64 {
+	sum := sha256.Sum256([]byte(s))
+	return binary.BigEndian.Uint64(sum[:8])
+}
@@ -0,0 +1,55 @@
+package shard
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+func Test_ring(t *testing.T) {
+	tests := []struct {
+		name    string
+		members []string
+		added   string
+	}{
+		{
+			name:    "case 0: keys move only to a joining member",
+			members: []string{"app-operator-0", "app-operator-1"},
+			added:   "app-operator-2",
+		},
+		{
+			name:    "case 1: keys move only to the first member",
+			members: []string{},
+			added:   "app-operator-0",
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			before := newRing(tc.members)
+			after := newRing(append(tc.members, tc.added))
+
+			counts := map[string]int{}
+			for k := 0; k < 1000; k++ {
+				key := fmt.Sprintf("org-%d/cluster-%d", k, k)
+
+				b := before.member(key)
+				a := after.member(key)
+				if b != a && a != tc.added {
+					t.Fatalf("key %#q moved from %#q to %#q, want %#q", key, b, a, tc.added)
+				}
+
+				counts[a]++
+			}
+
+			// Every member gets a share of the keys.
+			for _, m := range append(tc.members, tc.added) {
+				if counts[m] < 1000/(len(tc.members)+1)/3 {
+					t.Fatalf("member %#q got %d keys, want a fair share", m, counts[m])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,363 @@
+package shard
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/k8sclient/v7/pkg/k8sclient"
+	"github.com/giantswarm/k8smetadata/pkg/label"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// groupLabel is set on the leases of the replicas of a shard group so
+	// members are listed by it.
+	groupLabel = "app-operator.giantswarm.io/shard-group"
+
+	// staleLeaseDurations is the number of lease durations after which an
+	// expired lease is deleted. Leases are only deleted by their replica
+	// when it shuts down gracefully so the leases of crashed replicas are
+	// garbage collected by the other members.
+	staleLeaseDurations = 10
+)
+
+type Config struct {
+	K8sClient k8sclient.Interface
+	Logger    micrologger.Logger
+
+	// Group is the name of the shard group. Replicas of the same
+	// app-operator instance share the group and split its app CRs.
+	Group string
+	// Identity is the unique name of the replica, e.g. its pod name.
+	Identity string
+	// LeaseDuration is the time after which a replica which did not renew
+	// its lease is removed from the group. Leases are renewed every third
+	// of it.
+	LeaseDuration time.Duration
+	// Namespace is the namespace the leases are created in.
+	Namespace string
+}
+
+// Sharder splits the app CRs of an app-operator instance across its
+// replicas. Every replica holds a lease which it renews. The replicas with
+// unexpired leases are the members of the shard group and app CRs are
+// assigned to them by consistent hashing of their cluster ID or namespace.
+// When a replica joins or leaves only the app CRs of its share of the ring
+// move to other replicas.
+type Sharder struct {
+	k8sClient k8sclient.Interface
+	logger    micrologger.Logger
+
+	mutex sync.RWMutex
+	// leaseExpiry is the time the lease of the replica expires unless it is
+	// renewed. The replica owns no app CRs after it.
+	leaseExpiry time.Time
+	members     []string
+	ring        *ring
+	subscribers []func()
+
+	group         string
+	identity      string
+	leaseDuration time.Duration
+	namespace     string
+}
+
+func New(config Config) (*Sharder, error) {
+	if config.K8sClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.K8sClient must not be empty", config)
+	}
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+
+	if config.Group == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Group must not be empty", config)
+	}
+	if config.Identity == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Identity must not be empty", config)
+	}
+	if config.LeaseDuration < 3*time.Second {
+		return nil, microerror.Maskf(invalidConfigError, "%T.LeaseDuration must be at least 3s", config)
+	}
+	if config.Namespace == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Namespace must not be empty", config)
+	}
+
+	s := &Sharder{
+		k8sClient: config.K8sClient,
+		logger:    config.Logger,
+
+		group:         config.Group,
+		identity:      config.Identity,
+		leaseDuration: config.LeaseDuration,
+		namespace:     config.Namespace,
+	}
+
+	return s, nil
+}
+
+// Key returns the key app CRs are sharded by. App CRs of the same workload
+// cluster belong to the same replica so the clients and the chart status
+// watch of the cluster are only used by one replica. App CRs without a
+// cluster label are sharded by their namespace.
+func Key(app v1alpha1.App) string {
+	clusterID := app.Labels[label.Cluster]
+	if clusterID == "" {
+		return app.Namespace
+	}
+
+	return fmt.Sprintf("%s/%s", app.Namespace, clusterID)
+}
+
+// Join renews the lease of the replica and assigns app CRs to the members
+// of the shard group. It is called before the controllers start so app CRs
+// are assigned when they reconcile.
+func (s *Sharder) Join(ctx context.Context) {
+	err := s.sync(ctx)
+	if err != nil {
+		s.logger.Errorf(ctx, err, "failed to join shard group %#q", s.group)
+	}
+}
+
+// Boot renews the lease of the replica until the context is canceled. The
+// lease is deleted before Boot returns so the other replicas take over
+// immediately.
+func (s *Sharder) Boot(ctx context.Context) {
+	ticker := time.NewTicker(s.leaseDuration / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.leave()
+			return
+		case <-ticker.C:
+		}
+
+		err := s.sync(ctx)
+		if err != nil {
+			s.logger.Errorf(ctx, err, "failed to sync shard group %#q", s.group)
+		}
+	}
+}
+
+// Owns returns whether the app CR belongs to this replica. No app CR is owned
+// until the replica joined the shard group and after its lease expired, e.g.
+// when it cannot reach the API server, since other replicas take over its
+// app CRs then.
+func (s *Sharder) Owns(app v1alpha1.App) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if s.ring == nil || time.Now().After(s.leaseExpiry) {
+		return false
+	}
+
+	return s.ring.member(Key(app)) == s.identity
+}
+
+// Subscribe registers a function called after the members of the shard
+// group changed.
+func (s *Sharder) Subscribe(f func()) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.subscribers = append(s.subscribers, f)
+}
+
+// sync renews the lease of the replica and rebuilds the ring when replicas
+// joined or left. The replica owns no app CRs when its lease cannot be
+// renewed since the other replicas take over its app CRs once it expired.
+func (s *Sharder) sync(ctx context.Context) error {
+	err := s.renewLease(ctx)
+	if err != nil {
+		s.setMembers(ctx, nil)
+		return microerror.Mask(err)
+	}
+
+	members, err := s.listMembers(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	s.setMembers(ctx, members)
+
+	return nil
+}
+
+// setMembers rebuilds the ring and notifies the subscribers when the members
+// changed. The replica owns no app CRs unless it is a member.
+func (s *Sharder) setMembers(ctx context.Context, members []string) {
+	s.mutex.Lock()
+	if slices.Equal(s.members, members) {
+		s.mutex.Unlock()
+		return
+	}
+
+	if s.ring != nil {
+		rebalanceCounter.Inc()
+	}
+	s.members = members
+	s.ring = nil
+	if slices.Contains(members, s.identity) {
+		s.ring = newRing(members)
+	}
+	subscribers := slices.Clone(s.subscribers)
+	s.mutex.Unlock()
+
+	membersGauge.Set(float64(len(members)))
+	if s.ring == nil {
+		s.logger.Debugf(ctx, "owning no app CRs until the lease of replica %#q is renewed", s.identity)
+	} else {
+		s.logger.Debugf(ctx, "sharding app CRs across replicas %v", members)
+	}
+
+	for _, f := range subscribers {
+		f()
+	}
+}
+
+func (s *Sharder) renewLease(ctx context.Context) error {
+	leases := s.k8sClient.K8sClient().CoordinationV1().Leases(s.namespace)
+
+	renewed := time.Now()
+	now := metav1.NewMicroTime(renewed)
+	duration := int32(s.leaseDuration.Seconds())
+
+	lease, err := leases.Get(ctx, s.leaseName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      s.leaseName(),
+				Namespace: s.namespace,
+				Labels: map[string]string{
+					groupLabel: s.group,
+				},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &s.identity,
+				LeaseDurationSeconds: &duration,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}
+
+		_, err = leases.Create(ctx, lease, metav1.CreateOptions{})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	} else if err != nil {
+		return microerror.Mask(err)
+	} else {
+		lease.Spec.HolderIdentity = &s.identity
+		lease.Spec.LeaseDurationSeconds = &duration
+		lease.Spec.RenewTime = &now
+
+		_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	// The expiry is counted from before the request so the replica stops
+	// owning app CRs no later than the other replicas take over.
+	s.mutex.Lock()
+	s.leaseExpiry = renewed.Add(time.Duration(duration) * time.Second)
+	s.mutex.Unlock()
+
+	return nil
+}
+
+// listMembers returns the sorted identities of the replicas with unexpired
+// leases. The replica itself is only a member while its own lease is
+// unexpired. Stale leases are deleted.
+func (s *Sharder) listMembers(ctx context.Context) ([]string, error) {
+	list, err := s.k8sClient.K8sClient().CoordinationV1().Leases(s.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", groupLabel, s.group),
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var members []string
+	for _, lease := range list.Items {
+		if isStale(lease) && lease.Name != s.leaseName() {
+			s.deleteLease(ctx, lease)
+			continue
+		}
+		if isExpired(lease) || slices.Contains(members, *lease.Spec.HolderIdentity) {
+			continue
+		}
+
+		members = append(members, *lease.Spec.HolderIdentity)
+	}
+	sort.Strings(members)
+
+	return members, nil
+}
+
+// leave deletes the lease of the replica.
+func (s *Sharder) leave() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := s.k8sClient.K8sClient().CoordinationV1().Leases(s.namespace).Delete(ctx, s.leaseName(), metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		s.logger.Errorf(ctx, err, "failed to leave shard group %#q", s.group)
+	}
+}
+
+// deleteLease deletes a stale lease of another replica. Failures are only
+// logged since the lease is deleted again on the next sync.
+func (s *Sharder) deleteLease(ctx context.Context, lease coordinationv1.Lease) {
+	err := s.k8sClient.K8sClient().CoordinationV1().Leases(lease.Namespace).Delete(ctx, lease.Name, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{
+			ResourceVersion: &lease.ResourceVersion,
+		},
+	})
+	if apierrors.IsNotFound(err) || apierrors.IsConflict(err) {
+		// The lease was deleted or renewed in the meantime.
+		return
+	} else if err != nil {
+		s.logger.Errorf(ctx, err, "failed to delete stale lease %#q of shard group %#q", lease.Name, s.group)
+		return
+	}
+
+	s.logger.Debugf(ctx, "deleted stale lease %#q of shard group %#q", lease.Name, s.group)
+}
+
+func (s *Sharder) leaseName() string {
+	return fmt.Sprintf("%s-%s", s.group, s.identity)
+}
+
+func isExpired(lease coordinationv1.Lease) bool {
+	if lease.Spec.HolderIdentity == nil || lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+
+	expiry := lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+
+	return time.Now().After(expiry)
+}
+
+// isStale returns whether the lease expired more than staleLeaseDurations
+// lease durations ago.
+func isStale(lease coordinationv1.Lease) bool {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return false
+	}
+
+	duration := time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second
+	expiry := lease.Spec.RenewTime.Add(duration + staleLeaseDurations*duration)
+
+	return time.Now().After(expiry)
+}
@@ -0,0 +1,243 @@
+package shard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/k8sclient/v7/pkg/k8sclienttest"
+	"github.com/giantswarm/micrologger/microloggertest"
+	"github.com/google/go-cmp/cmp"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgofake "k8s.io/client-go/kubernetes/fake"
+	clientgotesting "k8s.io/client-go/testing"
+)
+
+func newLease(identity, group string, renewed time.Time) *coordinationv1.Lease {
+	duration := int32(15)
+	renewTime := metav1.NewMicroTime(renewed)
+
+	return &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      group + "-" + identity,
+			Namespace: "giantswarm",
+			Labels: map[string]string{
+				groupLabel: group,
+			},
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &identity,
+			LeaseDurationSeconds: &duration,
+			RenewTime:            &renewTime,
+		},
+	}
+}
+
+func Test_Sharder_sync(t *testing.T) {
+	tests := []struct {
+		name            string
+		leases          []runtime.Object
+		expectedMembers []string
+		expectedLeases  []string
+	}{
+		{
+			name:            "case 0: single replica owns all app CRs",
+			expectedMembers: []string{"app-operator-0"},
+			expectedLeases:  []string{"app-operator-app-operator-0"},
+		},
+		{
+			name: "case 1: replicas with unexpired leases are members",
+			leases: []runtime.Object{
+				newLease("app-operator-1", "app-operator", time.Now()),
+				newLease("app-operator-2", "app-operator", time.Now()),
+			},
+			expectedMembers: []string{"app-operator-0", "app-operator-1", "app-operator-2"},
+			expectedLeases:  []string{"app-operator-app-operator-0", "app-operator-app-operator-1", "app-operator-app-operator-2"},
+		},
+		{
+			name: "case 2: replicas with expired leases and other groups are ignored",
+			leases: []runtime.Object{
+				newLease("app-operator-1", "app-operator", time.Now().Add(-time.Minute)),
+				newLease("app-operator-2", "app-operator-abc01", time.Now()),
+			},
+			expectedMembers: []string{"app-operator-0"},
+			expectedLeases:  []string{"app-operator-abc01-app-operator-2", "app-operator-app-operator-0", "app-operator-app-operator-1"},
+		},
+		{
+			name: "case 3: stale leases of crashed replicas are deleted",
+			leases: []runtime.Object{
+				newLease("app-operator-1", "app-operator", time.Now().Add(-time.Hour)),
+				newLease("app-operator-2", "app-operator-abc01", time.Now().Add(-time.Hour)),
+			},
+			expectedMembers: []string{"app-operator-0"},
+			expectedLeases:  []string{"app-operator-abc01-app-operator-2", "app-operator-app-operator-0"},
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			k8sClient := clientgofake.NewClientset(tc.leases...)
+			clients := k8sclienttest.NewClients(k8sclienttest.ClientsConfig{
+				K8sClient: k8sClient,
+			})
+
+			c := Config{
+				K8sClient: clients,
+				Logger:    microloggertest.New(),
+
+				Group:         "app-operator",
+				Identity:      "app-operator-0",
+				LeaseDuration: 15 * time.Second,
+				Namespace:     "giantswarm",
+			}
+			s, err := New(c)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			app := v1alpha1.App{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "kiam",
+					Namespace: "org-acme",
+				},
+			}
+			if s.Owns(app) {
+				t.Fatalf("app CR is owned before joining the shard group")
+			}
+
+			var rebalanced int
+			s.Subscribe(func() { rebalanced++ })
+
+			err = s.sync(context.Background())
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+			// The membership did not change so subscribers are only
+			// called once.
+			err = s.sync(context.Background())
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			if diff := cmp.Diff(tc.expectedMembers, s.members); diff != "" {
+				t.Fatalf("want matching members \n %s", diff)
+			}
+			if rebalanced != 1 {
+				t.Fatalf("subscribers called %d times, want 1", rebalanced)
+			}
+
+			list, err := k8sClient.CoordinationV1().Leases("giantswarm").List(context.Background(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+			var leases []string
+			for _, lease := range list.Items {
+				leases = append(leases, lease.Name)
+			}
+			if diff := cmp.Diff(tc.expectedLeases, leases); diff != "" {
+				t.Fatalf("want matching leases \n %s", diff)
+			}
+
+			if len(tc.expectedMembers) == 1 && !s.Owns(app) {
+				t.Fatalf("app CR is not owned by the only replica")
+			}
+		})
+	}
+}
+
+func Test_Sharder_sync_renewFailure(t *testing.T) {
+	k8sClient := clientgofake.NewClientset(newLease("app-operator-1", "app-operator", time.Now()))
+	clients := k8sclienttest.NewClients(k8sclienttest.ClientsConfig{
+		K8sClient: k8sClient,
+	})
+
+	c := Config{
+		K8sClient: clients,
+		Logger:    microloggertest.New(),
+
+		Group:         "app-operator",
+		Identity:      "app-operator-0",
+		LeaseDuration: 15 * time.Second,
+		Namespace:     "giantswarm",
+	}
+	s, err := New(c)
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	var rebalanced int
+	s.Subscribe(func() { rebalanced++ })
+
+	err = s.sync(context.Background())
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+	if !ownsAny(s) {
+		t.Fatalf("replica owns no app CRs after joining the shard group")
+	}
+
+	var failing bool
+	k8sClient.PrependReactor("update", "leases", func(action clientgotesting.Action) (bool, runtime.Object, error) {
+		if !failing {
+			return false, nil, nil
+		}
+		return true, nil, errors.New("connection refused")
+	})
+
+	failing = true
+	err = s.sync(context.Background())
+	if err == nil {
+		t.Fatalf("error == nil, want non-nil")
+	}
+	if len(s.members) != 0 {
+		t.Fatalf("members == %v, want none", s.members)
+	}
+	if ownsAny(s) {
+		t.Fatalf("replica owns app CRs although its lease was not renewed")
+	}
+	if rebalanced != 2 {
+		t.Fatalf("subscribers called %d times, want 2", rebalanced)
+	}
+
+	failing = false
+	err = s.sync(context.Background())
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+	if !ownsAny(s) {
+		t.Fatalf("replica owns no app CRs after its lease was renewed")
+	}
+
+	// The lease expires when it is not renewed in time, e.g. because
+	// requests to the API server hang.
+	s.leaseExpiry = time.Now().Add(-time.Second)
+	if ownsAny(s) {
+		t.Fatalf("replica owns app CRs although its lease expired")
+	}
+}
+
+// ownsAny returns whether the replica owns any of a set of app CRs spread
+// across the ring.
+func ownsAny(s *Sharder) bool {
+	for i := 0; i < 100; i++ {
+		app := v1alpha1.App{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "kiam",
+				Namespace: fmt.Sprintf("org-%d", i),
+			},
+		}
+		if s.Owns(app) {
+			return true
+		}
+	}
+
+	return false
+}
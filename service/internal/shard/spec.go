@@ -0,0 +1,11 @@
+package shard
+
+import "github.com/giantswarm/apiextensions-application/api/v1alpha1"
+
+type Interface interface {
+	// Owns returns whether the app CR belongs to the shard of this replica.
+	Owns(app v1alpha1.App) bool
+	// Subscribe registers a function called after the members of the
+	// shard group changed and app CRs were rebalanced.
+	Subscribe(f func())
+}
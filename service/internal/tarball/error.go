@@ -0,0 +1,57 @@
+package tarball
+
+import (
+	"github.com/giantswarm/microerror"
+)
+
+var invalidConfigError = &microerror.Error{
+	Kind: "invalidConfigError",
+}
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+var appNotFoundError = &microerror.Error{
+	Kind: "appNotFoundError",
+}
+
+// IsAppNotFound asserts appNotFoundError.
+func IsAppNotFound(err error) bool {
+	return microerror.Cause(err) == appNotFoundError
+}
+
+var appVersionNotFoundError = &microerror.Error{
+	Kind: "appVersionNotFoundError",
+}
+
+// IsAppVersionNotFound asserts appVersionNotFoundError.
+func IsAppVersionNotFound(err error) bool {
+	return microerror.Cause(err) == appVersionNotFoundError
+}
+
+var catalogEmptyError = &microerror.Error{
+	Kind: "catalogEmptyError",
+}
+
+// IsCatalogEmpty asserts catalogEmptyError.
+func IsCatalogEmpty(err error) bool {
+	return microerror.Cause(err) == catalogEmptyError
+}
+
+var indexNotFoundError = &microerror.Error{
+	Kind: "indexNotFoundError",
+}
+
+// IsIndexNotFound asserts indexNotFoundError.
+func IsIndexNotFound(err error) bool {
+	return microerror.Cause(err) == indexNotFoundError
+}
+
+// IsNotFound asserts:
+// appVersionNotFoundError OR appNotFoundError OR catalogEmptyError OR
+// indexNotFoundError.
+func IsNotFound(err error) bool {
+	return IsAppNotFound(err) || IsAppVersionNotFound(err) || IsCatalogEmpty(err) || IsIndexNotFound(err)
+}
@@ -0,0 +1,288 @@
+// Package tarball resolves the URL of the chart tarball of app CRs from the
+// repositories of their catalog. It is used to point chart CRs at the
+// tarball and to find the values schema of the chart before values are
+// written.
+package tarball
+
+import (
+	"context"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/app/v8/pkg/key"
+	"github.com/giantswarm/appcatalog"
+	"github.com/giantswarm/errors/tenant"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/app-operator/v7/service/internal/indexcache"
+)
+
+const (
+	chartPullFailedStatus = "chart-pull-failed"
+)
+
+type Config struct {
+	IndexCache indexcache.Interface
+	Logger     micrologger.Logger
+
+	ChartNamespace string
+}
+
+// Resolver resolves the tarball URLs of app CRs.
+type Resolver struct {
+	indexCache indexcache.Interface
+	logger     micrologger.Logger
+
+	chartNamespace string
+}
+
+// Tarball is the chart tarball of an app CR.
+type Tarball struct {
+	// URL is the URL of the tarball.
+	URL string
+	// RepositoryURL is the URL of the catalog repository the tarball was
+	// found in.
+	RepositoryURL string
+	// Version is the chart version as listed in the repository. It may lack
+	// the v prefix of the version of the app CR.
+	Version string
+}
+
+func New(config Config) (*Resolver, error) {
+	if config.IndexCache == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.IndexCache must not be empty", config)
+	}
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+	if config.ChartNamespace == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.ChartNamespace must not be empty", config)
+	}
+
+	r := &Resolver{
+		indexCache: config.IndexCache,
+		logger:     config.Logger,
+
+		chartNamespace: config.ChartNamespace,
+	}
+
+	return r, nil
+}
+
+// Resolve returns the tarball of the app CR. The repository of the existing
+// chart CR is tried first unless chart-operator failed to pull from it. The
+// other repositories of public catalogs are tried as fallbacks. The chart CR
+// is read with the given client of the cluster the app is deployed to.
+func (r *Resolver) Resolve(ctx context.Context, ctrlClient client.Client, cr v1alpha1.App, catalog v1alpha1.Catalog, chartName string) (Tarball, error) {
+	repositoryURL, err := r.pickRepositoryURL(ctx, ctrlClient, cr, catalog, chartName)
+	if err != nil {
+		return Tarball{}, microerror.Mask(err)
+	}
+	repositories := []string{repositoryURL}
+
+	if key.CatalogVisibility(catalog) != "internal" {
+		repositories = append(repositories, fallbackRepositories(catalog, repositoryURL)...)
+	}
+
+	for _, u := range repositories {
+		var tarballURL, version string
+		tarballURL, version, err = r.buildTarballURL(ctx, cr, catalog, u)
+		if err == nil {
+			r.logger.Debugf(ctx, "found a working tarball URL in repository %#q", u)
+
+			tarball := Tarball{
+				URL:           tarballURL,
+				RepositoryURL: u,
+				Version:       version,
+			}
+
+			return tarball, nil
+		}
+
+		r.logger.Errorf(ctx, err, "failed to resolve tarball URL for %#q repository", u)
+	}
+
+	return Tarball{}, microerror.Mask(err)
+}
+
+func (r *Resolver) pickRepositoryURL(ctx context.Context, ctrlClient client.Client, cr v1alpha1.App, catalog v1alpha1.Catalog, chartName string) (string, error) {
+	switch len(catalog.Spec.Repositories) {
+	case 0:
+		return catalog.Spec.Storage.URL, nil
+	case 1:
+		return catalog.Spec.Repositories[0].URL, nil
+	}
+
+	var chart v1alpha1.Chart
+	err := ctrlClient.Get(
+		ctx,
+		types.NamespacedName{Name: chartName, Namespace: r.chartNamespace},
+		&chart,
+	)
+	if apierrors.IsNotFound(err) || tenant.IsAPINotAvailable(err) {
+		// Repositories is guaranteed by Custom Resource Definition to have at least one entry.
+		return catalog.Spec.Repositories[0].URL, nil
+	} else if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	// Check currently selected repository
+	repositoryIndex := -1
+	for i, repo := range catalog.Spec.Repositories {
+		if strings.Contains(chart.Spec.TarballURL, repo.URL) {
+			repositoryIndex = i
+			break
+		}
+	}
+	if repositoryIndex == -1 {
+		// Could not match current tarballURL to any of Catalog's repositories.
+		// Maybe the list was updated. Let's pick any existing repository.
+		r.logger.Debugf(ctx, "could not match tarball URL %q to any of %q Catalog repositories; using default", chart.Spec.TarballURL, catalog.Name)
+		return catalog.Spec.Repositories[0].URL, nil
+	}
+
+	if chart.Status.Release.Status == chartPullFailedStatus {
+		// chart-operator had trouble pulling the chart -- this includes timeouts and chart not being found (404)
+		// Round-robin the repository.
+		repositoryIndex = (repositoryIndex + 1) % len(catalog.Spec.Repositories)
+	}
+	return catalog.Spec.Repositories[repositoryIndex].URL, nil
+}
+
+func (r *Resolver) buildTarballURL(ctx context.Context, cr v1alpha1.App, catalog v1alpha1.Catalog, repositoryURL string) (url string, version string, err error) {
+	if key.CatalogVisibility(catalog) == "internal" || IsOCIRepositoryURL(repositoryURL) {
+		// For internal catalogs we generate the URL as its predictable
+		// and to avoid having chicken egg problems.
+		// For OCI repositories there is no discovery mechanism, so we just
+		// make an assumption about URL format.
+		url, err = appcatalog.NewTarballURL(repositoryURL, key.AppName(cr), key.Version(cr))
+		if err != nil {
+			return "", "", microerror.Mask(err)
+		}
+		version = key.Version(cr)
+		return url, version, nil
+	}
+
+	// For all other catalogs we check the index.yaml for compatibility
+	// with community catalogs.
+	index, err := r.indexCache.GetIndex(ctx, repositoryURL)
+	if err != nil {
+		r.logger.Errorf(ctx, err, "failed to get index.yaml from %q", repositoryURL)
+		return "", "", microerror.Mask(err)
+	}
+	if index == nil {
+		return "", "", microerror.Maskf(indexNotFoundError, "index %#v for %q is <nil>", index, repositoryURL)
+	}
+	if len(index.Entries) == 0 {
+		return "", "", microerror.Maskf(catalogEmptyError, "index %#v for %q has no entries", index, repositoryURL)
+	}
+
+	entries, ok := index.Entries[cr.Spec.Name]
+	if !ok {
+		return "", "", microerror.Maskf(appNotFoundError, "no entries for app %#q in index.yaml for %q", cr.Spec.Name, repositoryURL)
+	}
+
+	// We first try with the full version set in .spec.version of the app CR.
+	version = cr.Spec.Version
+	url, err = getEntryURL(entries, cr.Spec.Name, version)
+	if err != nil {
+		// We try again without the `v` prefix. This enables us to use the
+		// Flux Image Automation controller to automatically update apps.
+		version = strings.TrimPrefix(version, "v")
+
+		url, err = getEntryURL(entries, cr.Spec.Name, version)
+		if err != nil {
+			return "", "", microerror.Mask(err)
+		}
+	}
+
+	if url == "" {
+		return "", "", microerror.Maskf(appVersionNotFoundError, "found entry for app %#q but URL is not specified", cr.Spec.Name)
+	}
+
+	if !isValidURL(url) {
+		// URL may be relative. If so we join it to the Catalog Storage URL.
+		url, err = joinRelativeURL(repositoryURL, url)
+		if err != nil {
+			return "", "", microerror.Mask(err)
+		}
+	}
+
+	return url, version, err
+}
+
+func fallbackRepositories(catalog v1alpha1.Catalog, repositoryURL string) []string {
+	urls := []string{}
+	repositoryIndex := -1
+	for i, repo := range catalog.Spec.Repositories {
+		if repo.URL == repositoryURL {
+			repositoryIndex = i
+		}
+		urls = append(urls, repo.URL)
+	}
+	if repositoryIndex == -1 {
+		// could not find failed repositoryURL, let's just return the whole slice
+		return urls
+	}
+
+	// Return all repositoryURLs, starting with the one after repositoryURL and skip repositoryURL.
+	// example: urls=["a", "b", "c", "d"], repositoryURL="c" -> ["d", "a", "b"]
+	// example: urls=["x"], repositoryURL="x" -> []
+	return append(urls[repositoryIndex+1:], urls[:repositoryIndex]...)
+}
+
+func getEntryURL(entries []indexcache.Entry, app, version string) (string, error) {
+	for _, e := range entries {
+		if e.Version == version {
+			if len(e.Urls) == 0 {
+				return "", microerror.Maskf(appVersionNotFoundError, "no URL in index.yaml for app %#q version %#q", app, version)
+			}
+
+			return e.Urls[0], nil
+		}
+	}
+
+	return "", microerror.Maskf(appVersionNotFoundError, "no app %#q in index.yaml with given version %#q", app, version)
+}
+
+func isValidURL(input string) bool {
+	_, err := url.ParseRequestURI(input)
+	if err != nil {
+		return false
+	}
+
+	u, err := url.Parse(input)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return false
+	}
+
+	return true
+}
+
+func joinRelativeURL(baseURL, relativeURL string) (string, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	u.Path = path.Join(u.Path, relativeURL)
+	return u.String(), nil
+}
+
+// IsOCIRepositoryURL determines whether given URL points to OCI repository.
+func IsOCIRepositoryURL(repositoryURL string) bool {
+	if repositoryURL == "" {
+		return false
+	}
+	u, err := url.Parse(repositoryURL)
+	if err != nil {
+		return false
+	}
+	return u.Scheme == "oci"
+}
@@ -0,0 +1,120 @@
+// Package valuesmerge merges the values of app CRs the way they are
+// deployed. It is shared by the configmap and secret resources and the dry
+// run service so dry runs render exactly the values written for chart CRs.
+package valuesmerge
+
+import (
+	"context"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/microerror"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/giantswarm/app-operator/v7/pkg/valueref"
+	"github.com/giantswarm/app-operator/v7/pkg/valuesource"
+	"github.com/giantswarm/app-operator/v7/pkg/valuestemplate"
+)
+
+type Config struct {
+	ValueRefs    *valueref.Resolver
+	ValueSources *valuesource.Resolver
+
+	// Provider is exposed to values templates.
+	Provider string
+}
+
+type Merger struct {
+	valueRefs    *valueref.Resolver
+	valueSources *valuesource.Resolver
+
+	provider string
+}
+
+// Result holds the merged values with the layers they were merged from in
+// the order they take effect.
+type Result struct {
+	Values map[string]interface{}
+	Layers []valuesource.Layer
+}
+
+func New(config Config) (*Merger, error) {
+	if config.ValueRefs == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.ValueRefs must not be empty", config)
+	}
+	if config.ValueSources == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.ValueSources must not be empty", config)
+	}
+
+	if config.Provider == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Provider must not be empty", config)
+	}
+
+	m := &Merger{
+		valueRefs:    config.ValueRefs,
+		valueSources: config.ValueSources,
+
+		provider: config.Provider,
+	}
+
+	return m, nil
+}
+
+// Merge merges the values of the given type of the app CR. The catalog,
+// app, user and extra config sources are merged in the same order as the
+// values package of github.com/giantswarm/app, the value references of the
+// app CR are merged in by priority and templates are rendered last. The
+// values are merged from the same objects whose resource versions are
+// recorded in the layers, so the recorded sources always match the values.
+// The clusterClient is the client of the cluster the app is deployed to.
+func (m *Merger) Merge(ctx context.Context, cr v1alpha1.App, catalog v1alpha1.Catalog, clusterClient kubernetes.Interface, valuesType string) (Result, error) {
+	sourceLayers, err := m.valueSources.Layers(ctx, cr, catalog, valuesType)
+	if err != nil {
+		return Result{}, microerror.Mask(err)
+	}
+
+	mergedData, err := valuesource.Merge(sourceLayers)
+	if err != nil {
+		return Result{}, microerror.Mask(err)
+	}
+
+	var refLayers []valueref.Layer
+	if valueref.HasRefs(cr, valuesType) {
+		refLayers, err = m.valueRefs.Layers(ctx, cr, clusterClient, valuesType)
+		if err != nil {
+			return Result{}, microerror.Mask(err)
+		}
+
+		mergedData, err = valueref.Merge(mergedData, refLayers, sourceLayers)
+		if err != nil {
+			return Result{}, microerror.Mask(err)
+		}
+	}
+
+	mode, err := valuestemplate.Mode(cr)
+	if err != nil {
+		return Result{}, microerror.Mask(err)
+	}
+	if mode != "" && mergedData != nil {
+		err = valuestemplate.Render(mergedData, valuestemplate.NewContext(cr, m.provider), mode)
+		if err != nil {
+			return Result{}, microerror.Mask(err)
+		}
+	}
+
+	result := Result{
+		Values: mergedData,
+		Layers: valueref.Layers(refLayers, sourceLayers),
+	}
+
+	return result, nil
+}
+
+// Sources returns the sources of the layers in the order they take effect.
+func (r Result) Sources() []valuesource.Source {
+	sources := []valuesource.Source{}
+	for _, l := range r.Layers {
+		sources = append(sources, l.Source)
+	}
+
+	return sources
+}
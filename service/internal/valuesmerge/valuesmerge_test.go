@@ -0,0 +1,192 @@
+package valuesmerge
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/k8sclient/v7/pkg/k8sclienttest"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	clientgofake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/giantswarm/app-operator/v7/pkg/valueref"
+	"github.com/giantswarm/app-operator/v7/pkg/valuesource"
+	"github.com/giantswarm/app-operator/v7/pkg/valuestemplate"
+)
+
+func Test_Merger_Merge(t *testing.T) {
+	tests := []struct {
+		name            string
+		annotations     map[string]string
+		expectedValues  map[string]interface{}
+		expectedSources []string
+	}{
+		{
+			name: "case 0: configmaps are merged by priority",
+			expectedValues: map[string]interface{}{
+				"cluster":  "user",
+				"replicas": float64(1),
+			},
+			expectedSources: []string{
+				"app/cluster-values",
+				"user/user-values",
+			},
+		},
+		{
+			name: "case 1: referenced app is merged between the cluster and the user values",
+			annotations: map[string]string{
+				valueref.Annotation: "- kind: app\n  name: database\n  priority: 75\n",
+			},
+			expectedValues: map[string]interface{}{
+				"cluster": "user",
+				"database": map[string]interface{}{
+					"host": "db.{{ .Provider }}",
+				},
+				"replicas": float64(1),
+			},
+			expectedSources: []string{
+				"app/cluster-values",
+				"appRef/database",
+				"user/user-values",
+			},
+		},
+		{
+			name: "case 2: templates are rendered after references are merged",
+			annotations: map[string]string{
+				valueref.Annotation:       "- kind: app\n  name: database\n  priority: 75\n",
+				valuestemplate.Annotation: valuestemplate.ModeEnabled,
+			},
+			expectedValues: map[string]interface{}{
+				"cluster": "user",
+				"database": map[string]interface{}{
+					"host": "db.aws",
+				},
+				"replicas": float64(1),
+			},
+			expectedSources: []string{
+				"app/cluster-values",
+				"appRef/database",
+				"user/user-values",
+			},
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			app := v1alpha1.App{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "prometheus",
+					Namespace:   "org-acme",
+					Annotations: tc.annotations,
+				},
+				Spec: v1alpha1.AppSpec{
+					Config: v1alpha1.AppSpecConfig{
+						ConfigMap: v1alpha1.AppSpecConfigConfigMap{
+							Name:      "cluster-values",
+							Namespace: "org-acme",
+						},
+					},
+					UserConfig: v1alpha1.AppSpecUserConfig{
+						ConfigMap: v1alpha1.AppSpecUserConfigConfigMap{
+							Name:      "user-values",
+							Namespace: "org-acme",
+						},
+					},
+				},
+			}
+			database := &v1alpha1.App{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "database",
+					Namespace: "org-acme",
+				},
+			}
+
+			k8sClient := clientgofake.NewClientset(
+				newConfigMap("cluster-values", "org-acme", "cluster: cluster\nreplicas: 1\n"),
+				newConfigMap("user-values", "org-acme", "cluster: user\n"),
+				newConfigMap("database-chart-values", "giantswarm", "cluster: database\ndatabase:\n  host: db.{{ .Provider }}\n"),
+			)
+
+			s := runtime.NewScheme()
+			err := v1alpha1.AddToScheme(s)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			m := newTestMerger(t, k8sClient, fake.NewClientBuilder().WithScheme(s).WithObjects(database).Build())
+
+			result, err := m.Merge(context.Background(), app, v1alpha1.Catalog{}, k8sClient, valuesource.TypeConfigMap)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			if diff := cmp.Diff(tc.expectedValues, result.Values); diff != "" {
+				t.Fatalf("want matching values \n %s", diff)
+			}
+
+			var sources []string
+			for _, source := range result.Sources() {
+				sources = append(sources, source.Kind+"/"+source.Name)
+			}
+			if diff := cmp.Diff(tc.expectedSources, sources); diff != "" {
+				t.Fatalf("want matching sources \n %s", diff)
+			}
+		})
+	}
+}
+
+func newTestMerger(t *testing.T, k8sClient kubernetes.Interface, ctrlClient client.Client) *Merger {
+	t.Helper()
+
+	valueRefs, err := valueref.New(valueref.Config{
+		K8sClient: k8sclienttest.NewClients(k8sclienttest.ClientsConfig{
+			CtrlClient: ctrlClient,
+			K8sClient:  k8sClient,
+		}),
+
+		ChartNamespace: "giantswarm",
+	})
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	valueSources, err := valuesource.New(valuesource.Config{
+		K8sClient: k8sClient,
+	})
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	m, err := New(Config{
+		ValueRefs:    valueRefs,
+		ValueSources: valueSources,
+
+		Provider: "aws",
+	})
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	return m
+}
+
+func newConfigMap(name, namespace, values string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Data: map[string]string{
+			"values": values,
+		},
+	}
+}
@@ -0,0 +1,204 @@
+// Package valuesschema fetches and caches the values.schema.json of charts
+// and validates merged app values against it before they are passed to
+// chart-operator.
+package valuesschema
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	gocache "github.com/patrickmn/go-cache"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// Chart tarballs are immutable for a given version so the schema can be
+	// cached for longer than the catalog index.
+	expiration = 1 * time.Hour
+
+	// maxTarballSize protects against downloading huge tarballs.
+	maxTarballSize = 20 * 1024 * 1024
+
+	schemaFileName = "values.schema.json"
+	valuesFileName = "values.yaml"
+)
+
+type Config struct {
+	Logger micrologger.Logger
+
+	HTTPClientTimeout time.Duration
+}
+
+type Resource struct {
+	cache      *gocache.Cache
+	httpClient *http.Client
+	logger     micrologger.Logger
+}
+
+func New(config Config) (*Resource, error) {
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+
+	if config.HTTPClientTimeout == 0 {
+		return nil, microerror.Maskf(invalidConfigError, "%T.HTTPClientTimeout must not be empty", config)
+	}
+
+	r := &Resource{
+		cache: gocache.New(expiration, expiration/2),
+		httpClient: &http.Client{
+			Timeout: config.HTTPClientTimeout,
+		},
+		logger: config.Logger,
+	}
+
+	return r, nil
+}
+
+// GetSchema implements Interface. The chart defaults from values.yaml are
+// only known when the tarball can be fetched over HTTP. OCI tarballs are
+// only validated when schemaURL is set.
+func (r *Resource) GetSchema(ctx context.Context, tarballURL, schemaURL string) (*Schema, error) {
+	cacheKey := tarballURL + "|" + schemaURL
+
+	if v, ok := r.cache.Get(cacheKey); ok {
+		s, ok := v.(*Schema)
+		if !ok {
+			return nil, microerror.Maskf(wrongTypeError, "expected '%T', got '%T'", &Schema{}, v)
+		}
+
+		return s, nil
+	}
+
+	var rawSchema []byte
+	var defaults map[string]interface{}
+	{
+		if isHTTPURL(tarballURL) {
+			files, err := r.getTarballFiles(ctx, tarballURL)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+
+			rawSchema = files[schemaFileName]
+
+			defaults = map[string]interface{}{}
+			err = yaml.Unmarshal(files[valuesFileName], &defaults)
+			if err != nil {
+				return nil, microerror.Maskf(invalidSchemaError, "failed to parse %#q of %#q: %s", valuesFileName, tarballURL, err)
+			}
+		}
+
+		if schemaURL != "" {
+			var err error
+			rawSchema, err = r.get(ctx, schemaURL)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+		}
+	}
+
+	var s *Schema
+	if len(rawSchema) > 0 {
+		var err error
+		s, err = newSchema(rawSchema, defaults)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	r.cache.SetDefault(cacheKey, s)
+
+	return s, nil
+}
+
+func (r *Resource) get(ctx context.Context, u string) ([]byte, error) {
+	r.logger.Debugf(ctx, "getting %#q", u)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, microerror.Maskf(unexpectedStatusCodeError, "%#q returned status code %d", u, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxTarballSize))
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	r.logger.Debugf(ctx, "got %#q", u)
+
+	return body, nil
+}
+
+// getTarballFiles returns the values.yaml and values.schema.json of the top
+// level chart in the tarball. Files of subcharts are ignored.
+func (r *Resource) getTarballFiles(ctx context.Context, tarballURL string) (map[string][]byte, error) {
+	body, err := r.get(ctx, tarballURL)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, microerror.Maskf(invalidSchemaError, "failed to read tarball %#q: %s", tarballURL, err)
+	}
+	defer func() { _ = gz.Close() }()
+
+	files := map[string][]byte{}
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, microerror.Maskf(invalidSchemaError, "failed to read tarball %#q: %s", tarballURL, err)
+		}
+
+		// Top level chart files are located at <chart>/<file>.
+		dir, file := path.Split(path.Clean(header.Name))
+		if strings.Count(dir, "/") != 1 {
+			continue
+		}
+		if file != schemaFileName && file != valuesFileName {
+			continue
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, microerror.Maskf(invalidSchemaError, "failed to read %#q in tarball %#q: %s", header.Name, tarballURL, err)
+		}
+		files[file] = data
+	}
+
+	return files, nil
+}
+
+func isHTTPURL(u string) bool {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+
+	return parsed.Scheme == "http" || parsed.Scheme == "https"
+}
@@ -0,0 +1,187 @@
+package valuesschema
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+	"github.com/google/go-cmp/cmp"
+)
+
+const testSchema = `{
+	"$schema": "http://json-schema.org/schema#",
+	"type": "object",
+	"required": ["image"],
+	"properties": {
+		"image": {
+			"type": "object",
+			"properties": {
+				"tag": {"type": "string"}
+			}
+		},
+		"replicas": {"type": "integer", "minimum": 1}
+	}
+}`
+
+func Test_Resource_GetSchema(t *testing.T) {
+	tests := []struct {
+		name               string
+		files              map[string]string
+		schemaURL          bool
+		values             map[string]interface{}
+		expectedNilSchema  bool
+		expectedViolations []Violation
+	}{
+		{
+			name: "case 0: chart without schema",
+			files: map[string]string{
+				"test-app/values.yaml": "replicas: 1\n",
+			},
+			expectedNilSchema: true,
+		},
+		{
+			name: "case 1: valid values merged with defaults",
+			files: map[string]string{
+				"test-app/values.schema.json": testSchema,
+				"test-app/values.yaml":        "image:\n  tag: 1.0.0\n",
+			},
+			values: map[string]interface{}{
+				"replicas": 2,
+			},
+		},
+		{
+			name: "case 2: invalid values",
+			files: map[string]string{
+				"test-app/values.schema.json": testSchema,
+				"test-app/values.yaml":        "image:\n  tag: 1.0.0\n",
+			},
+			values: map[string]interface{}{
+				"image": map[string]interface{}{
+					"tag": 1,
+				},
+				"replicas": 0,
+			},
+			expectedViolations: []Violation{
+				{Pointer: "/image/tag", Message: "type: got number, want string"},
+				{Pointer: "/replicas", Message: "minimum"},
+			},
+		},
+		{
+			name: "case 3: subchart schemas are ignored",
+			files: map[string]string{
+				"test-app/charts/sub/values.schema.json": testSchema,
+				"test-app/values.yaml":                   "replicas: 1\n",
+			},
+			expectedNilSchema: true,
+		},
+		{
+			name: "case 4: schema from annotation URL without defaults skips required",
+			files: map[string]string{
+				"test-app/values.yaml": "image:\n  tag: 1.0.0\n",
+			},
+			schemaURL: true,
+			values: map[string]interface{}{
+				"replicas": "two",
+			},
+			expectedViolations: []Violation{
+				{Pointer: "/replicas", Message: "type: got string, want integer"},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tarball := newTestTarball(t, tc.files)
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("/test-app-1.0.0.tgz", func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write(tarball)
+			})
+			mux.HandleFunc("/values.schema.json", func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(testSchema))
+			})
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			c := Config{
+				Logger: microloggertest.New(),
+
+				HTTPClientTimeout: 5 * time.Second,
+			}
+			r, err := New(c)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			tarballURL := server.URL + "/test-app-1.0.0.tgz"
+			var schemaURL string
+			if tc.schemaURL {
+				// Use an OCI tarball URL so the defaults are unknown.
+				tarballURL = "oci://example.com/test-app:1.0.0"
+				schemaURL = server.URL + "/values.schema.json"
+			}
+
+			s, err := r.GetSchema(context.Background(), tarballURL, schemaURL)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+			if tc.expectedNilSchema {
+				if s != nil {
+					t.Fatalf("expected nil schema got %#v", s)
+				}
+				return
+			}
+			if s == nil {
+				t.Fatal("expected non-nil schema got nil")
+			}
+
+			violations, err := s.Validate(tc.values)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+			if !cmp.Equal(violations, tc.expectedViolations) {
+				t.Fatalf("want matching violations \n %s", cmp.Diff(violations, tc.expectedViolations))
+			}
+		})
+	}
+}
+
+func newTestTarball(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	for name, content := range files {
+		err := tw.WriteHeader(&tar.Header{
+			Name: name,
+			Mode: 0600,
+			Size: int64(len(content)),
+		})
+		if err != nil {
+			t.Fatalf("error == %#v, want nil", err)
+		}
+		_, err = tw.Write([]byte(content))
+		if err != nil {
+			t.Fatalf("error == %#v, want nil", err)
+		}
+	}
+
+	err := tw.Close()
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+	err = gz.Close()
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	return buf.Bytes()
+}
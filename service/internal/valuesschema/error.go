@@ -0,0 +1,39 @@
+package valuesschema
+
+import "github.com/giantswarm/microerror"
+
+var invalidConfigError = &microerror.Error{
+	Kind: "invalidConfigError",
+}
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+var invalidSchemaError = &microerror.Error{
+	Kind: "invalidSchemaError",
+}
+
+// IsInvalidSchema asserts invalidSchemaError.
+func IsInvalidSchema(err error) bool {
+	return microerror.Cause(err) == invalidSchemaError
+}
+
+var unexpectedStatusCodeError = &microerror.Error{
+	Kind: "unexpectedStatusCodeError",
+}
+
+// IsUnexpectedStatusCode asserts unexpectedStatusCodeError.
+func IsUnexpectedStatusCode(err error) bool {
+	return microerror.Cause(err) == unexpectedStatusCodeError
+}
+
+var wrongTypeError = &microerror.Error{
+	Kind: "wrongTypeError",
+}
+
+// IsWrongTypeError asserts wrongTypeError.
+func IsWrongTypeError(err error) bool {
+	return microerror.Cause(err) == wrongTypeError
+}
@@ -0,0 +1,160 @@
+package valuesschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+	"github.com/imdario/mergo"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+)
+
+const schemaLocation = "values.schema.json"
+
+// Schema is a compiled values schema together with the chart defaults.
+type Schema struct {
+	schema *jsonschema.Schema
+
+	// defaults are the values from the values.yaml of the chart. They are
+	// nil when they are not known.
+	defaults map[string]interface{}
+}
+
+func newSchema(raw []byte, defaults map[string]interface{}) (*Schema, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+	if err != nil {
+		return nil, microerror.Maskf(invalidSchemaError, "failed to parse schema: %s", err)
+	}
+
+	c := jsonschema.NewCompiler()
+	err = c.AddResource(schemaLocation, doc)
+	if err != nil {
+		return nil, microerror.Maskf(invalidSchemaError, "failed to add schema: %s", err)
+	}
+
+	compiled, err := c.Compile(schemaLocation)
+	if err != nil {
+		return nil, microerror.Maskf(invalidSchemaError, "failed to compile schema: %s", err)
+	}
+
+	s := &Schema{
+		schema:   compiled,
+		defaults: defaults,
+	}
+
+	return s, nil
+}
+
+// Validate validates the values merged on top of the chart defaults, the
+// same way Helm does. When the defaults are not known missing required
+// properties are not reported since they may be set by the chart.
+func (s *Schema) Validate(values map[string]interface{}) ([]Violation, error) {
+	merged := map[string]interface{}{}
+	if s.defaults != nil {
+		err := mergo.Merge(&merged, deepCopy(s.defaults))
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+	err := mergo.Merge(&merged, deepCopy(values), mergo.WithOverride)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	// Values are converted to JSON types so numbers are validated
+	// correctly.
+	raw, err := json.Marshal(merged)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	err = s.schema.Validate(instance)
+	if err == nil {
+		return nil, nil
+	}
+
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return nil, microerror.Mask(err)
+	}
+
+	var violations []Violation
+	for _, unit := range validationErr.BasicOutput().Errors {
+		if unit.Error == nil {
+			continue
+		}
+		if _, ok := unit.Error.Kind.(*kind.Required); ok && s.defaults == nil {
+			continue
+		}
+
+		pointer := unit.InstanceLocation
+		if pointer == "" {
+			pointer = "/"
+		}
+
+		violations = append(violations, Violation{
+			Pointer: pointer,
+			Message: message(unit.Error.Kind),
+		})
+	}
+
+	sort.SliceStable(violations, func(i, j int) bool {
+		return violations[i].Pointer < violations[j].Pointer
+	})
+
+	return violations, nil
+}
+
+// Message formats violations for the app CR status.
+func Message(violations []Violation) string {
+	var parts []string
+	for _, v := range violations {
+		parts = append(parts, fmt.Sprintf("%s: %s", v.Pointer, v.Message))
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+// message describes the violated keyword without the invalid value. The
+// values may come from secrets and violations are shown in the app CR status
+// which can be read by anyone allowed to read the app CR. Only the types of
+// type violations and the names of missing properties are added since they
+// are no values.
+func message(k jsonschema.ErrorKind) string {
+	keyword := strings.Join(k.KeywordPath(), "/")
+
+	switch k := k.(type) {
+	case *kind.Type:
+		return fmt.Sprintf("%s: got %s, want %s", keyword, k.Got, strings.Join(k.Want, " or "))
+	case *kind.Required:
+		return fmt.Sprintf("%s: missing %s", keyword, strings.Join(k.Missing, ", "))
+	}
+
+	return keyword
+}
+
+func deepCopy(values map[string]interface{}) map[string]interface{} {
+	if values == nil {
+		return nil
+	}
+
+	result := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		if m, ok := v.(map[string]interface{}); ok {
+			result[k] = deepCopy(m)
+		} else {
+			result[k] = v
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,19 @@
+package valuesschema
+
+import "context"
+
+// Interface fetches the values schema of charts.
+type Interface interface {
+	// GetSchema returns the values schema of the chart tarball. When
+	// schemaURL is set the schema is fetched from there instead of the
+	// tarball. A nil schema is returned when the chart has no schema.
+	GetSchema(ctx context.Context, tarballURL, schemaURL string) (*Schema, error)
+}
+
+// Violation is a single error found while validating values against a
+// schema.
+type Violation struct {
+	// Pointer is the JSON pointer of the invalid value, e.g. /image/tag.
+	Pointer string
+	Message string
+}
@@ -0,0 +1,30 @@
+package valuessize
+
+import "github.com/giantswarm/microerror"
+
+var invalidConfigError = &microerror.Error{
+	Kind: "invalidConfigError",
+}
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+var tooLargeError = &microerror.Error{
+	Kind: "tooLargeError",
+}
+
+// IsTooLarge asserts tooLargeError.
+func IsTooLarge(err error) bool {
+	return microerror.Cause(err) == tooLargeError
+}
+
+var wrongTypeError = &microerror.Error{
+	Kind: "wrongTypeError",
+}
+
+// IsWrongType asserts wrongTypeError.
+func IsWrongType(err error) bool {
+	return microerror.Cause(err) == wrongTypeError
+}
@@ -0,0 +1,34 @@
+package valuessize
+
+import "github.com/prometheus/client_golang/prometheus"
+
+const (
+	PrometheusNamespace = "app_operator"
+	PrometheusSubsystem = "values"
+)
+
+var (
+	sizeGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: PrometheusNamespace,
+			Subsystem: PrometheusSubsystem,
+			Name:      "size_bytes",
+			Help:      "Serialized size of the configmap or secret generated for the values of an app CR before they are encoded.",
+		},
+		[]string{"app", "namespace", "type"},
+	)
+	nearLimitCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: PrometheusNamespace,
+			Subsystem: PrometheusSubsystem,
+			Name:      "near_limit_total",
+			Help:      "Number of times the configmap or secret generated for the values of an app CR crossed the warning size.",
+		},
+		[]string{"app", "namespace", "type"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(sizeGauge)
+	prometheus.MustRegister(nearLimitCounter)
+}
@@ -0,0 +1,214 @@
+// Package valuessize guards the size of the configmaps and secrets generated
+// for app CRs. Kubernetes rejects objects larger than MaxSize so large
+// objects are reported before they fail at the API server and their values
+// are optionally compressed with the valuesencoding package.
+package valuessize
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/giantswarm/app-operator/v7/pkg/valuesencoding"
+	"github.com/giantswarm/app-operator/v7/service/internal/recorder"
+)
+
+const (
+	// MaxSize is the maximum size of configmaps and secrets.
+	MaxSize = 1024 * 1024
+	// WarningSize is the size at which objects are reported as approaching
+	// MaxSize and their values compressed when compression is enabled.
+	WarningSize = MaxSize * 8 / 10
+
+	// valuesKey is the key of the values in the data of the generated
+	// objects.
+	valuesKey = "values"
+)
+
+type Config struct {
+	Event recorder.Interface
+
+	// Compression enables compressing values of objects larger than
+	// WarningSize.
+	Compression bool
+	// Type is the type of the generated object, i.e. configmap or secret.
+	Type string
+}
+
+type Guard struct {
+	event recorder.Interface
+
+	// nearLimit holds the app CRs whose objects are larger than WarningSize
+	// so they are only reported when the threshold is crossed.
+	nearLimitMutex sync.Mutex
+	nearLimit      map[string]bool
+
+	compression bool
+	objectType  string
+}
+
+func New(config Config) (*Guard, error) {
+	if config.Event == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Event must not be empty", config)
+	}
+	if config.Type == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Type must not be empty", config)
+	}
+
+	g := &Guard{
+		event: config.Event,
+
+		nearLimit: map[string]bool{},
+
+		compression: config.Compression,
+		objectType:  config.Type,
+	}
+
+	return g, nil
+}
+
+// Apply measures the serialized size of the configmap or secret generated
+// for the app CR, including its metadata, and compresses its values when
+// compression is enabled and the object is approaching MaxSize. It returns
+// the encoding of the values which is empty when they are not encoded.
+// Objects approaching MaxSize are only reported when they cross
+// WarningSize.
+func (g *Guard) Apply(ctx context.Context, cr *v1alpha1.App, obj interface{}) (string, error) {
+	size, err := objectSize(obj)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+	sizeGauge.WithLabelValues(cr.Name, cr.Namespace, g.objectType).Set(float64(size))
+
+	if size < WarningSize {
+		g.setNearLimit(cr, false)
+		return "", nil
+	}
+
+	crossed := g.setNearLimit(cr, true)
+	if crossed {
+		nearLimitCounter.WithLabelValues(cr.Name, cr.Namespace, g.objectType).Inc()
+	}
+
+	if !g.compression {
+		if size > MaxSize {
+			return "", microerror.Maskf(tooLargeError, "%s of %d bytes exceeds the limit of %d bytes", g.objectType, size, MaxSize)
+		}
+
+		if crossed {
+			g.event.Warn(ctx, cr, "ValuesSizeNearLimit", "%s of %d bytes is approaching the limit of %d bytes", g.objectType, size, MaxSize)
+		}
+		return "", nil
+	}
+
+	values, err := getValues(obj)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+	encoded, err := valuesencoding.Encode(values)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+	err = setValues(obj, encoded, valuesencoding.Gzip)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	compressedSize, err := objectSize(obj)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+	if compressedSize > MaxSize {
+		return "", microerror.Maskf(tooLargeError, "%s of %d bytes exceeds the limit of %d bytes after its values were compressed to %d bytes", g.objectType, size, MaxSize, compressedSize)
+	}
+
+	if crossed {
+		g.event.Warn(ctx, cr, "ValuesCompressed", "%s of %d bytes is approaching the limit of %d bytes and was compressed to %d bytes", g.objectType, size, MaxSize, compressedSize)
+	}
+
+	return valuesencoding.Gzip, nil
+}
+
+// Delete removes the metrics and state of the deleted app CR.
+func (g *Guard) Delete(cr v1alpha1.App) {
+	sizeGauge.DeleteLabelValues(cr.Name, cr.Namespace, g.objectType)
+	nearLimitCounter.DeleteLabelValues(cr.Name, cr.Namespace, g.objectType)
+
+	g.nearLimitMutex.Lock()
+	defer g.nearLimitMutex.Unlock()
+
+	delete(g.nearLimit, appKey(cr))
+}
+
+// setNearLimit records whether the object of the app CR is approaching
+// MaxSize and returns true when it was not before.
+func (g *Guard) setNearLimit(cr *v1alpha1.App, nearLimit bool) bool {
+	g.nearLimitMutex.Lock()
+	defer g.nearLimitMutex.Unlock()
+
+	k := appKey(*cr)
+	if !nearLimit {
+		delete(g.nearLimit, k)
+		return false
+	}
+
+	crossed := !g.nearLimit[k]
+	g.nearLimit[k] = true
+
+	return crossed
+}
+
+func appKey(cr v1alpha1.App) string {
+	return fmt.Sprintf("%s/%s", cr.Namespace, cr.Name)
+}
+
+// objectSize returns the size of the protobuf serialization of the object
+// which is how it is sent to the API server and stored.
+func objectSize(obj interface{}) (int, error) {
+	switch o := obj.(type) {
+	case *corev1.ConfigMap:
+		return o.Size(), nil
+	case *corev1.Secret:
+		return o.Size(), nil
+	default:
+		return 0, microerror.Maskf(wrongTypeError, "expected %T or %T, got %T", &corev1.ConfigMap{}, &corev1.Secret{}, obj)
+	}
+}
+
+func getValues(obj interface{}) ([]byte, error) {
+	switch o := obj.(type) {
+	case *corev1.ConfigMap:
+		return []byte(o.Data[valuesKey]), nil
+	case *corev1.Secret:
+		return o.Data[valuesKey], nil
+	default:
+		return nil, microerror.Maskf(wrongTypeError, "expected %T or %T, got %T", &corev1.ConfigMap{}, &corev1.Secret{}, obj)
+	}
+}
+
+// setValues replaces the values of the object with the encoded values and
+// records the encoding in its annotations.
+func setValues(obj interface{}, values []byte, encoding string) error {
+	switch o := obj.(type) {
+	case *corev1.ConfigMap:
+		o.Data[valuesKey] = string(values)
+		if o.Annotations == nil {
+			o.Annotations = map[string]string{}
+		}
+		o.Annotations[valuesencoding.Annotation] = encoding
+	case *corev1.Secret:
+		o.Data[valuesKey] = values
+		if o.Annotations == nil {
+			o.Annotations = map[string]string{}
+		}
+		o.Annotations[valuesencoding.Annotation] = encoding
+	default:
+		return microerror.Maskf(wrongTypeError, "expected %T or %T, got %T", &corev1.ConfigMap{}, &corev1.Secret{}, obj)
+	}
+
+	return nil
+}
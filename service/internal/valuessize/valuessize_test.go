@@ -0,0 +1,200 @@
+package valuessize
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"strconv"
+	"testing"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	pkgruntime "k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/giantswarm/app-operator/v7/pkg/valuesencoding"
+)
+
+type eventRecorder struct {
+	warnings []string
+}
+
+func (r *eventRecorder) Emit(ctx context.Context, obj pkgruntime.Object, reason, message string, args ...interface{}) {
+}
+
+func (r *eventRecorder) Warn(ctx context.Context, obj pkgruntime.Object, reason, message string, args ...interface{}) {
+	r.warnings = append(r.warnings, reason)
+}
+
+func Test_Guard_Apply(t *testing.T) {
+	random := make([]byte, MaxSize)
+	_, err := rand.Read(random)
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	tests := []struct {
+		name             string
+		compression      bool
+		values           []byte
+		annotations      map[string]string
+		expectedEncoding string
+		expectedWarnings int
+		errorMatcher     func(error) bool
+	}{
+		{
+			name:   "case 0: small values are not changed",
+			values: []byte("replicas: 1\n"),
+		},
+		{
+			name:             "case 1: objects near the limit are reported",
+			values:           bytes.Repeat([]byte("a"), WarningSize),
+			expectedWarnings: 1,
+		},
+		{
+			name:         "case 2: values over the limit fail",
+			values:       bytes.Repeat([]byte("a"), MaxSize+1),
+			errorMatcher: IsTooLarge,
+		},
+		{
+			name:             "case 3: values over the limit are compressed",
+			compression:      true,
+			values:           bytes.Repeat([]byte("a"), MaxSize+1),
+			expectedEncoding: valuesencoding.Gzip,
+			expectedWarnings: 1,
+		},
+		{
+			name:         "case 4: values over the limit after compression fail",
+			compression:  true,
+			values:       random,
+			errorMatcher: IsTooLarge,
+		},
+		{
+			name:   "case 5: annotations count towards the limit",
+			values: []byte("replicas: 1\n"),
+			annotations: map[string]string{
+				"application.giantswarm.io/values-sources": string(bytes.Repeat([]byte("a"), MaxSize)),
+			},
+			errorMatcher: IsTooLarge,
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			r := &eventRecorder{}
+			g, err := New(Config{
+				Event:       r,
+				Compression: tc.compression,
+				Type:        "configmap",
+			})
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			app := &v1alpha1.App{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "hello-world",
+					Namespace: "org-acme",
+				},
+			}
+
+			configMap := &corev1.ConfigMap{
+				Data: map[string]string{
+					"values": string(tc.values),
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "hello-world-chart-values",
+					Namespace:   "giantswarm",
+					Annotations: tc.annotations,
+				},
+			}
+
+			encoding, err := g.Apply(context.Background(), app, configMap)
+			switch {
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case err != nil && !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+			if tc.errorMatcher != nil {
+				return
+			}
+
+			if encoding != tc.expectedEncoding {
+				t.Fatalf("encoding == %#q, want %#q", encoding, tc.expectedEncoding)
+			}
+			if len(r.warnings) != tc.expectedWarnings {
+				t.Fatalf("warnings == %d, want %d", len(r.warnings), tc.expectedWarnings)
+			}
+
+			if configMap.Annotations[valuesencoding.Annotation] != encoding {
+				t.Fatalf("encoding annotation == %#q, want %#q", configMap.Annotations[valuesencoding.Annotation], encoding)
+			}
+
+			decoded, err := valuesencoding.Decode(configMap.Annotations, []byte(configMap.Data["values"]))
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+			if !bytes.Equal(decoded, tc.values) {
+				t.Fatalf("decoded values do not match")
+			}
+		})
+	}
+}
+
+func Test_Guard_Apply_threshold(t *testing.T) {
+	r := &eventRecorder{}
+	g, err := New(Config{
+		Event: r,
+		Type:  "secret",
+	})
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	app := v1alpha1.App{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "threshold",
+			Namespace: "org-acme",
+		},
+	}
+	apply := func(size int) {
+		secret := &corev1.Secret{
+			Data: map[string][]byte{
+				"values": bytes.Repeat([]byte("a"), size),
+			},
+		}
+
+		_, err := g.Apply(context.Background(), &app, secret)
+		if err != nil {
+			t.Fatalf("error == %#v, want nil", err)
+		}
+	}
+
+	// Reconciling an object above the warning size again is not reported
+	// again.
+	apply(WarningSize)
+	apply(WarningSize)
+	if len(r.warnings) != 1 {
+		t.Fatalf("warnings == %d, want %d", len(r.warnings), 1)
+	}
+
+	// Crossing the warning size again after the object shrank is reported.
+	apply(1)
+	apply(WarningSize)
+	if len(r.warnings) != 2 {
+		t.Fatalf("warnings == %d, want %d", len(r.warnings), 2)
+	}
+	if count := testutil.ToFloat64(nearLimitCounter.WithLabelValues(app.Name, app.Namespace, "secret")); count != 2 {
+		t.Fatalf("near limit count == %v, want %v", count, 2)
+	}
+
+	series := testutil.CollectAndCount(sizeGauge)
+	g.Delete(app)
+	if count := testutil.CollectAndCount(sizeGauge); count != series-1 {
+		t.Fatalf("size series == %d, want %d", count, series-1)
+	}
+}
@@ -0,0 +1,157 @@
+// Package valuesvalidation validates the merged values of app CRs against
+// the values schema of their chart. The configmap and secret resources
+// validate the values before they are written so values violating the
+// schema never reach chart-operator.
+package valuesvalidation
+
+import (
+	"context"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/app/v8/pkg/key"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	"github.com/imdario/mergo"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/app-operator/v7/service/internal/indexcache"
+	"github.com/giantswarm/app-operator/v7/service/internal/tarball"
+	"github.com/giantswarm/app-operator/v7/service/internal/valuesschema"
+)
+
+var (
+	// valuesSchemaAnnotations are the index.yaml entry annotations pointing to
+	// a values schema published next to the chart. When set the schema is
+	// fetched from there instead of the chart tarball.
+	valuesSchemaAnnotations = []string{
+		"io.giantswarm.application.values-schema",
+		"application.giantswarm.io/values-schema",
+	}
+)
+
+type Config struct {
+	IndexCache   indexcache.Interface
+	Logger       micrologger.Logger
+	ValuesSchema valuesschema.Interface
+
+	ChartNamespace    string
+	WorkloadClusterID string
+}
+
+// Validator validates merged values against the values schema of the chart.
+type Validator struct {
+	indexCache   indexcache.Interface
+	logger       micrologger.Logger
+	tarballs     *tarball.Resolver
+	valuesSchema valuesschema.Interface
+
+	workloadClusterID string
+}
+
+func New(config Config) (*Validator, error) {
+	if config.IndexCache == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.IndexCache must not be empty", config)
+	}
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+	if config.ValuesSchema == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.ValuesSchema must not be empty", config)
+	}
+
+	var tarballs *tarball.Resolver
+	{
+		c := tarball.Config{
+			IndexCache: config.IndexCache,
+			Logger:     config.Logger,
+
+			ChartNamespace: config.ChartNamespace,
+		}
+
+		var err error
+		tarballs, err = tarball.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	v := &Validator{
+		indexCache:   config.IndexCache,
+		logger:       config.Logger,
+		tarballs:     tarballs,
+		valuesSchema: config.ValuesSchema,
+
+		workloadClusterID: config.WorkloadClusterID,
+	}
+
+	return v, nil
+}
+
+// Validate validates the configmap and secret values of the app CR merged
+// together against the values schema of its chart. Failing to resolve the
+// tarball or to fetch the schema is not fatal so an unavailable schema never
+// blocks deployments. Tarball errors are reported by the chart resource. The
+// chart CR is read with the given client of the cluster the app is deployed
+// to.
+func (v *Validator) Validate(ctx context.Context, ctrlClient client.Client, cr v1alpha1.App, catalog v1alpha1.Catalog, configMapValues, secretValues map[string]interface{}) ([]valuesschema.Violation, error) {
+	chartTarball, err := v.tarballs.Resolve(ctx, ctrlClient, cr, catalog, key.ChartName(cr, v.workloadClusterID))
+	if err != nil {
+		v.logger.Errorf(ctx, err, "failed to resolve tarball URL to get values schema")
+		return nil, nil
+	}
+
+	schemaURL := v.schemaURL(ctx, cr, catalog, chartTarball)
+
+	schema, err := v.valuesSchema.GetSchema(ctx, chartTarball.URL, schemaURL)
+	if err != nil {
+		v.logger.Errorf(ctx, err, "failed to get values schema for tarball %#q", chartTarball.URL)
+		return nil, nil
+	}
+	if schema == nil {
+		v.logger.Debugf(ctx, "chart has no values schema")
+		return nil, nil
+	}
+
+	values := map[string]interface{}{}
+	for _, vs := range []map[string]interface{}{configMapValues, secretValues} {
+		err = mergo.Merge(&values, vs, mergo.WithOverride)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	violations, err := schema.Validate(values)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return violations, nil
+}
+
+// schemaURL returns the values schema URL annotated on the index.yaml entry
+// of the chart version, or an empty string when there is none.
+func (v *Validator) schemaURL(ctx context.Context, cr v1alpha1.App, catalog v1alpha1.Catalog, chartTarball tarball.Tarball) string {
+	repositoryURL := chartTarball.RepositoryURL
+	if repositoryURL == "" || key.CatalogVisibility(catalog) == "internal" || tarball.IsOCIRepositoryURL(repositoryURL) {
+		return ""
+	}
+
+	index, err := v.indexCache.GetIndex(ctx, repositoryURL)
+	if err != nil || index == nil {
+		return ""
+	}
+
+	for _, e := range index.Entries[cr.Spec.Name] {
+		if e.Version != chartTarball.Version {
+			continue
+		}
+
+		for _, a := range valuesSchemaAnnotations {
+			if u := e.Annotations[a]; u != "" {
+				return u
+			}
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,159 @@
+package valuesvalidation
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/micrologger/microloggertest"
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake" //nolint:staticcheck
+
+	"github.com/giantswarm/app-operator/v7/service/internal/indexcache"
+	"github.com/giantswarm/app-operator/v7/service/internal/indexcache/indexcachetest"
+	"github.com/giantswarm/app-operator/v7/service/internal/valuesschema"
+)
+
+const testSchema = `{
+	"$schema": "http://json-schema.org/schema#",
+	"type": "object",
+	"properties": {
+		"replicas": {"type": "integer", "minimum": 1},
+		"password": {"type": "string"}
+	}
+}`
+
+func Test_Validator_Validate(t *testing.T) {
+	tests := []struct {
+		name               string
+		annotations        map[string]string
+		configMapValues    map[string]interface{}
+		secretValues       map[string]interface{}
+		expectedViolations []valuesschema.Violation
+	}{
+		{
+			name:            "case 0: valid values",
+			configMapValues: map[string]interface{}{"replicas": 2},
+			secretValues:    map[string]interface{}{"password": "secret"},
+		},
+		{
+			name:            "case 1: invalid configmap and secret values",
+			configMapValues: map[string]interface{}{"replicas": 0},
+			secretValues:    map[string]interface{}{"password": 1},
+			expectedViolations: []valuesschema.Violation{
+				{Pointer: "/password", Message: "type: got number, want string"},
+				{Pointer: "/replicas", Message: "minimum"},
+			},
+		},
+		{
+			name:            "case 2: secret values override configmap values",
+			configMapValues: map[string]interface{}{"replicas": 0},
+			secretValues:    map[string]interface{}{"replicas": 1},
+		},
+		{
+			name:            "case 3: chart without values schema",
+			annotations:     map[string]string{},
+			configMapValues: map[string]interface{}{"replicas": 0},
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("/values.schema.json", func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(testSchema))
+			})
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			annotations := tc.annotations
+			if annotations == nil {
+				annotations = map[string]string{
+					"io.giantswarm.application.values-schema": server.URL + "/values.schema.json",
+				}
+			}
+
+			repositoryURL := "https://example.com/catalog"
+			index := &indexcache.Index{
+				Entries: map[string][]indexcache.Entry{
+					"test-app": {
+						{
+							Annotations: annotations,
+							// An OCI tarball is not downloaded so only the
+							// annotated schema is used.
+							Urls:    []string{"oci://example.com/test-app:1.0.0"},
+							Version: "1.0.0",
+						},
+					},
+				},
+			}
+
+			schemas, err := valuesschema.New(valuesschema.Config{
+				Logger: microloggertest.New(),
+
+				HTTPClientTimeout: 5 * time.Second,
+			})
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			c := Config{
+				IndexCache: indexcachetest.NewMap(map[string]indexcachetest.Config{
+					repositoryURL: {GetIndexResponse: index},
+				}),
+				Logger:       microloggertest.New(),
+				ValuesSchema: schemas,
+
+				ChartNamespace: "giantswarm",
+			}
+			v, err := New(c)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			cr := v1alpha1.App{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-app",
+					Namespace: "giantswarm",
+				},
+				Spec: v1alpha1.AppSpec{
+					Catalog: "giantswarm",
+					Name:    "test-app",
+					Version: "1.0.0",
+					KubeConfig: v1alpha1.AppSpecKubeConfig{
+						InCluster: true,
+					},
+				},
+			}
+			catalog := v1alpha1.Catalog{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "giantswarm",
+					Namespace: "default",
+				},
+				Spec: v1alpha1.CatalogSpec{
+					Storage: v1alpha1.CatalogSpecStorage{
+						URL: repositoryURL,
+					},
+				},
+			}
+
+			ctrlClient := fake.NewClientBuilder().WithScheme(runtime.NewScheme()).Build()
+
+			violations, err := v.Validate(context.Background(), ctrlClient, cr, catalog, tc.configMapValues, tc.secretValues)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+			if !cmp.Equal(violations, tc.expectedViolations) {
+				t.Fatalf("want matching violations \n %s", cmp.Diff(violations, tc.expectedViolations))
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+// Package leader runs the controllers and watchers of app-operator only in
+// the replica holding the leader lease so replicas do not duplicate writes.
+package leader
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/giantswarm/k8sclient/v7/pkg/k8sclient"
+	"github.com/giantswarm/microendpoint/service/healthz"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const (
+	// Description describes which functionality this health check implements.
+	Description = "Ensure the replica is the leader."
+	// Name is the identifier of the health check.
+	Name = "leader"
+)
+
+type Config struct {
+	K8sClient k8sclient.Interface
+	Logger    micrologger.Logger
+
+	// Identity is the unique name of the replica, e.g. its pod name.
+	Identity string
+	// LeaseName and LeaseNamespace identify the lease the replicas compete
+	// for.
+	LeaseName      string
+	LeaseNamespace string
+	// LeaseDuration is the time followers wait before taking over a lease
+	// which was not renewed. The leader gives up leadership when it could
+	// not renew the lease within RenewDeadline. Renewals are retried every
+	// RetryPeriod.
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+}
+
+// Elector elects the leader among the replicas of an app-operator instance.
+// It implements the healthz service so only the leader reports ready.
+type Elector struct {
+	k8sClient k8sclient.Interface
+	logger    micrologger.Logger
+
+	// leading is set while the replica holds the lease.
+	leading atomic.Bool
+
+	identity       string
+	leaseName      string
+	leaseNamespace string
+	leaseDuration  time.Duration
+	renewDeadline  time.Duration
+	retryPeriod    time.Duration
+}
+
+func New(config Config) (*Elector, error) {
+	if config.K8sClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.K8sClient must not be empty", config)
+	}
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+
+	if config.Identity == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Identity must not be empty", config)
+	}
+	if config.LeaseName == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.LeaseName must not be empty", config)
+	}
+	if config.LeaseNamespace == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.LeaseNamespace must not be empty", config)
+	}
+	if config.RetryPeriod <= 0 {
+		return nil, microerror.Maskf(invalidConfigError, "%T.RetryPeriod must be greater than zero", config)
+	}
+	if config.RenewDeadline <= config.RetryPeriod {
+		return nil, microerror.Maskf(invalidConfigError, "%T.RenewDeadline must be greater than %T.RetryPeriod", config, config)
+	}
+	if config.LeaseDuration <= config.RenewDeadline {
+		return nil, microerror.Maskf(invalidConfigError, "%T.LeaseDuration must be greater than %T.RenewDeadline", config, config)
+	}
+
+	e := &Elector{
+		k8sClient: config.K8sClient,
+		logger:    config.Logger,
+
+		identity:       config.Identity,
+		leaseName:      config.LeaseName,
+		leaseNamespace: config.LeaseNamespace,
+		leaseDuration:  config.LeaseDuration,
+		renewDeadline:  config.RenewDeadline,
+		retryPeriod:    config.RetryPeriod,
+	}
+
+	return e, nil
+}
+
+// Run waits until the replica is elected and calls run with a context which
+// is canceled when leadership is lost. Run returns once run returned after
+// leadership was lost or the context was canceled. The lease is released
+// when the context is canceled so another replica takes over immediately.
+func (e *Elector) Run(ctx context.Context, run func(ctx context.Context)) error {
+	lock := &acquireLock{
+		Interface: &resourcelock.LeaseLock{
+			LeaseMeta: metav1.ObjectMeta{
+				Name:      e.leaseName,
+				Namespace: e.leaseNamespace,
+			},
+			Client: e.k8sClient.K8sClient().CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{
+				Identity: e.identity,
+			},
+		},
+	}
+
+	done := make(chan struct{})
+	c := leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   e.leaseDuration,
+		RenewDeadline:   e.renewDeadline,
+		RetryPeriod:     e.retryPeriod,
+		ReleaseOnCancel: true,
+		Name:            e.leaseName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				defer close(done)
+
+				e.leading.Store(true)
+				e.logger.Debugf(ctx, "replica %#q is the leader", e.identity)
+
+				run(ctx)
+			},
+			OnStoppedLeading: func() {
+				e.leading.Store(false)
+				e.logger.Debugf(ctx, "replica %#q is no longer the leader", e.identity)
+			},
+			OnNewLeader: func(identity string) {
+				if identity != e.identity {
+					e.logger.Debugf(ctx, "replica %#q is the leader", identity)
+				}
+			},
+		},
+	}
+
+	elector, err := leaderelection.NewLeaderElector(c)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	elector.Run(ctx)
+
+	// The elector only returns before the context is canceled when
+	// leadership was lost. The context of run is canceled by then but run
+	// may still be stopping so we wait for it. run is started by the
+	// elector once the lease was acquired in this call so we also wait for
+	// it when the context was canceled after that.
+	if ctx.Err() == nil || lock.acquired.Load() {
+		<-done
+	}
+
+	return nil
+}
+
+// IsLeader returns whether the replica currently holds the lease.
+func (e *Elector) IsLeader() bool {
+	return e.leading.Load()
+}
+
+// GetHealthz fails unless the replica is the leader so only the leader is
+// ready.
+func (e *Elector) GetHealthz(ctx context.Context) (healthz.Response, error) {
+	r := healthz.Response{
+		Description: Description,
+		Failed:      false,
+		Message:     "Replica is the leader.",
+		Name:        Name,
+	}
+
+	if !e.IsLeader() {
+		r.Failed = true
+		r.Message = "Replica is not the leader."
+	}
+
+	return r, nil
+}
+
+// acquireLock records whether the replica acquired the lease. The elector
+// calls the leader callback asynchronously so Run cannot tell from the
+// callback whether it is about to be called.
+type acquireLock struct {
+	resourcelock.Interface
+
+	acquired atomic.Bool
+}
+
+func (l *acquireLock) Create(ctx context.Context, record resourcelock.LeaderElectionRecord) error {
+	err := l.Interface.Create(ctx, record)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	l.acquired.Store(true)
+
+	return nil
+}
+
+func (l *acquireLock) Update(ctx context.Context, record resourcelock.LeaderElectionRecord) error {
+	err := l.Interface.Update(ctx, record)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	l.acquired.Store(true)
+
+	return nil
+}
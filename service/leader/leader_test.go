@@ -0,0 +1,210 @@
+package leader
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/k8sclient/v7/pkg/k8sclienttest"
+	"github.com/giantswarm/micrologger/microloggertest"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgofake "k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_Elector_Run(t *testing.T) {
+	holder := "app-operator-1"
+	duration := int32(60)
+	now := metav1.NewMicroTime(time.Now())
+
+	tests := []struct {
+		name           string
+		leases         []runtime.Object
+		expectedLeader bool
+	}{
+		{
+			name:           "case 0: replica is elected when no replica holds the lease",
+			expectedLeader: true,
+		},
+		{
+			name: "case 1: replica is a follower while another replica holds the lease",
+			leases: []runtime.Object{
+				&coordinationv1.Lease{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "app-operator",
+						Namespace: "giantswarm",
+					},
+					Spec: coordinationv1.LeaseSpec{
+						HolderIdentity:       &holder,
+						LeaseDurationSeconds: &duration,
+						AcquireTime:          &now,
+						RenewTime:            &now,
+					},
+				},
+			},
+			expectedLeader: false,
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			clients := k8sclienttest.NewClients(k8sclienttest.ClientsConfig{
+				K8sClient: clientgofake.NewClientset(tc.leases...),
+			})
+
+			c := Config{
+				K8sClient: clients,
+				Logger:    microloggertest.New(),
+
+				Identity:       "app-operator-0",
+				LeaseName:      "app-operator",
+				LeaseNamespace: "giantswarm",
+				LeaseDuration:  3 * time.Second,
+				RenewDeadline:  2 * time.Second,
+				RetryPeriod:    100 * time.Millisecond,
+			}
+			e, err := New(c)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			started := make(chan struct{})
+			stopped := make(chan struct{})
+			run := func(ctx context.Context) {
+				close(started)
+				<-ctx.Done()
+				close(stopped)
+			}
+
+			errCh := make(chan error)
+			go func() {
+				errCh <- e.Run(ctx, run)
+			}()
+
+			var leader bool
+			select {
+			case <-started:
+				leader = true
+			case <-ctx.Done():
+			}
+
+			if leader != tc.expectedLeader {
+				t.Fatalf("leader == %t, want %t", leader, tc.expectedLeader)
+			}
+
+			res, err := e.GetHealthz(ctx)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+			if res.Failed == tc.expectedLeader {
+				t.Fatalf("healthz failed == %t, want %t", res.Failed, !tc.expectedLeader)
+			}
+
+			cancel()
+			err = <-errCh
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			// run is stopped before Run returns.
+			if leader {
+				select {
+				case <-stopped:
+				default:
+					t.Fatalf("run did not stop before Run returned")
+				}
+			}
+		})
+	}
+}
+
+func Test_Elector_Run_again(t *testing.T) {
+	k8sClient := clientgofake.NewClientset()
+	clients := k8sclienttest.NewClients(k8sclienttest.ClientsConfig{
+		K8sClient: k8sClient,
+	})
+
+	c := Config{
+		K8sClient: clients,
+		Logger:    microloggertest.New(),
+
+		Identity:       "app-operator-0",
+		LeaseName:      "app-operator",
+		LeaseNamespace: "giantswarm",
+		LeaseDuration:  3 * time.Second,
+		RenewDeadline:  2 * time.Second,
+		RetryPeriod:    100 * time.Millisecond,
+	}
+	e, err := New(c)
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	// The replica leads in the first call.
+	{
+		ctx, cancel := context.WithCancel(context.Background())
+
+		run := func(ctx context.Context) {
+			cancel()
+			<-ctx.Done()
+		}
+
+		err = e.Run(ctx, run)
+		if err != nil {
+			t.Fatalf("error == %#v, want nil", err)
+		}
+	}
+
+	// Another replica takes over the released lease.
+	{
+		lease, err := k8sClient.CoordinationV1().Leases("giantswarm").Get(context.Background(), "app-operator", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("error == %#v, want nil", err)
+		}
+
+		holder := "app-operator-1"
+		duration := int32(60)
+		now := metav1.NewMicroTime(time.Now())
+		lease.Spec.HolderIdentity = &holder
+		lease.Spec.LeaseDurationSeconds = &duration
+		lease.Spec.AcquireTime = &now
+		lease.Spec.RenewTime = &now
+
+		_, err = k8sClient.CoordinationV1().Leases("giantswarm").Update(context.Background(), lease, metav1.UpdateOptions{})
+		if err != nil {
+			t.Fatalf("error == %#v, want nil", err)
+		}
+	}
+
+	// The second call returns when its context is canceled before the
+	// replica becomes the leader.
+	{
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+
+		run := func(ctx context.Context) {
+			t.Errorf("replica became the leader while another replica holds the lease")
+		}
+
+		errCh := make(chan error)
+		go func() {
+			errCh <- e.Run(ctx, run)
+		}()
+
+		select {
+		case err := <-errCh:
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Run did not return after its context was canceled")
+		}
+	}
+}
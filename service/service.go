@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"fmt"
+	"os"
 	"sync"
 
 	"github.com/giantswarm/k8sclient/v7/pkg/k8sclient"
@@ -19,9 +20,17 @@ import (
 	"github.com/giantswarm/app-operator/v7/pkg/project"
 	"github.com/giantswarm/app-operator/v7/service/controller/app"
 	"github.com/giantswarm/app-operator/v7/service/controller/catalog"
+	"github.com/giantswarm/app-operator/v7/service/controller/rollout"
+	"github.com/giantswarm/app-operator/v7/service/dryrun"
 	"github.com/giantswarm/app-operator/v7/service/internal/clientcache"
 	"github.com/giantswarm/app-operator/v7/service/internal/indexcache"
 	"github.com/giantswarm/app-operator/v7/service/internal/recorder"
+	"github.com/giantswarm/app-operator/v7/service/internal/secretstore"
+	"github.com/giantswarm/app-operator/v7/service/internal/secretstore/file"
+	"github.com/giantswarm/app-operator/v7/service/internal/secretstore/vault"
+	"github.com/giantswarm/app-operator/v7/service/internal/shard"
+	"github.com/giantswarm/app-operator/v7/service/internal/valuesschema"
+	"github.com/giantswarm/app-operator/v7/service/leader"
 	"github.com/giantswarm/app-operator/v7/service/watcher/appvalue"
 	"github.com/giantswarm/app-operator/v7/service/watcher/chartstatus"
 )
@@ -37,19 +46,48 @@ type Config struct {
 
 // Service is a type providing implementation of microkit service interface.
 type Service struct {
+	// DryRun is nil unless enabled via the debug values flag.
+	DryRun *dryrun.Service
+	// Leader is nil unless leader election is enabled.
+	Leader  *leader.Elector
 	Version *version.Service
 
 	// Internals
-	appController      *app.App
-	catalogController  *catalog.Catalog
-	appValueWatcher    *appvalue.AppValueWatcher
-	chartStatusWatcher *chartstatus.ChartStatusWatcher
-	bootOnce           sync.Once
+	logger             micrologger.Logger
+	appController      controller
+	catalogController  controller
+	rolloutController  controller
+	appValueWatcher    watcher
+	chartStatusWatcher watcher
+	// sharder is nil unless app CRs are sharded across replicas.
+	sharder  *shard.Sharder
+	bootOnce sync.Once
+	// shutdown is closed by Shutdown to stop the controllers and watchers.
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
+	// stopped is closed once the controllers and watchers returned.
+	stopped chan struct{}
 
 	// Settings
 	unique bool
 }
 
+// controller is implemented by the operatorkit controllers. Boot blocks
+// until the context is canceled and in-flight reconciliations finished.
+// Booted is closed once the controller started its manager.
+type controller interface {
+	Boot(ctx context.Context)
+	Booted() chan struct{}
+	Stop(ctx context.Context)
+}
+
+// watcher is implemented by the app value and chart status watchers. The
+// chart status of all clusters of app CRs is watched by a manager. Boot
+// blocks until the context is canceled and the watch loops returned.
+type watcher interface {
+	Boot(ctx context.Context)
+}
+
 // New creates a new service with given configuration.
 func New(config Config) (*Service, error) {
 	if config.Logger == nil {
@@ -66,6 +104,12 @@ func New(config Config) (*Service, error) {
 		return nil, microerror.Maskf(invalidConfigError, "%T.Viper must not be empty", config)
 	}
 
+	// Only the leader runs when leader election is enabled so app CRs
+	// cannot be sharded across replicas.
+	if config.Viper.GetBool(config.Flag.Service.LeaderElection.Enabled) && config.Viper.GetBool(config.Flag.Service.Shard.Enabled) {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Flag.Service.LeaderElection.Enabled and %T.Flag.Service.Shard.Enabled must not both be set", config, config)
+	}
+
 	// Configure controller-runtime logger
 	opts := zap.Options{
 		Development: true,
@@ -90,6 +134,21 @@ func New(config Config) (*Service, error) {
 		}
 	}
 
+	var rolloutController *rollout.Rollout
+	{
+		c := rollout.Config{
+			K8sClient: config.K8sClient,
+			Logger:    config.Logger,
+
+			ResyncPeriod: config.Viper.GetDuration(config.Flag.Service.Operatorkit.ResyncPeriod),
+		}
+
+		rolloutController, err = rollout.NewRollout(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
 	fs := afero.NewOsFs()
 	podNamespace := env.PodNamespace()
 
@@ -100,8 +159,17 @@ func New(config Config) (*Service, error) {
 			K8sClient: config.K8sClient,
 			Logger:    config.Logger,
 
-			HTTPClientTimeout: config.Viper.GetDuration(config.Flag.Service.Helm.HTTP.ClientTimeout),
-			DisableCache:      config.Viper.GetBool(config.Flag.Service.Kubernetes.DisableClientCache),
+			HTTPClientTimeout:      config.Viper.GetDuration(config.Flag.Service.Helm.HTTP.ClientTimeout),
+			DisableCache:           config.Viper.GetBool(config.Flag.Service.Kubernetes.DisableClientCache),
+			CircuitBreakerCooldown: config.Viper.GetDuration(config.Flag.Service.Kubernetes.ClientCache.CircuitBreakerCooldown),
+			FailureThreshold:       config.Viper.GetInt(config.Flag.Service.Kubernetes.ClientCache.FailureThreshold),
+			MaxSize:                config.Viper.GetInt(config.Flag.Service.Kubernetes.ClientCache.MaxSize),
+			DefaultSource:          config.Viper.GetString(config.Flag.Service.Kubernetes.KubeConfigSource.Default),
+			ExecArgs:               config.Viper.GetStringSlice(config.Flag.Service.Kubernetes.KubeConfigSource.ExecArgs),
+			ExecCommand:            config.Viper.GetString(config.Flag.Service.Kubernetes.KubeConfigSource.ExecCommand),
+			KubeConfigFile:         config.Viper.GetString(config.Flag.Service.Kubernetes.KubeConfigSource.File),
+			TokenFile:              config.Viper.GetString(config.Flag.Service.Kubernetes.KubeConfigSource.TokenFile),
+			TrustedNamespaces:      config.Viper.GetStringSlice(config.Flag.Service.Kubernetes.KubeConfigSource.TrustedNamespaces),
 		}
 
 		clientCache, err = clientcache.New(c)
@@ -124,14 +192,142 @@ func New(config Config) (*Service, error) {
 		}
 	}
 
+	// valuesSchema is nil when validation is disabled so the chart resource
+	// skips it.
+	var valuesSchema valuesschema.Interface
+	if config.Viper.GetBool(config.Flag.Service.App.ValuesSchemaValidation) {
+		c := valuesschema.Config{
+			Logger: config.Logger,
+
+			HTTPClientTimeout: config.Viper.GetDuration(config.Flag.Service.Helm.HTTP.ClientTimeout),
+		}
+
+		valuesSchema, err = valuesschema.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	secretStoreProviders := map[string]secretstore.Provider{}
+	secretStorePathPrefixes := map[string]string{}
+	if root := config.Viper.GetString(config.Flag.Service.SecretStore.File.Root); root != "" {
+		c := file.Config{
+			Fs:   fs,
+			Root: root,
+		}
+
+		secretStoreProviders[file.Name], err = file.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+	if address := config.Viper.GetString(config.Flag.Service.SecretStore.Vault.Address); address != "" {
+		c := vault.Config{
+			Fs: fs,
+
+			Address:           address,
+			TokenFile:         config.Viper.GetString(config.Flag.Service.SecretStore.Vault.TokenFile),
+			HTTPClientTimeout: config.Viper.GetDuration(config.Flag.Service.Helm.HTTP.ClientTimeout),
+		}
+
+		secretStoreProviders[vault.Name], err = vault.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		secretStorePathPrefixes[vault.Name] = config.Viper.GetString(config.Flag.Service.SecretStore.Vault.PathPrefix)
+	}
+
+	// secretStore is nil when no provider is configured so secret store
+	// value references fail to resolve.
+	var secretStore secretstore.Interface
+	if len(secretStoreProviders) > 0 {
+		c := secretstore.Config{
+			Logger:    config.Logger,
+			Providers: secretStoreProviders,
+
+			TTL: config.Viper.GetDuration(config.Flag.Service.SecretStore.TTL),
+		}
+
+		secretStore, err = secretstore.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	// The hostname of the pod is its name. It identifies the replica in
+	// leader election and sharding.
+	identity, err := os.Hostname()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	instance := instanceName(config.Viper.GetString(config.Flag.Service.App.WorkloadClusterID))
+
+	var leaderElector *leader.Elector
+	if config.Viper.GetBool(config.Flag.Service.LeaderElection.Enabled) {
+		c := leader.Config{
+			K8sClient: config.K8sClient,
+			Logger:    config.Logger,
+
+			Identity:       identity,
+			LeaseName:      instance,
+			LeaseNamespace: podNamespace,
+			LeaseDuration:  config.Viper.GetDuration(config.Flag.Service.LeaderElection.LeaseDuration),
+			RenewDeadline:  config.Viper.GetDuration(config.Flag.Service.LeaderElection.RenewDeadline),
+			RetryPeriod:    config.Viper.GetDuration(config.Flag.Service.LeaderElection.RetryPeriod),
+		}
+
+		leaderElector, err = leader.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	// sharder is nil unless app CRs are sharded across replicas. shards is
+	// only set when it is so a nil sharder is not passed as a non-nil
+	// interface.
+	var sharder *shard.Sharder
+	var shards shard.Interface
+	if config.Viper.GetBool(config.Flag.Service.Shard.Enabled) {
+		c := shard.Config{
+			K8sClient: config.K8sClient,
+			Logger:    config.Logger,
+
+			Group:         instance,
+			Identity:      identity,
+			LeaseDuration: config.Viper.GetDuration(config.Flag.Service.Shard.LeaseDuration),
+			Namespace:     podNamespace,
+		}
+
+		sharder, err = shard.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		shards = sharder
+	}
+
+	var event recorder.Interface
+	{
+		c := recorder.Config{
+			K8sClient: config.K8sClient,
+
+			Component: fmt.Sprintf("%s-%s", project.Name(), project.Version()),
+		}
+
+		event = recorder.New(c)
+	}
+
 	var appController *app.App
 	{
 		c := app.Config{
-			ClientCache: clientCache,
-			Fs:          fs,
-			IndexCache:  indexCache,
-			Logger:      config.Logger,
-			K8sClient:   config.K8sClient,
+			ClientCache:  clientCache,
+			Event:        event,
+			Fs:           fs,
+			IndexCache:   indexCache,
+			ValuesSchema: valuesSchema,
+			SecretStore:  secretStore,
+			Sharder:      shards,
+			Logger:       config.Logger,
+			K8sClient:    config.K8sClient,
 
 			ChartNamespace:               config.Viper.GetString(config.Flag.Service.Chart.Namespace),
 			HTTPClientTimeout:            config.Viper.GetDuration(config.Flag.Service.Helm.HTTP.ClientTimeout),
@@ -143,6 +339,14 @@ func New(config Config) (*Service, error) {
 			WatchNamespace:               config.Viper.GetString(config.Flag.Service.App.WatchNamespace),
 			WorkloadClusterID:            config.Viper.GetString(config.Flag.Service.App.WorkloadClusterID),
 			DependencyWaitTimeoutMinutes: config.Viper.GetInt(config.Flag.Service.App.DependencyWaitTimeoutMinutes),
+			ImmutableValues:              config.Viper.GetBool(config.Flag.Service.App.ImmutableValues),
+			ValuesRetention:              config.Viper.GetInt(config.Flag.Service.App.ValuesRetention),
+			ValuesCompression:            config.Viper.GetBool(config.Flag.Service.App.ValuesCompression),
+			MaintenanceWindowSchedule:    config.Viper.GetString(config.Flag.Service.App.MaintenanceWindowSchedule),
+			MaintenanceWindowDuration:    config.Viper.GetString(config.Flag.Service.App.MaintenanceWindowDuration),
+			MaintenanceWindowTimezone:    config.Viper.GetString(config.Flag.Service.App.MaintenanceWindowTimezone),
+			ValueRefNamespaces:           config.Viper.GetStringSlice(config.Flag.Service.ValueRef.Namespaces),
+			SecretStorePathPrefixes:      secretStorePathPrefixes,
 		}
 
 		appController, err = app.NewApp(c)
@@ -151,42 +355,64 @@ func New(config Config) (*Service, error) {
 		}
 	}
 
-	var event recorder.Interface
+	var appValueWatcher *appvalue.AppValueWatcher
 	{
-		c := recorder.Config{
-			K8sClient: config.K8sClient,
-
-			Component: fmt.Sprintf("%s-%s", project.Name(), project.Version()),
+		c := appvalue.AppValueWatcherConfig{
+			Event:       event,
+			K8sClient:   config.K8sClient,
+			Logger:      config.Logger,
+			SecretStore: secretStore,
+			Sharder:     shards,
+
+			ChartNamespace:             config.Viper.GetString(config.Flag.Service.Chart.Namespace),
+			ResyncPeriod:               config.Viper.GetDuration(config.Flag.Service.Operatorkit.ResyncPeriod),
+			SecretNamespace:            podNamespace,
+			SecretNamespaces:           config.Viper.GetStringSlice(config.Flag.Service.SecretWatch.Namespaces),
+			SecretNamespaceSelector:    config.Viper.GetString(config.Flag.Service.SecretWatch.NamespaceSelector),
+			SecretStorePathPrefixes:    secretStorePathPrefixes,
+			SecretStoreRefreshInterval: config.Viper.GetDuration(config.Flag.Service.SecretStore.RefreshInterval),
+			TriggerDebounce:            config.Viper.GetDuration(config.Flag.Service.App.ValuesTriggerDebounce),
+			TriggerRateLimit:           config.Viper.GetFloat64(config.Flag.Service.App.ValuesTriggerRateLimit),
+			TriggerBurst:               config.Viper.GetInt(config.Flag.Service.App.ValuesTriggerBurst),
+			UniqueApp:                  config.Viper.GetBool(config.Flag.Service.App.Unique),
+			WatchMode:                  config.Viper.GetString(config.Flag.Service.App.ValuesWatchMode),
+			WorkloadClusterID:          config.Viper.GetString(config.Flag.Service.App.WorkloadClusterID),
 		}
 
-		event = recorder.New(c)
+		appValueWatcher, err = appvalue.NewAppValueWatcher(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
 	}
 
-	var appValueWatcher *appvalue.AppValueWatcher
-	{
-		c := appvalue.AppValueWatcherConfig{
-			Event:     event,
-			K8sClient: config.K8sClient,
-			Logger:    config.Logger,
+	var chartStatusWatcher watcher
+	if config.Viper.GetBool(config.Flag.Service.App.MultiClusterChartStatus) {
+		c := chartstatus.ManagerConfig{
+			ClientCache: clientCache,
+			Event:       event,
+			K8sClient:   config.K8sClient,
+			Logger:      config.Logger,
+			Sharder:     shards,
 
-			SecretNamespace:   podNamespace,
+			ChartNamespace:    config.Viper.GetString(config.Flag.Service.Chart.Namespace),
+			DriftReconcile:    config.Viper.GetBool(config.Flag.Service.App.DriftReconcile),
 			UniqueApp:         config.Viper.GetBool(config.Flag.Service.App.Unique),
 			WorkloadClusterID: config.Viper.GetString(config.Flag.Service.App.WorkloadClusterID),
 		}
 
-		appValueWatcher, err = appvalue.NewAppValueWatcher(c)
+		chartStatusWatcher, err = chartstatus.NewManager(c)
 		if err != nil {
 			return nil, microerror.Mask(err)
 		}
-	}
-
-	var chartStatusWatcher *chartstatus.ChartStatusWatcher
-	{
+	} else {
 		c := chartstatus.ChartStatusWatcherConfig{
+			Event:     event,
 			K8sClient: config.K8sClient,
 			Logger:    config.Logger,
+			Sharder:   shards,
 
 			ChartNamespace:    config.Viper.GetString(config.Flag.Service.Chart.Namespace),
+			DriftReconcile:    config.Viper.GetBool(config.Flag.Service.App.DriftReconcile),
 			PodNamespace:      podNamespace,
 			UniqueApp:         config.Viper.GetBool(config.Flag.Service.App.Unique),
 			WorkloadClusterID: config.Viper.GetString(config.Flag.Service.App.WorkloadClusterID),
@@ -214,14 +440,48 @@ func New(config Config) (*Service, error) {
 		}
 	}
 
+	var dryRunService *dryrun.Service
+	if config.Viper.GetBool(config.Flag.Service.Debug.Values) {
+		c := dryrun.Config{
+			ClientCache: clientCache,
+			K8sClient:   config.K8sClient,
+			Logger:      config.Logger,
+
+			ChartNamespace:     config.Viper.GetString(config.Flag.Service.Chart.Namespace),
+			Provider:           config.Viper.GetString(config.Flag.Service.Provider.Kind),
+			ValueRefNamespaces: config.Viper.GetStringSlice(config.Flag.Service.ValueRef.Namespaces),
+
+			SecretStorePathPrefixes: secretStorePathPrefixes,
+		}
+		// The store is only set when configured so a nil store is not
+		// passed as a non-nil interface.
+		if secretStore != nil {
+			c.SecretStore = secretStore
+		}
+
+		dryRunService, err = dryrun.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
 	newService := &Service{
+		DryRun:  dryRunService,
+		Leader:  leaderElector,
 		Version: versionService,
 
+		logger: config.Logger,
+
 		appController:      appController,
 		catalogController:  catalogController,
+		rolloutController:  rolloutController,
 		appValueWatcher:    appValueWatcher,
 		chartStatusWatcher: chartStatusWatcher,
+		sharder:            sharder,
 		bootOnce:           sync.Once{},
+		shutdown:           make(chan struct{}),
+		shutdownOnce:       sync.Once{},
+		stopped:            make(chan struct{}),
 
 		unique: config.Viper.GetBool(config.Flag.Service.App.Unique),
 	}
@@ -229,19 +489,141 @@ func New(config Config) (*Service, error) {
 	return newService, nil
 }
 
-// Boot starts top level service implementation.
+// Boot starts top level service implementation. It blocks until the
+// context is canceled or Shutdown is called and the controllers and watchers
+// stopped. When leader election is enabled the controllers and watchers only
+// run while the replica is the leader. Controllers cannot be booted again so
+// the replica exits when it loses leadership and restarts as a follower.
 func (s *Service) Boot(ctx context.Context) {
 	s.bootOnce.Do(func() {
-		// Boot appCatalogController only if it's unique app.
-		if s.unique {
-			go s.catalogController.Boot(ctx)
+		defer close(s.stopped)
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		go func() {
+			select {
+			case <-s.shutdown:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		if s.Leader == nil {
+			s.boot(ctx)
+			return
 		}
 
-		// Start the controller.
-		go s.appController.Boot(ctx)
+		err := s.Leader.Run(ctx, s.boot)
+		if err != nil {
+			s.logger.Errorf(ctx, err, "failed to run leader election")
+			os.Exit(1)
+		}
 
-		// Start the watchers.
-		go s.appValueWatcher.Boot(ctx)
-		go s.chartStatusWatcher.Boot(ctx)
+		if ctx.Err() == nil {
+			s.logger.Debugf(ctx, "stopped controllers and watchers after leadership was lost, exiting")
+			os.Exit(1)
+		}
 	})
 }
+
+// Shutdown stops the controllers and watchers and waits until in-flight
+// reconciliations finished or the context is canceled.
+func (s *Service) Shutdown(ctx context.Context) error {
+	s.shutdownOnce.Do(func() {
+		close(s.shutdown)
+	})
+
+	// A service which was not booted has nothing to stop.
+	s.bootOnce.Do(func() {
+		close(s.stopped)
+	})
+
+	select {
+	case <-s.stopped:
+		return nil
+	case <-ctx.Done():
+		return microerror.Maskf(timeoutError, "controllers and watchers did not stop: %s", ctx.Err())
+	}
+}
+
+// boot runs the controllers and watchers until the context is canceled and
+// all of them returned.
+func (s *Service) boot(ctx context.Context) {
+	// The replica joins the shard group before the controllers start so
+	// app CRs are assigned to replicas. It leaves the group once the
+	// controllers stopped so no app CR is reconciled by two replicas.
+	var shards sync.WaitGroup
+	if s.sharder != nil {
+		s.sharder.Join(ctx)
+
+		shardCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		defer shards.Wait()
+		defer cancel()
+
+		shards.Go(func() {
+			s.sharder.Boot(shardCtx)
+		})
+	}
+
+	var wg sync.WaitGroup
+
+	// Operatorkit replaces the global Kubernetes error handlers while a
+	// controller boots so the controllers boot one after another.
+	boot := func(c controller) {
+		wg.Go(func() {
+			c.Boot(ctx)
+		})
+
+		select {
+		case <-c.Booted():
+		case <-ctx.Done():
+		}
+	}
+
+	// Boot appCatalogController and rolloutController only if it's
+	// unique app.
+	if s.unique {
+		boot(s.catalogController)
+		boot(s.rolloutController)
+	}
+
+	// Start the controller.
+	boot(s.appController)
+
+	// Start the watchers.
+	wg.Go(func() {
+		s.appValueWatcher.Boot(ctx)
+	})
+	wg.Go(func() {
+		s.chartStatusWatcher.Boot(ctx)
+	})
+
+	wg.Wait()
+	s.stop()
+}
+
+// stop stops the metrics collectors of the controllers once they returned.
+func (s *Service) stop() {
+	ctx := context.Background()
+
+	if s.unique {
+		s.catalogController.Stop(ctx)
+		s.rolloutController.Stop(ctx)
+	}
+
+	s.appController.Stop(ctx)
+}
+
+// instanceName returns the name of the leader lease and the shard group of
+// the replicas of an app-operator instance. Instances of other versions or
+// workload clusters reconcile other app CRs so their replicas do not
+// compete.
+func instanceName(workloadClusterID string) string {
+	group := fmt.Sprintf("%s-%s", project.Name(), project.Version())
+	if workloadClusterID != "" {
+		group = fmt.Sprintf("%s-%s", group, workloadClusterID)
+	}
+
+	return group
+}
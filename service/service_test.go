@@ -0,0 +1,454 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/k8sclient/v7/pkg/k8sclienttest"
+	"github.com/giantswarm/k8smetadata/pkg/label"
+	"github.com/giantswarm/micrologger/microloggertest"
+	"github.com/spf13/viper"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clientgofake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	restfake "k8s.io/client-go/rest/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/giantswarm/app-operator/v7/flag"
+	rolloutv1alpha1 "github.com/giantswarm/app-operator/v7/pkg/apis/rollout/v1alpha1"
+	"github.com/giantswarm/app-operator/v7/pkg/env"
+	"github.com/giantswarm/app-operator/v7/pkg/project"
+)
+
+func Test_Service_Shutdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]interface{}
+		boot     bool
+	}{
+		{
+			name: "case 0: controllers and watchers of a unique app-operator stop",
+			settings: map[string]interface{}{
+				"service.app.unique": true,
+			},
+			boot: true,
+		},
+		{
+			name: "case 1: chart status watch waiting for the kubeconfig stops",
+			settings: map[string]interface{}{
+				"service.app.watchnamespace":    "org-acme",
+				"service.app.workloadclusterid": "abc12",
+			},
+			boot: true,
+		},
+		{
+			name: "case 2: watches of the manager and the shard lease renewal stop",
+			settings: map[string]interface{}{
+				"service.app.multiclusterchartstatus": true,
+				"service.app.unique":                  true,
+				"service.shard.enabled":               true,
+			},
+			boot: true,
+		},
+		{
+			name: "case 3: service which was not booted stops",
+			settings: map[string]interface{}{
+				"service.app.unique": true,
+			},
+			boot: false,
+		},
+	}
+
+	t.Setenv(env.EnvVarPodNamespace, "giantswarm")
+
+	for i, tc := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			s, k8sClient := newTestService(t, tc.settings, nil)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			booted := make(chan struct{})
+			if tc.boot {
+				go func() {
+					defer close(booted)
+					s.Boot(ctx)
+				}()
+
+				// The replica joined the shard group before the controllers
+				// booted. The watchers run once configmaps are watched.
+				waitFor(t, func() bool {
+					return isBooted(s.appController) && isWatching(k8sClient)
+				})
+			} else {
+				close(booted)
+			}
+
+			shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer shutdownCancel()
+
+			err := s.Shutdown(shutdownCtx)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			<-booted
+
+			// The replica left the shard group.
+			leases, err := k8sClient.CoordinationV1().Leases("giantswarm").List(ctx, metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+			if len(leases.Items) != 0 {
+				t.Fatalf("len(leases.Items) == %d, want 0", len(leases.Items))
+			}
+
+			// All goroutines started by the service return. Operatorkit
+			// leaves goroutines of its own running after the controllers
+			// stopped so only goroutines of app-operator are checked.
+			waitFor(t, func() bool {
+				return !isRunning("github.com/giantswarm/app-operator/v7/service/")
+			})
+		})
+	}
+}
+
+func Test_Service_Shutdown_reconcile(t *testing.T) {
+	tests := []struct {
+		name string
+		// getApp is called when the in-flight reconciliation gets the app
+		// CR. It returns once the reconciliation completed or was canceled.
+		getApp            func(ctx context.Context) error
+		expectedCompleted bool
+	}{
+		{
+			name: "case 0: in-flight reconciliation completes",
+			getApp: func(ctx context.Context) error {
+				time.Sleep(500 * time.Millisecond)
+				return nil
+			},
+			expectedCompleted: true,
+		},
+		{
+			name: "case 1: in-flight reconciliation is canceled",
+			getApp: func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+			expectedCompleted: false,
+		},
+	}
+
+	t.Setenv(env.EnvVarPodNamespace, "giantswarm")
+
+	for i, tc := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			reconciling := make(chan struct{})
+			reconciled := make(chan error, 1)
+			getApp := func(ctx context.Context) error {
+				close(reconciling)
+				err := tc.getApp(ctx)
+				reconciled <- err
+				return err
+			}
+
+			s, _ := newTestService(t, map[string]interface{}{"service.app.unique": true}, getApp)
+
+			booted := make(chan struct{})
+			go func() {
+				defer close(booted)
+				s.Boot(context.Background())
+			}()
+
+			select {
+			case <-reconciling:
+			case <-time.After(30 * time.Second):
+				t.Fatalf("app CR was not reconciled within 30s")
+			}
+
+			timeout := 10 * time.Second
+			shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), timeout)
+			defer shutdownCancel()
+
+			start := time.Now()
+			err := s.Shutdown(shutdownCtx)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+			if time.Since(start) > timeout {
+				t.Fatalf("shutdown took %s, want less than %s", time.Since(start), timeout)
+			}
+
+			<-booted
+
+			// The reconciliation returned before Shutdown returned.
+			select {
+			case err := <-reconciled:
+				if completed := err == nil; completed != tc.expectedCompleted {
+					t.Fatalf("completed == %t, want %t", completed, tc.expectedCompleted)
+				}
+			default:
+				t.Fatalf("in-flight reconciliation did not return before shutdown")
+			}
+		})
+	}
+}
+
+// isBooted returns whether the operatorkit controller booted.
+func isBooted(c controller) bool {
+	select {
+	case <-c.Booted():
+		return true
+	default:
+		return false
+	}
+}
+
+// isRunning returns whether any goroutine runs a function of the packages
+// with the given prefix.
+func isRunning(prefix string) bool {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return strings.Contains(string(buf[:n]), prefix)
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// newTestService creates a service whose controllers watch a fake API server
+// serving the app CR test-app. The app CR is reconciled with getApp called
+// when it is read. When getApp is nil the app CR is not found.
+func newTestService(t *testing.T, settings map[string]interface{}, getApp func(ctx context.Context) error) (*Service, *clientgofake.Clientset) {
+	s := k8sruntime.NewScheme()
+	err := v1alpha1.AddToScheme(s)
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	// The managers of the controllers use the global scheme.
+	err = v1alpha1.AddToScheme(scheme.Scheme)
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+	err = rolloutv1alpha1.AddToScheme(scheme.Scheme)
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	apiServer := newTestAPIServer(t)
+
+	ctrlClient := fake.NewClientBuilder().WithScheme(s).WithInterceptorFuncs(interceptor.Funcs{
+		Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			if _, ok := obj.(*v1alpha1.App); !ok {
+				return c.Get(ctx, key, obj, opts...)
+			}
+			if getApp == nil {
+				return apierrors.NewNotFound(v1alpha1.SchemeGroupVersion.WithResource("apps").GroupResource(), key.Name)
+			}
+
+			err := getApp(ctx)
+			if err != nil {
+				return err
+			}
+
+			// The reconciliation ends here since the app CR is deleted.
+			return apierrors.NewNotFound(v1alpha1.SchemeGroupVersion.WithResource("apps").GroupResource(), key.Name)
+		},
+	}).Build()
+
+	k8sClient := clientgofake.NewSimpleClientset()
+	clients := k8sclienttest.NewClients(k8sclienttest.ClientsConfig{
+		CtrlClient: ctrlClient,
+		DynClient: dynamicfake.NewSimpleDynamicClientWithCustomListKinds(k8sruntime.NewScheme(), map[schema.GroupVersionResource]string{
+			{Group: "application.giantswarm.io", Version: "v1alpha1", Resource: "apps"}:     "AppList",
+			{Group: "application.giantswarm.io", Version: "v1alpha1", Resource: "catalogs"}: "CatalogList",
+			{Group: "application.giantswarm.io", Version: "v1alpha1", Resource: "charts"}:   "ChartList",
+		}),
+		K8sClient:  k8sClient,
+		RestClient: &restfake.RESTClient{},
+		RestConfig: &rest.Config{Host: apiServer.URL},
+	})
+
+	f := flag.New()
+	v := viper.New()
+	v.Set(f.Service.App.DependencyWaitTimeoutMinutes, 30)
+	v.Set(f.Service.App.ValuesTriggerBurst, 20)
+	v.Set(f.Service.App.ValuesWatchMode, "label")
+	v.Set(f.Service.Chart.Namespace, "giantswarm")
+	v.Set(f.Service.Helm.HTTP.ClientTimeout, "5s")
+	v.Set(f.Service.Image.Registry, "gsoci.azurecr.io")
+	v.Set(f.Service.Kubernetes.ClientCache.CircuitBreakerCooldown, "1m")
+	v.Set(f.Service.Kubernetes.ClientCache.FailureThreshold, 5)
+	v.Set(f.Service.Kubernetes.ClientCache.MaxSize, 100)
+	v.Set(f.Service.Kubernetes.KubeConfigSource.Default, "secret")
+	v.Set(f.Service.Operatorkit.ResyncPeriod, "5m")
+	v.Set(f.Service.Provider.Kind, "aws")
+	v.Set(f.Service.SecretStore.RefreshInterval, "1m")
+	v.Set(f.Service.Shard.LeaseDuration, "15s")
+	for k, value := range settings {
+		v.Set(k, value)
+	}
+
+	c := Config{
+		K8sClient: clients,
+		Logger:    microloggertest.New(),
+
+		Flag:  f,
+		Viper: v,
+	}
+	newService, err := New(c)
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	return newService, k8sClient
+}
+
+// newTestAPIServer serves the discovery and watch requests of the managers of
+// the controllers. The app CR test-app is sent as initial event of the app
+// watch. Watches block until they are closed.
+func newTestAPIServer(t *testing.T) *httptest.Server {
+	kinds := map[string]string{
+		"apps":        "App",
+		"approllouts": "AppRollout",
+		"catalogs":    "Catalog",
+	}
+
+	groupVersion := v1alpha1.SchemeGroupVersion.String()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		var obj interface{}
+
+		switch {
+		case r.URL.Path == "/api":
+			obj = metav1.APIVersions{Versions: []string{"v1"}}
+		case r.URL.Path == "/apis":
+			version := metav1.GroupVersionForDiscovery{GroupVersion: groupVersion, Version: v1alpha1.SchemeGroupVersion.Version}
+			obj = metav1.APIGroupList{
+				Groups: []metav1.APIGroup{
+					{Name: v1alpha1.SchemeGroupVersion.Group, Versions: []metav1.GroupVersionForDiscovery{version}, PreferredVersion: version},
+				},
+			}
+		case r.URL.Path == "/apis/"+groupVersion:
+			list := metav1.APIResourceList{GroupVersion: groupVersion}
+			for resource, kind := range kinds {
+				list.APIResources = append(list.APIResources, metav1.APIResource{
+					Name:       resource,
+					Namespaced: true,
+					Kind:       kind,
+					Verbs:      metav1.Verbs{"get", "list", "watch"},
+				})
+			}
+			obj = list
+		case strings.HasPrefix(r.URL.Path, "/apis/"+groupVersion+"/") && r.URL.Query().Get("watch") == "true":
+			path := strings.Split(r.URL.Path, "/")
+			resource := path[len(path)-1]
+
+			var events []interface{}
+			if resource == "apps" {
+				events = append(events, map[string]interface{}{"type": "ADDED", "object": newTestApp()})
+			}
+			// The bookmark ends the initial events of the watch list
+			// which syncs the informers.
+			events = append(events, map[string]interface{}{
+				"type": "BOOKMARK",
+				"object": map[string]interface{}{
+					"apiVersion": groupVersion,
+					"kind":       kinds[resource],
+					"metadata": map[string]interface{}{
+						"annotations":     map[string]string{metav1.InitialEventsAnnotationKey: "true"},
+						"resourceVersion": "1",
+					},
+				},
+			})
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			for _, e := range events {
+				err := json.NewEncoder(w).Encode(e)
+				if err != nil {
+					t.Errorf("error == %#v, want nil", err)
+				}
+			}
+			w.(http.Flusher).Flush()
+
+			<-r.Context().Done()
+			return
+		default:
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(obj)
+		if err != nil {
+			t.Errorf("error == %#v, want nil", err)
+		}
+	}
+
+	apiServer := httptest.NewServer(http.HandlerFunc(handler))
+	t.Cleanup(apiServer.Close)
+
+	return apiServer
+}
+
+func newTestApp() v1alpha1.App {
+	return v1alpha1.App{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: v1alpha1.SchemeGroupVersion.String(),
+			Kind:       "App",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "test-app",
+			Namespace:       "giantswarm",
+			ResourceVersion: "1",
+			Labels: map[string]string{
+				label.AppOperatorVersion: project.ManagementClusterAppVersion(),
+			},
+		},
+	}
+}
+
+// isWatching returns whether the app value watcher watches configmaps.
+func isWatching(k8sClient *clientgofake.Clientset) bool {
+	for _, action := range k8sClient.Actions() {
+		if action.GetResource().Resource == "configmaps" && action.GetVerb() == "watch" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func waitFor(t *testing.T, f func() bool) {
+	t.Helper()
+
+	for start := time.Now(); time.Since(start) < 30*time.Second; time.Sleep(10 * time.Millisecond) {
+		if f() {
+			return
+		}
+	}
+
+	t.Fatalf("condition was not met within 30s")
+}
@@ -0,0 +1,453 @@
+package appvalue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/app/v8/pkg/key"
+	"github.com/giantswarm/k8smetadata/pkg/label"
+	"github.com/giantswarm/microerror"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+)
+
+var (
+	appResource     = schema.GroupVersionResource{Group: "application.giantswarm.io", Version: "v1alpha1", Resource: "apps"}
+	catalogResource = schema.GroupVersionResource{Group: "application.giantswarm.io", Version: "v1alpha1", Resource: "catalogs"}
+)
+
+// appHandler queues the configmaps and secrets of changed app CRs so their
+// labels are updated and starts watching the objects they reference.
+func (c *AppValueWatcher) appHandler(ctx context.Context) cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			cr, err := toApp(obj)
+			if err != nil {
+				c.logger.Errorf(ctx, err, "failed to convert %#v to app", obj)
+				return
+			}
+			if !c.owns(cr) {
+				return
+			}
+
+			c.enqueueResources(ctx, c.appResources(ctx, cr))
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldCR, err := toApp(oldObj)
+			if err != nil {
+				c.logger.Errorf(ctx, err, "failed to convert %#v to app", oldObj)
+				return
+			}
+			newCR, err := toApp(newObj)
+			if err != nil {
+				c.logger.Errorf(ctx, err, "failed to convert %#v to app", newObj)
+				return
+			}
+
+			// The app may itself be referenced by other apps.
+			c.triggerAppRefs(oldCR, newCR)
+
+			if !c.owns(newCR) {
+				return
+			}
+
+			// Resources no longer referenced are queued as well so their
+			// labels are removed.
+			c.enqueueResources(ctx, append(c.appResources(ctx, oldCR), c.appResources(ctx, newCR)...))
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+
+			cr, err := toApp(obj)
+			if err != nil {
+				c.logger.Errorf(ctx, err, "failed to convert %#v to app", obj)
+				return
+			}
+			if !c.owns(cr) {
+				return
+			}
+
+			c.enqueueResources(ctx, c.appResources(ctx, cr))
+		},
+	}
+}
+
+// owns returns whether the app CR belongs to the shard of this replica. All
+// app CRs are owned when they are not sharded. App CRs of other replicas are
+// still indexed so labels are only removed when no app CR references the
+// resource.
+func (c *AppValueWatcher) owns(cr v1alpha1.App) bool {
+	return c.sharder == nil || c.sharder.Owns(cr)
+}
+
+// rebalance queues the resources of all owned app CRs after replicas joined
+// or left so the resources of app CRs moved to this replica are watched.
+// Watches of app CRs moved to other replicas are kept until the replica
+// restarts but their changes only trigger updates of owned app CRs.
+func (c *AppValueWatcher) rebalance(ctx context.Context) {
+	for _, obj := range c.appInformer.GetStore().List() {
+		cr, err := toApp(obj)
+		if err != nil {
+			c.logger.Errorf(ctx, err, "failed to convert %#v to app", obj)
+			continue
+		}
+		if !c.owns(cr) {
+			continue
+		}
+
+		c.enqueueResources(ctx, c.appResources(ctx, cr))
+	}
+}
+
+func (c *AppValueWatcher) enqueueResources(ctx context.Context, resources []resourceIndex) {
+	for _, resource := range resources {
+		switch resource.ResourceType {
+		case configMapType:
+			if c.watchMode == WatchModeIndex {
+				c.ensureNamespaceWatch(ctx, configMapType, resource.Namespace)
+				continue
+			}
+
+			c.labelQueue.Add(resource)
+		case secretType:
+			if !c.isSecretNamespaceAllowed(resource.Namespace) {
+				c.logger.Debugf(ctx, "not watching secret %#q since namespace %#q is not allowed", resource.Name, resource.Namespace)
+				continue
+			}
+
+			c.ensureNamespaceWatch(ctx, secretType, resource.Namespace)
+			if c.watchMode == WatchModeLabel {
+				c.labelQueue.Add(resource)
+			}
+		case objectType:
+			c.ensureObjectWatch(ctx, resource.APIVersion, resource.Kind)
+		}
+	}
+}
+
+// indexResources is the index function of resourcesIndex. Index functions
+// must not fail so errors are only logged.
+func (c *AppValueWatcher) indexResources(obj interface{}) ([]string, error) {
+	ctx := context.Background()
+
+	cr, err := toApp(obj)
+	if err != nil {
+		c.logger.Errorf(ctx, err, "failed to convert %#v to app", obj)
+		return nil, nil
+	}
+
+	var keys []string
+	for _, resource := range c.appResources(ctx, cr) {
+		keys = append(keys, resource.key())
+	}
+
+	return keys, nil
+}
+
+// appResources returns the resources the values of the app CR are sourced
+// from.
+func (c *AppValueWatcher) appResources(ctx context.Context, cr v1alpha1.App) []resourceIndex {
+	resources := []resourceIndex{}
+
+	catalog, err := c.findCatalog(cr)
+	if err != nil {
+		c.logger.LogCtx(ctx, "level", "info", "message", fmt.Sprintf("failed to find catalog of app %#q in namespace %#q", cr.Name, cr.Namespace), "stack", fmt.Sprintf("%#v", err))
+	} else {
+		if key.CatalogConfigMapName(*catalog) != "" {
+			resources = append(resources, resourceIndex{
+				ResourceType: configMapType,
+				Name:         key.CatalogConfigMapName(*catalog),
+				Namespace:    key.CatalogConfigMapNamespace(*catalog),
+			})
+		}
+
+		if key.CatalogSecretName(*catalog) != "" {
+			resources = append(resources, resourceIndex{
+				ResourceType: secretType,
+				Name:         key.CatalogSecretName(*catalog),
+				Namespace:    key.CatalogSecretNamespace(*catalog),
+			})
+		}
+	}
+
+	if key.AppConfigMapName(cr) != "" {
+		resources = append(resources, resourceIndex{
+			ResourceType: configMapType,
+			Name:         key.AppConfigMapName(cr),
+			Namespace:    key.AppConfigMapNamespace(cr),
+		})
+	}
+
+	if key.UserConfigMapName(cr) != "" {
+		resources = append(resources, resourceIndex{
+			ResourceType: configMapType,
+			Name:         key.UserConfigMapName(cr),
+			Namespace:    key.UserConfigMapNamespace(cr),
+		})
+	}
+
+	if key.AppSecretName(cr) != "" {
+		resources = append(resources, resourceIndex{
+			ResourceType: secretType,
+			Name:         key.AppSecretName(cr),
+			Namespace:    key.AppSecretNamespace(cr),
+		})
+	}
+
+	if key.UserSecretName(cr) != "" {
+		resources = append(resources, resourceIndex{
+			ResourceType: secretType,
+			Name:         key.UserSecretName(cr),
+			Namespace:    key.UserSecretNamespace(cr),
+		})
+	}
+
+	// Watch extra configs as well
+	for _, extraConfig := range key.ExtraConfigs(cr) {
+		var kind resourceType
+		switch extraConfig.Kind {
+		case "secret":
+			kind = secretType
+		default:
+			kind = configMapType
+		}
+
+		resources = append(resources, resourceIndex{
+			ResourceType: kind,
+			Name:         extraConfig.Name,
+			Namespace:    extraConfig.Namespace,
+		})
+	}
+
+	// Watch value references as well
+	resources = append(resources, valueRefResources(ctx, c.logger, c.secretStorePathPrefixes, cr)...)
+
+	return resources
+}
+
+// syncLabel labels the configmap or secret as long as app CRs source values
+// from it so it is watched and removes the label otherwise. In index mode
+// labels are always removed.
+func (c *AppValueWatcher) syncLabel(ctx context.Context, resource resourceIndex) error {
+	referenced, err := c.isReferenced(resource)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if referenced && c.watchMode == WatchModeLabel {
+		err = c.addLabel(ctx, resource)
+	} else {
+		err = c.removeLabel(ctx, resource)
+	}
+	if apierrors.IsNotFound(err) {
+		// The resource is labeled on the next resync once it exists.
+		c.logger.Debugf(ctx, "%s %#q in namespace %#q not found", resource.ResourceType, resource.Name, resource.Namespace)
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (c *AppValueWatcher) addLabel(ctx context.Context, resource resourceIndex) error {
+	var currentLabels map[string]string
+	{
+		switch resource.ResourceType {
+		case configMapType:
+			currentCM, err := c.k8sClient.K8sClient().CoreV1().ConfigMaps(resource.Namespace).Get(ctx, resource.Name, metav1.GetOptions{})
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			currentLabels = currentCM.GetLabels()
+		case secretType:
+			currentSecret, err := c.k8sClient.K8sClient().CoreV1().Secrets(resource.Namespace).Get(ctx, resource.Name, metav1.GetOptions{})
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			currentLabels = currentSecret.GetLabels()
+		default:
+			return microerror.Maskf(wrongTypeError, "expected %T or %T but got %T", configMapType, secretType, resource.ResourceType)
+		}
+	}
+
+	if _, ok := currentLabels[label.AppOperatorWatching]; ok {
+		// no-op
+		return nil
+	}
+
+	patches := []patch{}
+
+	if len(currentLabels) == 0 {
+		patches = append(patches, patch{
+			Op:    "add",
+			Path:  "/metadata/labels",
+			Value: map[string]string{},
+		})
+	}
+
+	patches = append(patches, patch{
+		Op:    "add",
+		Path:  fmt.Sprintf("/metadata/labels/%s", replaceToEscape(label.AppOperatorWatching)),
+		Value: "true",
+	})
+
+	bytes, err := json.Marshal(patches)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	switch resource.ResourceType {
+	case configMapType:
+		_, err = c.k8sClient.K8sClient().CoreV1().ConfigMaps(resource.Namespace).Patch(ctx, resource.Name, types.JSONPatchType, bytes, metav1.PatchOptions{})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	case secretType:
+		_, err = c.k8sClient.K8sClient().CoreV1().Secrets(resource.Namespace).Patch(ctx, resource.Name, types.JSONPatchType, bytes, metav1.PatchOptions{})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	c.logger.Debugf(ctx, "labeled %s %#q in namespace %#q", resource.ResourceType, resource.Name, resource.Namespace)
+
+	return nil
+}
+
+// isReferenced returns whether app CRs source values from the resource.
+func (c *AppValueWatcher) isReferenced(resource resourceIndex) (bool, error) {
+	apps, err := c.appInformer.GetIndexer().ByIndex(resourcesIndex, resource.key())
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	return len(apps) > 0, nil
+}
+
+// findCatalog returns the catalog of the app CR from the informer cache.
+func (c *AppValueWatcher) findCatalog(cr v1alpha1.App) (*v1alpha1.Catalog, error) {
+	var namespaces []string
+	{
+		if cr.Spec.CatalogNamespace != "" {
+			namespaces = []string{cr.Spec.CatalogNamespace}
+		} else {
+			namespaces = []string{metav1.NamespaceDefault, "giantswarm"}
+		}
+	}
+
+	for _, namespace := range namespaces {
+		obj, exists, err := c.catalogs.GetIndexer().GetByKey(fmt.Sprintf("%s/%s", namespace, key.CatalogName(cr)))
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		if !exists {
+			continue
+		}
+
+		u, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			return nil, microerror.Maskf(wrongTypeError, "expected '%T', got '%T'", &unstructured.Unstructured{}, obj)
+		}
+
+		catalog := &v1alpha1.Catalog{}
+		err = runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, catalog)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return catalog, nil
+	}
+
+	return nil, microerror.Maskf(notFoundError, "catalog %#q", key.CatalogName(cr))
+}
+
+func (c *AppValueWatcher) removeLabel(ctx context.Context, resource resourceIndex) error {
+	var currentLabels map[string]string
+	{
+		switch resource.ResourceType {
+		case configMapType:
+			currentCM, err := c.k8sClient.K8sClient().CoreV1().ConfigMaps(resource.Namespace).Get(ctx, resource.Name, metav1.GetOptions{})
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			currentLabels = currentCM.GetLabels()
+		case secretType:
+			currentSecret, err := c.k8sClient.K8sClient().CoreV1().Secrets(resource.Namespace).Get(ctx, resource.Name, metav1.GetOptions{})
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			currentLabels = currentSecret.GetLabels()
+		default:
+			return microerror.Maskf(wrongTypeError, "expected %T or %T but got %T", configMapType, secretType, resource.ResourceType)
+		}
+	}
+
+	if _, ok := currentLabels[label.AppOperatorWatching]; !ok {
+		// no-op
+		return nil
+	}
+
+	patches := []patch{
+		{
+			Op:   "remove",
+			Path: fmt.Sprintf("/metadata/labels/%s", replaceToEscape(label.AppOperatorWatching)),
+		},
+	}
+
+	bytes, err := json.Marshal(patches)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	switch resource.ResourceType {
+	case configMapType:
+		_, err = c.k8sClient.K8sClient().CoreV1().ConfigMaps(resource.Namespace).Patch(ctx, resource.Name, types.JSONPatchType, bytes, metav1.PatchOptions{})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	case secretType:
+		_, err = c.k8sClient.K8sClient().CoreV1().Secrets(resource.Namespace).Patch(ctx, resource.Name, types.JSONPatchType, bytes, metav1.PatchOptions{})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	c.logger.Debugf(ctx, "removed label from %s %#q in namespace %#q", resource.ResourceType, resource.Name, resource.Namespace)
+
+	return nil
+}
+
+// toApp converts an app CR from the informer cache.
+func toApp(obj interface{}) (v1alpha1.App, error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return v1alpha1.App{}, microerror.Maskf(wrongTypeError, "expected '%T', got '%T'", &unstructured.Unstructured{}, obj)
+	}
+
+	var cr v1alpha1.App
+	err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, &cr)
+	if err != nil {
+		return v1alpha1.App{}, microerror.Mask(err)
+	}
+
+	return cr, nil
+}
+
+func replaceToEscape(from string) string {
+	return strings.ReplaceAll(from, "/", "~1")
+}
@@ -3,37 +3,163 @@ package appvalue
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/giantswarm/k8sclient/v7/pkg/k8sclient"
+	"github.com/giantswarm/k8smetadata/pkg/label"
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
+	"golang.org/x/time/rate"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/controller/priorityqueue"
 
-	"github.com/giantswarm/app-operator/v7/pkg/label"
+	pkglabel "github.com/giantswarm/app-operator/v7/pkg/label"
 	"github.com/giantswarm/app-operator/v7/service/internal/recorder"
+	"github.com/giantswarm/app-operator/v7/service/internal/secretstore"
+	"github.com/giantswarm/app-operator/v7/service/internal/shard"
+)
+
+const (
+	// resourcesIndex indexes app CRs by the keys of the resources their
+	// values are sourced from.
+	resourcesIndex = "resources"
+
+	// maxRetries is the number of times queued items are retried with
+	// backoff before they are dropped.
+	maxRetries = 10
+)
+
+const (
+	// WatchModeLabel watches configmaps and secrets labeled with
+	// app-operator.giantswarm.io/watching. The label is added to the
+	// configmaps and secrets app CRs source values from.
+	WatchModeLabel = "label"
+	// WatchModeIndex watches all configmaps and secrets of the namespaces
+	// app CRs source values from and only considers the ones referenced in
+	// the resources index of app CRs. User objects are not modified and
+	// labels added in label mode are removed.
+	WatchModeIndex = "index"
 )
 
 type AppValueWatcherConfig struct {
 	Event     recorder.Interface
 	K8sClient k8sclient.Interface
 	Logger    micrologger.Logger
+	// SecretStore is optional. When set values of secret store references
+	// are resolved every SecretStoreRefreshInterval and apps are updated
+	// when they changed.
+	SecretStore secretstore.Interface
+	// Sharder is optional. When set only the app CRs of the shard of this
+	// replica are updated and their resources watched.
+	Sharder shard.Interface
 
-	// SecretNamespace is used to limit access to secrets to only the
-	// SecretNamespace. No other namespaces will be watched for Secrets.
-	SecretNamespace   string
-	UniqueApp         bool
+	// ChartNamespace is optional. When set triggers of in-cluster app CRs
+	// whose generated values already reflect the change are skipped.
+	ChartNamespace string
+	// ResyncPeriod is the interval in which the informers deliver all
+	// cached objects again. Labels of watched configmaps and secrets are
+	// ensured on every resync.
+	ResyncPeriod time.Duration
+	// SecretNamespace is the namespace of app-operator. Secrets are always
+	// watched in it.
+	SecretNamespace string
+	// SecretNamespaces and SecretNamespaceSelector allow-list the other
+	// namespaces secrets app CRs source values from are watched in. The
+	// selector is a label selector matching namespaces. Secrets in other
+	// namespaces are only read when app CRs are reconciled.
+	SecretNamespaces        []string
+	SecretNamespaceSelector string
+	// SecretStorePathPrefixes are the prefixes by provider the paths of
+	// secret store references must start with, followed by the namespace
+	// of the app CR. Paths outside this scope are not refreshed.
+	SecretStorePathPrefixes    map[string]string
+	SecretStoreRefreshInterval time.Duration
+	// TriggerDebounce delays updates of app CRs so changes of resources
+	// within the window are coalesced into one update.
+	TriggerDebounce time.Duration
+	// TriggerRateLimit is the number of app CR updates per second with
+	// bursts of TriggerBurst. Updates are not rate limited when it is zero.
+	TriggerRateLimit float64
+	TriggerBurst     int
+	UniqueApp        bool
+	// WatchMode is either WatchModeLabel or WatchModeIndex. It defaults to
+	// WatchModeLabel.
+	WatchMode         string
 	WorkloadClusterID string
 }
 
+// AppValueWatcher triggers reconciliations of app CRs when the resources
+// their values are sourced from change. It is built on shared informers.
+// App CRs are indexed by the keys of their resources so changes are mapped
+// to app CRs via the informer cache. Labels of watched configmaps and
+// secrets and the triggered updates are processed by rate limited
+// workqueues which retry failures with backoff.
 type AppValueWatcher struct {
 	event     recorder.Interface
 	k8sClient k8sclient.Interface
 	logger    micrologger.Logger
+	// secretStore is nil unless secret store providers are configured.
+	secretStore secretstore.Interface
+	// sharder is nil unless app CRs are sharded across replicas.
+	sharder shard.Interface
+
+	appFactory    dynamicinformer.DynamicSharedInformerFactory
+	objectFactory dynamicinformer.DynamicSharedInformerFactory
+	appInformer   cache.SharedIndexInformer
+	catalogs      cache.SharedIndexInformer
+
+	// configMapFactory watches labeled configmaps in label mode.
+	configMapFactory informers.SharedInformerFactory
+	// namespaceFactory watches namespaces when SecretNamespaceSelector is
+	// set.
+	namespaceFactory informers.SharedInformerFactory
+
+	// namespaceFactories watch the secrets of allowed namespaces referenced
+	// by app CRs and, in index mode, their configmaps.
+	namespaceFactoriesMutex sync.Mutex
+	namespaceFactories      map[namespaceWatch]informers.SharedInformerFactory
+
+	labelQueue   workqueue.TypedRateLimitingInterface[resourceIndex]
+	triggerQueue workqueue.TypedRateLimitingInterface[trigger]
+	// appQueue holds the app CRs to be updated ordered by priority. The
+	// changes to apply are collected in pending until the app CR is
+	// processed.
+	appQueue       priorityqueue.PriorityQueue[appIndex]
+	pendingMutex   sync.Mutex
+	pending        map[appIndex]pendingUpdate
+	triggerLimiter *rate.Limiter
+
+	// objectWatches tracks the types of objects referenced in the
+	// values-refs annotation of app CRs which are watched.
+	objectWatchesMutex sync.Mutex
+	objectWatches      map[schema.GroupVersionResource]bool
 
-	appIndexMutex   sync.RWMutex
-	resourcesToApps sync.Map
-	secretNamespace string
-	selector        labels.Selector
+	chartNamespace             string
+	resyncPeriod               time.Duration
+	secretNamespace            string
+	secretNamespaces           map[string]bool
+	secretNamespaceSelector    labels.Selector
+	secretStorePathPrefixes    map[string]string
+	secretStoreRefreshInterval time.Duration
+	triggerDebounce            time.Duration
+	watchMode                  string
+	workloadClusterID          string
+}
+
+// trigger is a change of a resource which triggers the app CRs depending on
+// it.
+type trigger struct {
+	Resource        resourceIndex
+	ResourceVersion string
+	// Deleted is true when the resource was deleted. Deletions always
+	// trigger the app CRs since their values cannot be fresh.
+	Deleted bool
 }
 
 func NewAppValueWatcher(config AppValueWatcherConfig) (*AppValueWatcher, error) {
@@ -49,37 +175,274 @@ func NewAppValueWatcher(config AppValueWatcherConfig) (*AppValueWatcher, error)
 	if config.SecretNamespace == "" {
 		return nil, microerror.Maskf(invalidConfigError, "%T.SecretNamespace must not be empty", config)
 	}
+	if config.SecretStore != nil && config.SecretStoreRefreshInterval <= 0 {
+		return nil, microerror.Maskf(invalidConfigError, "%T.SecretStoreRefreshInterval must be greater than zero", config)
+	}
+	if config.ResyncPeriod < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "%T.ResyncPeriod must not be negative", config)
+	}
+	if config.TriggerDebounce < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "%T.TriggerDebounce must not be negative", config)
+	}
+	if config.TriggerRateLimit < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "%T.TriggerRateLimit must not be negative", config)
+	}
+	if config.TriggerRateLimit > 0 && config.TriggerBurst <= 0 {
+		return nil, microerror.Maskf(invalidConfigError, "%T.TriggerBurst must be greater than zero", config)
+	}
+	if config.WatchMode == "" {
+		config.WatchMode = WatchModeLabel
+	}
+	if config.WatchMode != WatchModeLabel && config.WatchMode != WatchModeIndex {
+		return nil, microerror.Maskf(invalidConfigError, "%T.WatchMode must be %#q or %#q but got %#q", config, WatchModeLabel, WatchModeIndex, config.WatchMode)
+	}
 
 	var selector labels.Selector
 	{
 		if config.WorkloadClusterID != "" {
-			selector = label.ClusterSelector(config.WorkloadClusterID)
+			selector = pkglabel.ClusterSelector(config.WorkloadClusterID)
 		} else {
-			selector = label.AppVersionSelector(config.UniqueApp)
+			selector = pkglabel.AppVersionSelector(config.UniqueApp)
 		}
 	}
 
+	secretNamespaces := map[string]bool{
+		config.SecretNamespace: true,
+	}
+	for _, namespace := range config.SecretNamespaces {
+		secretNamespaces[namespace] = true
+	}
+
+	var secretNamespaceSelector labels.Selector
+	var namespaceFactory informers.SharedInformerFactory
+	if config.SecretNamespaceSelector != "" {
+		var err error
+		secretNamespaceSelector, err = labels.Parse(config.SecretNamespaceSelector)
+		if err != nil {
+			return nil, microerror.Maskf(invalidConfigError, "%T.SecretNamespaceSelector %#q must be a label selector: %s", config, config.SecretNamespaceSelector, err)
+		}
+
+		namespaceFactory = informers.NewSharedInformerFactoryWithOptions(config.K8sClient.K8sClient(), config.ResyncPeriod, informers.WithTweakListOptions(func(lo *metav1.ListOptions) {
+			lo.LabelSelector = secretNamespaceSelector.String()
+		}))
+	}
+
+	triggerLimiter := rate.NewLimiter(rate.Inf, 0)
+	if config.TriggerRateLimit > 0 {
+		triggerLimiter = rate.NewLimiter(rate.Limit(config.TriggerRateLimit), config.TriggerBurst)
+	}
+
 	c := &AppValueWatcher{
 		event:     config.Event,
 		k8sClient: config.K8sClient,
 		logger:    config.Logger,
 
-		appIndexMutex:   sync.RWMutex{},
-		resourcesToApps: sync.Map{},
-		secretNamespace: config.SecretNamespace,
-		selector:        selector,
+		secretStore: config.SecretStore,
+		sharder:     config.Sharder,
+
+		appFactory: dynamicinformer.NewFilteredDynamicSharedInformerFactory(config.K8sClient.DynClient(), config.ResyncPeriod, metav1.NamespaceAll, func(lo *metav1.ListOptions) {
+			lo.LabelSelector = selector.String()
+		}),
+		objectFactory: dynamicinformer.NewDynamicSharedInformerFactory(config.K8sClient.DynClient(), config.ResyncPeriod),
+
+		configMapFactory: informers.NewSharedInformerFactoryWithOptions(config.K8sClient.K8sClient(), config.ResyncPeriod, informers.WithTweakListOptions(func(lo *metav1.ListOptions) {
+			lo.LabelSelector = label.AppOperatorWatching
+		})),
+		namespaceFactory: namespaceFactory,
+
+		labelQueue: workqueue.NewTypedRateLimitingQueueWithConfig(
+			workqueue.DefaultTypedControllerRateLimiter[resourceIndex](),
+			workqueue.TypedRateLimitingQueueConfig[resourceIndex]{Name: "appvalue-labels"},
+		),
+		triggerQueue: workqueue.NewTypedRateLimitingQueueWithConfig(
+			workqueue.DefaultTypedControllerRateLimiter[trigger](),
+			workqueue.TypedRateLimitingQueueConfig[trigger]{Name: "appvalue-triggers"},
+		),
+
+		appQueue:       priorityqueue.New[appIndex]("appvalue-apps"),
+		pending:        map[appIndex]pendingUpdate{},
+		triggerLimiter: triggerLimiter,
+
+		namespaceFactories: map[namespaceWatch]informers.SharedInformerFactory{},
+		objectWatches:      map[schema.GroupVersionResource]bool{},
+
+		chartNamespace:             config.ChartNamespace,
+		resyncPeriod:               config.ResyncPeriod,
+		secretNamespace:            config.SecretNamespace,
+		secretNamespaces:           secretNamespaces,
+		secretNamespaceSelector:    secretNamespaceSelector,
+		secretStorePathPrefixes:    config.SecretStorePathPrefixes,
+		secretStoreRefreshInterval: config.SecretStoreRefreshInterval,
+		triggerDebounce:            config.TriggerDebounce,
+		watchMode:                  config.WatchMode,
+		workloadClusterID:          config.WorkloadClusterID,
 	}
 
 	return c, nil
 }
 
+// Boot starts the informers and workers and blocks until the context is
+// canceled. Items being processed are finished and queued items are dropped
+// before Boot returns. The informers are stopped as well.
 func (c *AppValueWatcher) Boot(ctx context.Context) {
-	// Watch for configmap changes.
-	go c.watchConfigMap(ctx)
+	// The informers are stopped by canceling the context after the workers
+	// returned so Boot does not block when the informers failed to start.
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer c.stopInformers()
+	defer cancel()
+	defer wg.Wait()
+	defer c.stopQueues()
+
+	err := c.startInformers(ctx)
+	if ctx.Err() != nil {
+		return
+	} else if err != nil {
+		c.logger.Errorf(ctx, err, "failed to start appvalue informers")
+		return
+	}
+
+	wg.Go(func() {
+		runWorker(ctx, c, "labels", c.labelQueue, c.syncLabel, nil)
+	})
+	wg.Go(func() {
+		runWorker(ctx, c, "triggers", c.triggerQueue, c.triggerApps, func(trigger) {
+			triggersDropped.WithLabelValues("triggers").Inc()
+		})
+	})
+	wg.Go(func() {
+		runWorker(ctx, c, "apps", c.appQueue, c.updateApp, func(app appIndex) {
+			triggersDropped.WithLabelValues("apps").Inc()
+			c.dropUpdate(app)
+		})
+	})
 
-	// Watch for secret changes.
-	go c.watchSecret(ctx)
+	// Labels added in label mode are removed so user objects are not
+	// modified anymore.
+	if c.watchMode == WatchModeIndex {
+		wg.Go(func() {
+			c.removeWatchingLabels(ctx)
+		})
+	}
+
+	// App CRs moved to this replica are handled like new app CRs so their
+	// resources are watched.
+	if c.sharder != nil {
+		c.sharder.Subscribe(func() { c.rebalance(ctx) })
+	}
+
+	// Resolve values of secret store references again to detect changes.
+	if c.secretStore != nil {
+		wg.Go(func() {
+			c.refreshSecretStores(ctx)
+		})
+	}
 
-	// Build a cache of configmaps and link each app to its configmaps.
-	go c.buildCache(ctx)
+	<-ctx.Done()
+}
+
+// stopQueues shuts down the queues so the workers return once they finished
+// the items they are processing.
+func (c *AppValueWatcher) stopQueues() {
+	c.labelQueue.ShutDown()
+	c.triggerQueue.ShutDown()
+	c.appQueue.ShutDown()
+}
+
+// stopInformers waits for the informers to stop. They are stopped once the
+// context they were started with is canceled.
+func (c *AppValueWatcher) stopInformers() {
+	c.appFactory.Shutdown()
+	c.objectFactory.Shutdown()
+	c.configMapFactory.Shutdown()
+	if c.namespaceFactory != nil {
+		c.namespaceFactory.Shutdown()
+	}
+
+	c.namespaceFactoriesMutex.Lock()
+	defer c.namespaceFactoriesMutex.Unlock()
+
+	for _, factory := range c.namespaceFactories {
+		factory.Shutdown()
+	}
+}
+
+func (c *AppValueWatcher) startInformers(ctx context.Context) error {
+	// Catalogs are synced first so the resources of app CRs include the
+	// configmaps and secrets of their catalogs when they are indexed.
+	c.catalogs = c.objectFactory.ForResource(catalogResource).Informer()
+	c.objectFactory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), c.catalogs.HasSynced) {
+		return microerror.Maskf(executionFailedError, "catalog informer did not sync")
+	}
+
+	// Namespaces are synced before app CRs so secrets referenced by app CRs
+	// are watched when their namespace matches the selector.
+	if c.namespaceFactory != nil {
+		namespaces := c.namespaceFactory.Core().V1().Namespaces().Informer()
+		c.namespaceFactory.Start(ctx.Done())
+		if !cache.WaitForCacheSync(ctx.Done(), namespaces.HasSynced) {
+			return microerror.Maskf(executionFailedError, "namespace informer did not sync")
+		}
+	}
+
+	c.appInformer = c.appFactory.ForResource(appResource).Informer()
+	err := c.appInformer.AddIndexers(cache.Indexers{resourcesIndex: c.indexResources})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	_, err = c.appInformer.AddEventHandler(c.appHandler(ctx))
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	// In index mode configmaps are watched per namespace once app CRs
+	// reference them.
+	if c.watchMode == WatchModeLabel {
+		_, err = c.configMapFactory.Core().V1().ConfigMaps().Informer().AddEventHandler(c.configMapHandler())
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	c.appFactory.Start(ctx.Done())
+	c.configMapFactory.Start(ctx.Done())
+
+	// Changes are only mapped to app CRs once the app CRs are indexed.
+	if !cache.WaitForCacheSync(ctx.Done(), c.appInformer.HasSynced) {
+		return microerror.Maskf(executionFailedError, "app informer did not sync")
+	}
+
+	c.logger.Debugf(ctx, "started appvalue informers")
+
+	return nil
+}
+
+// runWorker processes the items of the queue until it is shut down. Failed
+// items are retried with backoff up to maxRetries times. dropped is called
+// for items dropped afterwards unless it is nil.
+func runWorker[T comparable](ctx context.Context, c *AppValueWatcher, name string, queue workqueue.TypedRateLimitingInterface[T], process func(context.Context, T) error, dropped func(T)) {
+	for {
+		item, shutdown := queue.Get()
+		if shutdown {
+			return
+		}
+
+		err := process(ctx, item)
+		switch {
+		case err == nil:
+			queue.Forget(item)
+		case queue.NumRequeues(item) < maxRetries:
+			c.logger.Debugf(ctx, "retrying %s item %v: %s", name, item, err)
+			queue.AddRateLimited(item)
+		default:
+			c.logger.Errorf(ctx, err, "dropping %s item %v after %d retries", name, item, maxRetries)
+			queue.Forget(item)
+			if dropped != nil {
+				dropped(item)
+			}
+		}
+
+		queue.Done(item)
+	}
 }
@@ -0,0 +1,244 @@
+package appvalue
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/k8sclient/v7/pkg/k8sclienttest"
+	"github.com/giantswarm/k8smetadata/pkg/annotation"
+	"github.com/giantswarm/k8smetadata/pkg/label"
+	"github.com/giantswarm/micrologger/microloggertest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clientgofake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/giantswarm/app-operator/v7/pkg/project"
+	"github.com/giantswarm/app-operator/v7/service/internal/recorder"
+)
+
+func Test_AppValueWatcher(t *testing.T) {
+	tests := []struct {
+		name      string
+		watchMode string
+		labels    map[string]string
+		// interrupt is applied to the first configmap watch after the
+		// configmap changed. The change must still trigger the app.
+		interrupt       func(w *watch.FakeWatcher)
+		expectedLabeled bool
+	}{
+		{
+			name:            "case 0: configmap change triggers the app",
+			expectedLabeled: true,
+		},
+		{
+			name: "case 1: configmap change is detected after the watch was closed",
+			interrupt: func(w *watch.FakeWatcher) {
+				w.Stop()
+			},
+			expectedLabeled: true,
+		},
+		{
+			name: "case 2: configmap change is detected after the watch expired",
+			interrupt: func(w *watch.FakeWatcher) {
+				w.Error(&metav1.Status{
+					Status:  metav1.StatusFailure,
+					Code:    http.StatusGone,
+					Reason:  metav1.StatusReasonExpired,
+					Message: "too old resource version",
+				})
+			},
+			expectedLabeled: true,
+		},
+		{
+			name:      "case 3: configmap change triggers the app in index mode and the label is removed",
+			watchMode: WatchModeIndex,
+			labels: map[string]string{
+				label.AppOperatorWatching: "true",
+			},
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			app := &v1alpha1.App{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "application.giantswarm.io/v1alpha1",
+					Kind:       "App",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-app",
+					Namespace: "default",
+					Labels: map[string]string{
+						label.AppOperatorVersion: project.Version(),
+					},
+				},
+				Spec: v1alpha1.AppSpec{
+					Catalog: "giantswarm",
+					Config: v1alpha1.AppSpecConfig{
+						ConfigMap: v1alpha1.AppSpecConfigConfigMap{
+							Name:      "test-app-values",
+							Namespace: "default",
+						},
+					},
+				},
+			}
+			cm := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            "test-app-values",
+					Namespace:       "default",
+					Labels:          tc.labels,
+					ResourceVersion: "1",
+				},
+				Data: map[string]string{
+					"values": "replicas: 1",
+				},
+			}
+
+			s := runtime.NewScheme()
+			err := v1alpha1.AddToScheme(s)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(app)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			k8sClient := clientgofake.NewSimpleClientset(cm)
+
+			var interrupted sync.Once
+			changed := make(chan struct{})
+			if tc.interrupt != nil {
+				w := watch.NewFake()
+				k8sClient.PrependWatchReactor("configmaps", func(action k8stesting.Action) (bool, watch.Interface, error) {
+					handled := false
+					interrupted.Do(func() {
+						handled = true
+						go func() {
+							<-changed
+							tc.interrupt(w)
+						}()
+					})
+
+					return handled, w, nil
+				})
+			}
+
+			ctrlClient := fake.NewClientBuilder().WithScheme(s).WithObjects(app.DeepCopy()).Build()
+			dynClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+				appResource:     "AppList",
+				catalogResource: "CatalogList",
+			}, &unstructured.Unstructured{Object: u})
+
+			clients := k8sclienttest.NewClients(k8sclienttest.ClientsConfig{
+				CtrlClient: ctrlClient,
+				DynClient:  dynClient,
+				K8sClient:  k8sClient,
+			})
+
+			c := AppValueWatcherConfig{
+				Event: recorder.New(recorder.Config{
+					K8sClient: clients,
+				}),
+				K8sClient: clients,
+				Logger:    microloggertest.New(),
+
+				SecretNamespace: "giantswarm",
+				WatchMode:       tc.watchMode,
+			}
+			w, err := NewAppValueWatcher(c)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			go w.Boot(ctx)
+
+			// Changes are only detected once the configmaps are listed.
+			err = poll(ctx, func(ctx context.Context) (bool, error) {
+				return configMapsSynced(w, cm.Namespace), nil
+			})
+			if err != nil {
+				t.Fatalf("configmaps were not synced: %#v", err)
+			}
+
+			// The configmap is only labeled in label mode.
+			err = poll(ctx, func(ctx context.Context) (bool, error) {
+				current, err := k8sClient.CoreV1().ConfigMaps(cm.Namespace).Get(ctx, cm.Name, metav1.GetOptions{})
+				if err != nil {
+					return false, err
+				}
+
+				_, labeled := current.Labels[label.AppOperatorWatching]
+				return labeled == tc.expectedLabeled, nil
+			})
+			if err != nil {
+				t.Fatalf("configmap label was not synced: %#v", err)
+			}
+
+			current, err := k8sClient.CoreV1().ConfigMaps(cm.Namespace).Get(ctx, cm.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+			current.Data["values"] = "replicas: 2"
+			current.ResourceVersion = "5"
+			_, err = k8sClient.CoreV1().ConfigMaps(cm.Namespace).Update(ctx, current, metav1.UpdateOptions{})
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+			close(changed)
+
+			err = poll(ctx, func(ctx context.Context) (bool, error) {
+				var current v1alpha1.App
+				err := ctrlClient.Get(ctx, types.NamespacedName{Name: app.Name, Namespace: app.Namespace}, &current)
+				if err != nil {
+					return false, err
+				}
+
+				return current.Annotations[annotation.AppOperatorLatestConfigMapVersion] == "5", nil
+			})
+			if err != nil {
+				t.Fatalf("app was not triggered: %#v", err)
+			}
+		})
+	}
+}
+
+func configMapsSynced(w *AppValueWatcher, namespace string) bool {
+	if w.watchMode == WatchModeLabel {
+		return w.configMapFactory.Core().V1().ConfigMaps().Informer().HasSynced()
+	}
+
+	w.namespaceFactoriesMutex.Lock()
+	defer w.namespaceFactoriesMutex.Unlock()
+
+	factory, ok := w.namespaceFactories[namespaceWatch{ResourceType: configMapType, Namespace: namespace}]
+	if !ok {
+		return false
+	}
+
+	return factory.Core().V1().ConfigMaps().Informer().HasSynced()
+}
+
+func poll(ctx context.Context, condition wait.ConditionWithContextFunc) error {
+	return wait.PollUntilContextTimeout(ctx, 10*time.Millisecond, 10*time.Second, true, condition)
+}
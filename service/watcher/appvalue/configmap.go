@@ -2,150 +2,103 @@ package appvalue
 
 import (
 	"context"
-	"fmt"
-	"strconv"
+	"reflect"
 
 	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
 	"github.com/giantswarm/k8smetadata/pkg/annotation"
-	"github.com/giantswarm/k8smetadata/pkg/label"
 	"github.com/giantswarm/microerror"
 	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-)
-
-func (c *AppValueWatcher) watchConfigMap(ctx context.Context) {
-	for {
-		lo := metav1.ListOptions{
-			LabelSelector: label.AppOperatorWatching,
-		}
-
-		// Find the highest resourceVersion for each configmap.
-		cms, err := c.k8sClient.K8sClient().CoreV1().ConfigMaps("").List(ctx, lo)
-		if err != nil {
-			c.logger.LogCtx(ctx, "level", "info", "message", fmt.Sprintf("failed to get configmaps with label %#q", label.AppOperatorWatching), "stack", fmt.Sprintf("%#v", err))
-			continue
-		}
-
-		var highestResourceVersion uint64
-
-		for _, cm := range cms.Items {
-			currentResourceVersion, err := getResourceVersion(cm.GetResourceVersion())
-			if err != nil {
-				c.logger.LogCtx(ctx, "level", "info", "message", fmt.Sprintf("failed to get resourceVersion from configmaps %#q in namespace %#q", cm.GetName(), cm.GetNamespace()), "stack", fmt.Sprintf("%#v", err))
-				continue
-			}
-			if highestResourceVersion < currentResourceVersion {
-				highestResourceVersion = currentResourceVersion
-			}
-		}
-
-		c.logger.LogCtx(ctx, "debug", fmt.Sprintf("starting ResourceVersion is %d", highestResourceVersion))
 
-		res, err := c.k8sClient.K8sClient().CoreV1().ConfigMaps("").Watch(ctx, lo)
-		if err != nil {
-			c.logger.LogCtx(ctx, "level", "info", "message", fmt.Sprintf("failed to get configmaps with label %#q", label.AppOperatorWatching), "stack", fmt.Sprintf("%#v", err))
-			continue
-		}
-
-		for r := range res.ResultChan() {
-			if r.Type == watch.Bookmark {
-				// no-op for unsupported events
-				continue
-			}
+	"github.com/giantswarm/app-operator/v7/pkg/valueref"
+)
 
-			if r.Type == watch.Error {
-				c.logger.LogCtx(ctx, "level", "info", "message", fmt.Sprintf("got error event: %#q", r.Object))
-				continue
+// configMapHandler queues triggers for the apps depending on
+// configmaps when their data changes.
+func (c *AppValueWatcher) configMapHandler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			// Configmaps listed on start are not changes.
+			if isInInitialList {
+				return
 			}
 
-			cm, err := toConfigMap(r.Object)
+			c.enqueueConfigMap(obj, false)
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldCM, err := toConfigMap(oldObj)
 			if err != nil {
-				c.logger.LogCtx(ctx, "level", "info", "message", "failed to convert configmap object", "stack", fmt.Sprintf("%#v", err))
-				continue
+				return
 			}
-
-			v, err := getResourceVersion(cm.GetResourceVersion())
+			newCM, err := toConfigMap(newObj)
 			if err != nil {
-				c.logger.LogCtx(ctx, "level", "info", "message", fmt.Sprintf("failed to get resourceVersion from configmaps %#q in namespace %#q", cm.GetName(), cm.GetNamespace()), "stack", fmt.Sprintf("%#v", err))
-				continue
+				return
 			}
 
-			if v <= highestResourceVersion {
-				// no-op
-				c.logger.LogCtx(ctx, "level", "info", "message", fmt.Sprintf("no need to reconcile for the older resourceVersion %d", v))
-				continue
+			// Resyncs and label changes do not change the values.
+			if reflect.DeepEqual(oldCM.Data, newCM.Data) && reflect.DeepEqual(oldCM.BinaryData, newCM.BinaryData) {
+				return
 			}
 
-			configMap := resourceIndex{
-				ResourceType: configMapType,
-				Name:         cm.GetName(),
-				Namespace:    cm.GetNamespace(),
+			c.enqueueConfigMap(newCM, false)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
 			}
 
-			var storedIndex map[appIndex]bool
-			{
-				v, ok := c.resourcesToApps.Load(configMap)
-				if !ok {
-					c.logger.Debugf(ctx, "cache missed configMap %#q in namespace %#q", configMap.Name, configMap.Namespace)
-					continue
-				}
-
-				storedIndex, ok = v.(map[appIndex]bool)
-				if !ok {
-					c.logger.LogCtx(ctx, "level", "info", "message", fmt.Sprintf("expected '%T', got '%T'", map[appIndex]bool{}, v), "stack", fmt.Sprintf("%#v", err))
-					continue
-				}
-			}
-
-			c.logger.Debugf(ctx, "listed apps depends on %#q configmap in namespace %#q", cm.Name, cm.Namespace)
-
-			var currentApp v1alpha1.App
-
-			c.appIndexMutex.RLock()
-			for app := range storedIndex {
-				c.logger.Debugf(ctx, "triggering %#q app update in namespace %#q", app.Name, app.Namespace)
-
-				err = c.k8sClient.CtrlClient().Get(
-					ctx,
-					types.NamespacedName{Name: app.Name, Namespace: app.Namespace},
-					&currentApp,
-				)
-				if err != nil {
-					c.logger.Errorf(ctx, err, "cannot fetch app CR %s/%s", app.Namespace, app.Name)
-					continue
-				}
-
-				err = c.addAnnotation(ctx, &currentApp, cm.GetResourceVersion(), configMapType)
-				if err != nil {
-					c.logger.LogCtx(ctx, "level", "info", "message", fmt.Sprintf("failed to add annotation to app %#q in namespace %#q", app.Name, app.Namespace), "stack", fmt.Sprintf("%#v", err))
-					continue
-				}
+			c.enqueueConfigMap(obj, true)
+		},
+	}
+}
 
-				c.logger.Debugf(ctx, "triggered %#q app update in namespace %#q", app.Name, app.Namespace)
+func (c *AppValueWatcher) enqueueConfigMap(obj interface{}, deleted bool) {
+	cm, err := toConfigMap(obj)
+	if err != nil {
+		c.logger.Errorf(context.Background(), err, "failed to convert configmap object")
+		return
+	}
 
-				c.event.Emit(ctx, &currentApp, "AppUpdated", "change to configmap %s/%s triggered an update", configMap.Namespace, configMap.Name)
-			}
-			c.appIndexMutex.RUnlock()
-			c.logger.Debugf(ctx, "listed apps depends on %#q configmap in namespace %#q", cm.Name, cm.Namespace)
-		}
+	resource := resourceIndex{
+		ResourceType: configMapType,
+		Name:         cm.GetName(),
+		Namespace:    cm.GetNamespace(),
+	}
 
-		c.logger.Log("debug", "watch channel had been closed, reopening...")
+	// In index mode all configmaps of the namespace are watched so the
+	// ones no app CR sources values from are skipped.
+	referenced, err := c.isReferenced(resource)
+	if err != nil {
+		c.logger.Errorf(context.Background(), err, "failed to look up app CRs of configmap %#q in namespace %#q", resource.Name, resource.Namespace)
+		return
+	}
+	if !referenced {
+		return
 	}
+
+	c.triggerQueue.Add(trigger{
+		Resource:        resource,
+		ResourceVersion: cm.GetResourceVersion(),
+		Deleted:         deleted,
+	})
 }
 
-func (c *AppValueWatcher) addAnnotation(ctx context.Context, app *v1alpha1.App, latestResourceVersion string, resType resourceType) error {
-	var versionAnnotation string
-	{
-		if resType == configMapType {
-			versionAnnotation = annotation.AppOperatorLatestConfigMapVersion
-		} else {
-			versionAnnotation = annotation.AppOperatorLatestSecretVersion
-		}
+// versionAnnotation returns the annotation of app CRs the latest version of
+// resources of the type is written to.
+func versionAnnotation(resType resourceType) string {
+	switch resType {
+	case configMapType:
+		return annotation.AppOperatorLatestConfigMapVersion
+	case appType, objectType, secretStoreType:
+		return valueref.LatestVersionAnnotation
+	default:
+		return annotation.AppOperatorLatestSecretVersion
 	}
+}
 
+func (c *AppValueWatcher) addAnnotations(ctx context.Context, app *v1alpha1.App, versions map[string]string) error {
 	var modifiedApp v1alpha1.App
 
 	err := c.k8sClient.CtrlClient().Get(
@@ -162,7 +115,9 @@ func (c *AppValueWatcher) addAnnotation(ctx context.Context, app *v1alpha1.App,
 	}
 
 	annotations := modifiedApp.Annotations
-	annotations[versionAnnotation] = latestResourceVersion
+	for k, v := range versions {
+		annotations[k] = v
+	}
 	modifiedApp.Annotations = annotations
 
 	err = c.k8sClient.CtrlClient().Patch(ctx, &modifiedApp, client.MergeFrom(app))
@@ -173,15 +128,6 @@ func (c *AppValueWatcher) addAnnotation(ctx context.Context, app *v1alpha1.App,
 	return nil
 }
 
-func getResourceVersion(resourceVersion string) (uint64, error) {
-	r, err := strconv.ParseUint(resourceVersion, 0, 64)
-	if err != nil {
-		return 0, microerror.Mask(err)
-	}
-
-	return r, nil
-}
-
 // toConfigMap converts the input into a ConfigMap.
 func toConfigMap(v interface{}) (*corev1.ConfigMap, error) {
 	if v == nil {
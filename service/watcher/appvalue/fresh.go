@@ -0,0 +1,132 @@
+package appvalue
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/app/v8/pkg/key"
+	"github.com/giantswarm/microerror"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/giantswarm/app-operator/v7/pkg/valuesource"
+)
+
+// isFresh returns true when the values generated for the app CR already
+// reflect the change of the trigger, e.g. because the app CR was reconciled
+// after the change or the change was seen before. The sources recorded in
+// the values-sources annotation of the configmap and secret the chart CR
+// points at are compared with the trigger.
+//
+// Only the values of in-cluster app CRs are generated in the management
+// cluster so the app CRs of workload clusters are always updated. So are app
+// CRs whose referenced app was deployed since the trigger carries the
+// version of the app CR instead of its values. Errors are logged and the app
+// CR is updated.
+func (c *AppValueWatcher) isFresh(ctx context.Context, cr v1alpha1.App, t trigger) bool {
+	if c.chartNamespace == "" || t.Deleted || t.ResourceVersion == "" || !key.InCluster(cr) {
+		return false
+	}
+
+	var sourceType string
+	var isFresh func([]valuesource.Source) bool
+	{
+		r := t.Resource
+
+		switch r.ResourceType {
+		case configMapType, secretType:
+			sourceType = valuesource.TypeConfigMap
+			if r.ResourceType == secretType {
+				sourceType = valuesource.TypeSecret
+			}
+			isFresh = func(sources []valuesource.Source) bool {
+				return valuesource.IsFresh(sources, sourceType, r.Name, r.Namespace, t.ResourceVersion)
+			}
+		case objectType:
+			sourceType = valuesource.TypeConfigMap
+			isFresh = func(sources []valuesource.Source) bool {
+				return valuesource.IsRefFresh(sources, valuesource.Source{
+					Kind:            valuesource.KindObjectRef,
+					Name:            r.Name,
+					Namespace:       r.Namespace,
+					ResourceVersion: t.ResourceVersion,
+					APIVersion:      r.APIVersion,
+					ObjectKind:      r.Kind,
+				})
+			}
+		case secretStoreType:
+			sourceType = valuesource.TypeSecret
+			isFresh = func(sources []valuesource.Source) bool {
+				return valuesource.IsRefFresh(sources, valuesource.Source{
+					Kind:            valuesource.KindSecretStore,
+					Name:            r.Name,
+					ResourceVersion: t.ResourceVersion,
+					Provider:        r.Kind,
+				})
+			}
+		default:
+			return false
+		}
+	}
+
+	sources, err := c.generatedSources(ctx, cr, sourceType)
+	if err != nil {
+		c.logger.LogCtx(ctx, "level", "info", "message", fmt.Sprintf("failed to get value sources of app %#q in namespace %#q", cr.Name, cr.Namespace), "stack", fmt.Sprintf("%#v", err))
+		return false
+	}
+
+	return isFresh(sources)
+}
+
+// generatedSources returns the sources of the configmap or secret the chart
+// CR of the in-cluster app CR points at. There are no sources when the
+// chart CR or the configmap or secret do not exist yet.
+func (c *AppValueWatcher) generatedSources(ctx context.Context, cr v1alpha1.App, sourceType string) ([]valuesource.Source, error) {
+	var chart v1alpha1.Chart
+	err := c.k8sClient.CtrlClient().Get(ctx, types.NamespacedName{Name: key.ChartName(cr, c.workloadClusterID), Namespace: c.chartNamespace}, &chart)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var annotations map[string]string
+	if sourceType == valuesource.TypeSecret {
+		name, namespace := chart.Spec.Config.Secret.Name, chart.Spec.Config.Secret.Namespace
+		if name == "" {
+			return nil, nil
+		}
+
+		secret, err := c.k8sClient.K8sClient().CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		} else if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		annotations = secret.Annotations
+	} else {
+		name, namespace := chart.Spec.Config.ConfigMap.Name, chart.Spec.Config.ConfigMap.Namespace
+		if name == "" {
+			return nil, nil
+		}
+
+		configMap, err := c.k8sClient.K8sClient().CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		} else if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		annotations = configMap.Annotations
+	}
+
+	sources, err := valuesource.FromAnnotation(annotations)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return sources, nil
+}
@@ -0,0 +1,42 @@
+package appvalue
+
+import "github.com/prometheus/client_golang/prometheus"
+
+const (
+	PrometheusNamespace = "app_operator"
+	PrometheusSubsystem = "value_triggers"
+)
+
+var (
+	triggersQueued = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: PrometheusNamespace,
+			Subsystem: PrometheusSubsystem,
+			Name:      "queued",
+			Help:      "App CRs waiting to be updated because resources their values are sourced from changed.",
+		},
+	)
+	triggersCoalesced = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: PrometheusNamespace,
+			Subsystem: PrometheusSubsystem,
+			Name:      "coalesced_total",
+			Help:      "Number of changes merged into an already queued update of an app CR.",
+		},
+	)
+	triggersDropped = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: PrometheusNamespace,
+			Subsystem: PrometheusSubsystem,
+			Name:      "dropped_total",
+			Help:      "Number of triggers or app CR updates dropped after exceeding the retries.",
+		},
+		[]string{"queue"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(triggersQueued)
+	prometheus.MustRegister(triggersCoalesced)
+	prometheus.MustRegister(triggersDropped)
+}
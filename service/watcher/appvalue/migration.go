@@ -0,0 +1,46 @@
+package appvalue
+
+import (
+	"context"
+
+	"github.com/giantswarm/k8smetadata/pkg/label"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// removeWatchingLabels queues the configmaps and secrets labeled in label
+// mode so syncLabel removes the label. It is used in index mode where
+// configmaps and secrets are watched without modifying them.
+func (c *AppValueWatcher) removeWatchingLabels(ctx context.Context) {
+	lo := metav1.ListOptions{
+		LabelSelector: label.AppOperatorWatching,
+	}
+
+	cms, err := c.k8sClient.K8sClient().CoreV1().ConfigMaps(metav1.NamespaceAll).List(ctx, lo)
+	if err != nil {
+		c.logger.Errorf(ctx, err, "failed to list configmaps with label %#q", label.AppOperatorWatching)
+	} else {
+		for _, cm := range cms.Items {
+			c.labelQueue.Add(resourceIndex{
+				ResourceType: configMapType,
+				Name:         cm.GetName(),
+				Namespace:    cm.GetNamespace(),
+			})
+		}
+	}
+
+	for _, namespace := range c.allowedSecretNamespaces() {
+		secrets, err := c.k8sClient.K8sClient().CoreV1().Secrets(namespace).List(ctx, lo)
+		if err != nil {
+			c.logger.Errorf(ctx, err, "failed to list secrets with label %#q in namespace %#q", label.AppOperatorWatching, namespace)
+			continue
+		}
+
+		for _, secret := range secrets.Items {
+			c.labelQueue.Add(resourceIndex{
+				ResourceType: secretType,
+				Name:         secret.GetName(),
+				Namespace:    secret.GetNamespace(),
+			})
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package appvalue
+
+import (
+	"context"
+	"sort"
+
+	"github.com/giantswarm/k8smetadata/pkg/label"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+)
+
+// namespaceWatch is a type of resources watched in a namespace.
+type namespaceWatch struct {
+	ResourceType resourceType
+	Namespace    string
+}
+
+// ensureNamespaceWatch starts an informer for the configmaps or secrets of
+// the namespace unless they are already watched. In label mode only labeled
+// objects are watched.
+func (c *AppValueWatcher) ensureNamespaceWatch(ctx context.Context, resType resourceType, namespace string) {
+	c.namespaceFactoriesMutex.Lock()
+	defer c.namespaceFactoriesMutex.Unlock()
+
+	w := namespaceWatch{
+		ResourceType: resType,
+		Namespace:    namespace,
+	}
+	if _, ok := c.namespaceFactories[w]; ok {
+		return
+	}
+
+	options := []informers.SharedInformerOption{
+		informers.WithNamespace(namespace),
+	}
+	if c.watchMode == WatchModeLabel {
+		options = append(options, informers.WithTweakListOptions(func(lo *metav1.ListOptions) {
+			lo.LabelSelector = label.AppOperatorWatching
+		}))
+	} else {
+		options = append(options, informers.WithTransform(stripManagedFields))
+	}
+
+	factory := informers.NewSharedInformerFactoryWithOptions(c.k8sClient.K8sClient(), c.resyncPeriod, options...)
+
+	var err error
+	switch resType {
+	case configMapType:
+		_, err = factory.Core().V1().ConfigMaps().Informer().AddEventHandler(c.configMapHandler())
+	case secretType:
+		_, err = factory.Core().V1().Secrets().Informer().AddEventHandler(c.secretHandler())
+	}
+	if err != nil {
+		c.logger.Errorf(ctx, err, "failed to watch %ss in namespace %#q", resType, namespace)
+		return
+	}
+
+	factory.Start(ctx.Done())
+	c.namespaceFactories[w] = factory
+
+	c.logger.Debugf(ctx, "watching %ss in namespace %#q", resType, namespace)
+}
+
+// isSecretNamespaceAllowed returns whether secrets may be watched in the
+// namespace. It is the namespace of app-operator, one of the allowed
+// namespaces or a namespace matching the allowed namespace selector.
+func (c *AppValueWatcher) isSecretNamespaceAllowed(namespace string) bool {
+	if c.secretNamespaces[namespace] {
+		return true
+	}
+	if c.namespaceFactory == nil {
+		return false
+	}
+
+	ns, err := c.namespaceFactory.Core().V1().Namespaces().Lister().Get(namespace)
+	if err != nil {
+		return false
+	}
+
+	return c.secretNamespaceSelector.Matches(labels.Set(ns.GetLabels()))
+}
+
+// allowedSecretNamespaces returns the namespaces secrets may be watched in.
+func (c *AppValueWatcher) allowedSecretNamespaces() []string {
+	allowed := map[string]bool{}
+	for namespace := range c.secretNamespaces {
+		allowed[namespace] = true
+	}
+
+	if c.namespaceFactory != nil {
+		namespaces, err := c.namespaceFactory.Core().V1().Namespaces().Lister().List(c.secretNamespaceSelector)
+		if err == nil {
+			for _, ns := range namespaces {
+				allowed[ns.GetName()] = true
+			}
+		}
+	}
+
+	var result []string
+	for namespace := range allowed {
+		result = append(result, namespace)
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+// stripManagedFields removes the managed fields of cached objects since all
+// configmaps and secrets of the namespace are cached in index mode.
+func stripManagedFields(obj interface{}) (interface{}, error) {
+	if accessor, err := meta.Accessor(obj); err == nil {
+		accessor.SetManagedFields(nil)
+	}
+
+	return obj, nil
+}
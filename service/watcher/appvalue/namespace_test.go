@@ -0,0 +1,115 @@
+package appvalue
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/giantswarm/k8sclient/v7/pkg/k8sclienttest"
+	"github.com/giantswarm/micrologger/microloggertest"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgofake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/giantswarm/app-operator/v7/service/internal/recorder"
+)
+
+func Test_isSecretNamespaceAllowed(t *testing.T) {
+	newNamespace := func(name string, labels map[string]string) runtime.Object {
+		return &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: labels,
+			},
+		}
+	}
+
+	namespaces := []runtime.Object{
+		newNamespace("giantswarm", nil),
+		newNamespace("org-acme", map[string]string{"giantswarm.io/organization": "acme"}),
+		newNamespace("org-umbrella", map[string]string{"giantswarm.io/organization": "umbrella"}),
+		newNamespace("kube-system", nil),
+	}
+
+	tests := []struct {
+		name              string
+		secretNamespaces  []string
+		namespaceSelector string
+		expectedAllowed   []string
+	}{
+		{
+			name:            "case 0: only the namespace of app-operator is allowed by default",
+			expectedAllowed: []string{"giantswarm"},
+		},
+		{
+			name:             "case 1: allowed namespaces",
+			secretNamespaces: []string{"org-acme"},
+			expectedAllowed:  []string{"giantswarm", "org-acme"},
+		},
+		{
+			name:              "case 2: namespaces matching the selector are allowed",
+			namespaceSelector: "giantswarm.io/organization",
+			expectedAllowed:   []string{"giantswarm", "org-acme", "org-umbrella"},
+		},
+		{
+			name:              "case 3: allowed namespaces and selector are combined",
+			secretNamespaces:  []string{"kube-system"},
+			namespaceSelector: "giantswarm.io/organization=umbrella",
+			expectedAllowed:   []string{"giantswarm", "kube-system", "org-umbrella"},
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			clients := k8sclienttest.NewClients(k8sclienttest.ClientsConfig{
+				K8sClient: clientgofake.NewSimpleClientset(namespaces...),
+			})
+
+			c := AppValueWatcherConfig{
+				Event: recorder.New(recorder.Config{
+					K8sClient: clients,
+				}),
+				K8sClient: clients,
+				Logger:    microloggertest.New(),
+
+				SecretNamespace:         "giantswarm",
+				SecretNamespaces:        tc.secretNamespaces,
+				SecretNamespaceSelector: tc.namespaceSelector,
+			}
+			w, err := NewAppValueWatcher(c)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			if w.namespaceFactory != nil {
+				informer := w.namespaceFactory.Core().V1().Namespaces().Informer()
+				w.namespaceFactory.Start(ctx.Done())
+				if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+					t.Fatalf("namespace informer did not sync")
+				}
+			}
+
+			var allowed []string
+			for _, namespace := range []string{"giantswarm", "kube-system", "org-acme", "org-umbrella", "org-missing"} {
+				if w.isSecretNamespaceAllowed(namespace) {
+					allowed = append(allowed, namespace)
+				}
+			}
+
+			if diff := cmp.Diff(tc.expectedAllowed, allowed); diff != "" {
+				t.Fatalf("want matching allowed namespaces \n %s", diff)
+			}
+			if diff := cmp.Diff(tc.expectedAllowed, w.allowedSecretNamespaces()); diff != "" {
+				t.Fatalf("want matching allowed namespaces \n %s", diff)
+			}
+		})
+	}
+}
@@ -2,135 +2,80 @@ package appvalue
 
 import (
 	"context"
-	"fmt"
+	"reflect"
 
-	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
-	"github.com/giantswarm/k8smetadata/pkg/label"
 	"github.com/giantswarm/microerror"
 	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
 )
 
-func (c *AppValueWatcher) watchSecret(ctx context.Context) {
-	for {
-		lo := metav1.ListOptions{
-			LabelSelector: label.AppOperatorWatching,
-		}
-
-		// Find the highest resourceVersion for each secret.
-		secrets, err := c.k8sClient.K8sClient().CoreV1().Secrets(c.secretNamespace).List(ctx, lo)
-		if err != nil {
-			c.logger.LogCtx(ctx, "level", "info", "message", fmt.Sprintf("failed to get secrets with label %#q", label.AppOperatorWatching), "stack", fmt.Sprintf("%#v", err))
-			continue
-		}
-
-		var highestResourceVersion uint64
-
-		for _, secret := range secrets.Items {
-			currentResourceVersion, err := getResourceVersion(secret.GetResourceVersion())
-			if err != nil {
-				c.logger.LogCtx(ctx, "level", "info", "message", fmt.Sprintf("failed to get resourceVersion from secrets %#q in namespace %#q", secret.GetName(), secret.GetNamespace()), "stack", fmt.Sprintf("%#v", err))
-				continue
+// secretHandler queues triggers for the apps depending on secrets when their data changes.
+func (c *AppValueWatcher) secretHandler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			// Secrets listed on start are not changes.
+			if isInInitialList {
+				return
 			}
-			if highestResourceVersion < currentResourceVersion {
-				highestResourceVersion = currentResourceVersion
-			}
-		}
-
-		c.logger.LogCtx(ctx, "debug", fmt.Sprintf("starting ResourceVersion is %d", highestResourceVersion))
-
-		res, err := c.k8sClient.K8sClient().CoreV1().Secrets(c.secretNamespace).Watch(ctx, lo)
-		if err != nil {
-			c.logger.LogCtx(ctx, "level", "info", "message", fmt.Sprintf("failed to get secrets with label %#q", label.AppOperatorWatching), "stack", fmt.Sprintf("%#v", err))
-			continue
-		}
 
-		for r := range res.ResultChan() {
-			if r.Type == watch.Bookmark {
-				// no-op for unsupported events
-				continue
-			}
-
-			if r.Type == watch.Error {
-				c.logger.LogCtx(ctx, "level", "info", "message", fmt.Sprintf("got error event: %#q", r.Object))
-				continue
-			}
-
-			secret, err := toSecret(r.Object)
+			c.enqueueSecret(obj, false)
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldSecret, err := toSecret(oldObj)
 			if err != nil {
-				c.logger.LogCtx(ctx, "level", "info", "message", "failed to convert secret object", "stack", fmt.Sprintf("%#v", err))
-				continue
+				return
 			}
-
-			v, err := getResourceVersion(secret.GetResourceVersion())
+			newSecret, err := toSecret(newObj)
 			if err != nil {
-				c.logger.LogCtx(ctx, "level", "info", "message", fmt.Sprintf("failed to get resourceVersion from secrets %#q in namespace %#q", secret.GetName(), secret.GetNamespace()), "stack", fmt.Sprintf("%#v", err))
-				continue
-			}
-
-			if v <= highestResourceVersion {
-				// no-op
-				c.logger.LogCtx(ctx, "level", "info", "message", fmt.Sprintf("no need to reconcile for the older resourceVersion %d", v))
-				continue
+				return
 			}
 
-			secretIndex := resourceIndex{
-				ResourceType: secretType,
-				Name:         secret.GetName(),
-				Namespace:    secret.GetNamespace(),
+			// Resyncs and label changes do not change the values.
+			if reflect.DeepEqual(oldSecret.Data, newSecret.Data) && oldSecret.Type == newSecret.Type {
+				return
 			}
 
-			var storedIndex map[appIndex]bool
-			{
-				v, ok := c.resourcesToApps.Load(secretIndex)
-				if !ok {
-					c.logger.Debugf(ctx, "cache missed secret %#q in namespace %#q", secret.Name, secret.Namespace)
-					continue
-				}
-
-				storedIndex, ok = v.(map[appIndex]bool)
-				if !ok {
-					c.logger.LogCtx(ctx, "level", "info", "message", fmt.Sprintf("expected '%T', got '%T'", map[appIndex]bool{}, v), "stack", fmt.Sprintf("%#v", err))
-					continue
-				}
+			c.enqueueSecret(newSecret, false)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
 			}
 
-			c.logger.Debugf(ctx, "listing apps depends on %#q secret in namespace %#q", secret.Name, secret.Namespace)
-
-			var currentApp v1alpha1.App
-
-			c.appIndexMutex.RLock()
-			for app := range storedIndex {
-				c.logger.Debugf(ctx, "triggering %#q app update in namespace %#q", app.Name, app.Namespace)
-
-				err = c.k8sClient.CtrlClient().Get(
-					ctx,
-					types.NamespacedName{Name: app.Name, Namespace: app.Namespace},
-					&currentApp,
-				)
-				if err != nil {
-					c.logger.Errorf(ctx, err, "cannot fetch app CR %s/%s", app.Namespace, app.Name)
-					continue
-				}
-
-				err = c.addAnnotation(ctx, &currentApp, secret.GetResourceVersion(), secretType)
-				if err != nil {
-					c.logger.LogCtx(ctx, "level", "info", "message", fmt.Sprintf("failed to add annotation to app %#q in namespace %#q", app.Name, app.Namespace), "stack", fmt.Sprintf("%#v", err))
-					continue
-				}
+			c.enqueueSecret(obj, true)
+		},
+	}
+}
 
-				c.logger.Debugf(ctx, "triggered %#q app update in namespace %#q", app.Name, app.Namespace)
+func (c *AppValueWatcher) enqueueSecret(obj interface{}, deleted bool) {
+	secret, err := toSecret(obj)
+	if err != nil {
+		c.logger.Errorf(context.Background(), err, "failed to convert secret object")
+		return
+	}
 
-				c.event.Emit(ctx, &currentApp, "AppUpdated", "change to secret %s/%s triggered an update", secret.Namespace, secret.Name)
-			}
-			c.appIndexMutex.RUnlock()
-			c.logger.Debugf(ctx, "listed apps depends on %#q secret in namespace %#q", secret.Name, secret.Namespace)
-		}
+	resource := resourceIndex{
+		ResourceType: secretType,
+		Name:         secret.GetName(),
+		Namespace:    secret.GetNamespace(),
+	}
 
-		c.logger.Log("debug", "watch channel had been closed, reopening...")
+	// In index mode all secrets of the namespace are watched so the
+	// ones no app CR sources values from are skipped.
+	referenced, err := c.isReferenced(resource)
+	if err != nil {
+		c.logger.Errorf(context.Background(), err, "failed to look up app CRs of secret %#q in namespace %#q", resource.Name, resource.Namespace)
+		return
+	}
+	if !referenced {
+		return
 	}
+
+	c.triggerQueue.Add(trigger{
+		Resource:        resource,
+		ResourceVersion: secret.GetResourceVersion(),
+		Deleted:         deleted,
+	})
 }
 
 // toSecret converts the input into a Secret.
@@ -0,0 +1,50 @@
+package appvalue
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// refreshSecretStores resolves the values of all secret store references
+// every refresh interval and triggers the apps referencing values which
+// changed. Secret stores are polled since they cannot be watched.
+func (c *AppValueWatcher) refreshSecretStores(ctx context.Context) {
+	ticker := time.NewTicker(c.secretStoreRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var resources []resourceIndex
+		for _, k := range c.appInformer.GetIndexer().ListIndexFuncValues(resourcesIndex) {
+			resource, err := resourceFromKey(k)
+			if err != nil {
+				c.logger.Errorf(ctx, err, "failed to parse resource %#q", k)
+				continue
+			}
+			if resource.ResourceType == secretStoreType {
+				resources = append(resources, resource)
+			}
+		}
+
+		for _, resource := range resources {
+			version, changed, err := c.secretStore.Refresh(ctx, resource.Kind, resource.Name)
+			if err != nil {
+				c.logger.LogCtx(ctx, "level", "info", "message", fmt.Sprintf("failed to refresh values at %#q of secret store %#q", resource.Name, resource.Kind), "stack", fmt.Sprintf("%#v", err))
+				continue
+			}
+			if !changed {
+				continue
+			}
+
+			c.logger.Debugf(ctx, "values at %#q of secret store %#q changed", resource.Name, resource.Kind)
+
+			c.triggerQueue.Add(trigger{Resource: resource, ResourceVersion: version})
+		}
+	}
+}
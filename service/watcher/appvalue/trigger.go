@@ -0,0 +1,214 @@
+package appvalue
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/app/v8/pkg/key"
+	"github.com/giantswarm/microerror"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/priorityqueue"
+)
+
+// Priorities of app updates. When many app CRs are waiting to be updated the
+// ones whose own values changed are updated before the ones whose catalog
+// values changed.
+const (
+	catalogPriority = iota
+	extraConfigPriority
+	appConfigPriority
+	userConfigPriority
+)
+
+// pendingUpdate collects the changes of resources an app CR depends on
+// until the app CR is updated.
+type pendingUpdate struct {
+	// versions are the version annotations set on the app CR.
+	versions map[string]string
+	// resources describe the changed resources for the event.
+	resources map[string]bool
+}
+
+// triggerApps queues updates of the app CRs depending on the resource of the
+// trigger. Updates of an app CR are delayed by the debounce window so bursts
+// of changes are coalesced into one update. App CRs whose values already
+// reflect the change are skipped.
+func (c *AppValueWatcher) triggerApps(ctx context.Context, t trigger) error {
+	objs, err := c.appInformer.GetIndexer().ByIndex(resourcesIndex, t.Resource.key())
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	for _, obj := range objs {
+		cr, err := toApp(obj)
+		if err != nil {
+			c.logger.Errorf(ctx, err, "failed to convert %#v to app", obj)
+			continue
+		}
+
+		// The values of app CRs of other replicas are not checked.
+		if !c.owns(cr) {
+			continue
+		}
+		if c.isFresh(ctx, cr, t) {
+			c.logger.Debugf(ctx, "skipping %#q app update in namespace %#q since its values reflect %s", cr.Name, cr.Namespace, describe(t.Resource))
+			continue
+		}
+
+		c.enqueueUpdate(cr, t, c.triggerPriority(ctx, cr, t.Resource))
+	}
+
+	return nil
+}
+
+func (c *AppValueWatcher) enqueueUpdate(cr v1alpha1.App, t trigger, priority int) {
+	// App CRs of other replicas are updated by them.
+	if !c.owns(cr) {
+		return
+	}
+
+	app := appIndex{
+		Name:      cr.GetName(),
+		Namespace: cr.GetNamespace(),
+	}
+
+	c.pendingMutex.Lock()
+	update, ok := c.pending[app]
+	if ok {
+		triggersCoalesced.Inc()
+	} else {
+		update = pendingUpdate{
+			versions:  map[string]string{},
+			resources: map[string]bool{},
+		}
+		c.pending[app] = update
+	}
+	update.versions[versionAnnotation(t.Resource.ResourceType)] = t.ResourceVersion
+	update.resources[describe(t.Resource)] = true
+	triggersQueued.Set(float64(len(c.pending)))
+	c.pendingMutex.Unlock()
+
+	// The queue keeps the highest priority and the earliest time of an app
+	// CR which is already queued.
+	c.appQueue.AddWithOpts(priorityqueue.AddOpts{
+		After:    c.triggerDebounce,
+		Priority: &priority,
+	}, app)
+}
+
+// updateApp annotates the app CR with the versions of the changed resources
+// so it is reconciled. Updates are rate limited globally.
+func (c *AppValueWatcher) updateApp(ctx context.Context, app appIndex) error {
+	err := c.triggerLimiter.Wait(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	c.pendingMutex.Lock()
+	update, ok := c.pending[app]
+	delete(c.pending, app)
+	triggersQueued.Set(float64(len(c.pending)))
+	c.pendingMutex.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	c.logger.Debugf(ctx, "triggering %#q app update in namespace %#q", app.Name, app.Namespace)
+
+	var currentApp v1alpha1.App
+	err = c.k8sClient.CtrlClient().Get(ctx, types.NamespacedName{Name: app.Name, Namespace: app.Namespace}, &currentApp)
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		c.restoreUpdate(app, update)
+		return microerror.Mask(err)
+	}
+
+	err = c.addAnnotations(ctx, &currentApp, update.versions)
+	if err != nil {
+		c.restoreUpdate(app, update)
+		return microerror.Mask(err)
+	}
+
+	c.logger.Debugf(ctx, "triggered %#q app update in namespace %#q", app.Name, app.Namespace)
+
+	var resources []string
+	for r := range update.resources {
+		resources = append(resources, r)
+	}
+	sort.Strings(resources)
+
+	c.event.Emit(ctx, &currentApp, "AppUpdated", "change to %s triggered an update", strings.Join(resources, ", "))
+
+	return nil
+}
+
+// restoreUpdate keeps the changes of a failed update for the retry. Newer
+// changes queued in the meantime take precedence.
+func (c *AppValueWatcher) restoreUpdate(app appIndex, update pendingUpdate) {
+	c.pendingMutex.Lock()
+	defer c.pendingMutex.Unlock()
+
+	if newer, ok := c.pending[app]; ok {
+		for k, v := range newer.versions {
+			update.versions[k] = v
+		}
+		for r := range newer.resources {
+			update.resources[r] = true
+		}
+	}
+
+	c.pending[app] = update
+	triggersQueued.Set(float64(len(c.pending)))
+}
+
+// dropUpdate discards the changes of an app CR whose update was dropped.
+func (c *AppValueWatcher) dropUpdate(app appIndex) {
+	c.pendingMutex.Lock()
+	defer c.pendingMutex.Unlock()
+
+	delete(c.pending, app)
+	triggersQueued.Set(float64(len(c.pending)))
+}
+
+// triggerPriority returns the priority of the update of the app CR caused by
+// a change of the resource.
+func (c *AppValueWatcher) triggerPriority(ctx context.Context, cr v1alpha1.App, resource resourceIndex) int {
+	is := func(resType resourceType, name, namespace string) bool {
+		return name != "" && resource.ResourceType == resType && resource.Name == name && resource.Namespace == namespace
+	}
+
+	switch {
+	case is(configMapType, key.UserConfigMapName(cr), key.UserConfigMapNamespace(cr)),
+		is(secretType, key.UserSecretName(cr), key.UserSecretNamespace(cr)):
+		return userConfigPriority
+	case is(configMapType, key.AppConfigMapName(cr), key.AppConfigMapNamespace(cr)),
+		is(secretType, key.AppSecretName(cr), key.AppSecretNamespace(cr)):
+		return appConfigPriority
+	}
+
+	catalog, err := c.findCatalog(cr)
+	if err == nil && (is(configMapType, key.CatalogConfigMapName(*catalog), key.CatalogConfigMapNamespace(*catalog)) ||
+		is(secretType, key.CatalogSecretName(*catalog), key.CatalogSecretNamespace(*catalog))) {
+		return catalogPriority
+	}
+
+	// Extra configs and value references.
+	return extraConfigPriority
+}
+
+func describe(resource resourceIndex) string {
+	switch resource.ResourceType {
+	case secretStoreType:
+		return fmt.Sprintf("%s %s/%s", resource.ResourceType, resource.Kind, resource.Name)
+	case objectType:
+		return fmt.Sprintf("%s %s %s/%s", resource.ResourceType, resource.Kind, resource.Namespace, resource.Name)
+	default:
+		return fmt.Sprintf("%s %s/%s", resource.ResourceType, resource.Namespace, resource.Name)
+	}
+}
@@ -0,0 +1,299 @@
+package appvalue
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/k8sclient/v7/pkg/k8sclienttest"
+	"github.com/giantswarm/k8smetadata/pkg/annotation"
+	"github.com/giantswarm/micrologger/microloggertest"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clientgofake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/giantswarm/app-operator/v7/pkg/valuesource"
+	"github.com/giantswarm/app-operator/v7/service/internal/recorder"
+)
+
+func newTestApp() *v1alpha1.App {
+	return &v1alpha1.App{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-app",
+			Namespace: "org-acme",
+		},
+		Spec: v1alpha1.AppSpec{
+			Catalog:          "giantswarm",
+			CatalogNamespace: "default",
+			Config: v1alpha1.AppSpecConfig{
+				ConfigMap: v1alpha1.AppSpecConfigConfigMap{
+					Name:      "test-app-values",
+					Namespace: "org-acme",
+				},
+			},
+			ExtraConfigs: []v1alpha1.AppExtraConfig{
+				{
+					Kind:      "configMap",
+					Name:      "test-app-extra",
+					Namespace: "org-acme",
+				},
+			},
+			UserConfig: v1alpha1.AppSpecUserConfig{
+				Secret: v1alpha1.AppSpecUserConfigSecret{
+					Name:      "test-app-user-secrets",
+					Namespace: "org-acme",
+				},
+			},
+		},
+	}
+}
+
+func newTestWatcher(t *testing.T, objs ...runtime.Object) *AppValueWatcher {
+	s := runtime.NewScheme()
+	err := v1alpha1.AddToScheme(s)
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	clients := k8sclienttest.NewClients(k8sclienttest.ClientsConfig{
+		CtrlClient: fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(objs...).Build(),
+		DynClient: dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+			appResource:     "AppList",
+			catalogResource: "CatalogList",
+		}),
+		K8sClient: clientgofake.NewSimpleClientset(),
+	})
+
+	c := AppValueWatcherConfig{
+		Event: recorder.New(recorder.Config{
+			K8sClient: clients,
+		}),
+		K8sClient: clients,
+		Logger:    microloggertest.New(),
+
+		SecretNamespace: "giantswarm",
+	}
+	w, err := NewAppValueWatcher(c)
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	// The informers are not started. Their stores are filled by the tests.
+	w.catalogs = w.objectFactory.ForResource(catalogResource).Informer()
+	catalog := &unstructured.Unstructured{}
+	catalog.SetAPIVersion("application.giantswarm.io/v1alpha1")
+	catalog.SetKind("Catalog")
+	catalog.SetName("giantswarm")
+	catalog.SetNamespace("default")
+	err = unstructured.SetNestedMap(catalog.Object, map[string]interface{}{
+		"configMap": map[string]interface{}{
+			"name":      "giantswarm-catalog",
+			"namespace": "default",
+		},
+	}, "spec", "config")
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+	err = w.catalogs.GetIndexer().Add(catalog)
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	return w
+}
+
+func Test_triggerPriority(t *testing.T) {
+	tests := []struct {
+		name             string
+		resource         resourceIndex
+		expectedPriority int
+	}{
+		{
+			name:             "case 0: user config",
+			resource:         resourceIndex{ResourceType: secretType, Name: "test-app-user-secrets", Namespace: "org-acme"},
+			expectedPriority: userConfigPriority,
+		},
+		{
+			name:             "case 1: app config",
+			resource:         resourceIndex{ResourceType: configMapType, Name: "test-app-values", Namespace: "org-acme"},
+			expectedPriority: appConfigPriority,
+		},
+		{
+			name:             "case 2: extra config",
+			resource:         resourceIndex{ResourceType: configMapType, Name: "test-app-extra", Namespace: "org-acme"},
+			expectedPriority: extraConfigPriority,
+		},
+		{
+			name:             "case 3: catalog config",
+			resource:         resourceIndex{ResourceType: configMapType, Name: "giantswarm-catalog", Namespace: "default"},
+			expectedPriority: catalogPriority,
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			w := newTestWatcher(t)
+
+			priority := w.triggerPriority(context.Background(), *newTestApp(), tc.resource)
+			if priority != tc.expectedPriority {
+				t.Fatalf("priority == %d, want %d", priority, tc.expectedPriority)
+			}
+		})
+	}
+}
+
+func Test_updateApp(t *testing.T) {
+	app := newTestApp()
+
+	w := newTestWatcher(t, app)
+	defer w.appQueue.ShutDown()
+
+	// A burst of changes is coalesced into one update of the app CR.
+	triggers := []trigger{
+		{Resource: resourceIndex{ResourceType: configMapType, Name: "test-app-values", Namespace: "org-acme"}, ResourceVersion: "1"},
+		{Resource: resourceIndex{ResourceType: secretType, Name: "test-app-user-secrets", Namespace: "org-acme"}, ResourceVersion: "7"},
+		{Resource: resourceIndex{ResourceType: configMapType, Name: "test-app-values", Namespace: "org-acme"}, ResourceVersion: "2"},
+	}
+	for _, tr := range triggers {
+		w.enqueueUpdate(*app, tr, appConfigPriority)
+	}
+
+	if w.appQueue.Len() != 1 {
+		t.Fatalf("queue length == %d, want 1", w.appQueue.Len())
+	}
+
+	item, priority, _ := w.appQueue.GetWithPriority()
+	if priority != appConfigPriority {
+		t.Fatalf("priority == %d, want %d", priority, appConfigPriority)
+	}
+
+	err := w.updateApp(context.Background(), item)
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+	w.appQueue.Done(item)
+
+	var current v1alpha1.App
+	err = w.k8sClient.CtrlClient().Get(context.Background(), types.NamespacedName{Name: app.Name, Namespace: app.Namespace}, &current)
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	expectedAnnotations := map[string]string{
+		annotation.AppOperatorLatestConfigMapVersion: "2",
+		annotation.AppOperatorLatestSecretVersion:    "7",
+	}
+	if diff := cmp.Diff(expectedAnnotations, current.Annotations); diff != "" {
+		t.Fatalf("want matching annotations \n %s", diff)
+	}
+
+	if len(w.pending) != 0 {
+		t.Fatalf("pending == %d, want 0", len(w.pending))
+	}
+}
+
+func Test_isFresh(t *testing.T) {
+	tests := []struct {
+		name          string
+		trigger       trigger
+		inCluster     bool
+		expectedFresh bool
+	}{
+		{
+			name:          "case 0: configmap version is in the sources",
+			trigger:       trigger{Resource: resourceIndex{ResourceType: configMapType, Name: "test-app-values", Namespace: "org-acme"}, ResourceVersion: "1"},
+			inCluster:     true,
+			expectedFresh: true,
+		},
+		{
+			name:      "case 1: configmap changed since the values were generated",
+			trigger:   trigger{Resource: resourceIndex{ResourceType: configMapType, Name: "test-app-values", Namespace: "org-acme"}, ResourceVersion: "2"},
+			inCluster: true,
+		},
+		{
+			name:      "case 2: deleted configmap",
+			trigger:   trigger{Resource: resourceIndex{ResourceType: configMapType, Name: "test-app-values", Namespace: "org-acme"}, ResourceVersion: "1", Deleted: true},
+			inCluster: true,
+		},
+		{
+			name:          "case 3: object reference version is in the sources",
+			trigger:       trigger{Resource: resourceIndex{ResourceType: objectType, Name: "database", Namespace: "org-acme", APIVersion: "example.com/v1", Kind: "Database"}, ResourceVersion: "5"},
+			inCluster:     true,
+			expectedFresh: true,
+		},
+		{
+			name:      "case 4: app CR of a workload cluster",
+			trigger:   trigger{Resource: resourceIndex{ResourceType: configMapType, Name: "test-app-values", Namespace: "org-acme"}, ResourceVersion: "1"},
+			inCluster: false,
+		},
+		{
+			name:      "case 5: referenced app deployed again",
+			trigger:   trigger{Resource: resourceIndex{ResourceType: appType, Name: "database", Namespace: "org-acme"}, ResourceVersion: "1"},
+			inCluster: true,
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			app := newTestApp()
+			app.Spec.KubeConfig.InCluster = tc.inCluster
+
+			chart := &v1alpha1.Chart{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-app",
+					Namespace: "giantswarm",
+				},
+				Spec: v1alpha1.ChartSpec{
+					Config: v1alpha1.ChartSpecConfig{
+						ConfigMap: v1alpha1.ChartSpecConfigConfigMap{
+							Name:      "test-app-chart-values",
+							Namespace: "giantswarm",
+						},
+					},
+				},
+			}
+
+			w := newTestWatcher(t, chart)
+			w.chartNamespace = "giantswarm"
+
+			sources := []valuesource.Source{
+				{Kind: valuesource.KindApp, Type: valuesource.TypeConfigMap, Name: "test-app-values", Namespace: "org-acme", ResourceVersion: "1", Priority: v1alpha1.ConfigPriorityCluster},
+				{Kind: valuesource.KindObjectRef, Type: valuesource.TypeConfigMap, Name: "database", Namespace: "org-acme", ResourceVersion: "5", Priority: v1alpha1.ConfigPriorityDefault, APIVersion: "example.com/v1", ObjectKind: "Database"},
+			}
+			sourcesAnnotation, err := valuesource.ToAnnotation(sources)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			_, err = w.k8sClient.K8sClient().CoreV1().ConfigMaps("giantswarm").Create(context.Background(), &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-app-chart-values",
+					Namespace: "giantswarm",
+					Annotations: map[string]string{
+						valuesource.Annotation: sourcesAnnotation,
+					},
+				},
+			}, metav1.CreateOptions{})
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			fresh := w.isFresh(context.Background(), *app, tc.trigger)
+			if fresh != tc.expectedFresh {
+				t.Fatalf("fresh == %t, want %t", fresh, tc.expectedFresh)
+			}
+		})
+	}
+}
@@ -1,10 +1,23 @@
 package appvalue
 
+import (
+	"encoding/json"
+
+	"github.com/giantswarm/microerror"
+)
+
 type resourceType string
 
 const (
 	configMapType resourceType = "configmap"
 	secretType    resourceType = "secret"
+	// appType and objectType are the types of value references. They are
+	// not labeled but tracked by watching the referenced resources.
+	appType    resourceType = "app"
+	objectType resourceType = "object"
+	// secretStoreType is the type of secret store references. Their values
+	// are resolved periodically since secret stores cannot be watched.
+	secretStoreType resourceType = "secretstore"
 )
 
 type appIndex struct {
@@ -16,6 +29,10 @@ type resourceIndex struct {
 	ResourceType resourceType
 	Name         string `json:"name"`
 	Namespace    string `json:"namespace"`
+	// APIVersion and Kind are only set for object references. For secret
+	// store references Kind is the provider and Name the path.
+	APIVersion string `json:"apiVersion,omitempty"`
+	Kind       string `json:"kind,omitempty"`
 }
 
 type patch struct {
@@ -23,3 +40,21 @@ type patch struct {
 	Path  string      `json:"path"`
 	Value interface{} `json:"value"`
 }
+
+// key returns the key the resource is indexed by. It is the JSON encoding of
+// the resource so it can be decoded again with resourceFromKey.
+func (r resourceIndex) key() string {
+	// resourceIndex only has string fields so the error is always nil.
+	b, _ := json.Marshal(r)
+	return string(b)
+}
+
+func resourceFromKey(key string) (resourceIndex, error) {
+	var r resourceIndex
+	err := json.Unmarshal([]byte(key), &r)
+	if err != nil {
+		return resourceIndex{}, microerror.Mask(err)
+	}
+
+	return r, nil
+}
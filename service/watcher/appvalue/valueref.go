@@ -0,0 +1,168 @@
+package appvalue
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/micrologger"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/giantswarm/app-operator/v7/pkg/valueref"
+)
+
+// valueRefResources returns the resources referenced in the values-refs
+// annotation of the app CR. Invalid references are reported when the app is
+// reconciled so they are only logged here. Secret store paths outside the
+// scope of the namespace of the app CR are not indexed so they are never
+// refreshed.
+func valueRefResources(ctx context.Context, logger micrologger.Logger, secretStorePathPrefixes map[string]string, cr v1alpha1.App) []resourceIndex {
+	refs, err := valueref.FromApp(cr)
+	if err != nil {
+		logger.LogCtx(ctx, "level", "info", "message", fmt.Sprintf("failed to parse value references of app %#q in namespace %#q", cr.Name, cr.Namespace), "stack", fmt.Sprintf("%#v", err))
+		return nil
+	}
+
+	var resources []resourceIndex
+	for _, ref := range refs {
+		switch ref.Kind {
+		case valueref.KindApp:
+			resources = append(resources, resourceIndex{
+				ResourceType: appType,
+				Name:         ref.Name,
+				Namespace:    ref.Namespace,
+			})
+		case valueref.KindObject:
+			resources = append(resources, resourceIndex{
+				ResourceType: objectType,
+				Name:         ref.Name,
+				Namespace:    ref.Namespace,
+				APIVersion:   ref.APIVersion,
+				Kind:         ref.ObjectKind,
+			})
+		case valueref.KindSecretStore:
+			err := valueref.CheckPath(secretStorePathPrefixes, cr, ref)
+			if err != nil {
+				logger.LogCtx(ctx, "level", "info", "message", fmt.Sprintf("skipping secret store reference of app %#q in namespace %#q", cr.Name, cr.Namespace), "stack", fmt.Sprintf("%#v", err))
+				continue
+			}
+
+			resources = append(resources, resourceIndex{
+				ResourceType: secretStoreType,
+				Name:         ref.Path,
+				Kind:         ref.Provider,
+			})
+		}
+	}
+
+	return resources
+}
+
+// triggerAppRefs triggers the apps referencing the given app when it was
+// deployed again or its spec changed. Only these changes are considered
+// since the values of the app change with them and since the annotation
+// patched to trigger an app must not trigger apps referencing it in turn.
+func (c *AppValueWatcher) triggerAppRefs(oldCR, newCR v1alpha1.App) {
+	if appFingerprint(oldCR) == appFingerprint(newCR) {
+		return
+	}
+
+	c.triggerQueue.Add(trigger{
+		Resource: resourceIndex{
+			ResourceType: appType,
+			Name:         newCR.GetName(),
+			Namespace:    newCR.GetNamespace(),
+		},
+		ResourceVersion: newCR.GetResourceVersion(),
+	})
+}
+
+func appFingerprint(cr v1alpha1.App) string {
+	return fmt.Sprintf("%d/%s/%s", cr.GetGeneration(), cr.Status.Release.Status, cr.Status.Release.LastDeployed.String())
+}
+
+// ensureObjectWatch starts an informer for objects of the given type unless
+// they are already watched. Failures are retried when the apps referencing
+// the objects are resynced.
+func (c *AppValueWatcher) ensureObjectWatch(ctx context.Context, apiVersion, kind string) {
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		c.logger.LogCtx(ctx, "level", "info", "message", fmt.Sprintf("failed to parse api version %#q", apiVersion), "stack", fmt.Sprintf("%#v", err))
+		return
+	}
+
+	mapping, err := c.k8sClient.CtrlClient().RESTMapper().RESTMapping(gv.WithKind(kind).GroupKind(), gv.Version)
+	if err != nil {
+		c.logger.LogCtx(ctx, "level", "info", "message", fmt.Sprintf("failed to watch %#q objects of %#q", kind, apiVersion), "stack", fmt.Sprintf("%#v", err))
+		return
+	}
+
+	c.objectWatchesMutex.Lock()
+	defer c.objectWatchesMutex.Unlock()
+
+	if c.objectWatches[mapping.Resource] {
+		return
+	}
+
+	informer := c.objectFactory.ForResource(mapping.Resource).Informer()
+	_, err = informer.AddEventHandler(c.objectHandler(apiVersion, kind))
+	if err != nil {
+		c.logger.LogCtx(ctx, "level", "info", "message", fmt.Sprintf("failed to watch %#q objects of %#q", kind, apiVersion), "stack", fmt.Sprintf("%#v", err))
+		return
+	}
+
+	c.objectFactory.Start(ctx.Done())
+	c.objectWatches[mapping.Resource] = true
+}
+
+// objectHandler queues triggers for the apps referencing changed objects.
+func (c *AppValueWatcher) objectHandler(apiVersion, kind string) cache.ResourceEventHandler {
+	enqueue := func(obj interface{}, deleted bool) {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+
+		u, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			return
+		}
+
+		c.triggerQueue.Add(trigger{
+			Resource: resourceIndex{
+				ResourceType: objectType,
+				Name:         u.GetName(),
+				Namespace:    u.GetNamespace(),
+				APIVersion:   apiVersion,
+				Kind:         kind,
+			},
+			ResourceVersion: u.GetResourceVersion(),
+			Deleted:         deleted,
+		})
+	}
+
+	return cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			// Objects listed on start are not changes.
+			if isInInitialList {
+				return
+			}
+
+			enqueue(obj, false)
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldU, oldOK := oldObj.(*unstructured.Unstructured)
+			newU, newOK := newObj.(*unstructured.Unstructured)
+			if oldOK && newOK && oldU.GetResourceVersion() == newU.GetResourceVersion() {
+				// Resyncs do not change the object.
+				return
+			}
+
+			enqueue(newObj, false)
+		},
+		DeleteFunc: func(obj interface{}) {
+			enqueue(obj, true)
+		},
+	}
+}
@@ -0,0 +1,88 @@
+package appvalue
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/micrologger/microloggertest"
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/giantswarm/app-operator/v7/pkg/valueref"
+)
+
+func Test_valueRefResources(t *testing.T) {
+	tests := []struct {
+		name              string
+		refs              string
+		expectedResources []resourceIndex
+	}{
+		{
+			name: "case 0: secret store path below the namespace is indexed",
+			refs: `
+- kind: secretStore
+  provider: vault
+  path: secret/data/org-acme/demo`,
+			expectedResources: []resourceIndex{
+				{ResourceType: secretStoreType, Name: "secret/data/org-acme/demo", Kind: "vault"},
+			},
+		},
+		{
+			name: "case 1: secret store path of another namespace is not indexed",
+			refs: `
+- kind: secretStore
+  provider: vault
+  path: secret/data/org-umbrella/demo`,
+		},
+		{
+			name: "case 2: secret store path escaping the namespace is not indexed",
+			refs: `
+- kind: secretStore
+  provider: vault
+  path: secret/data/org-acme/../org-umbrella/demo`,
+		},
+		{
+			name: "case 3: only secret store paths in scope are indexed",
+			refs: `
+- kind: app
+  name: demo-database
+  namespace: org-acme
+- kind: secretStore
+  provider: vault
+  path: org-acme/demo
+- kind: secretStore
+  provider: file
+  path: org-acme/demo`,
+			expectedResources: []resourceIndex{
+				{ResourceType: appType, Name: "demo-database", Namespace: "org-acme"},
+				{ResourceType: secretStoreType, Name: "org-acme/demo", Kind: "file"},
+			},
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			cr := v1alpha1.App{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "demo",
+					Namespace: "org-acme",
+					Annotations: map[string]string{
+						valueref.Annotation: tc.refs,
+					},
+				},
+			}
+			prefixes := map[string]string{
+				"vault": "secret/data",
+			}
+
+			resources := valueRefResources(context.Background(), microloggertest.New(), prefixes, cr)
+			if !cmp.Equal(resources, tc.expectedResources) {
+				t.Fatalf("want matching resources\n\n%s\n", cmp.Diff(tc.expectedResources, resources))
+			}
+		})
+	}
+}
@@ -17,34 +17,60 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
 
+	"github.com/giantswarm/app-operator/v7/pkg/rollback"
 	"github.com/giantswarm/app-operator/v7/pkg/status"
+	"github.com/giantswarm/app-operator/v7/service/internal/recorder"
+	"github.com/giantswarm/app-operator/v7/service/internal/shard"
 )
 
 var chartResource = schema.GroupVersionResource{Group: "application.giantswarm.io", Version: "v1alpha1", Resource: "charts"}
 
 type ChartStatusWatcherConfig struct {
+	Event     recorder.Interface
 	K8sClient k8sclient.Interface
 	Logger    micrologger.Logger
-
-	ChartNamespace    string
+	// Sharder is optional. When set only the status of app CRs of the shard
+	// of this replica is updated.
+	Sharder shard.Interface
+
+	ChartNamespace string
+	// DriftReconcile triggers a reconciliation of app CRs whose chart CR
+	// was changed in the workload cluster so the change is reverted.
+	DriftReconcile    bool
 	PodNamespace      string
 	UniqueApp         bool
 	WorkloadClusterID string
 }
 
 type ChartStatusWatcher struct {
+	event      recorder.Interface
 	k8sClient  k8sclient.Interface
 	kubeConfig kubeconfig.Interface
 	logger     micrologger.Logger
+	// sharder is nil unless app CRs are sharded across replicas.
+	sharder shard.Interface
+
+	// dynClient returns the client of the cluster chart CRs are watched in.
+	// It is waitForDynClient unless the watcher is started by the Manager.
+	dynClient func(ctx context.Context) (dynamic.Interface, error)
+
+	// drifted holds the hash of the spec of drifted chart CRs by app CR so
+	// each drift is reported once. It is only used by the watch loop.
+	drifted map[string]string
 
 	chartNamespace    string
+	driftReconcile    bool
 	podNamespace      string
 	uniqueApp         bool
 	workloadClusterID string
 }
 
 func NewChartStatusWatcher(config ChartStatusWatcherConfig) (*ChartStatusWatcher, error) {
+	if config.Event == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Event must not be empty", config)
+	}
 	if config.K8sClient == nil {
 		return nil, microerror.Maskf(invalidConfigError, "%T.K8sClient must not be empty", config)
 	}
@@ -74,33 +100,45 @@ func NewChartStatusWatcher(config ChartStatusWatcherConfig) (*ChartStatusWatcher
 	}
 
 	c := &ChartStatusWatcher{
+		event:      config.Event,
 		k8sClient:  config.K8sClient,
 		kubeConfig: kubeConfig,
 		logger:     config.Logger,
+		sharder:    config.Sharder,
+
+		drifted: map[string]string{},
 
 		// We get a kubeconfig for the cluster from the chart-operator app CR.
 		chartNamespace:    config.ChartNamespace,
+		driftReconcile:    config.DriftReconcile,
 		podNamespace:      config.PodNamespace,
 		uniqueApp:         config.UniqueApp,
 		workloadClusterID: config.WorkloadClusterID,
 	}
+	c.dynClient = c.waitForDynClient
 
 	return c, nil
 }
 
+// Boot watches chart CRs until the context is canceled.
 func (c *ChartStatusWatcher) Boot(ctx context.Context) {
-	go c.watchChartStatus(ctx)
+	c.watchChartStatus(ctx)
 }
 
 // watchChartStatus watches all chart CRs in the target cluster for status
 // changes. The matching app CR status is updated otherwise there can be a
-// delay of up to 5 minutes until the next resync period.
+// delay of up to 5 minutes until the next resync period. It returns when the
+// context is canceled.
 func (c *ChartStatusWatcher) watchChartStatus(ctx context.Context) {
 	for {
+		if ctx.Err() != nil {
+			return
+		}
+
 		// We need a dynamic client to connect to the cluster. For remote clusters
 		// we use the kubeconfig secret but there can be a delay while its
 		// created during cluster creation so we wait till it exists.
-		dynClient, err := c.waitForDynClient(ctx)
+		dynClient, err := c.dynClient(ctx)
 		if err != nil {
 			c.logger.Errorf(ctx, err, "failed to get dyn client")
 			continue
@@ -123,6 +161,10 @@ func (c *ChartStatusWatcher) watchChartStatus(ctx context.Context) {
 
 		c.logger.Debugf(ctx, "watching chart CRs in %#q namespace", c.chartNamespace)
 
+		// The watch is stopped when the context is canceled so the result
+		// channel is closed and the loop returns.
+		stopWatch := context.AfterFunc(ctx, res.Stop)
+
 		for r := range res.ResultChan() {
 			if r.Type == watch.Bookmark {
 				// no-op for unsupported events
@@ -169,6 +211,15 @@ func (c *ChartStatusWatcher) watchChartStatus(ctx context.Context) {
 				continue
 			}
 
+			// The status of app CRs of other replicas is updated by them.
+			if c.sharder != nil && !c.sharder.Owns(app) {
+				continue
+			}
+
+			if r.Type != watch.Deleted {
+				c.checkDrift(ctx, *chart, app)
+			}
+
 			desiredStatus := toAppStatus(*chart)
 			currentStatus := key.AppStatus(app)
 
@@ -178,6 +229,12 @@ func (c *ChartStatusWatcher) watchChartStatus(ctx context.Context) {
 				continue
 			}
 
+			// The status is kept until the deferred changes are applied in
+			// the next maintenance window.
+			if currentStatus.Release.Status == status.PendingMaintenanceWindowStatus {
+				continue
+			}
+
 			if !equals(currentStatus, desiredStatus) {
 				if diff := cmp.Diff(currentStatus, desiredStatus); diff != "" {
 					c.logger.LogCtx(ctx, "level", "debug", "message", fmt.Sprintf("status for app '%s/%s' has to be updated", app.Namespace, app.Name), "diff", fmt.Sprintf("(-current +desired):\n%s", diff))
@@ -194,6 +251,7 @@ func (c *ChartStatusWatcher) watchChartStatus(ctx context.Context) {
 			}
 		}
 
+		stopWatch()
 		c.logger.Debugf(ctx, "watch channel had been closed, reopening...")
 	}
 }
@@ -220,7 +278,8 @@ func equals(a, b v1alpha1.AppStatus) bool {
 	return true
 }
 
-// toAppStatus converts the chart CR to an app CR status.
+// toAppStatus converts the chart CR to an app CR status. Rollbacks of the
+// auto-rollback policy are reported with their own status.
 func toAppStatus(chart v1alpha1.Chart) v1alpha1.AppStatus {
 	appStatus := v1alpha1.AppStatus{
 		AppVersion: chart.Status.AppVersion,
@@ -234,5 +293,5 @@ func toAppStatus(chart v1alpha1.Chart) v1alpha1.AppStatus {
 		appStatus.Release.LastDeployed = *chart.Status.Release.LastDeployed
 	}
 
-	return appStatus
+	return rollback.AppStatus(chart, appStatus)
 }
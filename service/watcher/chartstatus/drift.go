@@ -0,0 +1,70 @@
+package chartstatus
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/microerror"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/app-operator/v7/pkg/chartdrift"
+)
+
+// checkDrift reports chart CRs whose spec was changed in the workload cluster
+// via events and metrics. Each drifted spec is reported once. When drift
+// reconciliation is enabled the app CR is annotated so it is reconciled
+// immediately and the drift is reverted.
+func (c *ChartStatusWatcher) checkDrift(ctx context.Context, chart v1alpha1.Chart, app v1alpha1.App) {
+	id := fmt.Sprintf("%s/%s", app.Namespace, app.Name)
+
+	if !chartdrift.IsDrifted(chart) {
+		if _, ok := c.drifted[id]; ok {
+			c.logger.Debugf(ctx, "chart %#q of app '%s/%s' no longer drifted", chart.Name, app.Namespace, app.Name)
+
+			delete(c.drifted, id)
+			driftedGauge.DeleteLabelValues(app.Name, app.Namespace)
+		}
+
+		return
+	}
+
+	hash := chartdrift.Hash(chart.Spec)
+	if c.drifted[id] == hash {
+		return
+	}
+	c.drifted[id] = hash
+
+	c.logger.Debugf(ctx, "chart %#q of app '%s/%s' drifted from the desired state", chart.Name, app.Namespace, app.Name)
+
+	driftCounter.WithLabelValues(app.Name, app.Namespace).Inc()
+	driftedGauge.WithLabelValues(app.Name, app.Namespace).Set(1)
+	c.event.Warn(ctx, &app, "ChartDrifted", "chart %s was changed in the workload cluster and no longer matches the desired state", chart.Name)
+
+	if !c.driftReconcile {
+		return
+	}
+
+	err := c.triggerReconcile(ctx, app, chart.ResourceVersion)
+	if err != nil {
+		c.logger.Errorf(ctx, err, "failed to trigger reconciliation of app '%s/%s'", app.Namespace, app.Name)
+		return
+	}
+
+	c.event.Emit(ctx, &app, "AppUpdated", "drift of chart %s triggered an update", chart.Name)
+}
+
+func (c *ChartStatusWatcher) triggerReconcile(ctx context.Context, app v1alpha1.App, resourceVersion string) error {
+	modifiedApp := app.DeepCopy()
+	if modifiedApp.Annotations == nil {
+		modifiedApp.Annotations = map[string]string{}
+	}
+	modifiedApp.Annotations[chartdrift.LatestDriftAnnotation] = resourceVersion
+
+	err := c.k8sClient.CtrlClient().Patch(ctx, modifiedApp, client.MergeFrom(&app))
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
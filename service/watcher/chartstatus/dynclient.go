@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	cenkaltibackoff "github.com/cenkalti/backoff/v4"
 	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
 	"github.com/giantswarm/app/v8/pkg/key"
 	"github.com/giantswarm/backoff"
@@ -63,6 +64,11 @@ func (c *ChartStatusWatcher) waitForAvailableConnection(ctx context.Context, dyn
 	var err error
 
 	o := func() error {
+		// Watches of workload clusters are stopped by the Manager.
+		if ctx.Err() != nil {
+			return backoff.Permanent(ctx.Err())
+		}
+
 		// List all chart CRs in the target cluster to confirm the connection
 		// is active and the chart CRD is installed.
 		_, err = dynClient.Resource(chartResource).Namespace(c.chartNamespace).List(ctx, metav1.ListOptions{})
@@ -84,7 +90,7 @@ func (c *ChartStatusWatcher) waitForAvailableConnection(ctx context.Context, dyn
 	}
 
 	// maxWait is 0 since cluster creation may fail.
-	b := backoff.NewExponential(0, 30*time.Second)
+	b := newBackOff(ctx)
 	err = backoff.RetryNotify(o, b, n)
 	if err != nil {
 		return microerror.Mask(err)
@@ -137,10 +143,16 @@ func (c *ChartStatusWatcher) waitForKubeConfig(ctx context.Context) (*corev1.Sec
 	}
 
 	// maxWait is 0 since kubeconfig creation may fail.
-	b := backoff.NewExponential(0, 30*time.Second)
+	b := newBackOff(ctx)
 	err = backoff.RetryNotify(o, b, n)
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
 	return &kubeConfigSecret, nil
 }
+
+// newBackOff returns an exponential backoff without max wait which stops
+// waiting for the next retry when the context is canceled.
+func newBackOff(ctx context.Context) backoff.BackOff {
+	return cenkaltibackoff.WithContext(backoff.NewExponential(0, 30*time.Second), ctx)
+}
@@ -0,0 +1,312 @@
+package chartstatus
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/app/v8/pkg/key"
+	"github.com/giantswarm/backoff"
+	"github.com/giantswarm/k8sclient/v7/pkg/k8sclient"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pkglabel "github.com/giantswarm/app-operator/v7/pkg/label"
+	"github.com/giantswarm/app-operator/v7/service/internal/clientcache"
+	"github.com/giantswarm/app-operator/v7/service/internal/recorder"
+	"github.com/giantswarm/app-operator/v7/service/internal/shard"
+)
+
+// discoveryInterval is the interval in which the clusters of app CRs are
+// listed to start and stop chart status watches.
+const discoveryInterval = time.Minute
+
+type ManagerConfig struct {
+	ClientCache *clientcache.Resource
+	Event       recorder.Interface
+	K8sClient   k8sclient.Interface
+	Logger      micrologger.Logger
+	// Sharder is optional. When set only the clusters of app CRs of the
+	// shard of this replica are watched.
+	Sharder shard.Interface
+
+	ChartNamespace string
+	// DriftReconcile triggers a reconciliation of app CRs whose chart CR
+	// was changed in the workload cluster so the change is reverted.
+	DriftReconcile    bool
+	UniqueApp         bool
+	WorkloadClusterID string
+}
+
+// Manager watches the chart CRs of all clusters app CRs are installed in so
+// one app-operator instance can serve many workload clusters. Clusters are
+// discovered from the kubeconfig sources of app CRs. A chart status watch
+// is started per cluster and stopped once no app CR references the
+// cluster anymore. Clients are shared with the app controller via the
+// client cache.
+type Manager struct {
+	clientCache *clientcache.Resource
+	event       recorder.Interface
+	k8sClient   k8sclient.Interface
+	logger      micrologger.Logger
+	sharder     shard.Interface
+
+	// watch runs the chart status watch of the cluster until the context
+	// is canceled.
+	watch func(ctx context.Context, cl cluster)
+
+	clustersMutex sync.Mutex
+	clusters      map[cluster]context.CancelFunc
+	// watches tracks the running watches so Boot waits for them to stop.
+	watches sync.WaitGroup
+
+	chartNamespace string
+	driftReconcile bool
+	selector       labels.Selector
+}
+
+// cluster is identified by the client cache target of app CRs. The zero
+// value is the management cluster for app CRs installed in-cluster.
+type cluster struct {
+	clientcache.Target
+}
+
+func (cl cluster) String() string {
+	if cl == (cluster{}) {
+		return "management cluster"
+	}
+
+	return fmt.Sprintf("cluster of %s", cl.Target)
+}
+
+func NewManager(config ManagerConfig) (*Manager, error) {
+	if config.ClientCache == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.ClientCache must not be empty", config)
+	}
+	if config.Event == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Event must not be empty", config)
+	}
+	if config.K8sClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.K8sClient must not be empty", config)
+	}
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+
+	if config.ChartNamespace == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.ChartNamespace must not be empty", config)
+	}
+
+	var selector labels.Selector
+	{
+		if config.WorkloadClusterID != "" {
+			selector = pkglabel.ClusterSelector(config.WorkloadClusterID)
+		} else {
+			selector = pkglabel.AppVersionSelector(config.UniqueApp)
+		}
+	}
+
+	m := &Manager{
+		clientCache: config.ClientCache,
+		event:       config.Event,
+		k8sClient:   config.K8sClient,
+		logger:      config.Logger,
+		sharder:     config.Sharder,
+
+		clusters: map[cluster]context.CancelFunc{},
+
+		chartNamespace: config.ChartNamespace,
+		driftReconcile: config.DriftReconcile,
+		selector:       selector,
+	}
+	m.watch = m.watchCluster
+
+	return m, nil
+}
+
+// Boot discovers the clusters of app CRs until the context is canceled. It
+// returns once the watches of all clusters stopped. Clusters are discovered
+// again immediately when app CRs are rebalanced across replicas.
+func (m *Manager) Boot(ctx context.Context) {
+	if m.sharder != nil {
+		m.sharder.Subscribe(func() {
+			err := m.sync(ctx)
+			if err != nil {
+				m.logger.Errorf(ctx, err, "failed to discover clusters of app CRs")
+			}
+		})
+	}
+
+	ticker := time.NewTicker(discoveryInterval)
+	defer ticker.Stop()
+
+	for {
+		err := m.sync(ctx)
+		if err != nil {
+			m.logger.Errorf(ctx, err, "failed to discover clusters of app CRs")
+		}
+
+		select {
+		case <-ctx.Done():
+			m.stop()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sync starts chart status watches for new clusters and stops the watches
+// of clusters no app CR references anymore.
+func (m *Manager) sync(ctx context.Context) error {
+	var apps v1alpha1.AppList
+	err := m.k8sClient.CtrlClient().List(ctx, &apps, client.MatchingLabelsSelector{Selector: m.selector})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	var owned []v1alpha1.App
+	for _, app := range apps.Items {
+		if m.sharder == nil || m.sharder.Owns(app) {
+			owned = append(owned, app)
+		}
+	}
+
+	desired := m.desiredClusters(ctx, owned)
+
+	m.clustersMutex.Lock()
+	defer m.clustersMutex.Unlock()
+
+	// No watches are started once Boot is stopping them.
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	for cl, cancel := range m.clusters {
+		if desired[cl] {
+			continue
+		}
+
+		cancel()
+		delete(m.clusters, cl)
+		m.logger.Debugf(ctx, "stopped watching chart CRs of %s", cl)
+	}
+
+	for cl := range desired {
+		if _, ok := m.clusters[cl]; ok {
+			continue
+		}
+
+		clusterCtx, cancel := context.WithCancel(ctx)
+		m.clusters[cl] = cancel
+		m.watches.Go(func() {
+			m.watch(clusterCtx, cl)
+		})
+		m.logger.Debugf(ctx, "started watching chart CRs of %s", cl)
+	}
+
+	watchedClustersGauge.Set(float64(len(m.clusters)))
+
+	return nil
+}
+
+// stop cancels the watches of all clusters and waits for them to return.
+func (m *Manager) stop() {
+	m.clustersMutex.Lock()
+	for cl, cancel := range m.clusters {
+		cancel()
+		delete(m.clusters, cl)
+	}
+	watchedClustersGauge.Set(0)
+	m.clustersMutex.Unlock()
+
+	m.watches.Wait()
+}
+
+// watchCluster runs a chart status watcher for the cluster.
+func (m *Manager) watchCluster(ctx context.Context, cl cluster) {
+	w := &ChartStatusWatcher{
+		event:     m.event,
+		k8sClient: m.k8sClient,
+		logger:    m.logger,
+		sharder:   m.sharder,
+
+		drifted: map[string]string{},
+
+		chartNamespace: m.chartNamespace,
+		driftReconcile: m.driftReconcile,
+	}
+	w.dynClient = func(ctx context.Context) (dynamic.Interface, error) {
+		return m.waitForClusterDynClient(ctx, cl)
+	}
+
+	w.watchChartStatus(ctx)
+}
+
+// waitForClusterDynClient returns the dynamic client of the cluster from the
+// client cache. We use a backoff because the kubeconfig may not exist yet
+// while the cluster is created.
+func (m *Manager) waitForClusterDynClient(ctx context.Context, cl cluster) (dynamic.Interface, error) {
+	if cl == (cluster{}) {
+		return m.k8sClient.DynClient(), nil
+	}
+
+	var dynClient dynamic.Interface
+	o := func() error {
+		if ctx.Err() != nil {
+			return backoff.Permanent(ctx.Err())
+		}
+
+		clients, err := m.clientCache.GetClientsForTarget(ctx, cl.Target)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		dynClient = clients.K8sClient.DynClient()
+
+		return nil
+	}
+
+	n := func(err error, t time.Duration) {
+		m.logger.Debugf(ctx, "failed to get clients of %s: %#v retrying in %s", cl, err, t)
+	}
+
+	// maxWait is 0 since kubeconfig creation may fail.
+	b := newBackOff(ctx)
+	err := backoff.RetryNotify(o, b, n)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return dynClient, nil
+}
+
+// desiredClusters returns the clusters app CRs are installed in. App CRs
+// with an invalid kubeconfig source are skipped.
+func (m *Manager) desiredClusters(ctx context.Context, apps []v1alpha1.App) map[cluster]bool {
+	clusters := map[cluster]bool{}
+
+	for _, app := range apps {
+		if key.InCluster(app) {
+			clusters[cluster{}] = true
+			continue
+		}
+
+		t, err := m.clientCache.Target(app)
+		if err != nil {
+			m.logger.Errorf(ctx, err, "failed to get cluster of app CR %#q in namespace %#q", app.Name, app.Namespace)
+			continue
+		}
+		if t.Name == "" {
+			continue
+		}
+
+		clusters[cluster{Target: t}] = true
+	}
+
+	return clusters
+}
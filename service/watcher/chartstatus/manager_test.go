@@ -0,0 +1,239 @@
+package chartstatus
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/k8sclient/v7/pkg/k8sclienttest"
+	"github.com/giantswarm/k8smetadata/pkg/label"
+	"github.com/giantswarm/micrologger/microloggertest"
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/afero"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/giantswarm/app-operator/v7/pkg/kubeconfigsource"
+	"github.com/giantswarm/app-operator/v7/pkg/project"
+	"github.com/giantswarm/app-operator/v7/service/internal/clientcache"
+	"github.com/giantswarm/app-operator/v7/service/internal/recorder"
+)
+
+func newApp(name, kubeConfigSecret string) *v1alpha1.App {
+	app := &v1alpha1.App{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "org-acme",
+			Labels: map[string]string{
+				label.AppOperatorVersion: project.Version(),
+			},
+		},
+	}
+	if kubeConfigSecret == "" {
+		app.Spec.KubeConfig.InCluster = true
+	} else {
+		app.Spec.KubeConfig.Secret = v1alpha1.AppSpecKubeConfigSecret{
+			Name:      kubeConfigSecret,
+			Namespace: "org-acme",
+		}
+	}
+
+	return app
+}
+
+// newCAPIApp returns an app CR of the Cluster API cluster which uses its
+// kubeconfig secret.
+func newCAPIApp(name, clusterName string) *v1alpha1.App {
+	app := newApp(name, "")
+	app.Spec.KubeConfig.InCluster = false
+	app.Annotations = map[string]string{
+		kubeconfigsource.Annotation: kubeconfigsource.CAPI,
+	}
+	app.Labels[label.Cluster] = clusterName
+
+	return app
+}
+
+func newCluster(kubeConfigSecret string) cluster {
+	return cluster{
+		Target: clientcache.Target{
+			Source:    kubeconfigsource.Secret,
+			Name:      kubeConfigSecret,
+			Namespace: "org-acme",
+		},
+	}
+}
+
+func sortClusters(clusters []cluster) {
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i].Name < clusters[j].Name
+	})
+}
+
+func Test_Manager_sync(t *testing.T) {
+	tests := []struct {
+		name             string
+		apps             []*v1alpha1.App
+		deleted          []string
+		expectedClusters []cluster
+		expectedStopped  []cluster
+	}{
+		{
+			name: "case 0: a watch is started per cluster",
+			apps: []*v1alpha1.App{
+				newApp("cert-manager", ""),
+				newApp("kiam", "abc01-kubeconfig"),
+				newApp("nginx", "abc01-kubeconfig"),
+				newApp("kyverno", "xyz02-kubeconfig"),
+			},
+			expectedClusters: []cluster{
+				{},
+				newCluster("abc01-kubeconfig"),
+				newCluster("xyz02-kubeconfig"),
+			},
+		},
+		{
+			name: "case 1: the watch of a cluster without app CRs is stopped",
+			apps: []*v1alpha1.App{
+				newApp("kiam", "abc01-kubeconfig"),
+				newApp("kyverno", "xyz02-kubeconfig"),
+			},
+			deleted: []string{"kyverno"},
+			expectedClusters: []cluster{
+				newCluster("abc01-kubeconfig"),
+			},
+			expectedStopped: []cluster{
+				newCluster("xyz02-kubeconfig"),
+			},
+		},
+		{
+			name: "case 2: the Cluster API kubeconfig secret is discovered by cluster name",
+			apps: []*v1alpha1.App{
+				newApp("kiam", "abc01-kubeconfig"),
+				newCAPIApp("nginx", "abc01"),
+				newCAPIApp("kyverno", "xyz02"),
+			},
+			expectedClusters: []cluster{
+				newCluster("abc01-kubeconfig"),
+				newCluster("xyz02-kubeconfig"),
+			},
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			s := runtime.NewScheme()
+			err := v1alpha1.AddToScheme(s)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			var objs []runtime.Object
+			for _, app := range tc.apps {
+				objs = append(objs, app)
+			}
+			ctrlClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(objs...).Build()
+
+			clients := k8sclienttest.NewClients(k8sclienttest.ClientsConfig{
+				CtrlClient: ctrlClient,
+			})
+
+			clientCache, err := clientcache.New(clientcache.Config{
+				Fs:        afero.NewMemMapFs(),
+				K8sClient: clients,
+				Logger:    microloggertest.New(),
+
+				HTTPClientTimeout:      time.Second,
+				CircuitBreakerCooldown: time.Minute,
+				FailureThreshold:       5,
+				MaxSize:                100,
+			})
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			c := ManagerConfig{
+				ClientCache: clientCache,
+				Event: recorder.New(recorder.Config{
+					K8sClient: clients,
+				}),
+				K8sClient: clients,
+				Logger:    microloggertest.New(),
+
+				ChartNamespace: "giantswarm",
+			}
+			m, err := NewManager(c)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			var mutex sync.Mutex
+			var stopped []cluster
+			m.watch = func(ctx context.Context, cl cluster) {
+				<-ctx.Done()
+
+				mutex.Lock()
+				stopped = append(stopped, cl)
+				mutex.Unlock()
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			err = m.sync(ctx)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			for _, name := range tc.deleted {
+				for _, app := range tc.apps {
+					if app.Name == name {
+						err = ctrlClient.Delete(ctx, app)
+						if err != nil {
+							t.Fatalf("error == %#v, want nil", err)
+						}
+					}
+				}
+			}
+
+			err = m.sync(ctx)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			var watched []cluster
+			for cl := range m.clusters {
+				watched = append(watched, cl)
+			}
+
+			sortClusters(watched)
+			if diff := cmp.Diff(tc.expectedClusters, watched); diff != "" {
+				t.Fatalf("want matching clusters \n %s", diff)
+			}
+
+			// Stopped watches return once their context is canceled.
+			for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+				mutex.Lock()
+				n := len(stopped)
+				mutex.Unlock()
+				if n >= len(tc.expectedStopped) {
+					break
+				}
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			sortClusters(stopped)
+			if diff := cmp.Diff(tc.expectedStopped, stopped); diff != "" {
+				t.Fatalf("want matching stopped clusters \n %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package chartstatus
+
+import "github.com/prometheus/client_golang/prometheus"
+
+const (
+	PrometheusNamespace = "app_operator"
+	PrometheusSubsystem = "chart"
+)
+
+var (
+	driftCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: PrometheusNamespace,
+			Subsystem: PrometheusSubsystem,
+			Name:      "drift_total",
+			Help:      "Number of times the spec of a chart CR was changed in the workload cluster.",
+		},
+		[]string{"app", "namespace"},
+	)
+	driftedGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: PrometheusNamespace,
+			Subsystem: PrometheusSubsystem,
+			Name:      "drifted",
+			Help:      "Chart CRs whose spec currently differs from the spec applied by app-operator.",
+		},
+		[]string{"app", "namespace"},
+	)
+	watchedClustersGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: PrometheusNamespace,
+			Subsystem: PrometheusSubsystem,
+			Name:      "watched_clusters",
+			Help:      "Clusters chart CRs are watched in by the chart status manager.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(driftCounter)
+	prometheus.MustRegister(driftedGauge)
+	prometheus.MustRegister(watchedClustersGauge)
+}